@@ -9,6 +9,7 @@ import (
 
 	sqladapter "github.com/Blank-Xu/sql-adapter"
 	"github.com/casbin/casbin/v3"
+	"github.com/casbin/casbin/v3/model"
 	_ "github.com/lib/pq"           // PostgreSQL驱动
 	_ "github.com/mattn/go-sqlite3" // SQLite3驱动
 	"go.uber.org/zap"
@@ -64,7 +65,27 @@ func InitAccessControl(rbacModel, driver, dsn string) (err error) {
 		return fmt.Errorf("创建casbin适配器失败: %w", err)
 	}
 
-	if enforcer, err = casbin.NewSyncedEnforcer(rbacModel, adapter); err != nil {
+	m, err := model.NewModelFromFile(rbacModel)
+	if err != nil {
+		return err
+	}
+	policyHasEffect = false
+	for _, token := range m["p"]["p"].Tokens {
+		if token == "p_eft" {
+			policyHasEffect = true
+		}
+	}
+	if policyHasEffect {
+		// 老策略只有 sub, dom, obj, act 四段，补齐 eft=allow，否则 LoadPolicy 报规则长度不符
+		if _, err = db.Exec("UPDATE casbin_rule SET v4 = 'allow' WHERE p_type = 'p' AND (v4 IS NULL OR v4 = '')"); err != nil {
+			return fmt.Errorf("补齐策略 eft 失败: %w", err)
+		}
+	}
+
+	// 老模型没有 OrgInherit 时不支持组织继承授权
+	orgInheritEnabled = strings.Contains(m["m"]["m"].Value, "OrgInherit(")
+
+	if enforcer, err = casbin.NewSyncedEnforcer(m, adapter); err != nil {
 		return err
 	}
 
@@ -72,25 +93,38 @@ func InitAccessControl(rbacModel, driver, dsn string) (err error) {
 	if err = enforcer.LoadPolicy(); err != nil {
 		return err
 	}
+	decisions = newDecisionCache(common.ServConfig.DecisionCacheSize)
+	resetOrgLineage()
 
 	// enforcer.StartAutoLoadPolicy(10 * time.Minute)
 
+	// root 放行已在 decide 中按请求计算；MyMatch 与 RootExempt 只为兼容 matcher 中仍在使用它们的老模型文件
 	enforcer.AddFunction("MyMatch", func(args ...any) (any, error) {
 		rsub, rdom, _, _ := args[0].(string), args[1].(string), args[2].(string), args[3].(string)
 		// fmt.Println("MyMatch: ", rsub, rdom, robj, ract)
 
 		// root账号放行
-		roles, err := enforcer.GetRolesForUser(rsub, rdom)
-		if err != nil {
-			panic(err)
-		}
-		for i := 0; i < len(roles); i++ {
-			if roles[i] == "root" {
-				return true, nil
-			}
-		}
+		return isRootInDomain(rsub, rdom), nil
+	})
+
+	// 租户级域：组织域 tenant-1-org-2 对应 tenant-1-all，其中的角色绑定和策略对租户下所有组织生效
+	enforcer.AddFunction("TenantDom", func(args ...any) (any, error) {
+		return tenantDomainOf(args[0].(string)), nil
+	})
 
-		return false, nil
+	// 组织继承授权：自身或上级组织上"继承到下级组织"的角色绑定在本组织生效
+	enforcer.AddFunction("OrgInherit", func(args ...any) (any, error) {
+		rsub, psub, rdom := args[0].(string), args[1].(string), args[2].(string)
+		return containsString(inheritedRoles(rsub, rdom), psub), nil
+	})
+
+	// deny 策略对 root 不生效，除非配置了 root_deny_override
+	enforcer.AddFunction("RootExempt", func(args ...any) (any, error) {
+		rsub, rdom := args[0].(string), args[1].(string)
+		if common.ServConfig.RootDenyOverride {
+			return false, nil
+		}
+		return isRootInDomain(rsub, rdom), nil
 	})
 
 	// enforcer.EnableLog(true)
@@ -106,11 +140,136 @@ func Enforce(uid, tenantID, orgID uint64, obj, act string) (bool, error) {
 	return enforce(genUserByUID(uid), Domain(tenantID, orgID), obj, act)
 }
 
+// EnforceBatch 批量判定同一用户在同一组织内的多个 (obj, act)，结果与 items 一一对应。
+func EnforceBatch(uid, tenantID, orgID uint64, items []protos.EnforceReq) ([]bool, error) {
+	if orgID == 0 {
+		return nil, common.ErrOrgRequired
+	}
+	if len(items) == 0 {
+		return []bool{}, nil
+	}
+	sub, dom := genUserByUID(uid), Domain(tenantID, orgID)
+	requests := make([][]interface{}, len(items))
+	for i := 0; i < len(items); i++ {
+		requests[i] = []interface{}{sub, dom, items[i].Obj, items[i].Act}
+	}
+	return batchEnforce(requests)
+}
+
+// Explain 解释一次访问决策：域、角色、命中的策略或未命中原因、是否经 root 角色放行。
+func Explain(uid, tenantID, orgID uint64, obj, act string) (*protos.AccessExplain, error) {
+	if orgID == 0 {
+		return nil, common.ErrOrgRequired
+	}
+	sub, dom := genUserByUID(uid), Domain(tenantID, orgID)
+	rst := &protos.AccessExplain{
+		UID:      uid,
+		TenantID: tenantID,
+		OrgID:    orgID,
+		Domain:   dom,
+		Obj:      obj,
+		Act:      act,
+		Roles:    getRoleForUserInDomain(sub, dom),
+	}
+
+	// 与 decide 走同样的分支：root 在进入 casbin 前放行，否则由 casbin 给出决定结果的策略
+	root, rule := rootDecision(sub, dom, obj, act)
+	if root {
+		rst.Allowed, rst.RootBypass = rule == nil, rule == nil
+	} else {
+		allowed, r, err := enforcer.EnforceEx(sub, dom, obj, act)
+		if err != nil {
+			return nil, err
+		}
+		rst.Allowed = allowed
+		// 拒绝且没有 deny 策略命中时 EnforceEx 不返回策略
+		if len(r) >= 4 {
+			rule = r
+		}
+	}
+
+	if rule != nil {
+		rst.MatchedPolicy = &protos.Policy{Role: rule[0], Obj: rule[2], Act: rule[3], OrgID: orgID}
+		if rule[1] != dom {
+			rst.MatchedPolicy.OrgID = 0
+			rst.MatchedPolicy.TenantWide = true
+		}
+		if len(rule) >= 5 {
+			rst.MatchedPolicy.Effect = rule[4]
+		}
+	}
+
+	switch {
+	case rst.MatchedPolicy != nil && rst.MatchedPolicy.Effect == protos.PolicyEffectDeny:
+		rst.Reason = "命中拒绝策略"
+	case rst.MatchedPolicy != nil:
+		rst.Reason = "命中策略"
+	case rst.RootBypass:
+		rst.Reason = "root 角色放行"
+	case rst.Allowed:
+		rst.Reason = "放行"
+	case len(rst.Roles) == 0:
+		rst.Reason = "用户在该域没有任何角色"
+	default:
+		rst.Reason = explainMiss(dom, rst.Roles, obj, act)
+	}
+
+	return rst, nil
+}
+
+// explainMiss 角色存在但无策略命中时，给出最接近的原因。
+func explainMiss(dom string, roles []string, obj, act string) string {
+	policys, _ := getFilteredPolicy(dom)
+	if tdom := tenantDomainOf(dom); tdom != dom {
+		tps, _ := getFilteredPolicy(tdom)
+		policys = append(policys, tps...)
+	}
+	acts := make([]string, 0)
+	for _, p := range policys {
+		if len(p) >= 4 && containsString(roles, p[0]) && p[2] == obj {
+			acts = append(acts, p[3])
+		}
+	}
+	if len(acts) > 0 {
+		return fmt.Sprintf("角色有 %s 的策略，但 act 不匹配: %s", obj, strings.Join(acts, ","))
+	}
+	return fmt.Sprintf("角色 %s 均没有 %s %s 的策略", strings.Join(roles, ","), obj, act)
+}
+
+func containsString(list []string, s string) bool {
+	for i := 0; i < len(list); i++ {
+		if list[i] == s {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleConstraint 授予角色前的校验（如职责分离），held 为用户在该域已持有的角色。
+type RoleConstraint func(tenantID uint64, role string, held []string) error
+
+var roleConstraint RoleConstraint
+
+// SetRoleConstraint 注册授予角色前的校验，由 service 层在初始化时设置。
+func SetRoleConstraint(fn RoleConstraint) {
+	roleConstraint = fn
+}
+
 func AddRoleForUserInDomain(uid, tenantID, orgID uint64, role string) (err error) {
 	if orgID == 0 {
 		return common.ErrOrgRequired
 	}
-	return addRoleForUserInDomain(genUserByUID(uid), role, Domain(tenantID, orgID))
+	sub, dom := genUserByUID(uid), Domain(tenantID, orgID)
+	if roleConstraint != nil {
+		held := getRoleForUserInDomain(sub, dom)
+		if containsString(held, role) {
+			return nil
+		}
+		if err = roleConstraint(tenantID, role, held); err != nil {
+			return err
+		}
+	}
+	return addRoleForUserInDomain(sub, role, dom)
 }
 
 func DeleteRoleForUserInDomain(uid, tenantID, orgID uint64, role string) (err error) {
@@ -120,35 +279,48 @@ func DeleteRoleForUserInDomain(uid, tenantID, orgID uint64, role string) (err er
 	return deleteRoleForUserInDomain(genUserByUID(uid), role, Domain(tenantID, orgID))
 }
 
+// DeleteRolesForUserInDomain 删除用户在组织域的全部角色，连同在该组织上的继承授权。
 func DeleteRolesForUserInDomain(uid, tenantID, orgID uint64) (err error) {
 	if orgID == 0 {
 		return common.ErrOrgRequired
 	}
-	return deleteRolesForUserInDomain(genUserByUID(uid), Domain(tenantID, orgID))
+	sub := genUserByUID(uid)
+	if len(enforcer.GetRolesForUserInDomain(sub, InheritDomain(tenantID, orgID))) > 0 {
+		if err = deleteRolesForUserInDomain(sub, InheritDomain(tenantID, orgID)); err != nil {
+			return
+		}
+		invalidateDomain(TenantDomain(tenantID))
+	}
+	return deleteRolesForUserInDomain(sub, Domain(tenantID, orgID))
 }
 
 func GetRoleForUserInDomain(uid, tenantID, orgID uint64) (roles []string) {
 	if orgID == 0 {
 		return
 	}
-	var userInfo *protos.User
-
-	userInfo, err := dao.UserQueryByID(uid)
+	member, err := dao.TenantMemberExists(tenantID, uid)
 	if err != nil {
-		common.Logger.Sugar().Errorf("GetRoleForUserInDomain UserSelectByID ERR: %v\n", err)
+		common.Logger.Sugar().Errorf("GetRoleForUserInDomain TenantMemberExists ERR: %v\n", err)
 		return
 	}
-	if userInfo == nil || userInfo.TenantID != tenantID {
-		common.Logger.Sugar().Errorf("GetRoleForUserInDomain userInfo ERR: %d %d %v\n", uid, tenantID, userInfo)
+	if !member {
+		common.Logger.Sugar().Errorf("GetRoleForUserInDomain not a tenant member: %d %d\n", uid, tenantID)
 		return
 	}
-	userInfo.Password = ""
 
-	common.Logger.Debug("GetRoleForUserInDomain: ", zap.Uint64("uid", uid), zap.Uint64("tid", tenantID), zap.Uint64("org", orgID), zap.Any("user", userInfo), zap.Error(err))
+	common.Logger.Debug("GetRoleForUserInDomain: ", zap.Uint64("uid", uid), zap.Uint64("tid", tenantID), zap.Uint64("org", orgID))
 
 	return getRoleForUserInDomain(genUserByUID(uid), Domain(tenantID, orgID))
 }
 
+// GetDirectRolesForUserInDomain 用户在组织域中直接持有的角色，不含租户级角色与继承授权。
+func GetDirectRolesForUserInDomain(uid, tenantID, orgID uint64) []string {
+	if orgID == 0 {
+		return nil
+	}
+	return enforcer.GetRolesForUserInDomain(genUserByUID(uid), Domain(tenantID, orgID))
+}
+
 func GetUsersForRoleInDomain(role string, tenantID, orgID uint64) (ids []uint64) {
 	if orgID == 0 {
 		return
@@ -165,10 +337,32 @@ func GetUsersForRoleInDomain(role string, tenantID, orgID uint64) (ids []uint64)
 }
 
 func AddPolicyToRole(tenantID, orgID uint64, role, obj, act string) (err error) {
+	return AddPolicyWithEffect(tenantID, orgID, role, obj, act, protos.PolicyEffectAllow)
+}
+
+// AddPolicyWithEffect 添加 allow / deny 策略；sub 可以是角色，也可以是 UserSubject(uid) 直接作用于用户。
+// 同一 (sub, obj, act) 只保留一种效果，后写覆盖先写。
+func AddPolicyWithEffect(tenantID, orgID uint64, sub, obj, act, eft string) (err error) {
 	if orgID == 0 {
 		return common.ErrOrgRequired
 	}
-	return addPolicy(role, Domain(tenantID, orgID), obj, act)
+	return addPolicyWithEffect(sub, Domain(tenantID, orgID), obj, act, eft)
+}
+
+func addPolicyWithEffect(sub, dom, obj, act, eft string) (err error) {
+	if eft == "" {
+		eft = protos.PolicyEffectAllow
+	}
+	if eft != protos.PolicyEffectAllow && eft != protos.PolicyEffectDeny {
+		return common.ErrParam
+	}
+	if eft == protos.PolicyEffectDeny && !policyHasEffect {
+		return common.ErrPolicyDenyUnsupported
+	}
+	if err = removePolicy(sub, dom, obj, act); err != nil {
+		return
+	}
+	return addPolicy(sub, dom, obj, act, eft)
 }
 
 func RemovePolicyFromRole(tenantID, orgID uint64, role, obj, act string) (err error) {
@@ -178,11 +372,31 @@ func RemovePolicyFromRole(tenantID, orgID uint64, role, obj, act string) (err er
 	return removePolicy(role, Domain(tenantID, orgID), obj, act)
 }
 
+// GetPolicyEffects 组织域中 sub 的 (obj, act) 规则的效果；老模型没有 eft 时为 allow。
+func GetPolicyEffects(tenantID, orgID uint64, sub, obj, act string) ([]string, error) {
+	policys, err := enforcer.GetFilteredPolicy(0, sub, Domain(tenantID, orgID), obj, act)
+	if err != nil {
+		return nil, err
+	}
+	effects := make([]string, 0, len(policys))
+	for _, p := range policys {
+		if len(p) >= 5 && p[4] != "" {
+			effects = append(effects, p[4])
+		} else {
+			effects = append(effects, protos.PolicyEffectAllow)
+		}
+	}
+	return effects, nil
+}
+
 func GetFilteredPolicy(tenantID, orgID uint64, roles []string) (lists [][]string) {
 	if orgID == 0 {
 		return
 	}
 	policys, err := getFilteredPolicy(Domain(tenantID, orgID))
+	if tps, _ := getFilteredPolicy(TenantDomain(tenantID)); len(tps) > 0 {
+		policys = append(policys, tps...)
+	}
 	common.Logger.Debug("getFilteredPolicy:", zap.Any("policys", policys), zap.Any("roles", roles), zap.Error(err))
 	if len(policys) == 0 {
 		return
@@ -205,6 +419,101 @@ func GetFilteredPolicy(tenantID, orgID uint64, roles []string) (lists [][]string
 	return
 }
 
+// GetRolePoliciesInDomain 只查询该域中下发给角色的策略（不合并租户级域，不含直接下发给用户的策略）；orgID 为 0 时查询租户级域。
+func GetRolePoliciesInDomain(tenantID, orgID uint64) ([]protos.Policy, error) {
+	dom := Domain(tenantID, orgID)
+	if orgID == 0 {
+		dom = TenantDomain(tenantID)
+	}
+	policys, err := getFilteredPolicy(dom)
+	if err != nil {
+		return nil, err
+	}
+
+	var rr []protos.Policy
+	for _, p := range policys {
+		if len(p) < 4 || p[0] == "" || parseUserSubject(p[0]) > 0 {
+			continue
+		}
+		one := protos.Policy{Role: p[0], Obj: p[2], Act: p[3]}
+		if len(p) >= 5 {
+			one.Effect = p[4]
+		}
+		rr = append(rr, one)
+	}
+	return rr, nil
+}
+
+// GetTenantPoliciesByObj 查询租户下所有组织域中引用了指定 obj 的策略（含域字段）。
+func GetTenantPoliciesByObj(tenantID uint64, objs []string) (lists [][]string) {
+	for _, obj := range objs {
+		policys, err := getFilteredPolicyByObj(obj)
+		if err != nil {
+			common.Logger.Sugar().Errorf("GetTenantPoliciesByObj ERR: %v %v\n", obj, err)
+			continue
+		}
+		for i := 0; i < len(policys); i++ {
+			if len(policys[i]) >= 4 && inTenantDomain(tenantID, policys[i][1]) {
+				lists = append(lists, policys[i])
+			}
+		}
+	}
+
+	return
+}
+
+// RemoveTenantPoliciesByObj 删除租户下引用了指定 obj 的全部策略，返回已删除的策略（出错时为出错前删掉的部分），可用 RestorePolicies 写回。
+func RemoveTenantPoliciesByObj(tenantID uint64, objs []string) (removed [][]string, err error) {
+	policys := GetTenantPoliciesByObj(tenantID, objs)
+	for i := 0; i < len(policys); i++ {
+		if err = removePolicy(policys[i][0], policys[i][1], policys[i][2], policys[i][3]); err != nil {
+			return
+		}
+		removed = append(removed, policys[i])
+	}
+
+	return
+}
+
+// RestorePolicies 按原样（含域与效果）写回 RemoveTenantPoliciesByObj 删除的策略。
+func RestorePolicies(policys [][]string) (err error) {
+	for i := 0; i < len(policys); i++ {
+		if len(policys[i]) < 4 {
+			continue
+		}
+		eft := protos.PolicyEffectAllow
+		if len(policys[i]) >= 5 && policys[i][4] != "" {
+			eft = policys[i][4]
+		}
+		if err = addPolicy(policys[i][0], policys[i][1], policys[i][2], policys[i][3], eft); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// ParseDomain 从 Domain 生成的域名中解析租户与组织ID；租户级域与老版本的租户域 orgID 为 0。
+func ParseDomain(domain string) (tenantID, orgID uint64, ok bool) {
+	if _, err := fmt.Sscanf(domain, "tenant-%d-org-%d", &tenantID, &orgID); err == nil && domain == Domain(tenantID, orgID) {
+		return tenantID, orgID, true
+	}
+	if _, err := fmt.Sscanf(domain, "tenant-%d", &tenantID); err == nil && (domain == TenantDomain(tenantID) || domain == LegacyTenantDomain(tenantID)) {
+		return tenantID, 0, true
+	}
+	return 0, 0, false
+}
+
+func inTenantDomain(tenantID uint64, domain string) bool {
+	tid, _, ok := ParseDomain(orgDomainOf(domain))
+	return ok && tid == tenantID
+}
+
+// UserSubject 返回用户在 casbin 中的 sub，用于直接对单个用户下发策略（如 deny）。
+func UserSubject(uid uint64) string {
+	return genUserByUID(uid)
+}
+
 func genUserByUID(uid uint64) string {
 	return fmt.Sprintf("uid-%v", uid)
 }
@@ -213,6 +522,12 @@ func Domain(tenantID, orgID uint64) string {
 	return fmt.Sprintf("tenant-%v-org-%v", tenantID, orgID)
 }
 
+// TenantDomain 租户级域，其中的角色绑定与策略对租户下所有组织生效。
+func TenantDomain(tenantID uint64) string {
+	return fmt.Sprintf("tenant-%v%s", tenantID, tenantDomainSuffix)
+}
+
+// LegacyTenantDomain 老版本（没有组织之前）的租户域，不参与鉴权；遗留数据见 LegacyTenantDomainRules。
 func LegacyTenantDomain(tenantID uint64) string {
 	return fmt.Sprintf("tenant-%v", tenantID)
 }
@@ -0,0 +1,156 @@
+package accessctl
+
+import (
+	"expvar"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// DefaultDecisionCacheSize 决策缓存默认条目上限；超过后整体清空重新积累。
+const DefaultDecisionCacheSize = 100000
+
+// decisions 鉴权决策缓存，nil 表示关闭。
+// 按域分桶，策略或角色绑定变化时只清空受影响的域；租户级域变化清空该租户所有组织域。
+var decisions *decisionCache
+
+type decisionCache struct {
+	lck     sync.RWMutex
+	doms    map[string]map[string]bool // dom => sub, obj, act => allowed
+	entries int
+	limit   int
+
+	// 每次失效加一；Enforce 前后不一致时说明期间策略有变化，结果不写缓存
+	gen atomic.Uint64
+
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	invalidations atomic.Uint64
+}
+
+func init() {
+	expvar.Publish("passport_decision_cache", expvar.Func(func() any { return DecisionCacheStats() }))
+}
+
+func newDecisionCache(limit int) *decisionCache {
+	if limit < 0 {
+		return nil
+	}
+	if limit == 0 {
+		limit = DefaultDecisionCacheSize
+	}
+	return &decisionCache{doms: make(map[string]map[string]bool), limit: limit}
+}
+
+func decisionKey(sub, obj, act string) string {
+	// root_deny_override 会改变 deny 对 root 的判定，一并作为 key 的一部分
+	if common.ServConfig.RootDenyOverride {
+		return "1\x00" + sub + "\x00" + obj + "\x00" + act
+	}
+	return "0\x00" + sub + "\x00" + obj + "\x00" + act
+}
+
+func (c *decisionCache) get(dom, key string) (allowed, ok bool) {
+	c.lck.RLock()
+	allowed, ok = c.doms[dom][key]
+	c.lck.RUnlock()
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return
+}
+
+func (c *decisionCache) put(gen uint64, dom, key string, allowed bool) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	if c.gen.Load() != gen {
+		return
+	}
+	if c.entries >= c.limit {
+		c.doms = make(map[string]map[string]bool)
+		c.entries = 0
+	}
+	bucket := c.doms[dom]
+	if bucket == nil {
+		bucket = make(map[string]bool)
+		c.doms[dom] = bucket
+	}
+	if _, ok := bucket[key]; !ok {
+		c.entries++
+	}
+	bucket[key] = allowed
+}
+
+// invalidate 清空域内缓存；租户级域同时清空该租户下所有组织域。
+func (c *decisionCache) invalidate(dom string) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	c.gen.Add(1)
+	c.invalidations.Add(1)
+
+	if tenantDomainOf(dom) != dom {
+		c.entries -= len(c.doms[dom])
+		delete(c.doms, dom)
+		return
+	}
+	prefix := strings.TrimSuffix(dom, tenantDomainSuffix) + "-org-"
+	for d, bucket := range c.doms {
+		if d == dom || strings.HasPrefix(d, prefix) {
+			c.entries -= len(bucket)
+			delete(c.doms, d)
+		}
+	}
+}
+
+func (c *decisionCache) reset() {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	c.gen.Add(1)
+	c.invalidations.Add(1)
+	c.doms = make(map[string]map[string]bool)
+	c.entries = 0
+}
+
+// invalidateDomain 策略变化事件：所有写 casbin 的地方都要调用。
+func invalidateDomain(doms ...string) {
+	if decisions == nil {
+		return
+	}
+	for _, dom := range doms {
+		decisions.invalidate(dom)
+	}
+}
+
+func invalidateAll() {
+	if decisions != nil {
+		decisions.reset()
+	}
+}
+
+// DecisionCacheStats 决策缓存命中情况，也通过 expvar 以 passport_decision_cache 暴露。
+func DecisionCacheStats() protos.DecisionCacheStats {
+	c := decisions
+	if c == nil {
+		return protos.DecisionCacheStats{}
+	}
+	c.lck.RLock()
+	entries := c.entries
+	c.lck.RUnlock()
+
+	rst := protos.DecisionCacheStats{
+		Enabled:       true,
+		Entries:       entries,
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Invalidations: c.invalidations.Load(),
+	}
+	if total := rst.Hits + rst.Misses; total > 0 {
+		rst.HitRate = float64(rst.Hits) / float64(total)
+	}
+	return rst
+}
@@ -0,0 +1,182 @@
+package accessctl
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+func TestDecisionCache(t *testing.T) {
+	initTestEnforcer(t)
+	if decisions == nil {
+		t.Skip("decision cache disabled")
+	}
+
+	const tid, org = 10035, 10001
+	if err := addRoleForUserInDomain(genUserByUID(501), "editor", Domain(tid, org)); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(name string, want bool) {
+		t.Helper()
+		for i := 0; i < 2; i++ {
+			got, err := Enforce(501, tid, org, "/cache", "GET")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Fatalf("%s: Enforce = %v, want %v", name, got, want)
+			}
+		}
+	}
+
+	before := DecisionCacheStats()
+	check("no_policy", false)
+	if after := DecisionCacheStats(); after.Hits != before.Hits+1 || after.Misses != before.Misses+1 {
+		t.Fatalf("stats = %+v, before %+v", after, before)
+	}
+
+	// 每类写操作都要让缓存失效
+	if err := AddPolicyToRole(tid, org, "editor", "/cache", "GET"); err != nil {
+		t.Fatal(err)
+	}
+	check("add_policy", true)
+	if err := AddPolicyWithEffect(tid, org, UserSubject(501), "/cache", "GET", protos.PolicyEffectDeny); err != nil {
+		t.Fatal(err)
+	}
+	check("add_deny", false)
+	if err := RemovePolicyFromRole(tid, org, UserSubject(501), "/cache", "GET"); err != nil {
+		t.Fatal(err)
+	}
+	check("remove_deny", true)
+	if err := DeleteRoleForUserInDomain(501, tid, org, "editor"); err != nil {
+		t.Fatal(err)
+	}
+	check("delete_role", false)
+	if err := AddRoleForUserInTenant(501, tid, "editor"); err != nil {
+		t.Fatal(err)
+	}
+	check("tenant_role", true)
+	if err := AddTenantPolicyWithEffect(tid, "editor", "/cache", "GET", protos.PolicyEffectDeny); err != nil {
+		t.Fatal(err)
+	}
+	check("tenant_deny", false)
+
+	// 其他域的变化不影响本域缓存
+	check("warm", false)
+	hits := DecisionCacheStats().Hits
+	if err := AddPolicyToRole(tid, 10002, "editor", "/other", "GET"); err != nil {
+		t.Fatal(err)
+	}
+	check("other_org_change", false)
+	if got := DecisionCacheStats().Hits; got != hits+2 {
+		t.Fatalf("other org change should keep cache: hits %d => %d", hits, got)
+	}
+
+	got, err := EnforceBatch(501, tid, org, []protos.EnforceReq{{Obj: "/cache", Act: "GET"}, {Obj: "/batch", Act: "GET"}})
+	if err != nil || len(got) != 2 || got[0] || got[1] {
+		t.Fatalf("EnforceBatch = %v, %v", got, err)
+	}
+}
+
+// setupBenchPolicies 在独立租户下写入 10k 条策略：100 个组织 × 100 条，用户 601 持有每个组织的 editor，用户 602 持有每个组织的 root。
+func setupBenchPolicies(b *testing.B) (tid uint64, orgs int) {
+	b.Helper()
+	if enforcer == nil {
+		if err := InitAccessControl("../rbac_with_domains_model.conf", "sqlite3", "file::memory:?cache=shared"); err != nil {
+			b.Fatal(err)
+		}
+	}
+	tid, orgs = 10090, 100
+	if ok, _ := HasPolicy("editor", Domain(tid, 1), "/bench/0", "GET"); ok {
+		return
+	}
+
+	rules := make([][]string, 0, 10000)
+	for org := 1; org <= orgs; org++ {
+		for i := 0; i < 100; i++ {
+			rules = append(rules, []string{"editor", Domain(tid, uint64(org)), fmt.Sprintf("/bench/%d", i), "GET", protos.PolicyEffectAllow})
+		}
+		if _, err := enforcer.AddRoleForUserInDomain(genUserByUID(601), "editor", Domain(tid, uint64(org))); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := enforcer.AddRoleForUserInDomain(genUserByUID(602), "root", Domain(tid, uint64(org))); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if _, err := enforcer.AddPolicies(rules); err != nil {
+		b.Fatal(err)
+	}
+	invalidateAll()
+	return
+}
+
+func benchmarkEnforce(b *testing.B, cached bool) {
+	tid, orgs := setupBenchPolicies(b)
+	saved := decisions
+	if cached {
+		decisions = newDecisionCache(0)
+	} else {
+		decisions = nil
+	}
+	defer func() { decisions = saved }()
+
+	// 约 1000 个并发请求
+	b.SetParallelism((1000 + runtime.GOMAXPROCS(0) - 1) / runtime.GOMAXPROCS(0))
+	// 请求集中在 10 个组织 × 20 个接口（含未授权的），贴近实际的热点分布；缓存先预热，测稳态
+	if cached {
+		for org := 1; org <= orgs/10; org++ {
+			for i := 0; i < 20; i++ {
+				if _, err := Enforce(601, tid, uint64(org), fmt.Sprintf("/bench/%d", i*6), "GET"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+	var seq atomic.Uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			n := seq.Add(1)
+			org := n%uint64(orgs/10) + 1
+			if _, err := Enforce(601, tid, org, fmt.Sprintf("/bench/%d", n%20*6), "GET"); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkEnforce10kPolicies(b *testing.B) {
+	b.Run("nocache", func(b *testing.B) { benchmarkEnforce(b, false) })
+	b.Run("cache", func(b *testing.B) { benchmarkEnforce(b, true) })
+}
+
+// BenchmarkEnforceUncached 不经决策缓存的单次判定耗时，root 放行不应随策略数增长
+func BenchmarkEnforceUncached(b *testing.B) {
+	tid, orgs := setupBenchPolicies(b)
+	saved := decisions
+	decisions = nil
+	defer func() { decisions = saved }()
+
+	for _, bc := range []struct {
+		name string
+		uid  uint64
+		obj  string
+	}{
+		{"allow", 601, "/bench/42"},
+		{"miss", 601, "/bench/none"},
+		{"root", 602, "/bench/none"},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := Enforce(bc.uid, tid, uint64(i%orgs+1), bc.obj, "GET"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
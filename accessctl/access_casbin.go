@@ -1,29 +1,163 @@
 package accessctl
 
 import (
+	"strings"
+
 	casbin "github.com/casbin/casbin/v3"
+
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/protos"
 )
 
 var (
 	enforcer *casbin.SyncedEnforcer
+
+	// policyHasEffect 模型的 p 定义是否带 eft 字段（支持 deny 策略）
+	policyHasEffect bool
 )
 
-func enforce(sub, domain, obj, act string) (bool, error) {
+// decide 不经缓存的一次判定。root 放行每个请求只算一次，不放进 matcher 里逐条策略计算。
+func decide(sub, domain, obj, act string) (bool, error) {
+	if root, deny := rootDecision(sub, domain, obj, act); root {
+		return deny == nil, nil
+	}
 	return enforcer.Enforce(sub, domain, obj, act)
 }
 
-func addPolicy(sub, domain, obj, act string) (err error) {
-	if _, err = enforcer.AddPolicy(sub, domain, obj, act); err != nil {
-		return
+// rootDecision 用户在域内是否持有 root；持有时再找出对 root 生效的 deny 策略（仅 root_deny_override 开启时）。
+func rootDecision(sub, domain, obj, act string) (root bool, deny []string) {
+	roles := getRoleForUserInDomain(sub, domain)
+	if !containsString(roles, "root") {
+		return false, nil
+	}
+	return true, rootDeny(sub, domain, roles, obj, act)
+}
+
+// rootDeny 持有 root 的用户在域内对 (obj, act) 生效的 deny 策略；roles 是用户在域内的角色
+func rootDeny(sub, domain string, roles []string, obj, act string) []string {
+	if !policyHasEffect || !common.ServConfig.RootDenyOverride {
+		return nil
+	}
+	policies, _ := enforcer.GetFilteredPolicy(2, obj, act)
+	tdom := tenantDomainOf(domain)
+	for _, p := range policies {
+		if len(p) >= 5 && p[4] == protos.PolicyEffectDeny && (p[1] == domain || p[1] == tdom) && (p[0] == sub || containsString(roles, p[0])) {
+			return p
+		}
+	}
+	return nil
+}
+
+func enforce(sub, domain, obj, act string) (bool, error) {
+	c := decisions
+	if c == nil {
+		return decide(sub, domain, obj, act)
+	}
+	key := decisionKey(sub, obj, act)
+	if allowed, ok := c.get(domain, key); ok {
+		return allowed, nil
+	}
+	gen := c.gen.Load()
+	allowed, err := decide(sub, domain, obj, act)
+	if err == nil {
+		c.put(gen, domain, key, allowed)
+	}
+	return allowed, err
+}
+
+// batchEnforce 一次加锁完成多条判定，命中缓存的不再进入 casbin
+func batchEnforce(requests [][]interface{}) ([]bool, error) {
+	c := decisions
+	if c == nil {
+		return batchDecide(requests)
+	}
+
+	rst := make([]bool, len(requests))
+	keys := make([]string, len(requests))
+	miss := make([]int, 0)
+	for i, req := range requests {
+		keys[i] = decisionKey(req[0].(string), req[2].(string), req[3].(string))
+		allowed, ok := c.get(req[1].(string), keys[i])
+		if !ok {
+			miss = append(miss, i)
+			continue
+		}
+		rst[i] = allowed
+	}
+	if len(miss) == 0 {
+		return rst, nil
+	}
+
+	gen := c.gen.Load()
+	missReqs := make([][]interface{}, len(miss))
+	for j, i := range miss {
+		missReqs[j] = requests[i]
+	}
+	got, err := batchDecide(missReqs)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range miss {
+		rst[i] = got[j]
+		c.put(gen, requests[i][1].(string), keys[i], got[j])
+	}
+	return rst, nil
+}
+
+// batchDecide 与 decide 相同，root 的请求不进 casbin，其余一次 BatchEnforce。
+// 一批请求的 sub、dom 通常相同，用户角色按 (sub, dom) 只取一次，循环内只做 root 的 deny 检查。
+func batchDecide(requests [][]interface{}) ([]bool, error) {
+	rst := make([]bool, len(requests))
+	rest := make([]int, 0, len(requests))
+	rolesOf := make(map[[2]string][]string, 1)
+	for i, req := range requests {
+		sub, dom := req[0].(string), req[1].(string)
+		roles, ok := rolesOf[[2]string{sub, dom}]
+		if !ok {
+			roles = getRoleForUserInDomain(sub, dom)
+			rolesOf[[2]string{sub, dom}] = roles
+		}
+		if containsString(roles, "root") {
+			rst[i] = rootDeny(sub, dom, roles, req[2].(string), req[3].(string)) == nil
+			continue
+		}
+		rest = append(rest, i)
+	}
+	if len(rest) == 0 {
+		return rst, nil
 	}
 
+	restReqs := make([][]interface{}, len(rest))
+	for j, i := range rest {
+		restReqs[j] = requests[i]
+	}
+	got, err := enforcer.BatchEnforce(restReqs)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range rest {
+		rst[i] = got[j]
+	}
+	return rst, nil
+}
+
+func addPolicy(sub, domain, obj, act, eft string) (err error) {
+	if policyHasEffect {
+		_, err = enforcer.AddPolicy(sub, domain, obj, act, eft)
+	} else {
+		_, err = enforcer.AddPolicy(sub, domain, obj, act)
+	}
+	invalidateDomain(domain)
+
 	return
 }
 
+// removePolicy 按 sub, dom, obj, act 删除，不区分 eft
 func removePolicy(sub, domain, obj, act string) (err error) {
-	if _, err = enforcer.RemovePolicy(sub, domain, obj, act); err != nil {
+	if _, err = enforcer.RemoveFilteredPolicy(0, sub, domain, obj, act); err != nil {
 		return
 	}
+	invalidateDomain(domain)
 
 	return
 }
@@ -32,12 +166,40 @@ func getFilteredPolicy(domain string) ([][]string, error) {
 	return enforcer.GetFilteredPolicy(1, domain)
 }
 
+func getFilteredPolicyByObj(obj string) ([][]string, error) {
+	return enforcer.GetFilteredPolicy(2, obj)
+}
+
 func HasPolicy(sub, domain, obj, act string) (bool, error) {
-	return enforcer.HasPolicy(sub, domain, obj, act)
+	policys, err := enforcer.GetFilteredPolicy(0, sub, domain, obj, act)
+	return len(policys) > 0, err
+}
+
+// tenantDomainSuffix 租户级域的后缀，与老版本的租户域 tenant-1 区分开
+const tenantDomainSuffix = "-all"
+
+// tenantDomainOf 组织域对应的租户级域：tenant-1-org-2 => tenant-1-all；其他域原样返回。
+func tenantDomainOf(domain string) string {
+	if i := strings.Index(domain, "-org-"); i > 0 {
+		return domain[:i] + tenantDomainSuffix
+	}
+	return domain
+}
+
+func isRootInDomain(sub, domain string) bool {
+	roles := getRoleForUserInDomain(sub, domain)
+	for i := 0; i < len(roles); i++ {
+		if roles[i] == "root" {
+			return true
+		}
+	}
+
+	return false
 }
 
 func addRoleForUserInDomain(user, role, domain string) error {
 	_, err := enforcer.AddRoleForUserInDomain(user, role, domain)
+	invalidateDomain(domain)
 	if err != nil {
 		return err
 	}
@@ -46,7 +208,9 @@ func addRoleForUserInDomain(user, role, domain string) error {
 }
 
 func deleteRolesForUserInDomain(user, domain string) (err error) {
-	if _, err = enforcer.DeleteRolesForUserInDomain(user, domain); err != nil {
+	_, err = enforcer.DeleteRolesForUserInDomain(user, domain)
+	invalidateDomain(domain)
+	if err != nil {
 		return
 	}
 
@@ -54,19 +218,45 @@ func deleteRolesForUserInDomain(user, domain string) (err error) {
 }
 
 func deleteRoleForUserInDomain(user, role, domain string) (err error) {
-	if _, err = enforcer.DeleteRoleForUserInDomain(user, role, domain); err != nil {
+	_, err = enforcer.DeleteRoleForUserInDomain(user, role, domain)
+	invalidateDomain(domain)
+	if err != nil {
 		return
 	}
 
 	return
 }
 
+// getRoleForUserInDomain 用户在组织域生效的角色：组织域角色 + 租户级域角色 + 自身及上级组织的继承授权。
 func getRoleForUserInDomain(user, domain string) []string {
-	return enforcer.GetRolesForUserInDomain(user, domain)
+	roles := enforcer.GetRolesForUserInDomain(user, domain)
+	if tdom := tenantDomainOf(domain); tdom != domain {
+		roles = mergeStrings(roles, enforcer.GetRolesForUserInDomain(user, tdom))
+		roles = mergeStrings(roles, inheritedRoles(user, domain))
+	}
+	return roles
 }
 
 func getUsersForRoleInDomain(role, domain string) []string {
-	return enforcer.GetUsersForRoleInDomain(role, domain)
+	users := enforcer.GetUsersForRoleInDomain(role, domain)
+	if tdom := tenantDomainOf(domain); tdom != domain {
+		users = mergeStrings(users, enforcer.GetUsersForRoleInDomain(role, tdom))
+		if tid, org, ok := ParseDomain(domain); ok {
+			for _, id := range orgLineage(org) {
+				users = mergeStrings(users, enforcer.GetUsersForRoleInDomain(role, InheritDomain(tid, id)))
+			}
+		}
+	}
+	return users
+}
+
+func mergeStrings(a, b []string) []string {
+	for i := 0; i < len(b); i++ {
+		if !containsString(a, b[i]) {
+			a = append(a, b[i])
+		}
+	}
+	return a
 }
 
 func CopyPolicies(fromDomain, toDomain string) error {
@@ -77,11 +267,13 @@ func CopyPolicies(fromDomain, toDomain string) error {
 	if err != nil {
 		return err
 	}
+	defer invalidateDomain(toDomain)
 	for _, p := range policies {
 		if len(p) < 4 || p[0] == "" || p[2] == "" || p[3] == "" {
 			continue
 		}
-		if _, err = enforcer.AddPolicy(p[0], toDomain, p[2], p[3]); err != nil {
+		rule := append([]string{p[0], toDomain}, p[2:]...)
+		if _, err = enforcer.AddPolicy(rule); err != nil {
 			return err
 		}
 	}
@@ -96,6 +288,7 @@ func CopyGrouping(fromDomain, toDomain string) error {
 	if err != nil {
 		return err
 	}
+	defer invalidateDomain(toDomain)
 	for _, g := range gs {
 		if len(g) < 2 || g[0] == "" || g[1] == "" {
 			continue
@@ -111,6 +304,7 @@ func RemoveDomain(domain string) error {
 	if enforcer == nil || domain == "" {
 		return nil
 	}
+	defer invalidateDomain(domain)
 	if _, err := enforcer.RemoveFilteredPolicy(1, domain); err != nil {
 		return err
 	}
@@ -0,0 +1,150 @@
+package accessctl
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/liuhengloveyou/passport/v4/common"
+)
+
+// 组织继承授权：在组织 A 上勾选"继承到下级组织"的角色绑定记在 InheritDomain(tid, A)，
+// 对 A 及其所有下级组织生效，包括之后新建或移入的组织；matcher 通过 OrgInherit 判定。
+// 角色在下级组织中使用该组织域与租户级域的策略。
+
+const inheritDomainSuffix = "-inherit"
+
+var (
+	// orgInheritEnabled 模型 matcher 是否引用了 OrgInherit
+	orgInheritEnabled bool
+
+	orgAncestors OrgAncestors
+	// orgID => 自身及所有上级组织；组织树变化时整体清空
+	orgLineageCache sync.Map
+)
+
+// OrgAncestors 返回组织自身及所有上级组织的 ID，近的在前。
+type OrgAncestors func(orgID uint64) ([]uint64, error)
+
+// SetOrgAncestors 注册组织上级的查询，由 service 层在初始化时设置。
+func SetOrgAncestors(fn OrgAncestors) {
+	orgAncestors = fn
+}
+
+// InheritDomain 组织上继承授权所在的域。
+func InheritDomain(tenantID, orgID uint64) string {
+	return Domain(tenantID, orgID) + inheritDomainSuffix
+}
+
+// OrgTreeChanged 组织新建、移动或删除后调用：清空上级组织缓存与该租户的决策缓存。
+func OrgTreeChanged(tenantID uint64) {
+	resetOrgLineage()
+	invalidateDomain(TenantDomain(tenantID))
+}
+
+func resetOrgLineage() {
+	orgLineageCache.Clear()
+}
+
+// orgLineage 组织自身及所有上级组织，近的在前
+func orgLineage(orgID uint64) []uint64 {
+	if v, ok := orgLineageCache.Load(orgID); ok {
+		return v.([]uint64)
+	}
+	if orgAncestors == nil {
+		return []uint64{orgID}
+	}
+	ids, err := orgAncestors(orgID)
+	if err != nil {
+		common.Logger.Sugar().Errorf("orgLineage ERR: %v %v\n", orgID, err)
+		return []uint64{orgID}
+	}
+	if len(ids) == 0 {
+		ids = []uint64{orgID}
+	}
+	orgLineageCache.Store(orgID, ids)
+	return ids
+}
+
+// inheritedRoles 用户在组织域中经自身或上级组织的继承授权得到的角色
+func inheritedRoles(sub, domain string) (roles []string) {
+	tid, org, ok := ParseDomain(domain)
+	if !ok || org == 0 {
+		return
+	}
+	for _, id := range orgLineage(org) {
+		roles = mergeStrings(roles, enforcer.GetRolesForUserInDomain(sub, InheritDomain(tid, id)))
+	}
+	return
+}
+
+// AddInheritedRoleForUser 在组织上授予角色，并对其所有下级组织生效。
+func AddInheritedRoleForUser(uid, tenantID, orgID uint64, role string) (err error) {
+	if orgID == 0 {
+		return common.ErrOrgRequired
+	}
+	if !orgInheritEnabled {
+		return common.ErrOrgInheritUnsupported
+	}
+	sub, dom := genUserByUID(uid), InheritDomain(tenantID, orgID)
+	if roleConstraint != nil {
+		if containsString(enforcer.GetRolesForUserInDomain(sub, dom), role) {
+			return nil
+		}
+		// 继承授权会在多个组织同时生效，职责分离按全租户校验
+		if err = roleConstraint(tenantID, role, getRoleForUserInTenantAll(sub, tenantID)); err != nil {
+			return err
+		}
+	}
+	_, err = enforcer.AddRoleForUserInDomain(sub, role, dom)
+	// 影响所有下级组织域
+	invalidateDomain(TenantDomain(tenantID))
+	return err
+}
+
+func DeleteInheritedRoleForUser(uid, tenantID, orgID uint64, role string) (err error) {
+	if orgID == 0 {
+		return common.ErrOrgRequired
+	}
+	_, err = enforcer.DeleteRoleForUserInDomain(genUserByUID(uid), role, InheritDomain(tenantID, orgID))
+	invalidateDomain(TenantDomain(tenantID))
+	return
+}
+
+// GetInheritedRolesForUser 用户在组织内经继承授权得到的角色 => 授权所在的组织（有多处时取最近的）。
+func GetInheritedRolesForUser(uid, tenantID, orgID uint64) map[string]uint64 {
+	rst := make(map[string]uint64)
+	if orgID == 0 {
+		return rst
+	}
+	sub := genUserByUID(uid)
+	for _, id := range orgLineage(orgID) {
+		for _, role := range enforcer.GetRolesForUserInDomain(sub, InheritDomain(tenantID, id)) {
+			if _, ok := rst[role]; !ok {
+				rst[role] = id
+			}
+		}
+	}
+	return rst
+}
+
+// GetUsersWithInheritedRoles 在组织自身或任一上级组织上持有继承授权的用户。
+func GetUsersWithInheritedRoles(tenantID, orgID uint64) (ids []uint64) {
+	for _, id := range orgLineage(orgID) {
+		gs, err := enforcer.GetFilteredGroupingPolicy(2, InheritDomain(tenantID, id))
+		if err != nil {
+			common.Logger.Sugar().Errorf("GetUsersWithInheritedRoles ERR: %v %v\n", id, err)
+			continue
+		}
+		for _, g := range gs {
+			if uid := parseUserSubject(g[0]); uid > 0 && !containsUint64(ids, uid) {
+				ids = append(ids, uid)
+			}
+		}
+	}
+	return
+}
+
+// orgDomainOf 继承授权域对应的组织域；其他域原样返回
+func orgDomainOf(domain string) string {
+	return strings.TrimSuffix(domain, inheritDomainSuffix)
+}
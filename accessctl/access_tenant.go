@@ -0,0 +1,327 @@
+package accessctl
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/liuhengloveyou/passport/v4/common"
+)
+
+// 租户级域 TenantDomain(tenantID) 中的角色绑定与策略通过 matcher 对租户下所有组织生效，
+// 包括之后新建的组织，不再需要逐个组织复制。老版本租户域 LegacyTenantDomain(tenantID) 中的遗留数据
+// 不参与鉴权，需经 MigrateLegacyTenantDomain 显式迁移。
+
+// AddRoleForUserInTenant 在租户级域授予角色，对租户下所有组织生效。
+func AddRoleForUserInTenant(uid, tenantID uint64, role string) (err error) {
+	if tenantID == 0 {
+		return common.ErrParam
+	}
+	sub, dom := genUserByUID(uid), TenantDomain(tenantID)
+	if roleConstraint != nil {
+		if containsString(enforcer.GetRolesForUserInDomain(sub, dom), role) {
+			return nil
+		}
+		// 租户级角色与任一组织内的角色都会同时生效，职责分离需按全租户校验
+		if err = roleConstraint(tenantID, role, getRoleForUserInTenantAll(sub, tenantID)); err != nil {
+			return err
+		}
+	}
+	return addRoleForUserInDomain(sub, role, dom)
+}
+
+func DeleteRoleForUserInTenant(uid, tenantID uint64, role string) (err error) {
+	if tenantID == 0 {
+		return common.ErrParam
+	}
+	return deleteRoleForUserInDomain(genUserByUID(uid), role, TenantDomain(tenantID))
+}
+
+// GetRoleForUserInTenant 只返回租户级域中的角色，不含组织域角色。
+func GetRoleForUserInTenant(uid, tenantID uint64) []string {
+	if tenantID == 0 {
+		return nil
+	}
+	return enforcer.GetRolesForUserInDomain(genUserByUID(uid), TenantDomain(tenantID))
+}
+
+// GetUsersInTenantDomain 在租户级域持有任意角色的用户。
+func GetUsersInTenantDomain(tenantID uint64) (ids []uint64) {
+	gs, err := enforcer.GetFilteredGroupingPolicy(2, TenantDomain(tenantID))
+	if err != nil {
+		common.Logger.Sugar().Errorf("GetUsersInTenantDomain ERR: %v %v\n", tenantID, err)
+		return
+	}
+	for _, g := range gs {
+		if uid := parseUserSubject(g[0]); uid > 0 && !containsUint64(ids, uid) {
+			ids = append(ids, uid)
+		}
+	}
+	return
+}
+
+// AddTenantPolicyWithEffect 在租户级域添加策略，对租户下所有组织生效。
+func AddTenantPolicyWithEffect(tenantID uint64, sub, obj, act, eft string) error {
+	if tenantID == 0 {
+		return common.ErrParam
+	}
+	return addPolicyWithEffect(sub, TenantDomain(tenantID), obj, act, eft)
+}
+
+func RemoveTenantPolicy(tenantID uint64, sub, obj, act string) error {
+	if tenantID == 0 {
+		return common.ErrParam
+	}
+	return removePolicy(sub, TenantDomain(tenantID), obj, act)
+}
+
+// CollapseTenantDomain 迁移：在租户所有组织域中都存在的角色绑定与策略合并为一条租户级记录，
+// 并删除各组织中与租户级记录重复的行。skipGrouping 返回 true 的绑定（如限时授权）保持原样。
+// 组织少于两个时无从判断是否为"全租户"授权，不做合并。
+func CollapseTenantDomain(tenantID uint64, orgIDs []uint64, skipGrouping func(sub, role string) bool) (groupings, policies int, err error) {
+	if tenantID == 0 || len(orgIDs) < 2 {
+		return
+	}
+	tdom := TenantDomain(tenantID)
+	doms := make([]string, len(orgIDs))
+	for i := range orgIDs {
+		doms[i] = Domain(tenantID, orgIDs[i])
+	}
+	// 租户级域失效会连带清空所有组织域
+	defer invalidateDomain(tdom)
+
+	// 角色绑定：key 为 sub, role
+	gcount, grows := make(map[string]int), make(map[string][]string)
+	for _, dom := range doms {
+		gs, e := enforcer.GetFilteredGroupingPolicy(2, dom)
+		if e != nil {
+			return groupings, policies, e
+		}
+		for _, g := range gs {
+			key := g[0] + "\x00" + g[1]
+			gcount[key]++
+			grows[key] = []string{g[0], g[1]}
+		}
+	}
+	for key, g := range grows {
+		if skipGrouping != nil && skipGrouping(g[0], g[1]) {
+			continue
+		}
+		has, e := enforcer.HasGroupingPolicy(g[0], g[1], tdom)
+		if e != nil {
+			return groupings, policies, e
+		}
+		if !has && gcount[key] < len(doms) {
+			continue
+		}
+		if !has {
+			if _, err = enforcer.AddGroupingPolicy(g[0], g[1], tdom); err != nil {
+				return
+			}
+		}
+		for _, dom := range doms {
+			if _, err = enforcer.RemoveGroupingPolicy(g[0], g[1], dom); err != nil {
+				return
+			}
+		}
+		groupings++
+	}
+
+	// 策略：key 为去掉 dom 之后的整条规则（含 eft），效果不同的不算重复
+	pcount, prows := make(map[string]int), make(map[string][]string)
+	for _, dom := range doms {
+		ps, e := getFilteredPolicy(dom)
+		if e != nil {
+			return groupings, policies, e
+		}
+		for _, p := range ps {
+			rest := append([]string{p[0]}, p[2:]...)
+			key := strings.Join(rest, "\x00")
+			pcount[key]++
+			prows[key] = rest
+		}
+	}
+	for key, rest := range prows {
+		trule := append([]string{rest[0], tdom}, rest[1:]...)
+		existing, e := enforcer.GetFilteredPolicy(0, rest[0], tdom, rest[1], rest[2])
+		if e != nil {
+			return groupings, policies, e
+		}
+		has := len(existing) > 0 && strings.Join(existing[0], "\x00") == strings.Join(trule, "\x00")
+		if len(existing) > 0 && !has {
+			// 租户级已有同 (sub, obj, act) 但效果不同的策略，保留组织级配置
+			continue
+		}
+		if !has && pcount[key] < len(doms) {
+			continue
+		}
+		if !has {
+			if _, err = enforcer.AddPolicy(trule); err != nil {
+				return
+			}
+		}
+		for _, dom := range doms {
+			if _, err = enforcer.RemovePolicy(append([]string{rest[0], dom}, rest[1:]...)); err != nil {
+				return
+			}
+		}
+		policies++
+	}
+
+	return
+}
+
+// TenantDomains 租户在 casbin 中用到的所有域：租户级域、老版本租户域、组织域及其继承域（含没有组织记录的组织）。
+func TenantDomains(tenantID uint64) ([]string, error) {
+	if tenantID == 0 {
+		return nil, nil
+	}
+	ps, err := enforcer.GetPolicy()
+	if err != nil {
+		return nil, err
+	}
+	gs, err := enforcer.GetGroupingPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	ldom := LegacyTenantDomain(tenantID)
+	seen := make(map[string]bool)
+	doms := make([]string, 0)
+	add := func(dom string) {
+		if seen[dom] || (dom != ldom && !strings.HasPrefix(dom, ldom+"-")) {
+			return
+		}
+		seen[dom] = true
+		doms = append(doms, dom)
+	}
+	for _, p := range ps {
+		if len(p) >= 2 {
+			add(p[1])
+		}
+	}
+	for _, g := range gs {
+		if len(g) >= 3 {
+			add(g[2])
+		}
+	}
+	return doms, nil
+}
+
+// LegacyTenantDomainRules 老版本租户域中遗留的角色绑定与策略。
+func LegacyTenantDomainRules(tenantID uint64) (groupings, policies [][]string, err error) {
+	if tenantID == 0 {
+		return
+	}
+	ldom := LegacyTenantDomain(tenantID)
+	if groupings, err = enforcer.GetFilteredGroupingPolicy(2, ldom); err != nil {
+		return
+	}
+	policies, err = getFilteredPolicy(ldom)
+	return
+}
+
+// MigrateLegacyTenantDomain 把老版本租户域中的角色绑定与策略移到租户级域，从此对租户下所有组织生效。
+// 租户级域已有同 (sub, obj, act) 但效果不同的策略时保留租户级的，遗留行不动。返回迁移条数。
+func MigrateLegacyTenantDomain(tenantID uint64) (groupings, policies int, err error) {
+	gs, ps, err := LegacyTenantDomainRules(tenantID)
+	if err != nil || len(gs)+len(ps) == 0 {
+		return
+	}
+	tdom, ldom := TenantDomain(tenantID), LegacyTenantDomain(tenantID)
+	defer invalidateDomain(tdom)
+
+	for _, g := range gs {
+		has, e := enforcer.HasGroupingPolicy(g[0], g[1], tdom)
+		if e != nil {
+			return groupings, policies, e
+		}
+		if !has {
+			if _, err = enforcer.AddGroupingPolicy(g[0], g[1], tdom); err != nil {
+				return
+			}
+		}
+		if _, err = enforcer.RemoveGroupingPolicy(g[0], g[1], ldom); err != nil {
+			return
+		}
+		groupings++
+	}
+
+	for _, p := range ps {
+		if len(p) < 4 {
+			continue
+		}
+		trule := append([]string{p[0], tdom}, p[2:]...)
+		existing, e := enforcer.GetFilteredPolicy(0, p[0], tdom, p[2], p[3])
+		if e != nil {
+			return groupings, policies, e
+		}
+		if len(existing) > 0 && strings.Join(existing[0], "\x00") != strings.Join(trule, "\x00") {
+			continue
+		}
+		if len(existing) == 0 {
+			if _, err = enforcer.AddPolicy(trule); err != nil {
+				return
+			}
+		}
+		if _, err = enforcer.RemovePolicy(p); err != nil {
+			return
+		}
+		policies++
+	}
+
+	return
+}
+
+// RoleInUse 角色在租户内是否还有角色绑定（含租户级域与继承授权）或策略。
+func RoleInUse(tenantID uint64, role string) (bool, error) {
+	gs, err := enforcer.GetFilteredGroupingPolicy(1, role)
+	if err != nil {
+		return false, err
+	}
+	for _, g := range gs {
+		if len(g) >= 3 && inTenantDomain(tenantID, g[2]) {
+			return true, nil
+		}
+	}
+	ps, err := enforcer.GetFilteredPolicy(0, role)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range ps {
+		if len(p) >= 2 && inTenantDomain(tenantID, p[1]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// getRoleForUserInTenantAll 用户在租户内所有域（租户级域与各组织域）持有的角色。
+func getRoleForUserInTenantAll(sub string, tenantID uint64) (roles []string) {
+	gs, err := enforcer.GetFilteredGroupingPolicy(0, sub)
+	if err != nil {
+		return
+	}
+	for _, g := range gs {
+		if len(g) >= 3 && inTenantDomain(tenantID, g[2]) && !containsString(roles, g[1]) {
+			roles = append(roles, g[1])
+		}
+	}
+	return
+}
+
+func parseUserSubject(sub string) uint64 {
+	if !strings.HasPrefix(sub, "uid-") {
+		return 0
+	}
+	uid, _ := strconv.ParseUint(strings.TrimPrefix(sub, "uid-"), 10, 64)
+	return uid
+}
+
+func containsUint64(list []uint64, n uint64) bool {
+	for i := 0; i < len(list); i++ {
+		if list[i] == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,414 @@
+package accessctl
+
+import (
+	"testing"
+
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+func TestParseDomain(t *testing.T) {
+	cases := []struct {
+		domain string
+		tid    uint64
+		org    uint64
+		ok     bool
+	}{
+		{domain: "tenant-10030-org-10001", tid: 10030, org: 10001, ok: true},
+		{domain: "tenant-10030-all", tid: 10030, org: 0, ok: true},
+		{domain: "tenant-10030", tid: 10030, org: 0, ok: true},
+		{domain: "tenant-10030-org-", ok: false},
+		{domain: "tenant-10030-org-10001x", ok: false},
+		{domain: "org-10001", ok: false},
+		{domain: "", ok: false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.domain, func(t *testing.T) {
+			tid, org, ok := ParseDomain(tc.domain)
+			if ok != tc.ok || (ok && (tid != tc.tid || org != tc.org)) {
+				t.Fatalf("ParseDomain(%q) = (%v,%v,%v), want (%v,%v,%v)", tc.domain, tid, org, ok, tc.tid, tc.org, tc.ok)
+			}
+		})
+	}
+}
+
+func initTestEnforcer(t *testing.T) {
+	t.Helper()
+	if enforcer != nil {
+		return
+	}
+	if err := InitAccessControl("../rbac_with_domains_model.conf", "sqlite3", "file::memory:?cache=shared"); err != nil {
+		t.Fatalf("InitAccessControl: %v", err)
+	}
+}
+
+func TestEnforceBatch(t *testing.T) {
+	initTestEnforcer(t)
+
+	if err := AddPolicyToRole(10036, 10001, "editor", "/devices", "GET"); err != nil {
+		t.Fatal(err)
+	}
+	if err := addRoleForUserInDomain(genUserByUID(123), "editor", Domain(10036, 10001)); err != nil {
+		t.Fatal(err)
+	}
+	if err := addRoleForUserInDomain(genUserByUID(124), "root", Domain(10036, 10001)); err != nil {
+		t.Fatal(err)
+	}
+
+	items := []protos.EnforceReq{
+		{Obj: "/devices", Act: "GET"},
+		{Obj: "/devices", Act: "POST"},
+		{Obj: "/orders", Act: "GET"},
+	}
+	cases := []struct {
+		name string
+		uid  uint64
+		org  uint64
+		want []bool
+	}{
+		{name: "editor", uid: 123, org: 10001, want: []bool{true, false, false}},
+		{name: "root", uid: 124, org: 10001, want: []bool{true, true, true}},
+		{name: "other_org", uid: 123, org: 10002, want: []bool{false, false, false}},
+		{name: "no_role", uid: 125, org: 10001, want: []bool{false, false, false}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := EnforceBatch(tc.uid, 10036, tc.org, items)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("EnforceBatch len = %d, want %d", len(got), len(tc.want))
+			}
+			for i := range got {
+				one, _ := Enforce(tc.uid, 10036, tc.org, items[i].Obj, items[i].Act)
+				if got[i] != tc.want[i] || got[i] != one {
+					t.Fatalf("EnforceBatch[%d] = %v, Enforce = %v, want %v", i, got[i], one, tc.want[i])
+				}
+			}
+		})
+	}
+
+	if _, err := EnforceBatch(123, 10036, 0, items); err != common.ErrOrgRequired {
+		t.Fatalf("EnforceBatch org=0 err = %v, want ErrOrgRequired", err)
+	}
+}
+
+func TestDenyPolicy(t *testing.T) {
+	initTestEnforcer(t)
+
+	const tid, org = 10031, 10001
+	dom := Domain(tid, org)
+	if err := AddPolicyToRole(tid, org, "editor", "/orders", "GET"); err != nil {
+		t.Fatal(err)
+	}
+	for uid, role := range map[uint64]string{201: "editor", 202: "editor", 203: "root"} {
+		if err := addRoleForUserInDomain(genUserByUID(uid), role, dom); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 单独拒绝 202 与 root 用户 203
+	for _, uid := range []uint64{202, 203} {
+		if err := AddPolicyWithEffect(tid, org, UserSubject(uid), "/orders", "GET", protos.PolicyEffectDeny); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name     string
+		uid      uint64
+		override bool
+		want     bool
+	}{
+		{"role_allow", 201, false, true},
+		{"deny_overrides_role", 202, false, false},
+		{"root_exempt", 203, false, true},
+		{"root_deny_override", 203, true, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			common.ServConfig.RootDenyOverride = tc.override
+			defer func() { common.ServConfig.RootDenyOverride = false }()
+
+			got, err := Enforce(tc.uid, tid, org, "/orders", "GET")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("Enforce(%d) = %v, want %v", tc.uid, got, tc.want)
+			}
+			// 批量判定中 root 的角色只取一次，deny 仍按每一项检查
+			batch, err := EnforceBatch(tc.uid, tid, org, []protos.EnforceReq{{Obj: "/orders", Act: "GET"}, {Obj: "/stock", Act: "GET"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if batch[0] != tc.want || batch[1] != (tc.uid == 203) {
+				t.Fatalf("EnforceBatch(%d) = %v", tc.uid, batch)
+			}
+		})
+	}
+
+	explain, err := Explain(202, tid, org, "/orders", "GET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if explain.Allowed || explain.MatchedPolicy == nil || explain.MatchedPolicy.Effect != protos.PolicyEffectDeny {
+		t.Fatalf("Explain = %+v", explain)
+	}
+
+	// 再次写入 allow 覆盖原 deny
+	if err := AddPolicyWithEffect(tid, org, UserSubject(202), "/orders", "GET", protos.PolicyEffectAllow); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := Enforce(202, tid, org, "/orders", "GET"); !ok {
+		t.Fatal("allow should replace deny")
+	}
+}
+
+func TestExplain(t *testing.T) {
+	initTestEnforcer(t)
+
+	const tid, org = 10037, 10001
+	dom := Domain(tid, org)
+	if err := AddPolicyToRole(tid, org, "editor", "/orders", "GET"); err != nil {
+		t.Fatal(err)
+	}
+	for uid, role := range map[uint64]string{401: "editor", 402: "editor", 403: "root"} {
+		if err := addRoleForUserInDomain(genUserByUID(uid), role, dom); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, uid := range []uint64{402, 403} {
+		if err := AddPolicyWithEffect(tid, org, UserSubject(uid), "/orders", "GET", protos.PolicyEffectDeny); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name       string
+		uid        uint64
+		obj        string
+		override   bool
+		allowed    bool
+		rootBypass bool
+		matched    string // 命中策略的 sub 与 eft，空为没有命中
+	}{
+		{"allow", 401, "/orders", false, true, false, "editor allow"},
+		{"deny", 402, "/orders", false, false, false, UserSubject(402) + " deny"},
+		{"miss", 401, "/devices", false, false, false, ""},
+		{"root_bypass", 403, "/devices", false, true, true, ""},
+		{"root_exempt_deny", 403, "/orders", false, true, true, ""},
+		{"root_deny_override", 403, "/orders", true, false, false, UserSubject(403) + " deny"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			common.ServConfig.RootDenyOverride = tc.override
+			defer func() { common.ServConfig.RootDenyOverride = false }()
+
+			rst, err := Explain(tc.uid, tid, org, tc.obj, "GET")
+			if err != nil {
+				t.Fatal(err)
+			}
+			matched := ""
+			if rst.MatchedPolicy != nil {
+				matched = rst.MatchedPolicy.Role + " " + rst.MatchedPolicy.Effect
+			}
+			if rst.Allowed != tc.allowed || rst.RootBypass != tc.rootBypass || matched != tc.matched {
+				t.Fatalf("Explain = %+v, matched %q", rst, matched)
+			}
+			if got, _ := Enforce(tc.uid, tid, org, tc.obj, "GET"); got != rst.Allowed {
+				t.Fatalf("Enforce = %v, Explain.Allowed = %v", got, rst.Allowed)
+			}
+		})
+	}
+}
+
+func TestTenantDomain(t *testing.T) {
+	initTestEnforcer(t)
+
+	const tid = 10032
+	// 租户级角色 + 租户级策略；manager 另有一条只在 org 10001 的策略
+	if err := AddTenantPolicyWithEffect(tid, "manager", "/reports", "GET", protos.PolicyEffectAllow); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddPolicyToRole(tid, 10001, "manager", "/orders", "GET"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddRoleForUserInTenant(301, tid, "manager"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddRoleForUserInTenant(302, tid, "root"); err != nil {
+		t.Fatal(err)
+	}
+	if err := addRoleForUserInDomain(genUserByUID(303), "manager", Domain(tid, 10001)); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		uid  uint64
+		tid  uint64
+		org  uint64
+		obj  string
+		want bool
+	}{
+		{"tenant_role_tenant_policy", 301, tid, 10001, "/reports", true},
+		{"tenant_role_new_org", 301, tid, 10099, "/reports", true},
+		{"tenant_role_org_policy", 301, tid, 10001, "/orders", true},
+		{"org_policy_other_org", 301, tid, 10002, "/orders", false},
+		{"tenant_root", 302, tid, 10099, "/anything", true},
+		{"org_role_tenant_policy", 303, tid, 10001, "/reports", true},
+		{"org_role_other_org", 303, tid, 10002, "/reports", false},
+		{"other_tenant", 301, 10033, 10001, "/reports", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Enforce(tc.uid, tc.tid, tc.org, tc.obj, "GET")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("Enforce(%d, org %d, %s) = %v, want %v", tc.uid, tc.org, tc.obj, got, tc.want)
+			}
+		})
+	}
+
+	explain, err := Explain(301, tid, 10099, "/reports", "GET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !explain.Allowed || explain.MatchedPolicy == nil || !explain.MatchedPolicy.TenantWide {
+		t.Fatalf("Explain = %+v", explain)
+	}
+
+	// 租户级 deny 对所有组织生效
+	if err := AddTenantPolicyWithEffect(tid, UserSubject(301), "/reports", "GET", protos.PolicyEffectDeny); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := Enforce(301, tid, 10002, "/reports", "GET"); ok {
+		t.Fatal("tenant deny should apply to every org")
+	}
+}
+
+func TestCollapseTenantDomain(t *testing.T) {
+	initTestEnforcer(t)
+
+	const tid = 10034
+	orgs := []uint64{10001, 10002, 10003}
+	for _, org := range orgs {
+		dom := Domain(tid, org)
+		if err := addRoleForUserInDomain(genUserByUID(401), "root", dom); err != nil {
+			t.Fatal(err)
+		}
+		if err := addRoleForUserInDomain(genUserByUID(402), "temp", dom); err != nil {
+			t.Fatal(err)
+		}
+		if err := AddPolicyToRole(tid, org, "clerk", "/orders", "GET"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 只在部分组织存在的不合并
+	if err := addRoleForUserInDomain(genUserByUID(403), "clerk", Domain(tid, 10001)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddPolicyToRole(tid, 10001, "clerk", "/orders", "POST"); err != nil {
+		t.Fatal(err)
+	}
+	// 效果不一致的不合并
+	for i, org := range orgs {
+		eft := protos.PolicyEffectAllow
+		if i == 0 {
+			eft = protos.PolicyEffectDeny
+		}
+		if err := AddPolicyWithEffect(tid, org, "clerk", "/refunds", "GET", eft); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	groupings, policies, err := CollapseTenantDomain(tid, orgs, func(sub, role string) bool { return role == "temp" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groupings != 1 || policies != 1 {
+		t.Fatalf("CollapseTenantDomain = (%d, %d), want (1, 1)", groupings, policies)
+	}
+
+	if roles := GetRoleForUserInTenant(401, tid); len(roles) != 1 || roles[0] != "root" {
+		t.Fatalf("tenant roles = %v", roles)
+	}
+	for _, org := range orgs {
+		dom := Domain(tid, org)
+		if roles := enforcer.GetRolesForUserInDomain(genUserByUID(401), dom); len(roles) != 0 {
+			t.Fatalf("org %d roles not collapsed: %v", org, roles)
+		}
+		if roles := enforcer.GetRolesForUserInDomain(genUserByUID(402), dom); len(roles) != 1 {
+			t.Fatalf("skipped grouping removed in org %d: %v", org, roles)
+		}
+		if ok, _ := HasPolicy("clerk", dom, "/orders", "GET"); ok {
+			t.Fatalf("org %d policy not collapsed", org)
+		}
+		if ok, _ := HasPolicy("clerk", dom, "/refunds", "GET"); !ok {
+			t.Fatalf("org %d conflicting policy removed", org)
+		}
+	}
+	if ok, _ := HasPolicy("clerk", TenantDomain(tid), "/orders", "GET"); !ok {
+		t.Fatal("tenant policy missing")
+	}
+	if ok, _ := HasPolicy("clerk", Domain(tid, 10001), "/orders", "POST"); !ok {
+		t.Fatal("partial policy should stay in org")
+	}
+	if ok, _ := Enforce(403, tid, 10002, "/orders", "GET"); ok {
+		t.Fatal("org role must not leak to other orgs")
+	}
+	if ok, _ := Enforce(403, tid, 10001, "/orders", "GET"); !ok {
+		t.Fatal("collapsed policy should still apply")
+	}
+
+	// 第二次执行没有可合并的内容
+	if groupings, policies, err = CollapseTenantDomain(tid, orgs, func(sub, role string) bool { return role == "temp" }); err != nil || groupings != 0 || policies != 0 {
+		t.Fatalf("second run = (%d, %d, %v)", groupings, policies, err)
+	}
+}
+
+func TestLegacyTenantDomain(t *testing.T) {
+	initTestEnforcer(t)
+
+	const tid, org = 10038, 10001
+	ldom := LegacyTenantDomain(tid)
+	if _, err := enforcer.AddGroupingPolicy(genUserByUID(501), "clerk", ldom); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := enforcer.AddPolicy("clerk", ldom, "/legacy", "GET", protos.PolicyEffectAllow); err != nil {
+		t.Fatal(err)
+	}
+	if err := addRoleForUserInDomain(genUserByUID(502), "clerk", Domain(tid, org)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 遗留数据不参与鉴权，也不算作租户级策略
+	for _, uid := range []uint64{501, 502} {
+		if ok, _ := Enforce(uid, tid, org, "/legacy", "GET"); ok {
+			t.Fatalf("legacy rule applies to uid %d", uid)
+		}
+	}
+	if ps, _ := GetRolePoliciesInDomain(tid, 0); len(ps) != 0 {
+		t.Fatalf("tenant policies = %v", ps)
+	}
+	gs, ps, err := LegacyTenantDomainRules(tid)
+	if err != nil || len(gs) != 1 || len(ps) != 1 {
+		t.Fatalf("LegacyTenantDomainRules = %v, %v, %v", gs, ps, err)
+	}
+
+	groupings, policies, err := MigrateLegacyTenantDomain(tid)
+	if err != nil || groupings != 1 || policies != 1 {
+		t.Fatalf("MigrateLegacyTenantDomain = (%d, %d, %v)", groupings, policies, err)
+	}
+	for _, uid := range []uint64{501, 502} {
+		if ok, _ := Enforce(uid, tid, org, "/legacy", "GET"); !ok {
+			t.Fatalf("migrated rule should apply to uid %d", uid)
+		}
+	}
+	if gs, ps, _ = LegacyTenantDomainRules(tid); len(gs)+len(ps) != 0 {
+		t.Fatalf("legacy rows left: %v %v", gs, ps)
+	}
+}
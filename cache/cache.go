@@ -2,18 +2,27 @@ package cache
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/liuhengloveyou/passport/v4/protos"
 )
 
 const (
-	tenantCache    = "tenant-%d"
-	orgCache       = "org-%d"
-	orgMemberCache = "org-member-%d-%d"
+	tenantCache       = "tenant-%d"
+	orgCache          = "org-%d"
+	orgMemberCache    = "org-member-%d-%d"
+	orgConfigCache    = "org-config-%d-%d"
+	tenantMemberCache = "tenant-member-%d-%d"
+	memberStateCache  = "tenant-member-disabled-%d-%d"
 )
 
 var defaultCache = NewExpiredMap()
 
+// Clear 清空全部缓存，切换数据库后（如测试）使用。
+func Clear() {
+	defaultCache.Clear()
+}
+
 // SetTenantCache 将租户信息写入内存缓存。
 func SetTenantCache(m *protos.Tenant) {
 	defaultCache.Set(tenantCacheKey(m.ID), m, 3600)
@@ -80,3 +89,80 @@ func DelOrgMemberCache(orgID, uid uint64) {
 func orgMemberCacheKey(orgID, uid uint64) string {
 	return fmt.Sprintf(orgMemberCache, orgID, uid)
 }
+
+func SetTenantMemberCache(tenantID, uid uint64, in bool) {
+	if tenantID == 0 || uid == 0 || !in {
+		return
+	}
+	defaultCache.Set(tenantMemberCacheKey(tenantID, uid), true, 300)
+}
+
+func GetTenantMemberCache(tenantID, uid uint64) (in bool, hit bool) {
+	ok, v := defaultCache.Get(tenantMemberCacheKey(tenantID, uid))
+	if !ok {
+		return false, false
+	}
+	return v.(bool), true
+}
+
+func DelTenantMemberCache(tenantID, uid uint64) {
+	defaultCache.Delete(tenantMemberCacheKey(tenantID, uid))
+	defaultCache.Delete(fmt.Sprintf(memberStateCache, tenantID, uid))
+}
+
+// SetTenantMemberDisabledCache 缓存账号在租户内是否已停用。
+func SetTenantMemberDisabledCache(tenantID, uid uint64, disabled bool) {
+	if tenantID == 0 || uid == 0 {
+		return
+	}
+	defaultCache.Set(fmt.Sprintf(memberStateCache, tenantID, uid), disabled, 300)
+}
+
+func GetTenantMemberDisabledCache(tenantID, uid uint64) (disabled bool, hit bool) {
+	ok, v := defaultCache.Get(fmt.Sprintf(memberStateCache, tenantID, uid))
+	if !ok {
+		return false, false
+	}
+	return v.(bool), true
+}
+
+func tenantMemberCacheKey(tenantID, uid uint64) string {
+	return fmt.Sprintf(tenantMemberCache, tenantID, uid)
+}
+
+// SetOrgConfigCache 缓存组织的生效配置（租户配置叠加组织配置）。
+func SetOrgConfigCache(tenantID, orgID uint64, conf protos.MapStruct) {
+	if tenantID == 0 || orgID == 0 {
+		return
+	}
+	defaultCache.Set(orgConfigCacheKey(tenantID, orgID), conf, 3600)
+}
+
+func GetOrgConfigCache(tenantID, orgID uint64) (protos.MapStruct, bool) {
+	if ok, v := defaultCache.Get(orgConfigCacheKey(tenantID, orgID)); ok {
+		return v.(protos.MapStruct), true
+	}
+	return nil, false
+}
+
+func DelOrgConfigCache(tenantID, orgID uint64) {
+	defaultCache.Delete(orgConfigCacheKey(tenantID, orgID))
+}
+
+// DelTenantOrgConfigCache 租户配置变化后删除该租户下所有组织的生效配置。
+func DelTenantOrgConfigCache(tenantID uint64) {
+	prefix := strings.TrimSuffix(fmt.Sprintf(orgConfigCache, tenantID, 0), "0")
+	keys := make([]string, 0)
+	defaultCache.DoForEach(func(k string, _ any) {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	})
+	for _, k := range keys {
+		defaultCache.Delete(k)
+	}
+}
+
+func orgConfigCacheKey(tenantID, orgID uint64) string {
+	return fmt.Sprintf(orgConfigCache, tenantID, orgID)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	gocommon "github.com/liuhengloveyou/go-common"
+
+	"github.com/liuhengloveyou/passport/v4"
+	"github.com/liuhengloveyou/passport/v4/common"
+	passporthttp "github.com/liuhengloveyou/passport/v4/face/http"
+)
+
+// 编译时由 Makefile 通过 -ldflags 写入
+var (
+	BuildTime string
+	CommitID  string
+	GitTag    string
+)
+
+var (
+	initEnv     = flag.Bool("init", false, "初始化库表结构与 root 管理员/租户后退出")
+	showVersion = flag.Bool("version", false, "打印版本信息后退出")
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `用法:
+  passport [-passport passport.conf.yaml]              启动 HTTP 服务
+  passport [-passport passport.conf.yaml] -init        初始化数据库
+  passport [-passport passport.conf.yaml] tenant-tree  租户树维护，见 passport tenant-tree -h
+
+`)
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Printf("passport %s (%s) built at %s\n", GitTag, CommitID, BuildTime)
+		return
+	}
+
+	if *initEnv {
+		if path := flag.Lookup("passport"); path.Value.String() != path.DefValue {
+			if err := gocommon.LoadYamlConfig(path.Value.String(), &common.ServConfig); err != nil {
+				fmt.Fprintf(os.Stderr, "加载配置文件 %s 失败: %v\n", path.Value.String(), err)
+				os.Exit(exitError)
+			}
+		}
+		if err := passport.InitDatabaseEnv(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitError)
+		}
+		return
+	}
+
+	if flag.NArg() > 0 {
+		switch flag.Arg(0) {
+		case "tenant-tree":
+			os.Exit(tenantTree(flag.Args()[1:]))
+		default:
+			fmt.Fprintf(os.Stderr, "未知命令: %s\n\n", flag.Arg(0))
+			usage()
+			os.Exit(exitUsage)
+		}
+	}
+
+	if err := loadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitError)
+	}
+	passporthttp.InitAndRunHttpApi(nil)
+}
+
+// loadConfig common 包在 init 时已按默认路径加载过配置；-passport 指定了其他文件时重新加载并连接数据库
+func loadConfig() error {
+	path := flag.Lookup("passport").Value.String()
+	if path != flag.Lookup("passport").DefValue || common.DB == nil {
+		if err := gocommon.LoadYamlConfig(path, &common.ServConfig); err != nil {
+			return fmt.Errorf("加载配置文件 %s 失败: %v", path, err)
+		}
+		if common.DB != nil {
+			common.DB.Close()
+			common.DB = nil
+		}
+		if err := common.InitWithOption(&common.ServConfig); err != nil {
+			return fmt.Errorf("初始化失败: %v", err)
+		}
+	}
+	if common.DB == nil {
+		return fmt.Errorf("未找到数据库配置（db_driver/db_dsn），请检查配置文件")
+	}
+	if common.Logger == nil {
+		if err := common.InitLog("./logs", "info"); err != nil {
+			return fmt.Errorf("初始化日志失败: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/service"
+)
+
+// 退出码，供 cron 监控判断
+const (
+	exitOK      = 0
+	exitProblem = 1 // 闭包表不一致、dry-run 有待修复项、移动被拒绝（环、租户不存在等）
+	exitUsage   = 2 // 参数错误
+	exitError   = 3 // 配置、数据库等运行错误
+)
+
+func tenantTreeUsage(w io.Writer) {
+	fmt.Fprint(w, `用法: passport tenant-tree <命令>
+
+  check                       检查闭包表与直接父子关系是否一致，不一致退出码为 1
+  repair [--dry-run]          以直接父子关系为准修复闭包表；--dry-run 只列出差异，有差异退出码为 1
+  move <tenant> <new-parent>  把租户连同子树移到新的父租户下
+  print                       以树形打印所有租户
+
+退出码: 0 正常；1 发现问题或操作被拒绝；2 参数错误；3 运行错误
+`)
+}
+
+func tenantTree(args []string) int {
+	if len(args) == 0 {
+		tenantTreeUsage(os.Stderr)
+		return exitUsage
+	}
+
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "check", "repair", "move", "print":
+	case "-h", "--help", "help":
+		tenantTreeUsage(os.Stdout)
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "未知命令: %s\n\n", cmd)
+		tenantTreeUsage(os.Stderr)
+		return exitUsage
+	}
+
+	fs := flag.NewFlagSet("tenant-tree "+cmd, flag.ContinueOnError)
+	fs.Usage = func() { tenantTreeUsage(fs.Output()) }
+	dryRun := fs.Bool("dry-run", false, "只列出差异，不写库")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	var tenantID, parentID uint64
+	switch cmd {
+	case "move":
+		if fs.NArg() != 2 {
+			fmt.Fprintln(os.Stderr, "用法: passport tenant-tree move <tenant> <new-parent>")
+			return exitUsage
+		}
+		var err1, err2 error
+		tenantID, err1 = strconv.ParseUint(fs.Arg(0), 10, 64)
+		parentID, err2 = strconv.ParseUint(fs.Arg(1), 10, 64)
+		if err1 != nil || err2 != nil || tenantID == 0 || parentID == 0 {
+			fmt.Fprintln(os.Stderr, "租户 ID 必须是正整数")
+			return exitUsage
+		}
+	default:
+		if fs.NArg() != 0 {
+			fmt.Fprintf(os.Stderr, "多余的参数: %v\n", fs.Args())
+			return exitUsage
+		}
+	}
+	if *dryRun && cmd != "repair" {
+		fmt.Fprintln(os.Stderr, "--dry-run 只用于 repair")
+		return exitUsage
+	}
+
+	if err := loadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	switch cmd {
+	case "check":
+		return tenantTreeRepair(true, "check")
+	case "repair":
+		return tenantTreeRepair(*dryRun, "repair")
+	case "move":
+		return tenantTreeMove(tenantID, parentID)
+	default:
+		return tenantTreePrint()
+	}
+}
+
+// tenantTreeRepair check 与 repair --dry-run 都不写库，事务最后回滚
+func tenantTreeRepair(dryRun bool, cmd string) int {
+	ctx := context.Background()
+	tx, err := common.DB.Begin(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "开始事务失败: %v\n", err)
+		return exitError
+	}
+
+	report, err := dao.TenantClosureRepair(tx, dryRun)
+	if err != nil {
+		tx.Rollback(ctx)
+		fmt.Fprintf(os.Stderr, "%s 失败: %v\n", cmd, err)
+		return exitError
+	}
+	if dryRun {
+		tx.Rollback(ctx)
+	} else if err = tx.Commit(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "提交事务失败: %v\n", err)
+		return exitError
+	}
+
+	fmt.Printf("租户: %d  闭包行: %d\n", report.Tenants, report.Rows)
+	if report.OK() {
+		fmt.Println("闭包表一致")
+		return exitOK
+	}
+	fmt.Printf("缺失: %d  多余: %d  深度错误: %d\n", report.Missing, report.Extra, report.WrongDepth)
+	if len(report.MultiParents) > 0 {
+		fmt.Printf("多个直接父节点（保留 ID 最小的父节点）: %v\n", report.MultiParents)
+	}
+	for _, cycle := range report.Cycles {
+		fmt.Printf("环: %v\n", cycle)
+	}
+	if !dryRun {
+		fmt.Println("已修复")
+		return exitOK
+	}
+	return exitProblem
+}
+
+func tenantTreeMove(tenantID, parentID uint64) int {
+	err := service.TenantSetParent(tenantID, parentID)
+	if err == nil {
+		fmt.Printf("租户 %d 已移到 %d 下\n", tenantID, parentID)
+		return exitOK
+	}
+
+	fmt.Fprintf(os.Stderr, "move 失败: %v\n", err)
+	var circular *dao.CircularReferenceError
+	switch {
+	case errors.As(err, &circular),
+		errors.Is(err, common.ErrParam),
+		errors.Is(err, common.ErrTenantNotFound),
+		errors.Is(err, common.ErrTenantSame),
+		errors.Is(err, common.ErrTenantRoot),
+		errors.Is(err, common.ErrTenantCircularRef):
+		return exitProblem
+	}
+	return exitError
+}
+
+func tenantTreePrint() int {
+	ctx := context.Background()
+	tx, err := common.DB.Begin(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "开始事务失败: %v\n", err)
+		return exitError
+	}
+	nodes, err := dao.TenantTreeNodes(tx)
+	tx.Rollback(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "查询租户失败: %v\n", err)
+		return exitError
+	}
+
+	if printTenantTree(os.Stdout, nodes) {
+		return exitOK
+	}
+	return exitProblem
+}
+
+// printTenantTree 从根租户开始打印；环上的租户从根不可达，单独列出并返回 false
+func printTenantTree(w io.Writer, nodes []dao.TenantTreeNode) bool {
+	byID := make(map[uint64]dao.TenantTreeNode, len(nodes))
+	for _, n := range nodes {
+		byID[n.ID] = n
+	}
+	children := make(map[uint64][]uint64)
+	var roots []uint64
+	for _, n := range nodes {
+		if _, ok := byID[n.ParentID]; n.ParentID == 0 || !ok {
+			roots = append(roots, n.ID)
+		} else {
+			children[n.ParentID] = append(children[n.ParentID], n.ID)
+		}
+	}
+
+	printed := make(map[uint64]bool, len(nodes))
+	var walk func(id uint64, prefix string, last bool, root bool)
+	walk = func(id uint64, prefix string, last bool, root bool) {
+		printed[id] = true
+		switch {
+		case root:
+			fmt.Fprintf(w, "%d %s\n", id, byID[id].Name)
+		case last:
+			fmt.Fprintf(w, "%s└── %d %s\n", prefix, id, byID[id].Name)
+			prefix += "    "
+		default:
+			fmt.Fprintf(w, "%s├── %d %s\n", prefix, id, byID[id].Name)
+			prefix += "│   "
+		}
+		for i, c := range children[id] {
+			walk(c, prefix, i == len(children[id])-1, false)
+		}
+	}
+	for _, id := range roots {
+		walk(id, "", false, true)
+	}
+
+	ok := true
+	for _, n := range nodes {
+		if !printed[n.ID] {
+			if ok {
+				fmt.Fprintln(w, "\n从根不可达（父子关系成环）:")
+				ok = false
+			}
+			fmt.Fprintf(w, "%d %s (父: %d)\n", n.ID, n.Name, n.ParentID)
+		}
+	}
+	return ok
+}
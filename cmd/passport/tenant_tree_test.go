@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/database"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// initTenantTreeTest 独立的 sqlite 库，租户树：
+//
+//	1
+//	├── 2
+//	│   └── 3
+//	└── 4
+//
+// 闭包表缺少 1 → 3 这一行。
+func initTenantTreeTest(t *testing.T) database.DB {
+	t.Helper()
+	common.Logger = zap.NewNop()
+	savedRoot := common.ServConfig.RootTenantID
+	common.ServConfig.RootTenantID = 1
+	t.Cleanup(func() { common.ServConfig.RootTenantID = savedRoot })
+
+	dsn := filepath.Join(t.TempDir(), "passport.db")
+	if err := dao.Init(&protos.OptionStruct{DBDriver: string(database.DriverSQLite3), DBDSN: dsn}); err != nil {
+		t.Fatalf("dao.Init: %v", err)
+	}
+	db, err := database.NewSQLite3DB(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	common.DB = db
+
+	ctx := context.Background()
+	for _, sql := range []string{
+		"INSERT INTO tenants (id, tenant_name) VALUES (1, 'root'), (2, 'a'), (3, 'b'), (4, 'c')",
+		`INSERT INTO tenant_closure (ancestor_id, descendant_id, depth) VALUES
+			(1, 1, 0), (2, 2, 0), (3, 3, 0), (4, 4, 0), (1, 2, 1), (2, 3, 1), (1, 4, 1)`,
+	} {
+		if _, err = db.Exec(ctx, sql); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func TestTenantTreeArgs(t *testing.T) {
+	initTenantTreeTest(t)
+
+	cases := []struct {
+		args []string
+		want int
+	}{
+		{nil, exitUsage},
+		{[]string{"bogus"}, exitUsage},
+		{[]string{"help"}, exitOK},
+		{[]string{"check", "-h"}, exitOK},
+		{[]string{"check", "--bogus"}, exitUsage},
+		{[]string{"check", "extra"}, exitUsage},
+		// --dry-run 只用于 repair
+		{[]string{"check", "--dry-run"}, exitUsage},
+		{[]string{"print", "--dry-run"}, exitUsage},
+		{[]string{"move", "--dry-run", "3", "4"}, exitUsage},
+		// move 需要两个正整数
+		{[]string{"move"}, exitUsage},
+		{[]string{"move", "3"}, exitUsage},
+		{[]string{"move", "3", "4", "1"}, exitUsage},
+		{[]string{"move", "3", "x"}, exitUsage},
+		{[]string{"move", "0", "4"}, exitUsage},
+		{[]string{"move", "3", "0"}, exitUsage},
+	}
+	for _, tc := range cases {
+		if got := tenantTree(tc.args); got != tc.want {
+			t.Errorf("tenantTree(%q) = %d, want %d", tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestTenantTree(t *testing.T) {
+	db := initTenantTreeTest(t)
+
+	steps := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"check"}, exitProblem},
+		{[]string{"repair", "--dry-run"}, exitProblem},
+		// dry-run 不写库
+		{[]string{"check"}, exitProblem},
+		{[]string{"repair"}, exitOK},
+		{[]string{"check"}, exitOK},
+		{[]string{"repair", "--dry-run"}, exitOK},
+		// 移到自己的子树下成环、租户不存在、移动根租户都被拒绝
+		{[]string{"move", "2", "3"}, exitProblem},
+		{[]string{"move", "9", "1"}, exitProblem},
+		{[]string{"move", "1", "4"}, exitProblem},
+		{[]string{"move", "3", "4"}, exitOK},
+		{[]string{"check"}, exitOK},
+		{[]string{"print"}, exitOK},
+	}
+	for _, step := range steps {
+		if got := tenantTree(step.args); got != step.want {
+			t.Fatalf("tenantTree(%q) = %d, want %d", step.args, got, step.want)
+		}
+	}
+
+	var parent uint64
+	if err := db.QueryRow(context.Background(), "SELECT ancestor_id FROM tenant_closure WHERE descendant_id = 3 AND depth = 1").Scan(&parent); err != nil || parent != 4 {
+		t.Fatalf("parent of 3 = %d %v", parent, err)
+	}
+
+	// 数据库不可用
+	db.Close()
+	if got := tenantTree([]string{"check"}); got != exitError {
+		t.Fatalf("check on closed db = %d, want %d", got, exitError)
+	}
+}
+
+func TestPrintTenantTree(t *testing.T) {
+	var buf bytes.Buffer
+	nodes := []dao.TenantTreeNode{{ID: 1, Name: "root"}, {ID: 2, Name: "a", ParentID: 1}, {ID: 3, Name: "b", ParentID: 2}, {ID: 4, Name: "c", ParentID: 1}}
+	if !printTenantTree(&buf, nodes) {
+		t.Fatalf("printTenantTree = false:\n%s", buf.String())
+	}
+	want := "1 root\n├── 2 a\n│   └── 3 b\n└── 4 c\n"
+	if buf.String() != want {
+		t.Fatalf("printTenantTree =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	// 5 与 6 互为父节点，从根不可达
+	buf.Reset()
+	nodes = append(nodes, dao.TenantTreeNode{ID: 5, Name: "d", ParentID: 6}, dao.TenantTreeNode{ID: 6, Name: "e", ParentID: 5})
+	if printTenantTree(&buf, nodes) {
+		t.Fatalf("printTenantTree with cycle = true:\n%s", buf.String())
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, want) || !strings.Contains(out, "从根不可达（父子关系成环）:\n5 d (父: 6)\n6 e (父: 5)\n") {
+		t.Fatalf("printTenantTree with cycle =\n%s", out)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	gocommon "github.com/liuhengloveyou/go-common"
+	"github.com/liuhengloveyou/passport/v4/mail"
 	"github.com/liuhengloveyou/passport/v4/protos"
 	"github.com/liuhengloveyou/passport/v4/sessions"
 	"github.com/liuhengloveyou/passport/v4/sms"
@@ -83,6 +84,12 @@ func init() {
 			log.Panic("sms.Init ", sms.ErrSmsDriver)
 		}
 	}
+
+	if len(ServConfig.MailConf) > 0 {
+		if e = mail.Init(ServConfig.MailConf); e != nil {
+			log.Panic("mail.Init ", e)
+		}
+	}
 }
 
 func InitWithOption(option *protos.OptionStruct) (e error) {
@@ -122,6 +129,8 @@ func InitWithOption(option *protos.OptionStruct) (e error) {
 	ServConfig.ApiConf = option.ApiConf
 	ServConfig.RootUserID = option.RootUserID
 	ServConfig.RootTenantID = option.RootTenantID
+	ServConfig.RootDenyOverride = option.RootDenyOverride
+	ServConfig.DecisionCacheSize = option.DecisionCacheSize
 
 	return nil
 }
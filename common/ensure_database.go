@@ -128,9 +128,19 @@ func InitDBTable(db *pgxpool.Pool) error {
 			tenant_type VARCHAR(45) NOT NULL DEFAULT '',
 			info JSONB,
 			configuration JSONB,
+			status VARCHAR(16) NOT NULL DEFAULT 'active',
+			status_reason VARCHAR(255) NOT NULL DEFAULT '',
+			trial_end_time TIMESTAMPTZ,
+			delete_time TIMESTAMPTZ,
+			pre_delete_status VARCHAR(16) NOT NULL DEFAULT '',
 			create_time TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			update_time TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
 		);
+		ALTER TABLE tenants ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'active';
+		ALTER TABLE tenants ADD COLUMN IF NOT EXISTS status_reason VARCHAR(255) NOT NULL DEFAULT '';
+		ALTER TABLE tenants ADD COLUMN IF NOT EXISTS trial_end_time TIMESTAMPTZ;
+		ALTER TABLE tenants ADD COLUMN IF NOT EXISTS delete_time TIMESTAMPTZ;
+		ALTER TABLE tenants ADD COLUMN IF NOT EXISTS pre_delete_status VARCHAR(16) NOT NULL DEFAULT '';
 		CREATE INDEX IF NOT EXISTS idx_tenants_tenant_name ON tenants(tenant_name);
 		DO $$
 		BEGIN
@@ -151,13 +161,20 @@ func InitDBTable(db *pgxpool.Pool) error {
 			domain VARCHAR(128) NOT NULL,
 			title VARCHAR(128) NOT NULL,
 			value VARCHAR(256) NOT NULL,
+			parent_id BIGINT NOT NULL DEFAULT 0,
+			type VARCHAR(16) NOT NULL DEFAULT 'action',
+			actions TEXT NOT NULL DEFAULT '[]',
 			create_time TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			update_time TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			UNIQUE (tenant_id, domain, title),
 			UNIQUE (value, domain, tenant_id)
 		);
+		ALTER TABLE permission ADD COLUMN IF NOT EXISTS parent_id BIGINT NOT NULL DEFAULT 0;
+		ALTER TABLE permission ADD COLUMN IF NOT EXISTS type VARCHAR(16) NOT NULL DEFAULT 'action';
+		ALTER TABLE permission ADD COLUMN IF NOT EXISTS actions TEXT NOT NULL DEFAULT '[]';
 		CREATE INDEX IF NOT EXISTS idx_permission_tenant_id ON permission(tenant_id);
 		CREATE INDEX IF NOT EXISTS idx_permission_domain ON permission(domain);
+		CREATE INDEX IF NOT EXISTS idx_permission_parent_id ON permission(parent_id);
 		DO $$
 		BEGIN
 			IF (SELECT last_value FROM permission_id_seq) < 10000 THEN
@@ -258,11 +275,197 @@ func InitDBTable(db *pgxpool.Pool) error {
 		);
 		CREATE INDEX IF NOT EXISTS idx_org_members_uid ON org_members(uid);
 		CREATE INDEX IF NOT EXISTS idx_org_members_tenant_id ON org_members(tenant_id);
+		-- 用户在租户内的默认组织，登录时作为当前组织
+		ALTER TABLE org_members ADD COLUMN IF NOT EXISTS is_default SMALLINT NOT NULL DEFAULT 0;
+		-- 组织配置，读取时覆盖在租户配置之上
+		ALTER TABLE organizations ADD COLUMN IF NOT EXISTS configuration JSONB;
+
+		-- 组织层级：parent_id 为直接上级，org_closure 为闭包表
+		ALTER TABLE organizations ADD COLUMN IF NOT EXISTS parent_id BIGINT NOT NULL DEFAULT 0;
+		CREATE INDEX IF NOT EXISTS idx_organizations_parent_id ON organizations(parent_id);
+		CREATE TABLE IF NOT EXISTS org_closure (
+			tenant_id BIGINT NOT NULL,
+			ancestor_id BIGINT NOT NULL,
+			descendant_id BIGINT NOT NULL,
+			depth INT NOT NULL CHECK (depth >= 0),
+			PRIMARY KEY (ancestor_id, descendant_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_org_closure_descendant ON org_closure(descendant_id);
+		INSERT INTO org_closure (tenant_id, ancestor_id, descendant_id, depth)
+			SELECT tenant_id, id, id, 0 FROM organizations WHERE id NOT IN (SELECT descendant_id FROM org_closure);
 	`)
 	if err != nil {
 		return fmt.Errorf("创建组织表失败: %w", err)
 	}
 
+	_, err = db.Exec(ctx, `
+		-- 限时角色授权表（与 casbin_rule 的 g 规则对应）
+		CREATE TABLE IF NOT EXISTS role_grants (
+			id BIGSERIAL PRIMARY KEY,
+			tenant_id BIGINT NOT NULL,
+			org_id BIGINT NOT NULL,
+			uid BIGINT NOT NULL,
+			role VARCHAR(100) NOT NULL,
+			not_before TIMESTAMPTZ,
+			expires_at TIMESTAMPTZ,
+			active SMALLINT NOT NULL DEFAULT 0,
+			create_time TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (tenant_id, org_id, uid, role)
+		);
+		CREATE INDEX IF NOT EXISTS idx_role_grants_not_before ON role_grants(not_before);
+		CREATE INDEX IF NOT EXISTS idx_role_grants_expires_at ON role_grants(expires_at);
+	`)
+	if err != nil {
+		return fmt.Errorf("创建限时角色授权表失败: %w", err)
+	}
+
+	_, err = db.Exec(ctx, `
+		-- 角色自定义数据范围表（data-scope custom 级别的部门列表，按角色+模块）
+		CREATE TABLE IF NOT EXISTS role_data_scopes (
+			id BIGSERIAL PRIMARY KEY,
+			tenant_id BIGINT NOT NULL,
+			org_id BIGINT NOT NULL,
+			role VARCHAR(100) NOT NULL,
+			module VARCHAR(100) NOT NULL,
+			dep_ids TEXT NOT NULL DEFAULT '[]',
+			update_time TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (tenant_id, org_id, role, module)
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("创建角色数据范围表失败: %w", err)
+	}
+
+	_, err = db.Exec(ctx, `
+		-- 租户配额表（quota_limit 为 0 表示不限，used 为当前用量计数）
+		CREATE TABLE IF NOT EXISTS tenant_quotas (
+			tenant_id BIGINT NOT NULL,
+			resource VARCHAR(32) NOT NULL,
+			quota_limit BIGINT NOT NULL DEFAULT 0,
+			used BIGINT NOT NULL DEFAULT 0,
+			update_time TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (tenant_id, resource)
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("创建租户配额表失败: %w", err)
+	}
+
+	_, err = db.Exec(ctx, `
+		-- 租户清理记录表（软删除的租户过了恢复期被物理删除时写入）
+		CREATE TABLE IF NOT EXISTS tenant_purges (
+			id BIGSERIAL PRIMARY KEY,
+			tenant_id BIGINT NOT NULL,
+			tenant_name VARCHAR(255) NOT NULL DEFAULT '',
+			delete_time TIMESTAMPTZ,
+			purge_time TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			users BIGINT NOT NULL DEFAULT 0,
+			departments BIGINT NOT NULL DEFAULT 0,
+			permissions BIGINT NOT NULL DEFAULT 0,
+			children TEXT NOT NULL DEFAULT '[]'
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("创建租户清理记录表失败: %w", err)
+	}
+
+	_, err = db.Exec(ctx, `
+		-- 租户邀请表
+		CREATE TABLE IF NOT EXISTS invitations (
+			id BIGSERIAL PRIMARY KEY,
+			tenant_id BIGINT NOT NULL,
+			org_id BIGINT NOT NULL,
+			target VARCHAR(64) NOT NULL,
+			channel VARCHAR(16) NOT NULL,
+			roles TEXT NOT NULL DEFAULT '[]',
+			dep_ids TEXT NOT NULL DEFAULT '[]',
+			inviter_uid BIGINT NOT NULL DEFAULT 0,
+			nonce VARCHAR(64) NOT NULL,
+			status VARCHAR(16) NOT NULL DEFAULT 'pending',
+			expire_time TIMESTAMPTZ NOT NULL,
+			accept_uid BIGINT NOT NULL DEFAULT 0,
+			accept_time TIMESTAMPTZ,
+			create_time TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			update_time TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_invitations_tenant_id ON invitations(tenant_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("创建邀请表失败: %w", err)
+	}
+
+	_, err = db.Exec(ctx, `
+		-- 租户成员表（users.tenant_id 为默认租户，这里记录账号加入的所有租户）
+		CREATE TABLE IF NOT EXISTS tenant_members (
+			tenant_id BIGINT NOT NULL,
+			uid BIGINT NOT NULL,
+			disabled SMALLINT NOT NULL DEFAULT 0,
+			ext JSONB,
+			create_time TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (tenant_id, uid)
+		);
+		CREATE INDEX IF NOT EXISTS idx_tenant_members_uid ON tenant_members(uid);
+	`)
+	if err != nil {
+		return fmt.Errorf("创建租户成员表失败: %w", err)
+	}
+
+	_, err = db.Exec(ctx, `
+		-- 租户模板表（content 为模板 JSON）
+		CREATE TABLE IF NOT EXISTS tenant_templates (
+			id BIGSERIAL PRIMARY KEY,
+			name VARCHAR(64) NOT NULL UNIQUE,
+			remark VARCHAR(255) NOT NULL DEFAULT '',
+			source_tenant_id BIGINT NOT NULL DEFAULT 0,
+			content TEXT NOT NULL DEFAULT '{}',
+			create_time TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			update_time TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("创建租户模板表失败: %w", err)
+	}
+
+	_, err = db.Exec(ctx, `
+		-- 租户配置版本表（configuration 为该版本的完整配置，diff 为相对上一版本的变更）
+		CREATE TABLE IF NOT EXISTS tenant_config_versions (
+			id BIGSERIAL PRIMARY KEY,
+			tenant_id BIGINT NOT NULL,
+			version BIGINT NOT NULL,
+			configuration TEXT NOT NULL DEFAULT '{}',
+			diff TEXT NOT NULL DEFAULT '[]',
+			author_uid BIGINT NOT NULL DEFAULT 0,
+			remark VARCHAR(255) NOT NULL DEFAULT '',
+			create_time TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (tenant_id, version)
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("创建租户配置版本表失败: %w", err)
+	}
+
+	_, err = db.Exec(ctx, `
+		-- 组织成员调动记录表（from_roles、to_roles、dep_ids 为 JSON 数组）
+		CREATE TABLE IF NOT EXISTS org_member_transfers (
+			id BIGSERIAL PRIMARY KEY,
+			tenant_id BIGINT NOT NULL,
+			uid BIGINT NOT NULL,
+			from_org_id BIGINT NOT NULL,
+			to_org_id BIGINT NOT NULL,
+			mode VARCHAR(16) NOT NULL,
+			from_roles TEXT NOT NULL DEFAULT '[]',
+			to_roles TEXT NOT NULL DEFAULT '[]',
+			dep_ids TEXT NOT NULL DEFAULT '[]',
+			operator_uid BIGINT NOT NULL DEFAULT 0,
+			remark VARCHAR(255) NOT NULL DEFAULT '',
+			create_time TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_org_member_transfers_tenant_id ON org_member_transfers(tenant_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("创建组织成员调动记录表失败: %w", err)
+	}
+
 	return nil
 }
 
@@ -38,20 +38,55 @@ var (
 	ErrTenantNotFound           = errors.NewError(-2000, "租户不存在")
 	ErrTenantNameNull           = errors.NewError(-2001, "租户名字为空")
 	ErrTenantTypeNull           = errors.NewError(-2002, "租户类型为空")
-	ErrTenantLimit              = errors.NewError(-2003, "账号只能属于一个租户")
+	ErrTenantLimit              = errors.NewError(-2003, "已属于租户的账号不能再创建租户")
 	ErrTenantAddERR             = errors.NewError(-2004, "添加租户失败")
 	ErrTenantAdminCellphoneNull = errors.NewError(-2005, "管理员手机号为空")
 	ErrTenantAdminPasswordNull  = errors.NewError(-2006, "管理员密码为空")
 	ErrTenantNameDup            = errors.NewError(-2007, "租户名称已存在")
+	ErrTenantSuspended          = errors.NewError(-2011, "租户已停用")
+	ErrTenantExpired            = errors.NewError(-2012, "租户已过期")
+	ErrTenantArchived           = errors.NewError(-2013, "租户已归档")
+	ErrTenantStatus             = errors.NewError(-2014, "不允许的租户状态变更")
+	ErrQuotaExceeded            = errors.NewError(-2015, "超出租户配额")
+	ErrTenantDeleted            = errors.NewError(-2016, "租户已删除")
+	ErrTenantRestoreExpired     = errors.NewError(-2017, "租户已超过恢复期限")
 	ErrTenantSetParent          = errors.NewError(-104000, "设置租户父级失败")
 	ErrTenantCircularRef        = errors.NewError(-104001, "循环设置租户父级")
 	ErrTenantRoot               = errors.NewError(-104002, "不能给Root租户设置父级")
 	ErrTenantSame               = errors.NewError(-104003, "不能设置相同账号为父级")
 
 	// 组织
-	ErrOrgNotFound = errors.NewError(-2008, "组织不存在")
-	ErrOrgRequired = errors.NewError(-2009, "缺少组织")
-	ErrOrgNameDup  = errors.NewError(-2010, "组织名称已存在")
+	ErrOrgNotFound    = errors.NewError(-2008, "组织不存在")
+	ErrOrgRequired    = errors.NewError(-2009, "缺少组织")
+	ErrOrgNameDup     = errors.NewError(-2010, "组织名称已存在")
+	ErrOrgCircularRef = errors.NewError(-2026, "不能把组织移到自己或下级组织之下")
+	ErrOrgHasChildren = errors.NewError(-2027, "组织下还有下级组织")
+
+	// 邀请
+	ErrInviteNotFound = errors.NewError(-2018, "邀请不存在")
+	ErrInviteInvalid  = errors.NewError(-2019, "邀请无效或已过期")
+	ErrInviteSend     = errors.NewError(-2020, "邀请发送失败")
+
+	// 租户模板
+	ErrTemplateNotFound = errors.NewError(-2021, "租户模板不存在")
+	ErrTemplateNameDup  = errors.NewError(-2022, "租户模板名称已存在")
+	ErrTemplateInvalid  = errors.NewError(-2023, "租户模板内容无效")
+	ErrTemplateApply    = errors.NewError(-2024, "按模板初始化租户失败")
+
+	// 租户配置版本
+	ErrConfigVersionNotFound = errors.NewError(-2025, "配置版本不存在")
+
+	// 权限点
+	ErrPermissionNotFound = errors.NewError(-5000, "权限点未登记")
+	ErrPermissionInUse    = errors.NewError(-5001, "权限点仍被引用")
+	ErrPermissionParent   = errors.NewError(-5002, "上级权限点无效")
+
+	// 策略
+	ErrPolicyDenyUnsupported = errors.NewError(-5003, "当前模型不支持deny策略")
+	ErrRoleExclusive         = errors.NewError(-5004, "角色互斥")
+	ErrOrgInheritUnsupported = errors.NewError(-5005, "当前模型不支持组织继承授权")
+	ErrPermissionActInvalid  = errors.NewError(-5006, "权限点未登记该操作")
+	ErrRoleInUse             = errors.NewError(-5007, "角色仍被使用")
 
 	// 微信
 	ErrWxService = errors.NewError(-3000, "微信接口返回错误")
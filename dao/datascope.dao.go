@@ -0,0 +1,114 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/database"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// RoleDataScopeSetDeps 写入角色在某模块下的自定义部门列表；depIDs 为空时删除该配置
+func RoleDataScopeSetDeps(tenantID, orgID uint64, role, module string, depIDs []uint64) error {
+	if len(depIDs) == 0 {
+		sql, args, err := sq.Delete("role_data_scopes").
+			Where(sq.Eq{"tenant_id": tenantID, "org_id": orgID, "role": role, "module": module}).
+			PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+		common.Logger.Sugar().Debugf("%v %v %v\n", sql, args, err)
+		if err != nil {
+			return err
+		}
+		_, err = common.DB.Exec(context.Background(), sql, args...)
+		return err
+	}
+
+	deps, err := json.Marshal(depIDs)
+	if err != nil {
+		return err
+	}
+	sql, args, err := sq.Insert("role_data_scopes").
+		Columns("tenant_id", "org_id", "role", "module", "dep_ids", "update_time").
+		Values(tenantID, orgID, role, module, string(deps), time.Now()).
+		Suffix("ON CONFLICT (tenant_id, org_id, role, module) DO UPDATE SET dep_ids = excluded.dep_ids, update_time = excluded.update_time").
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	common.Logger.Sugar().Debugf("%v %v %v\n", sql, args, err)
+	if err != nil {
+		return err
+	}
+
+	_, err = common.DB.Exec(context.Background(), sql, args...)
+	return err
+}
+
+// RoleDataScopeDeps 查询若干角色在某模块下的自定义部门，返回 role -> depIDs
+func RoleDataScopeDeps(tenantID, orgID uint64, module string, roles []string) (map[string][]uint64, error) {
+	rr := make(map[string][]uint64)
+	if len(roles) == 0 {
+		return rr, nil
+	}
+
+	sql, args, err := sq.Select("role", "dep_ids").From("role_data_scopes").
+		Where(sq.Eq{"tenant_id": tenantID, "org_id": orgID, "module": module, "role": roles}).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	common.Logger.Sugar().Debugf("%v %v %v\n", sql, args, err)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := common.DB.Query(context.Background(), sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var role, deps string
+		if err = rows.Scan(&role, &deps); err != nil {
+			return nil, err
+		}
+		var ids []uint64
+		if err = json.Unmarshal([]byte(deps), &ids); err != nil {
+			return nil, err
+		}
+		rr[role] = ids
+	}
+
+	return rr, rows.Err()
+}
+
+// RoleDataScopeList 查询组织内全部角色的自定义部门配置
+func RoleDataScopeList(tenantID, orgID uint64) ([]protos.TemplateDataScope, error) {
+	sql, args, err := sq.Select("role", "module", "dep_ids").From("role_data_scopes").
+		Where(sq.Eq{"tenant_id": tenantID, "org_id": orgID}).
+		OrderBy("id").
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	common.Logger.Sugar().Debugf("%v %v %v\n", sql, args, err)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := common.DB.Query(context.Background(), sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rr []protos.TemplateDataScope
+	for rows.Next() {
+		var one protos.TemplateDataScope
+		var deps string
+		if err = rows.Scan(&one.Role, &one.Module, &deps); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal([]byte(deps), &one.DepIDs); err != nil {
+			return nil, err
+		}
+		rr = append(rr, one)
+	}
+
+	return rr, rows.Err()
+}
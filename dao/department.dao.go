@@ -35,17 +35,17 @@ func DepartmentDelete(id, tenantID uint64) (rowsAffected int64, err error) {
 	return
 }
 
-func DepartmentDeleteByOrg(tenantID, orgID uint64) error {
+func DepartmentDeleteByOrg(tenantID, orgID uint64) (rowsAffected int64, err error) {
 	if tenantID == 0 || orgID == 0 {
-		return common.ErrParam
+		return 0, common.ErrParam
 	}
-	_, err := common.DB.Exec(context.Background(),
+	rst, err := common.DB.Exec(context.Background(),
 		`DELETE FROM departments WHERE tenant_id = $1 AND org_id = $2`, tenantID, orgID)
 	if err != nil {
 		common.Logger.Sugar().Errorf("DepartmentDeleteByOrg ERR: %v", err)
-		return err
+		return 0, err
 	}
-	return nil
+	return rst.RowsAffected()
 }
 
 func DepartmentUpdate(model *protos.Department) (rowsAffected int64, err error) {
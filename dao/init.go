@@ -106,6 +106,42 @@ func initTables(ctx context.Context, db database.DB, dialect database.Dialect) e
 		return fmt.Errorf("初始化组织表失败: %w", err)
 	}
 
+	if err := createRoleGrantsTable(ctx, db, dialect); err != nil {
+		return fmt.Errorf("创建限时角色授权表失败: %w", err)
+	}
+
+	if err := createRoleDataScopesTable(ctx, db, dialect); err != nil {
+		return fmt.Errorf("创建角色数据范围表失败: %w", err)
+	}
+
+	if err := createTenantQuotasTable(ctx, db, dialect); err != nil {
+		return fmt.Errorf("创建租户配额表失败: %w", err)
+	}
+
+	if err := createTenantPurgesTable(ctx, db, dialect); err != nil {
+		return fmt.Errorf("创建租户清理记录表失败: %w", err)
+	}
+
+	if err := createInvitationsTable(ctx, db, dialect); err != nil {
+		return fmt.Errorf("创建邀请表失败: %w", err)
+	}
+
+	if err := createTenantMembersTable(ctx, db, dialect); err != nil {
+		return fmt.Errorf("创建租户成员表失败: %w", err)
+	}
+
+	if err := createTenantTemplatesTable(ctx, db, dialect); err != nil {
+		return fmt.Errorf("创建租户模板表失败: %w", err)
+	}
+
+	if err := createTenantConfigVersionsTable(ctx, db, dialect); err != nil {
+		return fmt.Errorf("创建租户配置版本表失败: %w", err)
+	}
+
+	if err := createOrgMemberTransfersTable(ctx, db, dialect); err != nil {
+		return fmt.Errorf("创建组织成员调动记录表失败: %w", err)
+	}
+
 	return nil
 }
 
@@ -194,14 +230,37 @@ func createTenantsTable(ctx context.Context, db database.DB, dialect database.Di
 			tenant_type VARCHAR(45) NOT NULL DEFAULT '',
 			info %s,
 			configuration %s,
+			status VARCHAR(16) NOT NULL DEFAULT 'active',
+			status_reason VARCHAR(255) NOT NULL DEFAULT '',
+			trial_end_time %s,
+			delete_time %s,
+			pre_delete_status VARCHAR(16) NOT NULL DEFAULT '',
 			create_time %s NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			update_time %s NOT NULL DEFAULT CURRENT_TIMESTAMP
-		)`, autoIncrement, primaryKey, jsonType, jsonType, timestampType, timestampType)
+		)`, autoIncrement, primaryKey, jsonType, jsonType, timestampType, timestampType, timestampType, timestampType)
 
 	if _, err := db.Exec(ctx, sql); err != nil {
 		return err
 	}
 
+	// 老库补充生命周期状态字段
+	if err := addColumnIfNotExists(ctx, db, "tenants", "status", "VARCHAR(16) NOT NULL DEFAULT 'active'"); err != nil {
+		return err
+	}
+	if err := addColumnIfNotExists(ctx, db, "tenants", "status_reason", "VARCHAR(255) NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := addColumnIfNotExists(ctx, db, "tenants", "trial_end_time", timestampType); err != nil {
+		return err
+	}
+	// 软删除字段
+	if err := addColumnIfNotExists(ctx, db, "tenants", "delete_time", timestampType); err != nil {
+		return err
+	}
+	if err := addColumnIfNotExists(ctx, db, "tenants", "pre_delete_status", "VARCHAR(16) NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
 	// 创建索引
 	indexes := []string{
 		"CREATE INDEX IF NOT EXISTS idx_tenants_tenant_name ON tenants(tenant_name)",
@@ -250,6 +309,9 @@ func createPermissionTable(ctx context.Context, db database.DB, dialect database
 			domain VARCHAR(128) NOT NULL,
 			title VARCHAR(128) NOT NULL,
 			value VARCHAR(256) NOT NULL,
+			parent_id BIGINT NOT NULL DEFAULT 0,
+			type VARCHAR(16) NOT NULL DEFAULT 'action',
+			actions TEXT NOT NULL DEFAULT '[]',
 			create_time %s NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			update_time %s NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			UNIQUE (tenant_id, domain, title),
@@ -260,10 +322,22 @@ func createPermissionTable(ctx context.Context, db database.DB, dialect database
 		return err
 	}
 
+	// 老库补充权限树字段
+	if err := addColumnIfNotExists(ctx, db, "permission", "parent_id", "BIGINT NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumnIfNotExists(ctx, db, "permission", "type", "VARCHAR(16) NOT NULL DEFAULT 'action'"); err != nil {
+		return err
+	}
+	if err := addColumnIfNotExists(ctx, db, "permission", "actions", "TEXT NOT NULL DEFAULT '[]'"); err != nil {
+		return err
+	}
+
 	// 创建索引
 	indexes := []string{
 		"CREATE INDEX IF NOT EXISTS idx_permission_tenant_id ON permission(tenant_id)",
 		"CREATE INDEX IF NOT EXISTS idx_permission_domain ON permission(domain)",
+		"CREATE INDEX IF NOT EXISTS idx_permission_parent_id ON permission(parent_id)",
 	}
 	for _, idxSQL := range indexes {
 		if _, err := db.Exec(ctx, idxSQL); err != nil {
@@ -430,6 +504,261 @@ func createTenantClosureTable(ctx context.Context, db database.DB, dialect datab
 	return nil
 }
 
+// createRoleGrantsTable 创建限时角色授权表
+func createRoleGrantsTable(ctx context.Context, db database.DB, dialect database.Dialect) error {
+	autoIncrement := dialect.AutoIncrement()
+	timestampType := getTimestampType(dialect)
+	primaryKey := ""
+	if db.DriverType() == database.DriverPostgreSQL {
+		primaryKey = "PRIMARY KEY"
+	}
+
+	sql := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS role_grants (
+			id %s %s,
+			tenant_id BIGINT NOT NULL,
+			org_id BIGINT NOT NULL,
+			uid BIGINT NOT NULL,
+			role VARCHAR(100) NOT NULL,
+			not_before %s,
+			expires_at %s,
+			active SMALLINT NOT NULL DEFAULT 0,
+			create_time %s NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (tenant_id, org_id, uid, role)
+		)`, autoIncrement, primaryKey, timestampType, timestampType, timestampType)
+	if _, err := db.Exec(ctx, sql); err != nil {
+		return err
+	}
+
+	indexes := []string{
+		"CREATE INDEX IF NOT EXISTS idx_role_grants_not_before ON role_grants(not_before)",
+		"CREATE INDEX IF NOT EXISTS idx_role_grants_expires_at ON role_grants(expires_at)",
+	}
+	for _, idxSQL := range indexes {
+		if _, err := db.Exec(ctx, idxSQL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createRoleDataScopesTable 创建角色自定义数据范围表（custom 级别的部门列表）
+func createRoleDataScopesTable(ctx context.Context, db database.DB, dialect database.Dialect) error {
+	autoIncrement := dialect.AutoIncrement()
+	timestampType := getTimestampType(dialect)
+	primaryKey := ""
+	if db.DriverType() == database.DriverPostgreSQL {
+		primaryKey = "PRIMARY KEY"
+	}
+
+	sql := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS role_data_scopes (
+			id %s %s,
+			tenant_id BIGINT NOT NULL,
+			org_id BIGINT NOT NULL,
+			role VARCHAR(100) NOT NULL,
+			module VARCHAR(100) NOT NULL,
+			dep_ids TEXT NOT NULL DEFAULT '[]',
+			update_time %s NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (tenant_id, org_id, role, module)
+		)`, autoIncrement, primaryKey, timestampType)
+	_, err := db.Exec(ctx, sql)
+	return err
+}
+
+// createTenantQuotasTable 创建租户配额表（每个租户每项资源一行，used 为当前用量计数）
+func createTenantQuotasTable(ctx context.Context, db database.DB, dialect database.Dialect) error {
+	sql := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS tenant_quotas (
+			tenant_id BIGINT NOT NULL,
+			resource VARCHAR(32) NOT NULL,
+			quota_limit BIGINT NOT NULL DEFAULT 0,
+			used BIGINT NOT NULL DEFAULT 0,
+			update_time %s NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (tenant_id, resource)
+		)`, getTimestampType(dialect))
+	_, err := db.Exec(ctx, sql)
+	return err
+}
+
+// createTenantPurgesTable 创建租户清理记录表（软删除的租户过了恢复期被物理删除时写入）
+func createTenantPurgesTable(ctx context.Context, db database.DB, dialect database.Dialect) error {
+	autoIncrement := dialect.AutoIncrement()
+	timestampType := getTimestampType(dialect)
+	primaryKey := ""
+	if db.DriverType() == database.DriverPostgreSQL {
+		primaryKey = "PRIMARY KEY"
+	}
+
+	sql := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS tenant_purges (
+			id %s %s,
+			tenant_id BIGINT NOT NULL,
+			tenant_name VARCHAR(255) NOT NULL DEFAULT '',
+			delete_time %s,
+			purge_time %s NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			users BIGINT NOT NULL DEFAULT 0,
+			departments BIGINT NOT NULL DEFAULT 0,
+			permissions BIGINT NOT NULL DEFAULT 0,
+			children TEXT NOT NULL DEFAULT '[]'
+		)`, autoIncrement, primaryKey, timestampType, timestampType)
+	_, err := db.Exec(ctx, sql)
+	return err
+}
+
+// createInvitationsTable 创建租户邀请表
+func createInvitationsTable(ctx context.Context, db database.DB, dialect database.Dialect) error {
+	autoIncrement := dialect.AutoIncrement()
+	timestampType := getTimestampType(dialect)
+	primaryKey := ""
+	if db.DriverType() == database.DriverPostgreSQL {
+		primaryKey = "PRIMARY KEY"
+	}
+
+	sql := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS invitations (
+			id %s %s,
+			tenant_id BIGINT NOT NULL,
+			org_id BIGINT NOT NULL,
+			target VARCHAR(64) NOT NULL,
+			channel VARCHAR(16) NOT NULL,
+			roles TEXT NOT NULL DEFAULT '[]',
+			dep_ids TEXT NOT NULL DEFAULT '[]',
+			inviter_uid BIGINT NOT NULL DEFAULT 0,
+			nonce VARCHAR(64) NOT NULL,
+			status VARCHAR(16) NOT NULL DEFAULT 'pending',
+			expire_time %s NOT NULL,
+			accept_uid BIGINT NOT NULL DEFAULT 0,
+			accept_time %s,
+			create_time %s NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			update_time %s NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`, autoIncrement, primaryKey, timestampType, timestampType, timestampType, timestampType)
+	if _, err := db.Exec(ctx, sql); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(ctx, "CREATE INDEX IF NOT EXISTS idx_invitations_tenant_id ON invitations(tenant_id)")
+	return err
+}
+
+// createTenantMembersTable 创建租户成员表（users.tenant_id 为默认租户，这里记录账号加入的所有租户；早期数据的默认租户可能没有记录）。
+// disabled、ext 是账号在该租户内的停用状态和扩展信息。
+func createTenantMembersTable(ctx context.Context, db database.DB, dialect database.Dialect) error {
+	sql := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS tenant_members (
+			tenant_id BIGINT NOT NULL,
+			uid BIGINT NOT NULL,
+			disabled SMALLINT NOT NULL DEFAULT 0,
+			ext %s,
+			create_time %s NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (tenant_id, uid)
+		)`, dialect.JSONType(), getTimestampType(dialect))
+	if _, err := db.Exec(ctx, sql); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(ctx, "CREATE INDEX IF NOT EXISTS idx_tenant_members_uid ON tenant_members(uid)")
+	return err
+}
+
+// createTenantTemplatesTable 创建租户模板表（content 为模板 JSON）
+func createTenantTemplatesTable(ctx context.Context, db database.DB, dialect database.Dialect) error {
+	autoIncrement := dialect.AutoIncrement()
+	timestampType := getTimestampType(dialect)
+	primaryKey := ""
+	if db.DriverType() == database.DriverPostgreSQL {
+		primaryKey = "PRIMARY KEY"
+	}
+
+	sql := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS tenant_templates (
+			id %s %s,
+			name VARCHAR(64) NOT NULL UNIQUE,
+			remark VARCHAR(255) NOT NULL DEFAULT '',
+			source_tenant_id BIGINT NOT NULL DEFAULT 0,
+			content TEXT NOT NULL DEFAULT '{}',
+			create_time %s NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			update_time %s NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`, autoIncrement, primaryKey, timestampType, timestampType)
+	_, err := db.Exec(ctx, sql)
+	return err
+}
+
+// createTenantConfigVersionsTable 创建租户配置版本表（configuration 为该版本的完整配置，diff 为相对上一版本的变更）
+func createTenantConfigVersionsTable(ctx context.Context, db database.DB, dialect database.Dialect) error {
+	autoIncrement := dialect.AutoIncrement()
+	timestampType := getTimestampType(dialect)
+	primaryKey := ""
+	if db.DriverType() == database.DriverPostgreSQL {
+		primaryKey = "PRIMARY KEY"
+	}
+
+	sql := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS tenant_config_versions (
+			id %s %s,
+			tenant_id BIGINT NOT NULL,
+			version BIGINT NOT NULL,
+			configuration TEXT NOT NULL DEFAULT '{}',
+			diff TEXT NOT NULL DEFAULT '[]',
+			author_uid BIGINT NOT NULL DEFAULT 0,
+			remark VARCHAR(255) NOT NULL DEFAULT '',
+			create_time %s NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (tenant_id, version)
+		)`, autoIncrement, primaryKey, timestampType)
+	_, err := db.Exec(ctx, sql)
+	return err
+}
+
+// createOrgMemberTransfersTable 创建组织成员调动记录表（from_roles、to_roles、dep_ids 为 JSON 数组）
+func createOrgMemberTransfersTable(ctx context.Context, db database.DB, dialect database.Dialect) error {
+	autoIncrement := dialect.AutoIncrement()
+	timestampType := getTimestampType(dialect)
+	primaryKey := ""
+	if db.DriverType() == database.DriverPostgreSQL {
+		primaryKey = "PRIMARY KEY"
+	}
+
+	sql := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS org_member_transfers (
+			id %s %s,
+			tenant_id BIGINT NOT NULL,
+			uid BIGINT NOT NULL,
+			from_org_id BIGINT NOT NULL,
+			to_org_id BIGINT NOT NULL,
+			mode VARCHAR(16) NOT NULL,
+			from_roles TEXT NOT NULL DEFAULT '[]',
+			to_roles TEXT NOT NULL DEFAULT '[]',
+			dep_ids TEXT NOT NULL DEFAULT '[]',
+			operator_uid BIGINT NOT NULL DEFAULT 0,
+			remark VARCHAR(255) NOT NULL DEFAULT '',
+			create_time %s NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`, autoIncrement, primaryKey, timestampType)
+	if _, err := db.Exec(ctx, sql); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(ctx, "CREATE INDEX IF NOT EXISTS idx_org_member_transfers_tenant_id ON org_member_transfers(tenant_id)")
+	return err
+}
+
+// addColumnIfNotExists 为已存在的表补充字段（SQLite3 不支持 ADD COLUMN IF NOT EXISTS，需先查 table_info）
+func addColumnIfNotExists(ctx context.Context, db database.DB, table, column, definition string) error {
+	if db.DriverType() == database.DriverPostgreSQL {
+		_, err := db.Exec(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", table, column, definition))
+		return err
+	}
+
+	var n int
+	if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err := db.Exec(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
 // getTimestampType 获取时间戳类型
 func getTimestampType(dialect database.Dialect) string {
 	// 根据数据库类型返回时间戳类型
@@ -493,7 +822,7 @@ func migrateOrgSchema(ctx context.Context, db database.DB, dialect database.Dial
 		return err
 	}
 
-	if _, err := db.Exec(ctx, "ALTER TABLE departments ADD COLUMN IF NOT EXISTS org_id BIGINT NOT NULL DEFAULT 0"); err != nil {
+	if err := addColumnIfNotExists(ctx, db, "departments", "org_id", "BIGINT NOT NULL DEFAULT 0"); err != nil {
 		return err
 	}
 	if _, err := db.Exec(ctx, "CREATE INDEX IF NOT EXISTS idx_departments_org_id ON departments(org_id)"); err != nil {
@@ -532,5 +861,34 @@ func migrateOrgSchema(ctx context.Context, db database.DB, dialect database.Dial
 			return err
 		}
 	}
-	return nil
+
+	// 组织层级：parent_id 为直接上级，org_closure 为闭包表
+	if err := addColumnIfNotExists(ctx, db, "organizations", "parent_id", "BIGINT NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	for _, sql := range []string{
+		`CREATE TABLE IF NOT EXISTS org_closure (
+			tenant_id BIGINT NOT NULL,
+			ancestor_id BIGINT NOT NULL,
+			descendant_id BIGINT NOT NULL,
+			depth INT NOT NULL CHECK (depth >= 0),
+			PRIMARY KEY (ancestor_id, descendant_id)
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_org_closure_descendant ON org_closure(descendant_id)",
+		"CREATE INDEX IF NOT EXISTS idx_organizations_parent_id ON organizations(parent_id)",
+		// 已有组织补上自身一行，作为顶级组织
+		`INSERT INTO org_closure (tenant_id, ancestor_id, descendant_id, depth)
+			SELECT tenant_id, id, id, 0 FROM organizations WHERE id NOT IN (SELECT descendant_id FROM org_closure)`,
+	} {
+		if _, err := db.Exec(ctx, sql); err != nil {
+			return err
+		}
+	}
+
+	// 用户在租户内的默认组织，登录时作为当前组织
+	if err := addColumnIfNotExists(ctx, db, "org_members", "is_default", "SMALLINT NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	// 组织配置，读取时覆盖在租户配置之上
+	return addColumnIfNotExists(ctx, db, "organizations", "configuration", dialect.JSONType())
 }
@@ -0,0 +1,161 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/database"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+var invitationColumns = []string{"id", "tenant_id", "org_id", "target", "channel", "roles", "dep_ids", "inviter_uid", "nonce", "status", "expire_time", "accept_uid", "accept_time", "create_time", "update_time"}
+
+// InvitationInsert 写入一条待接受的邀请
+func InvitationInsert(m *protos.Invitation) (id uint64, err error) {
+	roles, err := json.Marshal(nonNilStrings(m.Roles))
+	if err != nil {
+		return 0, err
+	}
+	deps, err := json.Marshal(nonNilUint64s(m.DepIds))
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	query, args, err := sq.Insert("invitations").
+		Columns("tenant_id", "org_id", "target", "channel", "roles", "dep_ids", "inviter_uid", "nonce", "status", "expire_time", "create_time", "update_time").
+		Values(m.TenantID, m.OrgID, m.Target, m.Channel, string(roles), string(deps), m.InviterUID, m.Nonce, protos.InviteStatusPending, m.ExpireTime, now, now).
+		Suffix("RETURNING id").
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = common.DB.QueryRow(context.Background(), query, args...).Scan(&id); err != nil {
+		common.Logger.Sugar().Errorf("InvitationInsert ERR: %v %v\n", m.Target, err)
+		return 0, err
+	}
+	return id, nil
+}
+
+// InvitationGet 按 id 查询邀请，不存在时返回 nil
+func InvitationGet(id uint64) (*protos.Invitation, error) {
+	rr, err := invitationQuery(sq.Eq{"id": id}, 1, 1)
+	if err != nil || len(rr) == 0 {
+		return nil, err
+	}
+	return &rr[0], nil
+}
+
+// InvitationList 分页查询租户的邀请，最近的在前；status 为空时不过滤，pending 与 expired 按 now 区分
+func InvitationList(tenantID uint64, status string, now time.Time, page, pageSize uint64) ([]protos.Invitation, error) {
+	where := sq.And{sq.Eq{"tenant_id": tenantID}}
+	switch status {
+	case "":
+	case protos.InviteStatusPending:
+		where = append(where, sq.Eq{"status": protos.InviteStatusPending}, sq.Gt{"expire_time": now})
+	case protos.InviteStatusExpired:
+		where = append(where, sq.Eq{"status": protos.InviteStatusPending}, sq.LtOrEq{"expire_time": now})
+	default:
+		where = append(where, sq.Eq{"status": status})
+	}
+	return invitationQuery(where, page, pageSize)
+}
+
+// InvitationRenew 重发时更换 nonce 并顺延有效期；只对待接受的邀请生效
+func InvitationRenew(id uint64, nonce string, expireTime time.Time) (int64, error) {
+	query, args, err := sq.Update("invitations").
+		Set("nonce", nonce).
+		Set("expire_time", expireTime).
+		Set("update_time", time.Now()).
+		Where(sq.Eq{"id": id, "status": protos.InviteStatusPending}).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	rst, err := common.DB.Exec(context.Background(), query, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("InvitationRenew ERR: %v %v\n", id, err)
+		return 0, err
+	}
+	return rst.RowsAffected()
+}
+
+// InvitationSetStatus 以当前状态 from 为条件变更状态；接受时记下 acceptUID
+func InvitationSetStatus(id uint64, from, to string, acceptUID uint64) (int64, error) {
+	act := sq.Update("invitations").
+		Set("status", to).
+		Set("update_time", time.Now()).
+		Where(sq.Eq{"id": id, "status": from})
+	if to == protos.InviteStatusAccepted {
+		act = act.Set("accept_uid", acceptUID).Set("accept_time", time.Now())
+	} else if from == protos.InviteStatusAccepted {
+		act = act.Set("accept_uid", 0).Set("accept_time", nil)
+	}
+
+	query, args, err := act.PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return 0, err
+	}
+	rst, err := common.DB.Exec(context.Background(), query, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("InvitationSetStatus ERR: %v %v => %v %v\n", id, from, to, err)
+		return 0, err
+	}
+	return rst.RowsAffected()
+}
+
+func invitationQuery(where sq.Sqlizer, page, pageSize uint64) ([]protos.Invitation, error) {
+	query, args, err := sq.Select(invitationColumns...).From("invitations").
+		Where(where).
+		OrderBy("id DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := common.DB.Query(context.Background(), query, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("invitationQuery ERR: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rr []protos.Invitation
+	for rows.Next() {
+		var one protos.Invitation
+		var roles, deps string
+		if err = rows.Scan(&one.ID, &one.TenantID, &one.OrgID, &one.Target, &one.Channel, &roles, &deps, &one.InviterUID, &one.Nonce,
+			&one.Status, &one.ExpireTime, &one.AcceptUID, &one.AcceptTime, &one.CreateTime, &one.UpdateTime); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal([]byte(roles), &one.Roles); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal([]byte(deps), &one.DepIds); err != nil {
+			return nil, err
+		}
+		rr = append(rr, one)
+	}
+	return rr, rows.Err()
+}
+
+func nonNilStrings(v []string) []string {
+	if v == nil {
+		return []string{}
+	}
+	return v
+}
+
+func nonNilUint64s(v []uint64) []uint64 {
+	if v == nil {
+		return []uint64{}
+	}
+	return v
+}
@@ -2,6 +2,7 @@ package dao
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"strings"
 	"time"
@@ -14,19 +15,39 @@ import (
 	sq "github.com/Masterminds/squirrel"
 )
 
+// OrgInsert 新建组织并写入闭包表；m.ParentID 为 0 时是顶级组织
 func OrgInsert(m *protos.Organization) (id uint64, err error) {
 	if m == nil || m.TenantID == 0 || strings.TrimSpace(m.Name) == "" {
 		return 0, common.ErrParam
 	}
+	ctx := context.Background()
+	tx, err := common.DB.Begin(ctx)
+	if err != nil {
+		common.Logger.Sugar().Errorf("OrgInsert Begin ERR: %v", err)
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		}
+	}()
+
 	now := time.Now()
-	err = common.DB.QueryRow(context.Background(),
-		`INSERT INTO organizations (tenant_id, name, create_time, update_time) VALUES ($1, $2, $3, $4) RETURNING id`,
-		m.TenantID, strings.TrimSpace(m.Name), now, now,
+	err = tx.QueryRow(ctx,
+		`INSERT INTO organizations (tenant_id, parent_id, name, create_time, update_time) VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		m.TenantID, m.ParentID, strings.TrimSpace(m.Name), now, now,
 	).Scan(&id)
 	if err != nil {
 		common.Logger.Sugar().Errorf("OrgInsert ERR: %v", err)
 		return 0, err
 	}
+	if err = OrgClosureInsert(tx, m.TenantID, m.ParentID, id); err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		common.Logger.Sugar().Errorf("OrgInsert Commit ERR: %v", err)
+		return 0, err
+	}
 	return id, nil
 }
 
@@ -36,11 +57,11 @@ func OrgGetByTenantName(tenantID uint64, name string) (*protos.Organization, err
 		return nil, nil
 	}
 	row := common.DB.QueryRow(context.Background(),
-		`SELECT id, tenant_id, name, create_time, update_time FROM organizations WHERE tenant_id = $1 AND name = $2 LIMIT 1`,
+		`SELECT id, tenant_id, parent_id, name, create_time, update_time FROM organizations WHERE tenant_id = $1 AND name = $2 LIMIT 1`,
 		tenantID, name)
 	var org protos.Organization
-	if err := row.Scan(&org.ID, &org.TenantID, &org.Name, &org.CreateTime, &org.UpdateTime); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
+	if err := row.Scan(&org.ID, &org.TenantID, &org.ParentID, &org.Name, &org.CreateTime, &org.UpdateTime); err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
 		}
 		common.Logger.Sugar().Errorf("OrgGetByTenantName ERR: %v", err)
@@ -54,10 +75,10 @@ func OrgGetByID(id uint64) (*protos.Organization, error) {
 		return nil, nil
 	}
 	row := common.DB.QueryRow(context.Background(),
-		`SELECT id, tenant_id, name, create_time, update_time FROM organizations WHERE id = $1`, id)
+		`SELECT id, tenant_id, parent_id, name, configuration, create_time, update_time FROM organizations WHERE id = $1`, id)
 	var org protos.Organization
-	if err := row.Scan(&org.ID, &org.TenantID, &org.Name, &org.CreateTime, &org.UpdateTime); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
+	if err := row.Scan(&org.ID, &org.TenantID, &org.ParentID, &org.Name, &org.Configuration, &org.CreateTime, &org.UpdateTime); err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
 		}
 		common.Logger.Sugar().Errorf("OrgGetByID ERR: %v", err)
@@ -66,12 +87,60 @@ func OrgGetByID(id uint64) (*protos.Organization, error) {
 	return &org, nil
 }
 
+// OrgConfigurationForUpdate 在事务中读取组织配置与更新时间，PostgreSQL 下锁住该行
+func OrgConfigurationForUpdate(tx database.Tx, orgID uint64) (conf protos.MapStruct, updateTime *time.Time, found bool, err error) {
+	builder := sq.Select("configuration", "update_time").
+		From("organizations").
+		Where(sq.Eq{"id": orgID}).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType()))
+	if common.DB.DriverType() == database.DriverPostgreSQL {
+		builder = builder.Suffix("FOR UPDATE")
+	}
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	rows, err := tx.Query(context.Background(), query, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("OrgConfigurationForUpdate ERR: %v %v\n", orgID, err)
+		return nil, nil, false, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, nil, false, rows.Err()
+	}
+	if err = rows.Scan(&conf, &updateTime); err != nil {
+		return nil, nil, false, err
+	}
+	return conf, updateTime, true, nil
+}
+
+// OrgConfigurationSet 在事务中写入组织配置与更新时间
+func OrgConfigurationSet(tx database.Tx, orgID uint64, conf protos.MapStruct, updateTime time.Time) error {
+	query, args, err := sq.Update("organizations").
+		Set("configuration", conf).
+		Set("update_time", updateTime).
+		Where(sq.Eq{"id": orgID}).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(context.Background(), query, args...); err != nil {
+		common.Logger.Sugar().Errorf("OrgConfigurationSet ERR: %v %v\n", orgID, err)
+		return err
+	}
+	return nil
+}
+
 func OrgListByTenant(tenantID uint64) ([]protos.Organization, error) {
 	if tenantID == 0 {
 		return nil, common.ErrParam
 	}
 	rows, err := common.DB.Query(context.Background(),
-		`SELECT id, tenant_id, name, create_time, update_time FROM organizations WHERE tenant_id = $1 ORDER BY id`, tenantID)
+		`SELECT id, tenant_id, parent_id, name, create_time, update_time FROM organizations WHERE tenant_id = $1 ORDER BY id`, tenantID)
 	if err != nil {
 		common.Logger.Sugar().Errorf("OrgListByTenant ERR: %v", err)
 		return nil, err
@@ -80,7 +149,7 @@ func OrgListByTenant(tenantID uint64) ([]protos.Organization, error) {
 	out := make([]protos.Organization, 0)
 	for rows.Next() {
 		var org protos.Organization
-		if err = rows.Scan(&org.ID, &org.TenantID, &org.Name, &org.CreateTime, &org.UpdateTime); err != nil {
+		if err = rows.Scan(&org.ID, &org.TenantID, &org.ParentID, &org.Name, &org.CreateTime, &org.UpdateTime); err != nil {
 			return nil, err
 		}
 		out = append(out, org)
@@ -93,7 +162,7 @@ func OrgListByUser(uid, tenantID uint64) ([]protos.Organization, error) {
 		return nil, common.ErrParam
 	}
 	rows, err := common.DB.Query(context.Background(),
-		`SELECT o.id, o.tenant_id, o.name, o.create_time, o.update_time
+		`SELECT o.id, o.tenant_id, o.parent_id, o.name, o.create_time, o.update_time
 		 FROM organizations o
 		 INNER JOIN org_members m ON m.org_id = o.id
 		 WHERE m.uid = $1 AND o.tenant_id = $2
@@ -106,7 +175,7 @@ func OrgListByUser(uid, tenantID uint64) ([]protos.Organization, error) {
 	out := make([]protos.Organization, 0)
 	for rows.Next() {
 		var org protos.Organization
-		if err = rows.Scan(&org.ID, &org.TenantID, &org.Name, &org.CreateTime, &org.UpdateTime); err != nil {
+		if err = rows.Scan(&org.ID, &org.TenantID, &org.ParentID, &org.Name, &org.CreateTime, &org.UpdateTime); err != nil {
 			return nil, err
 		}
 		out = append(out, org)
@@ -142,6 +211,39 @@ func OrgMemberDelete(orgID, uid uint64) error {
 	return nil
 }
 
+// OrgMemberTransfer 在事务中把用户加入目标组织；move 时删除原组织的成员关系，原组织是默认组织的话目标组织接替为默认组织
+func OrgMemberTransfer(tx database.Tx, tenantID, uid, fromOrgID, toOrgID uint64, move bool) error {
+	if uid == 0 || tenantID == 0 || fromOrgID == 0 || toOrgID == 0 {
+		return common.ErrParam
+	}
+	ctx := context.Background()
+	_, err := tx.Exec(ctx,
+		`INSERT INTO org_members (org_id, uid, tenant_id, create_time) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (org_id, uid) DO NOTHING`,
+		toOrgID, uid, tenantID, time.Now())
+	if err != nil {
+		common.Logger.Sugar().Errorf("OrgMemberTransfer insert ERR: %v %v %v", toOrgID, uid, err)
+		return err
+	}
+	if !move {
+		return nil
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE org_members SET is_default = 1 WHERE org_id = $1 AND uid = $2
+		 AND EXISTS (SELECT 1 FROM org_members WHERE org_id = $3 AND uid = $4 AND is_default = 1)`,
+		toOrgID, uid, fromOrgID, uid)
+	if err != nil {
+		common.Logger.Sugar().Errorf("OrgMemberTransfer default ERR: %v %v %v", toOrgID, uid, err)
+		return err
+	}
+	if _, err = tx.Exec(ctx, `DELETE FROM org_members WHERE org_id = $1 AND uid = $2`, fromOrgID, uid); err != nil {
+		common.Logger.Sugar().Errorf("OrgMemberTransfer delete ERR: %v %v %v", fromOrgID, uid, err)
+		return err
+	}
+	return nil
+}
+
 func OrgMemberDeleteByOrg(orgID uint64) error {
 	if orgID == 0 {
 		return common.ErrParam
@@ -175,7 +277,7 @@ func OrgMemberExists(orgID, uid uint64) (bool, error) {
 	err := common.DB.QueryRow(context.Background(),
 		`SELECT 1 FROM org_members WHERE org_id = $1 AND uid = $2 LIMIT 1`, orgID, uid).Scan(&n)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
 			return false, nil
 		}
 		return false, err
@@ -190,6 +292,37 @@ func OrgMemberCountByUser(uid, tenantID uint64) (int64, error) {
 	return n, err
 }
 
+// OrgMemberSetDefault 把组织设为用户在该租户的默认组织，同租户其他组织取消默认
+func OrgMemberSetDefault(uid, tenantID, orgID uint64) error {
+	if uid == 0 || tenantID == 0 || orgID == 0 {
+		return common.ErrParam
+	}
+	_, err := common.DB.Exec(context.Background(),
+		`UPDATE org_members SET is_default = CASE WHEN org_id = $1 THEN 1 ELSE 0 END WHERE uid = $2 AND tenant_id = $3`,
+		orgID, uid, tenantID)
+	if err != nil {
+		common.Logger.Sugar().Errorf("OrgMemberSetDefault ERR: %v", err)
+		return err
+	}
+	return nil
+}
+
+// OrgMemberDefault 用户在租户内的默认组织；没有设置时取 ID 最小的组织，不属于任何组织时返回 0
+func OrgMemberDefault(uid, tenantID uint64) (uint64, error) {
+	var orgID uint64
+	err := common.DB.QueryRow(context.Background(),
+		`SELECT org_id FROM org_members WHERE uid = $1 AND tenant_id = $2 ORDER BY is_default DESC, org_id LIMIT 1`,
+		uid, tenantID).Scan(&orgID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		common.Logger.Sugar().Errorf("OrgMemberDefault ERR: %v", err)
+		return 0, err
+	}
+	return orgID, nil
+}
+
 func OrgMemberUIDs(orgID uint64) ([]uint64, error) {
 	if orgID == 0 {
 		return nil, common.ErrParam
@@ -217,11 +350,11 @@ func UserQueryByOrg(tenantID, orgID, page, pageSize uint64, nickname string, uid
 	if tenantID == 0 || orgID == 0 {
 		return nil, common.ErrParam
 	}
-	act := sq.Select("u.uid", "u.tenant_id", "u.cellphone", "u.email", "u.nickname", "u.avatar_url", "u.gender", "u.addr", "u.ext", "u.create_time").
+	act := sq.Select("u.uid", "m.tenant_id", "u.cellphone", "u.email", "u.nickname", "u.avatar_url", "u.gender", "u.addr", "u.ext", "u.create_time").
 		From("users u").
 		Join("org_members m ON m.uid = u.uid AND m.org_id = ?", orgID).
 		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).
-		Where(sq.Eq{"u.tenant_id": tenantID})
+		Where(sq.Eq{"m.tenant_id": tenantID})
 	if nickname != "" {
 		act = act.Where(sq.Like{"u.nickname": "%" + nickname + "%"})
 	} else if len(uids) > 0 {
@@ -266,7 +399,7 @@ func UserCountByOrg(tenantID, orgID uint64, nickname string, uids []uint64) (uin
 		From("users u").
 		Join("org_members m ON m.uid = u.uid AND m.org_id = ?", orgID).
 		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).
-		Where(sq.Eq{"u.tenant_id": tenantID})
+		Where(sq.Eq{"m.tenant_id": tenantID})
 	if nickname != "" {
 		act = act.Where(sq.Like{"u.nickname": "%" + nickname + "%"})
 	} else if len(uids) > 0 {
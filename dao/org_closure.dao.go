@@ -0,0 +1,153 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/database"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// OrgClosureInsert 在事务中写入新组织的闭包记录：自身一行，再加上父组织及其所有祖先到它的关系
+func OrgClosureInsert(tx database.Tx, tenantID, parentID, orgID uint64) error {
+	ctx := context.Background()
+	if _, err := tx.Exec(ctx, closureSQL("INSERT INTO org_closure (tenant_id, ancestor_id, descendant_id, depth) VALUES (?, ?, ?, 0)"), tenantID, orgID, orgID); err != nil {
+		common.Logger.Sugar().Errorf("OrgClosureInsert self ERR: %v %v\n", orgID, err)
+		return err
+	}
+	if parentID == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx, closureSQL(`
+		INSERT INTO org_closure (tenant_id, ancestor_id, descendant_id, depth)
+		SELECT tenant_id, ancestor_id, ?, depth + 1 FROM org_closure WHERE descendant_id = ?`), orgID, parentID)
+	if err != nil {
+		common.Logger.Sugar().Errorf("OrgClosureInsert ancestors ERR: %v %v %v\n", parentID, orgID, err)
+		return err
+	}
+	return nil
+}
+
+// OrgClosureMove 在事务中把组织连同下级组织移到 newParentID 之下（0 为顶级），同时更新 parent_id。
+// 调用方先用 OrgClosureDepth 排除移到自身子树下的情况。
+func OrgClosureMove(tx database.Tx, orgID, newParentID uint64) error {
+	ctx := context.Background()
+
+	// 断开子树与原祖先的关系，子树内部关系保持不变
+	_, err := tx.Exec(ctx, closureSQL(`
+		DELETE FROM org_closure
+		WHERE descendant_id IN (SELECT descendant_id FROM org_closure WHERE ancestor_id = ?)
+		  AND ancestor_id NOT IN (SELECT descendant_id FROM org_closure WHERE ancestor_id = ?)`), orgID, orgID)
+	if err != nil {
+		common.Logger.Sugar().Errorf("OrgClosureMove delete ERR: %v %v\n", orgID, err)
+		return err
+	}
+
+	if newParentID > 0 {
+		_, err = tx.Exec(ctx, closureSQL(`
+			INSERT INTO org_closure (tenant_id, ancestor_id, descendant_id, depth)
+			SELECT supertree.tenant_id, supertree.ancestor_id, subtree.descendant_id, supertree.depth + subtree.depth + 1
+			FROM org_closure AS supertree
+			CROSS JOIN org_closure AS subtree
+			WHERE supertree.descendant_id = ? AND subtree.ancestor_id = ?`), newParentID, orgID)
+		if err != nil {
+			common.Logger.Sugar().Errorf("OrgClosureMove insert ERR: %v %v %v\n", orgID, newParentID, err)
+			return err
+		}
+	}
+
+	if _, err = tx.Exec(ctx, closureSQL("UPDATE organizations SET parent_id = ?, update_time = ? WHERE id = ?"), newParentID, time.Now(), orgID); err != nil {
+		common.Logger.Sugar().Errorf("OrgClosureMove parent ERR: %v %v %v\n", orgID, newParentID, err)
+		return err
+	}
+	return nil
+}
+
+// OrgClosureDepth descendantID 在 ancestorID 之下的层级（自身为 0），不在其子树中时返回 -1
+func OrgClosureDepth(ancestorID, descendantID uint64) (int, error) {
+	var depth int
+	err := common.DB.QueryRow(context.Background(),
+		closureSQL("SELECT depth FROM org_closure WHERE ancestor_id = ? AND descendant_id = ?"), ancestorID, descendantID).Scan(&depth)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			return -1, nil
+		}
+		common.Logger.Sugar().Errorf("OrgClosureDepth ERR: %v %v %v\n", ancestorID, descendantID, err)
+		return -1, err
+	}
+	return depth, nil
+}
+
+// OrgClosureAncestors 组织自身及所有上级组织的 ID，近的在前
+func OrgClosureAncestors(orgID uint64) ([]uint64, error) {
+	return orgClosureIDs("SELECT ancestor_id FROM org_closure WHERE descendant_id = ? ORDER BY depth", orgID)
+}
+
+// OrgClosureDescendants 组织自身及所有下级组织的 ID，近的在前
+func OrgClosureDescendants(orgID uint64) ([]uint64, error) {
+	return orgClosureIDs("SELECT descendant_id FROM org_closure WHERE ancestor_id = ? ORDER BY depth, descendant_id", orgID)
+}
+
+func orgClosureIDs(query string, orgID uint64) ([]uint64, error) {
+	rows, err := common.DB.Query(context.Background(), closureSQL(query), orgID)
+	if err != nil {
+		common.Logger.Sugar().Errorf("orgClosureIDs ERR: %v %v\n", orgID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]uint64, 0)
+	for rows.Next() {
+		var id uint64
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// OrgListByAncestor 组织子树（含自身）中的所有组织，按层级排序
+func OrgListByAncestor(orgID uint64) ([]protos.Organization, error) {
+	rows, err := common.DB.Query(context.Background(), closureSQL(`
+		SELECT o.id, o.tenant_id, o.parent_id, o.name, o.create_time, o.update_time
+		FROM organizations o
+		INNER JOIN org_closure c ON c.descendant_id = o.id
+		WHERE c.ancestor_id = ?
+		ORDER BY c.depth, o.id`), orgID)
+	if err != nil {
+		common.Logger.Sugar().Errorf("OrgListByAncestor ERR: %v %v\n", orgID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]protos.Organization, 0)
+	for rows.Next() {
+		var org protos.Organization
+		if err = rows.Scan(&org.ID, &org.TenantID, &org.ParentID, &org.Name, &org.CreateTime, &org.UpdateTime); err != nil {
+			return nil, err
+		}
+		out = append(out, org)
+	}
+	return out, rows.Err()
+}
+
+// OrgChildCount 直接下级组织数
+func OrgChildCount(orgID uint64) (n int64, err error) {
+	err = common.DB.QueryRow(context.Background(), closureSQL("SELECT COUNT(1) FROM organizations WHERE parent_id = ?"), orgID).Scan(&n)
+	return
+}
+
+// OrgClosureDeleteByOrg 删除叶子组织的闭包记录
+func OrgClosureDeleteByOrg(orgID uint64) error {
+	if _, err := common.DB.Exec(context.Background(), closureSQL("DELETE FROM org_closure WHERE descendant_id = ?"), orgID); err != nil {
+		common.Logger.Sugar().Errorf("OrgClosureDeleteByOrg ERR: %v %v\n", orgID, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/database"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// OrgMemberTransferInsert 在事务中写入一条成员调动记录
+func OrgMemberTransferInsert(tx database.Tx, m *protos.OrgMemberTransfer) error {
+	fromRoles, err := json.Marshal(nonNilStrings(m.FromRoles))
+	if err != nil {
+		return err
+	}
+	toRoles, err := json.Marshal(nonNilStrings(m.ToRoles))
+	if err != nil {
+		return err
+	}
+	deps, err := json.Marshal(nonNilUint64s(m.DepIds))
+	if err != nil {
+		return err
+	}
+
+	query, args, err := sq.Insert("org_member_transfers").
+		Columns("tenant_id", "uid", "from_org_id", "to_org_id", "mode", "from_roles", "to_roles", "dep_ids", "operator_uid", "remark", "create_time").
+		Values(m.TenantID, m.UID, m.FromOrgID, m.ToOrgID, m.Mode, string(fromRoles), string(toRoles), string(deps), m.OperatorUID, m.Remark, m.CreateTime).
+		Suffix("RETURNING id").
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return err
+	}
+
+	if err = tx.QueryRow(context.Background(), query, args...).Scan(&m.ID); err != nil {
+		common.Logger.Sugar().Errorf("OrgMemberTransferInsert ERR: %v %v %v => %v %v\n", m.TenantID, m.UID, m.FromOrgID, m.ToOrgID, err)
+		return err
+	}
+	return nil
+}
+
+// OrgMemberTransferList 分页查询租户内调入或调出该组织的记录，最近的在前；orgID 为 0 时查询整个租户
+func OrgMemberTransferList(tenantID, orgID, page, pageSize uint64) ([]protos.OrgMemberTransfer, error) {
+	where := sq.And{sq.Eq{"tenant_id": tenantID}}
+	if orgID > 0 {
+		where = append(where, sq.Or{sq.Eq{"from_org_id": orgID}, sq.Eq{"to_org_id": orgID}})
+	}
+	query, args, err := sq.Select("id", "tenant_id", "uid", "from_org_id", "to_org_id", "mode", "from_roles", "to_roles", "dep_ids", "operator_uid", "remark", "create_time").
+		From("org_member_transfers").
+		Where(where).
+		OrderBy("id DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := common.DB.Query(context.Background(), query, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("OrgMemberTransferList ERR: %v %v %v\n", tenantID, orgID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	rr := []protos.OrgMemberTransfer{}
+	for rows.Next() {
+		var one protos.OrgMemberTransfer
+		var fromRoles, toRoles, deps string
+		if err = rows.Scan(&one.ID, &one.TenantID, &one.UID, &one.FromOrgID, &one.ToOrgID, &one.Mode, &fromRoles, &toRoles, &deps,
+			&one.OperatorUID, &one.Remark, &one.CreateTime); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal([]byte(fromRoles), &one.FromRoles); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal([]byte(toRoles), &one.ToRoles); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal([]byte(deps), &one.DepIds); err != nil {
+			return nil, err
+		}
+		rr = append(rr, one)
+	}
+	return rr, rows.Err()
+}
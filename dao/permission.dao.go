@@ -2,6 +2,7 @@ package dao
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"github.com/liuhengloveyou/passport/v4/common"
@@ -14,6 +15,10 @@ import (
 
 func PermissionCreate(m *protos.PermissionStruct) (id int64, e error) {
 	table := "permission"
+	actions, err := json.Marshal(nonNilStrings(m.Actions))
+	if err != nil {
+		return -1, err
+	}
 
 	// 构建插入数据
 	data := map[string]interface{}{
@@ -21,6 +26,9 @@ func PermissionCreate(m *protos.PermissionStruct) (id int64, e error) {
 		"domain":      m.Domain,
 		"title":       m.Title,
 		"value":       m.Value,
+		"parent_id":   m.ParentID,
+		"type":        m.Type,
+		"actions":     string(actions),
 		"create_time": time.Now(),
 	}
 
@@ -72,17 +80,61 @@ func PermissionDelete(id, tenantID uint64) (int64, error) {
 	return rst.RowsAffected()
 }
 
-func PermissionList(tenantID uint64, domain string) (rr []protos.PermissionStruct, err error) {
-	table := "permission"
+// permissionColumns 显式列出字段，避免老库 ALTER 追加列后 SELECT * 顺序与 Scan 不一致
+var permissionColumns = []string{"id", "tenant_id", "domain", "title", "value", "parent_id", "type", "actions", "create_time", "update_time"}
 
-	// 构建 WHERE 条件
+func PermissionList(tenantID uint64, domain string) (rr []protos.PermissionStruct, err error) {
 	where := sq.Eq{
 		"tenant_id": tenantID,
-		"domain":    domain,
 	}
+	if domain != "" {
+		where["domain"] = domain
+	}
+
+	return permissionQuery(where)
+}
+
+// PermissionGet 按ID查询租户内的权限点，不存在时返回 nil
+func PermissionGet(id, tenantID uint64) (*protos.PermissionStruct, error) {
+	rr, err := permissionQuery(sq.Eq{"id": id, "tenant_id": tenantID})
+	if err != nil {
+		return nil, err
+	}
+	if len(rr) == 0 {
+		return nil, nil
+	}
+	return &rr[0], nil
+}
+
+// PermissionListByValue 租户字典中该权限值的全部条目（不区分 domain）
+func PermissionListByValue(tenantID uint64, value string) ([]protos.PermissionStruct, error) {
+	return permissionQuery(sq.Eq{"tenant_id": tenantID, "value": value})
+}
+
+// PermissionDeleteByIDs 批量删除租户内的权限点
+func PermissionDeleteByIDs(tenantID uint64, ids []uint64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	sql, args, err := sq.Delete("permission").Where(sq.Eq{"tenant_id": tenantID, "id": ids}).PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	common.Logger.Sugar().Debugf("%v %v %v\n", sql, args, err)
+	if err != nil {
+		return 0, err
+	}
+
+	rst, err := common.DB.Exec(context.Background(), sql, args...)
+	if err != nil {
+		return 0, err
+	}
+	return rst.RowsAffected()
+}
+
+func permissionQuery(where sq.Eq) (rr []protos.PermissionStruct, err error) {
+	table := "permission"
 
 	// 使用squirrel构建SQL
-	sql, args, err := sq.Select("*").From(table).Where(where).PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	sql, args, err := sq.Select(permissionColumns...).From(table).Where(where).OrderBy("parent_id", "id").PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
 	common.Logger.Sugar().Debugf("%v %v %v\n", sql, args, err)
 	if err != nil {
 		return nil, err
@@ -100,12 +152,16 @@ func PermissionList(tenantID uint64, domain string) (rr []protos.PermissionStruc
 	// 手动扫描结果到结构体切片
 	for rows.Next() {
 		var p protos.PermissionStruct
+		var actions string
 		err = rows.Scan(
 			&p.ID,
 			&p.TenantID,
 			&p.Domain,
 			&p.Title,
 			&p.Value,
+			&p.ParentID,
+			&p.Type,
+			&actions,
 			&p.CreateTime,
 			&p.UpdateTime,
 		)
@@ -113,6 +169,9 @@ func PermissionList(tenantID uint64, domain string) (rr []protos.PermissionStruc
 			common.Logger.Sugar().Errorf("rows.Scan error: %v\n", err)
 			return nil, err
 		}
+		if err = json.Unmarshal([]byte(actions), &p.Actions); err != nil {
+			return nil, err
+		}
 		rr = append(rr, p)
 	}
 
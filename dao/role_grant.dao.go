@@ -0,0 +1,120 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/database"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+var roleGrantColumns = []string{"id", "tenant_id", "org_id", "uid", "role", "not_before", "expires_at", "active", "create_time"}
+
+// RoleGrantUpsert 写入或覆盖 (tenant, org, uid, role) 的限时授权
+func RoleGrantUpsert(m *protos.RoleGrant) error {
+	active := 0
+	if m.Active {
+		active = 1
+	}
+
+	sql, args, err := sq.Insert("role_grants").
+		Columns("tenant_id", "org_id", "uid", "role", "not_before", "expires_at", "active", "create_time").
+		Values(m.TenantID, m.OrgID, m.UID, m.Role, m.NotBefore, m.ExpiresAt, active, time.Now()).
+		Suffix("ON CONFLICT (tenant_id, org_id, uid, role) DO UPDATE SET not_before = excluded.not_before, expires_at = excluded.expires_at, active = excluded.active").
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	common.Logger.Sugar().Debugf("%v %v %v\n", sql, args, err)
+	if err != nil {
+		return err
+	}
+
+	_, err = common.DB.Exec(context.Background(), sql, args...)
+	return err
+}
+
+// RoleGrantDelete 删除授权记录；role 为空时删除该用户在组织内的全部记录
+func RoleGrantDelete(tenantID, orgID, uid uint64, role string) (int64, error) {
+	where := sq.Eq{"tenant_id": tenantID, "org_id": orgID, "uid": uid}
+	if role != "" {
+		where["role"] = role
+	}
+
+	sql, args, err := sq.Delete("role_grants").Where(where).PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	common.Logger.Sugar().Debugf("%v %v %v\n", sql, args, err)
+	if err != nil {
+		return 0, err
+	}
+
+	rst, err := common.DB.Exec(context.Background(), sql, args...)
+	if err != nil {
+		return 0, err
+	}
+	return rst.RowsAffected()
+}
+
+func RoleGrantDeleteByID(id uint64) error {
+	sql, args, err := sq.Delete("role_grants").Where(sq.Eq{"id": id}).PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = common.DB.Exec(context.Background(), sql, args...)
+	return err
+}
+
+func RoleGrantSetActive(id uint64) error {
+	sql, args, err := sq.Update("role_grants").Set("active", 1).Where(sq.Eq{"id": id}).PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = common.DB.Exec(context.Background(), sql, args...)
+	return err
+}
+
+// RoleGrantListByUser 查询用户在组织内的全部限时授权
+func RoleGrantListByUser(tenantID, orgID, uid uint64) ([]protos.RoleGrant, error) {
+	return roleGrantQuery(sq.Eq{"tenant_id": tenantID, "org_id": orgID, "uid": uid})
+}
+
+// RoleGrantListByTenant 查询租户下全部限时授权（含未生效的）
+func RoleGrantListByTenant(tenantID uint64) ([]protos.RoleGrant, error) {
+	return roleGrantQuery(sq.Eq{"tenant_id": tenantID})
+}
+
+// RoleGrantListDue 查询到期需要处理的授权：已过期的，以及到了生效时间但尚未写入 casbin 的
+func RoleGrantListDue(now time.Time) ([]protos.RoleGrant, error) {
+	return roleGrantQuery(sq.Or{
+		sq.LtOrEq{"expires_at": now},
+		sq.And{sq.Eq{"active": 0}, sq.LtOrEq{"not_before": now}},
+	})
+}
+
+func roleGrantQuery(where sq.Sqlizer) ([]protos.RoleGrant, error) {
+	sql, args, err := sq.Select(roleGrantColumns...).From("role_grants").Where(where).OrderBy("id").PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	common.Logger.Sugar().Debugf("%v %v %v\n", sql, args, err)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := common.DB.Query(context.Background(), sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rr := make([]protos.RoleGrant, 0)
+	for rows.Next() {
+		var m protos.RoleGrant
+		var active int
+		if err = rows.Scan(&m.ID, &m.TenantID, &m.OrgID, &m.UID, &m.Role, &m.NotBefore, &m.ExpiresAt, &active, &m.CreateTime); err != nil {
+			return nil, err
+		}
+		m.Active = active == 1
+		rr = append(rr, m)
+	}
+
+	return rr, rows.Err()
+}
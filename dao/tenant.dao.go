@@ -2,6 +2,7 @@ package dao
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"strings"
 	"time"
@@ -83,7 +84,8 @@ func TenantInsert(tx database.Tx, m *protos.Tenant) (tenantID uint64, e error) {
 			zap.String("tenant_type", m.TenantType),
 			zap.Uint64("uid", m.UID),
 		)
-		if _, err = tx.Exec(ctx, insertSQL, insertVals...); err != nil {
+		rst, err := tx.Exec(ctx, insertSQL, insertVals...)
+		if err != nil {
 			common.Logger.Sugar().Errorf("Failed to insert tenants: %v", err)
 			common.Logger.Error("TenantInsert insert failed",
 				zap.Error(err),
@@ -93,7 +95,8 @@ func TenantInsert(tx database.Tx, m *protos.Tenant) (tenantID uint64, e error) {
 			)
 			return 0, err
 		}
-		id, idErr := dialect.LastInsertID(ctx, common.DB, "tenants")
+		// 须在同一事务连接上取自增 ID；SQLite3 单连接时走 common.DB 会一直等待事务释放连接
+		id, idErr := rst.LastInsertId()
 		if idErr != nil {
 			common.Logger.Error("TenantInsert last insert id failed",
 				zap.Error(idErr),
@@ -159,6 +162,24 @@ func TenantInsert(tx database.Tx, m *protos.Tenant) (tenantID uint64, e error) {
 		)
 		return 0, common.ErrTenantLimit
 	}
+
+	memberSQL, memberVals, err := sq.Insert("tenant_members").
+		Columns("tenant_id", "uid", "create_time").
+		Values(tenantID, m.UID, time.Now()).
+		Suffix("ON CONFLICT (tenant_id, uid) DO NOTHING").
+		PlaceholderFormat(placeholderFormat).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+	if _, err = tx.Exec(ctx, memberSQL, memberVals...); err != nil {
+		common.Logger.Error("TenantInsert add tenant member failed",
+			zap.Error(err),
+			zap.Uint64("tenant_id", tenantID),
+			zap.Uint64("uid", m.UID),
+		)
+		return 0, err
+	}
 	common.Logger.Info("TenantInsert success",
 		zap.Uint64("tenant_id", tenantID),
 		zap.Uint64("uid", m.UID),
@@ -174,13 +195,13 @@ func TenantNameExists(tenantName string) (exists bool, e error) {
 		return false, nil
 	}
 	ph := database.GetPlaceholderFormat(common.DB.DriverType())
-	sql, args, err := sq.Select("1").From("tenants").Where(sq.Eq{"tenant_name": name}).Limit(1).PlaceholderFormat(ph).ToSql()
+	query, args, err := sq.Select("1").From("tenants").Where(sq.Eq{"tenant_name": name}).Limit(1).PlaceholderFormat(ph).ToSql()
 	if err != nil {
 		return false, err
 	}
 	var one int
-	err = common.DB.QueryRow(context.Background(), sql, args...).Scan(&one)
-	if errors.Is(err, pgx.ErrNoRows) {
+	err = common.DB.QueryRow(context.Background(), query, args...).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
 		return false, nil
 	}
 	if err != nil {
@@ -208,6 +229,11 @@ func TenantGetByID(tenantId uint64) (m *protos.Tenant, e error) {
 		"t.configuration",
 		"t.create_time",
 		"t.update_time",
+		"t.status",
+		"t.status_reason",
+		"t.trial_end_time",
+		"t.delete_time",
+		"t.pre_delete_status",
 	).
 		From(table).
 		LeftJoin("tenant_closure tp ON tp.descendant_id = t.id AND tp.depth = 1").
@@ -240,6 +266,11 @@ func TenantGetByID(tenantId uint64) (m *protos.Tenant, e error) {
 			&t.Configuration,
 			&t.CreateTime,
 			&t.UpdateTime,
+			&t.Status,
+			&t.StatusReason,
+			&t.TrialEndTime,
+			&t.DeleteTime,
+			&t.PreDeleteStatus,
 		)
 		if err != nil {
 			common.Logger.Sugar().Errorf("rows.Scan error: %v\n", err)
@@ -352,6 +383,11 @@ func TenantList(page, pageSize uint64) (rr []protos.Tenant, e error) {
 		"t.configuration",
 		"t.create_time",
 		"t.update_time",
+		"t.status",
+		"t.status_reason",
+		"t.trial_end_time",
+		"t.delete_time",
+		"t.pre_delete_status",
 	).
 		From("tenants t").
 		LeftJoin("tenant_closure tp ON tp.descendant_id = t.id AND tp.depth = 1").
@@ -378,7 +414,7 @@ func TenantList(page, pageSize uint64) (rr []protos.Tenant, e error) {
 	rr = []protos.Tenant{}
 	for rows.Next() {
 		var tenant protos.Tenant
-		err = rows.Scan(&tenant.ID, &tenant.UID, &tenant.ParentID, &tenant.TenantName, &tenant.TenantType, &tenant.Info, &tenant.Configuration, &tenant.CreateTime, &tenant.UpdateTime)
+		err = rows.Scan(&tenant.ID, &tenant.UID, &tenant.ParentID, &tenant.TenantName, &tenant.TenantType, &tenant.Info, &tenant.Configuration, &tenant.CreateTime, &tenant.UpdateTime, &tenant.Status, &tenant.StatusReason, &tenant.TrialEndTime, &tenant.DeleteTime, &tenant.PreDeleteStatus)
 		if err != nil {
 			common.Logger.Sugar().Errorf("Failed to scan row: %v", err)
 			return nil, err
@@ -407,6 +443,11 @@ func TenantListByAncestorID(ancestorID, page, pageSize uint64) (rr []protos.Tena
 		"t.configuration",
 		"t.create_time",
 		"t.update_time",
+		"t.status",
+		"t.status_reason",
+		"t.trial_end_time",
+		"t.delete_time",
+		"t.pre_delete_status",
 		"tc.depth",
 	).
 		From("tenants t").
@@ -436,7 +477,7 @@ func TenantListByAncestorID(ancestorID, page, pageSize uint64) (rr []protos.Tena
 	rr = []protos.Tenant{}
 	for rows.Next() {
 		var tenant protos.Tenant
-		err = rows.Scan(&tenant.ID, &tenant.UID, &tenant.ParentID, &tenant.TenantName, &tenant.TenantType, &tenant.Info, &tenant.Configuration, &tenant.CreateTime, &tenant.UpdateTime, &tenant.Depth)
+		err = rows.Scan(&tenant.ID, &tenant.UID, &tenant.ParentID, &tenant.TenantName, &tenant.TenantType, &tenant.Info, &tenant.Configuration, &tenant.CreateTime, &tenant.UpdateTime, &tenant.Status, &tenant.StatusReason, &tenant.TrialEndTime, &tenant.DeleteTime, &tenant.PreDeleteStatus, &tenant.Depth)
 		if err != nil {
 			common.Logger.Sugar().Errorf("Failed to scan row: %v", err)
 			return nil, err
@@ -465,6 +506,11 @@ func TenantQuery(tenantName, cellphone string, page, pageSize uint64) (rr []prot
 		"t.configuration",
 		"t.create_time",
 		"t.update_time",
+		"t.status",
+		"t.status_reason",
+		"t.trial_end_time",
+		"t.delete_time",
+		"t.pre_delete_status",
 	).
 		From("tenants t").
 		LeftJoin("tenant_closure tp ON tp.descendant_id = t.id AND tp.depth = 1").
@@ -502,7 +548,7 @@ func TenantQuery(tenantName, cellphone string, page, pageSize uint64) (rr []prot
 	rr = []protos.Tenant{}
 	for rows.Next() {
 		var tenant protos.Tenant
-		err = rows.Scan(&tenant.ID, &tenant.UID, &tenant.ParentID, &tenant.TenantName, &tenant.TenantType, &tenant.Info, &tenant.Configuration, &tenant.CreateTime, &tenant.UpdateTime)
+		err = rows.Scan(&tenant.ID, &tenant.UID, &tenant.ParentID, &tenant.TenantName, &tenant.TenantType, &tenant.Info, &tenant.Configuration, &tenant.CreateTime, &tenant.UpdateTime, &tenant.Status, &tenant.StatusReason, &tenant.TrialEndTime, &tenant.DeleteTime, &tenant.PreDeleteStatus)
 		if err != nil {
 			common.Logger.Sugar().Errorf("Failed to scan row: %v", err)
 			return nil, err
@@ -518,41 +564,6 @@ func TenantQuery(tenantName, cellphone string, page, pageSize uint64) (rr []prot
 	return
 }
 
-func TenantUpdateConfiguration(m *protos.Tenant) error {
-	common.Logger.Debug("TenantUpdateConfiguration %v", zap.Any("tenant", m))
-
-	commandTag, err := common.DB.Exec(context.Background(), "UPDATE tenants SET configuration = $1, update_time = NOW() WHERE (id = $2) AND (update_time = $3)", m.Configuration, m.ID, m.UpdateTime)
-	if err != nil {
-		common.Logger.Sugar().Errorf("Failed to update tenant configuration: %v", err)
-		return err
-	}
-
-	// 检查是否有行被更新
-	rowsAffected, err := commandTag.RowsAffected()
-	if err != nil {
-		common.Logger.Sugar().Errorf("Failed to get rows affected: %v", err)
-		return err
-	}
-	if rowsAffected == 0 {
-		// 检查租户是否存在
-		var exists bool
-		err = common.DB.QueryRow(context.Background(), "SELECT EXISTS(SELECT 1 FROM tenants WHERE id = $1)", m.ID).Scan(&exists)
-		if err != nil {
-			common.Logger.Sugar().Errorf("Failed to check tenant existence: %v", err)
-			return err
-		}
-
-		if !exists {
-			return common.ErrTenantNotFound
-		}
-
-		// 租户存在但更新时间不匹配
-		return common.ErrModify
-	}
-
-	return nil
-}
-
 func TenantUpdateBase(m *protos.Tenant) error {
 	common.Logger.Debug("TenantUpdateBase %v", zap.Any("tenant", m))
 	commandTag, err := common.DB.Exec(
@@ -578,8 +589,102 @@ func TenantUpdateBase(m *protos.Tenant) error {
 	return nil
 }
 
+// TenantUpdateStatus 以当前状态 from 为条件更新生命周期状态；并发变更时影响行数为 0。
+func TenantUpdateStatus(tenantID uint64, from, to, reason string, trialEndTime *time.Time) (int64, error) {
+	sql, args, err := sq.Update("tenants").
+		Set("status", to).
+		Set("status_reason", reason).
+		Set("trial_end_time", trialEndTime).
+		Set("update_time", sq.Expr("CURRENT_TIMESTAMP")).
+		Where(sq.Eq{"id": tenantID, "status": from}).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	rst, err := common.DB.Exec(context.Background(), sql, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("TenantUpdateStatus ERR: %v %v\n", tenantID, err)
+		return 0, err
+	}
+	return rst.RowsAffected()
+}
+
+// TenantSoftDelete 以当前状态 from 为条件归档租户并记下删除时间与原状态；已删除或并发变更时影响行数为 0。
+func TenantSoftDelete(tenantID uint64, from, reason string, deleteTime time.Time) (int64, error) {
+	sql, args, err := sq.Update("tenants").
+		Set("status", protos.TenantStatusArchived).
+		Set("status_reason", reason).
+		Set("pre_delete_status", from).
+		Set("delete_time", deleteTime).
+		Set("update_time", sq.Expr("CURRENT_TIMESTAMP")).
+		Where(sq.Eq{"id": tenantID, "status": from, "delete_time": nil}).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	rst, err := common.DB.Exec(context.Background(), sql, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("TenantSoftDelete ERR: %v %v\n", tenantID, err)
+		return 0, err
+	}
+	return rst.RowsAffected()
+}
+
+// TenantRestore 撤销软删除，状态恢复为 to；租户未处于删除状态时影响行数为 0。
+func TenantRestore(tenantID uint64, to, reason string) (int64, error) {
+	sql, args, err := sq.Update("tenants").
+		Set("status", to).
+		Set("status_reason", reason).
+		Set("pre_delete_status", "").
+		Set("delete_time", nil).
+		Set("update_time", sq.Expr("CURRENT_TIMESTAMP")).
+		Where(sq.Eq{"id": tenantID, "status": protos.TenantStatusArchived}).
+		Where(sq.NotEq{"delete_time": nil}).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	rst, err := common.DB.Exec(context.Background(), sql, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("TenantRestore ERR: %v %v\n", tenantID, err)
+		return 0, err
+	}
+	return rst.RowsAffected()
+}
+
+// TenantListDeleted 查询全部软删除的租户，按删除时间排序
+func TenantListDeleted() ([]protos.Tenant, error) {
+	sql, args, err := sq.Select("id", "tenant_name", "delete_time").From("tenants").
+		Where(sq.NotEq{"delete_time": nil}).
+		OrderBy("delete_time").
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := common.DB.Query(context.Background(), sql, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("TenantListDeleted ERR: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rr []protos.Tenant
+	for rows.Next() {
+		var one protos.Tenant
+		if err = rows.Scan(&one.ID, &one.TenantName, &one.DeleteTime); err != nil {
+			return nil, err
+		}
+		rr = append(rr, one)
+	}
+	return rr, rows.Err()
+}
+
 func UserQueryByTenant(tenantID, page, pageSize uint64, nickname string, uids []uint64) (rr []protos.User, e error) {
-	act := sq.Select("uid", "tenant_id", "cellphone", "email", "nickname", "avatar_url", "gender", "addr", "ext", "create_time").From("users").PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).Where(sq.Eq{"tenant_id": tenantID})
+	act := sq.Select("uid", "tenant_id", "cellphone", "email", "nickname", "avatar_url", "gender", "addr", "ext", "create_time").From("users").PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).Where(tenantMemberWhere("users.uid", "users.tenant_id", tenantID))
 	if nickname != "" {
 		act = act.Where(sq.Like{"nickname": "%" + nickname + "%"})
 	} else if len(uids) > 0 {
@@ -624,7 +729,7 @@ func UserQueryByTenant(tenantID, page, pageSize uint64, nickname string, uids []
 }
 
 func UserCountByTenant(tenantID uint64, nickname string, uids []uint64) (r uint64, e error) {
-	act := sq.Select("count(uid) as count").From("users").PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).Where(sq.Eq{"tenant_id": tenantID})
+	act := sq.Select("count(uid) as count").From("users").PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).Where(tenantMemberWhere("users.uid", "users.tenant_id", tenantID))
 	if nickname != "" {
 		act = act.Where(sq.Like{"nickname": "%" + nickname + "%"})
 	} else if len(uids) > 0 {
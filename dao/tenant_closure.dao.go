@@ -28,7 +28,7 @@ func TenantClosureInsert(tx database.Tx, ancestorId, descendantId uint64) error
 	switch driverType {
 	case "postgres":
 		insertSuffix = " ON CONFLICT (ancestor_id, descendant_id) DO NOTHING"
-	case "sqlite":
+	case database.DriverSQLite3:
 		insertPrefix = "INSERT OR IGNORE INTO tenant_closure"
 	default:
 		// mysql/mariadb 等数据库使用 INSERT IGNORE 保证幂等
@@ -90,3 +90,35 @@ func TenantClosureIsDescendant(ancestorID, descendantID uint64) (int, error) {
 	}
 	return depth, nil
 }
+
+// TenantTreeNode 租户树节点；ParentID 取 depth=1 的直接父节点，0 表示根（多个父节点时取 ID 最小的）
+type TenantTreeNode struct {
+	ID       uint64
+	Name     string
+	ParentID uint64
+}
+
+// TenantTreeNodes 全部租户及其直接父节点，按 ID 排序
+func TenantTreeNodes(tx database.Tx) (rr []TenantTreeNode, e error) {
+	rows, e := tx.Query(context.Background(), `
+		SELECT t.id, t.tenant_name, COALESCE(MIN(c.ancestor_id), 0)
+		FROM tenants t
+		LEFT JOIN tenant_closure c ON c.descendant_id = t.id AND c.depth = 1
+		GROUP BY t.id, t.tenant_name
+		ORDER BY t.id`)
+	if e != nil {
+		common.Logger.Sugar().Errorf("TenantTreeNodes ERR: %v\n", e)
+		return nil, e
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var one TenantTreeNode
+		if e = rows.Scan(&one.ID, &one.Name, &one.ParentID); e != nil {
+			common.Logger.Sugar().Errorf("TenantTreeNodes scan ERR: %v\n", e)
+			return nil, e
+		}
+		rr = append(rr, one)
+	}
+	return rr, rows.Err()
+}
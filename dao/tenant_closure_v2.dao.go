@@ -3,12 +3,13 @@ package dao
 import (
 	"context"
 	"fmt"
+	"sort"
 
-	"github.com/jackc/pgx/v5"
 	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/database"
 )
 
-// SQL常量定义，提高代码可维护性
+// SQL常量定义，提高代码可维护性；统一用 ? 占位，执行前经 closureSQL 按方言转换
 const (
 	sqlCheckCircularReference = `
 		SELECT a.ancestor_id, a.descendant_id
@@ -16,10 +17,19 @@ const (
 		JOIN tenant_closure b ON a.ancestor_id = b.descendant_id AND a.descendant_id = b.ancestor_id
 		WHERE a.ancestor_id != a.descendant_id
 	`
-	sqlCheckDirectRelation  = `SELECT EXISTS(SELECT 1 FROM tenant_closure WHERE ancestor_id = $1 AND descendant_id = $2 AND depth = 1)`
-	sqlInsertDirectRelation = `INSERT INTO tenant_closure (ancestor_id, descendant_id, depth) VALUES ($1, $2, 1) ON CONFLICT (ancestor_id, descendant_id) DO UPDATE SET depth = 1 WHERE tenant_closure.depth > 1`
+	sqlCheckDirectRelation  = `SELECT COUNT(*) FROM tenant_closure WHERE ancestor_id = ? AND descendant_id = ? AND depth = 1`
+	sqlInsertDirectRelation = `INSERT INTO tenant_closure (ancestor_id, descendant_id, depth) VALUES (?, ?, 1) ON CONFLICT (ancestor_id, descendant_id) DO UPDATE SET depth = 1 WHERE tenant_closure.depth > 1`
 )
 
+// closureSQL 把 ? 占位符转换为当前数据库的格式（PostgreSQL 为 $n）
+func closureSQL(sql string) string {
+	rst, err := database.GetPlaceholderFormat(common.DB.DriverType()).ReplacePlaceholders(sql)
+	if err != nil {
+		return sql
+	}
+	return rst
+}
+
 // CircularReferenceError 循环引用错误类型
 type CircularReferenceError struct {
 	TenantID, DescendantID uint64
@@ -32,7 +42,7 @@ func (e CircularReferenceError) Error() string {
 // DetectCircularReference 检测租户树中的循环引用
 // 返回包含循环引用的节点ID列表，如果没有循环引用则返回空列表
 // 注意：由于tenants表中已经没有parent_id字段，现在使用tenant_closure表中的depth=1关系来检测循环引用
-func DetectCircularReference(tx pgx.Tx) ([]uint64, error) {
+func DetectCircularReference(tx database.Tx) ([]uint64, error) {
 	// 使用更简单的方法检测循环引用：如果A是B的祖先，同时B也是A的祖先，则存在循环引用
 	rows, err := tx.Query(context.Background(), sqlCheckCircularReference)
 	if err != nil {
@@ -62,6 +72,9 @@ func DetectCircularReference(tx pgx.Tx) ([]uint64, error) {
 		common.Logger.Sugar().Warnf("Circular reference detected: %d is both ancestor and descendant of %d",
 			ancestorID, descendantID)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read circular references: %w", err)
+	}
 
 	if len(circularNodes) > 0 {
 		common.Logger.Sugar().Warnf("Detected circular references involving nodes: %v", circularNodes)
@@ -70,13 +83,14 @@ func DetectCircularReference(tx pgx.Tx) ([]uint64, error) {
 	return circularNodes, nil
 }
 
-// TenantClosureUpdateSubtree 更新整个子树的层级关系 - 优化版closure table实现
+// TenantClosureUpdateSubtree 更新整个子树的层级关系 - closure table 标准移动算法
 // 算法步骤：
-// 1. 删除移动子树与其当前所有祖先的关系（不包括自身和新父节点及其祖先）
-// 2. 为移动子树中每个节点与新父节点的所有祖先建立新关系
-// 特殊情况：
-// - 当节点已经是目标父节点的子节点时，保留这种关系
-func TenantClosureUpdateSubtree(tx pgx.Tx, tenantID, newParentID uint64, debug bool) error {
+// 1. 删除子树内所有节点与子树外祖先的关系（子树内部关系保持不变）
+// 2. 为子树中每个节点与新父节点及其所有祖先建立新关系
+// newParentID 为 0 时只做第 1 步，子树成为独立的根
+func TenantClosureUpdateSubtree(tx database.Tx, tenantID, newParentID uint64, debug bool) error {
+	ctx := context.Background()
+
 	// 参数验证
 	if tenantID == 0 {
 		return fmt.Errorf("invalid tenant ID: cannot be zero")
@@ -85,8 +99,8 @@ func TenantClosureUpdateSubtree(tx pgx.Tx, tenantID, newParentID uint64, debug b
 	// 检查循环引用
 	if newParentID > 0 {
 		var count int
-		err := tx.QueryRow(context.Background(),
-			"SELECT COUNT(*) FROM tenant_closure WHERE ancestor_id = $1 AND descendant_id = $2",
+		err := tx.QueryRow(ctx,
+			closureSQL("SELECT COUNT(*) FROM tenant_closure WHERE ancestor_id = ? AND descendant_id = ?"),
 			tenantID, newParentID).Scan(&count)
 		if err != nil {
 			return fmt.Errorf("failed to check circular reference: %w", err)
@@ -97,128 +111,69 @@ func TenantClosureUpdateSubtree(tx pgx.Tx, tenantID, newParentID uint64, debug b
 	}
 
 	// 记录操作前的状态（如果开启调试模式）
-	if debug && newParentID > 0 {
-		var beforeCount, existingRelation int
-		tx.QueryRow(context.Background(), "SELECT COUNT(*) FROM tenant_closure WHERE descendant_id = $1", tenantID).Scan(&beforeCount)
-		tx.QueryRow(context.Background(), "SELECT COUNT(*) FROM tenant_closure WHERE ancestor_id = $1 AND descendant_id = $2", newParentID, tenantID).Scan(&existingRelation)
-		common.Logger.Sugar().Infof("Before move: tenant %d has %d ancestor relations, relation with %d exists: %d",
-			tenantID, beforeCount, newParentID, existingRelation)
+	if debug {
+		var beforeCount int
+		tx.QueryRow(ctx, closureSQL("SELECT COUNT(*) FROM tenant_closure WHERE descendant_id = ?"), tenantID).Scan(&beforeCount)
+		common.Logger.Sugar().Infof("Before move: tenant %d has %d ancestor relations, new parent %d", tenantID, beforeCount, newParentID)
 	}
 
-	// 步骤1: 使用CTE优化删除操作
+	// 步骤1: 断开子树与原祖先的关系
 	deleteSQL := `
-		WITH subtree AS (
-		  SELECT descendant_id FROM tenant_closure WHERE ancestor_id = $1
-		),
-		supertree AS (
-		  SELECT ancestor_id FROM tenant_closure WHERE descendant_id = $2
-		)
-		DELETE FROM tenant_closure 
-		WHERE descendant_id IN (SELECT * FROM subtree)
-		  AND ancestor_id NOT IN (SELECT * FROM subtree)
-		  AND ancestor_id != $2
-		  AND ancestor_id NOT IN (SELECT * FROM supertree)
-		RETURNING ancestor_id, descendant_id
+		DELETE FROM tenant_closure
+		WHERE descendant_id IN (SELECT descendant_id FROM tenant_closure WHERE ancestor_id = ?)
+		  AND ancestor_id NOT IN (SELECT descendant_id FROM tenant_closure WHERE ancestor_id = ?)
 	`
-
-	// 删除旧的直接父子关系（深度为1），但保留新父节点的关系
-	deleteOldParentSQL := `
-		DELETE FROM tenant_closure 
-		WHERE descendant_id = $1 
-		AND depth = 1 
-		AND ancestor_id != $2
-	`
-
-	deleteResult, err := tx.Exec(context.Background(), deleteSQL, tenantID, newParentID)
+	deleteResult, err := tx.Exec(ctx, closureSQL(deleteSQL), tenantID, tenantID)
 	if err != nil {
 		return fmt.Errorf("failed to delete old relations: %w", err)
 	}
 
-	deleteParentResult, err := tx.Exec(context.Background(), deleteOldParentSQL, tenantID, newParentID)
-	if err != nil {
-		return fmt.Errorf("failed to delete old parent relations: %w", err)
-	}
-
 	if debug {
-		common.Logger.Sugar().Infof("Deleted %d old relations and %d direct parent relations for tenant %d",
-			deleteResult.RowsAffected(), deleteParentResult.RowsAffected(), tenantID)
+		deleted, _ := deleteResult.RowsAffected()
+		common.Logger.Sugar().Infof("Deleted %d old relations for subtree %d", deleted, tenantID)
 	}
 
 	// 步骤2: 为移动子树中每个节点与新父节点的所有祖先建立新关系
 	if newParentID > 0 {
-		// 优化：合并多个计数查询为一个（如果开启调试模式）
-		if debug {
-			var supertreeCount, subtreeCount, directRelationCount int
-			err := tx.QueryRow(context.Background(), `
-				SELECT 
-					(SELECT COUNT(*) FROM tenant_closure WHERE descendant_id = $1),
-					(SELECT COUNT(*) FROM tenant_closure WHERE ancestor_id = $2),
-					(SELECT COUNT(*) FROM tenant_closure WHERE ancestor_id = $1 AND descendant_id = $2)
-			`, newParentID, tenantID).Scan(&supertreeCount, &subtreeCount, &directRelationCount)
-
-			if err == nil {
-				common.Logger.Sugar().Infof("Before INSERT: supertree (parent %d) has %d ancestors, subtree (tenant %d) has %d descendants, direct relation exists: %d",
-					newParentID, supertreeCount, tenantID, subtreeCount, directRelationCount)
-			}
-		}
-
 		insertSQL := `
 			INSERT INTO tenant_closure (ancestor_id, descendant_id, depth)
 			SELECT supertree.ancestor_id, subtree.descendant_id, supertree.depth + subtree.depth + 1
 			FROM tenant_closure AS supertree
 			CROSS JOIN tenant_closure AS subtree
-			WHERE supertree.descendant_id = $2
-			AND subtree.ancestor_id = $1
-			ON CONFLICT (ancestor_id, descendant_id) DO UPDATE 
-			SET depth = EXCLUDED.depth
-			WHERE tenant_closure.depth > EXCLUDED.depth
+			WHERE supertree.descendant_id = ?
+			AND subtree.ancestor_id = ?
+			ON CONFLICT (ancestor_id, descendant_id) DO UPDATE
+			SET depth = excluded.depth
 		`
-		insertResult, err := tx.Exec(context.Background(), insertSQL, tenantID, newParentID)
+		insertResult, err := tx.Exec(ctx, closureSQL(insertSQL), newParentID, tenantID)
 		if err != nil {
 			return fmt.Errorf("failed to insert new relations: %w", err)
 		}
 
 		if debug {
-			common.Logger.Sugar().Infof("Inserted %d new relations for subtree %d with parent %d",
-				insertResult.RowsAffected(), tenantID, newParentID)
+			inserted, _ := insertResult.RowsAffected()
+			common.Logger.Sugar().Infof("Inserted %d new relations for subtree %d with parent %d", inserted, tenantID, newParentID)
 		}
 
-		// 验证直接父子关系是否建立，如果没有则手动添加
-		var directRelationExists bool
-		err = tx.QueryRow(context.Background(), sqlCheckDirectRelation, newParentID, tenantID).Scan(&directRelationExists)
-		if err != nil {
+		// 新父节点缺少自引用行时 CROSS JOIN 不会产生直接关系，这里兜底
+		var directRelation int
+		if err = tx.QueryRow(ctx, closureSQL(sqlCheckDirectRelation), newParentID, tenantID).Scan(&directRelation); err != nil {
 			return fmt.Errorf("failed to verify direct parent relation: %w", err)
 		}
-
-		if !directRelationExists {
-			if debug {
-				common.Logger.Sugar().Warnf("Direct parent relation not established, manually adding it")
-			}
-			_, err = tx.Exec(context.Background(), sqlInsertDirectRelation, newParentID, tenantID)
-			if err != nil {
+		if directRelation == 0 {
+			common.Logger.Sugar().Warnf("Direct parent relation not established, manually adding it: %d => %d", newParentID, tenantID)
+			if _, err = tx.Exec(ctx, closureSQL(sqlInsertDirectRelation), newParentID, tenantID); err != nil {
 				return fmt.Errorf("failed to manually add direct parent relation: %w", err)
 			}
-			if debug {
-				common.Logger.Sugar().Infof("Manually added direct parent relation between %d and %d", newParentID, tenantID)
-			}
 		}
 	}
 
 	// 最终验证（如果开启调试模式）
 	if debug {
 		var finalAncestorCount int
-		tx.QueryRow(context.Background(), "SELECT COUNT(*) FROM tenant_closure WHERE descendant_id = $1", tenantID).Scan(&finalAncestorCount)
-		common.Logger.Sugar().Infof("Final state: tenant %d has %d ancestor relations", tenantID, finalAncestorCount)
-
-		if newParentID > 0 {
-			var finalDirectRelation bool
-			tx.QueryRow(context.Background(), sqlCheckDirectRelation, newParentID, tenantID).Scan(&finalDirectRelation)
-			common.Logger.Sugar().Infof("Final verification: direct relation between %d and %d exists: %v",
-				newParentID, tenantID, finalDirectRelation)
-		}
-
-		common.Logger.Sugar().Infof("Successfully moved subtree %d to parent %d using optimized closure table algorithm",
-			tenantID, newParentID)
+		tx.QueryRow(ctx, closureSQL("SELECT COUNT(*) FROM tenant_closure WHERE descendant_id = ?"), tenantID).Scan(&finalAncestorCount)
+		common.Logger.Sugar().Infof("Successfully moved subtree %d to parent %d, tenant now has %d ancestor relations",
+			tenantID, newParentID, finalAncestorCount)
 	}
 
 	return nil
@@ -226,122 +181,220 @@ func TenantClosureUpdateSubtree(tx pgx.Tx, tenantID, newParentID uint64, debug b
 
 // TenantClosureUpdateSubtreeV2 原始函数的兼容包装
 // 保留此函数以兼容现有代码，内部调用优化后的TenantClosureUpdateSubtree函数
-func TenantClosureUpdateSubtreeV2(tx pgx.Tx, tenantID, newParentID uint64) error {
+func TenantClosureUpdateSubtreeV2(tx database.Tx, tenantID, newParentID uint64) error {
 	return TenantClosureUpdateSubtree(tx, tenantID, newParentID, true)
 }
 
-// 辅助函数：执行SQL并处理错误
-func execSQL(tx pgx.Tx, sql string, args ...interface{}) error {
-	_, err := tx.Exec(context.Background(), sql, args...)
-	if err != nil {
-		return fmt.Errorf("failed to execute SQL: %w", err)
-	}
-	return nil
+// TenantClosureReport 闭包表检查结果
+type TenantClosureReport struct {
+	Tenants      int        `json:"tenants"`      // 租户数
+	Rows         int        `json:"rows"`         // 检查前的闭包行数
+	Missing      int        `json:"missing"`      // 缺失需补齐的行
+	Extra        int        `json:"extra"`        // 多余需删除的行（含指向已删除租户的行）
+	WrongDepth   int        `json:"wrongDepth"`   // depth 不正确的行
+	MultiParents []uint64   `json:"multiParents"` // 有多个直接父节点的租户，只保留 ID 最小的父节点
+	Cycles       [][]uint64 `json:"cycles"`       // 直接父子关系中的环，环上 ID 最小（或根租户）的节点被断开为根
 }
 
-// CheckAndRepairTenantClosureTable 检查并修复整个租户闭包表的数据一致性
-// 这是一个系统维护函数，可以用于修复数据库中可能存在的不一致问题
-func CheckAndRepairTenantClosureTable(tx pgx.Tx) error {
-	common.Logger.Sugar().Info("Starting full tenant closure table consistency check")
+// OK 闭包表与直接父子关系完全一致
+func (r *TenantClosureReport) OK() bool {
+	return r.Missing == 0 && r.Extra == 0 && r.WrongDepth == 0 && len(r.MultiParents) == 0 && len(r.Cycles) == 0
+}
 
-	// 1. 确保所有节点都有自引用关系（depth=0）
-	err := execSQL(tx, `
-		INSERT INTO tenant_closure (ancestor_id, descendant_id, depth)
-		SELECT id, id, 0 FROM tenants
-		ON CONFLICT (ancestor_id, descendant_id) DO NOTHING
-	`)
+type closureKey struct {
+	ancestor, descendant uint64
+}
+
+// TenantClosureRepair 以 depth=1 的直接父子关系为准重建闭包表并与现有数据比对；dryRun 时只返回差异不写库。
+func TenantClosureRepair(tx database.Tx, dryRun bool) (*TenantClosureReport, error) {
+	ctx := context.Background()
+	rst := &TenantClosureReport{}
+
+	tenants := make(map[uint64]bool)
+	rows, err := tx.Query(ctx, "SELECT id FROM tenants")
 	if err != nil {
-		return fmt.Errorf("failed to ensure self-references: %w", err)
+		return nil, fmt.Errorf("failed to query tenants: %w", err)
 	}
+	for rows.Next() {
+		var id uint64
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan tenant: %w", err)
+		}
+		tenants[id] = true
+	}
+	rows.Close()
+	rst.Tenants = len(tenants)
 
-	// 2. 确保所有直接父子关系存在（depth=1）
-	// 注意：由于tenants表中已经没有parent_id字段，这一步现在依赖于tenant_closure表中已有的关系
-	// 我们只需确保depth=1的关系正确，不再从tenants表中读取parent_id
-	common.Logger.Sugar().Info("Skipping direct parent-child relation check as parent_id field no longer exists in tenants table")
+	existing := make(map[closureKey]int)
+	parents := make(map[uint64][]uint64)
+	if rows, err = tx.Query(ctx, "SELECT ancestor_id, descendant_id, depth FROM tenant_closure"); err != nil {
+		return nil, fmt.Errorf("failed to query tenant closure: %w", err)
+	}
+	for rows.Next() {
+		var k closureKey
+		var depth int
+		if err = rows.Scan(&k.ancestor, &k.descendant, &depth); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan tenant closure: %w", err)
+		}
+		existing[k] = depth
+		if depth == 1 && k.ancestor != k.descendant && tenants[k.ancestor] && tenants[k.descendant] {
+			parents[k.descendant] = append(parents[k.descendant], k.ancestor)
+		}
+	}
+	rows.Close()
+	rst.Rows = len(existing)
+
+	// 每个租户只保留一个直接父节点
+	parent := make(map[uint64]uint64, len(parents))
+	for id, ps := range parents {
+		sort.Slice(ps, func(i, j int) bool { return ps[i] < ps[j] })
+		if len(ps) > 1 {
+			rst.MultiParents = append(rst.MultiParents, id)
+		}
+		parent[id] = ps[0]
+	}
+	sort.Slice(rst.MultiParents, func(i, j int) bool { return rst.MultiParents[i] < rst.MultiParents[j] })
 
-	// 3. 确保传递关系完整（如果A是B的祖先，B是C的祖先，则A也是C的祖先）
-	// 使用批处理优化多个SQL操作
-	batch := &pgx.Batch{}
+	// 断开环：沿父链走，回到本链上已访问的节点即为环
+	ids := make([]uint64, 0, len(tenants))
+	for id := range tenants {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	done := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		path := make(map[uint64]int)
+		chain := []uint64{}
+		for n := id; !done[n]; {
+			if i, ok := path[n]; ok {
+				cycle := append([]uint64(nil), chain[i:]...)
+				cut := cycle[0]
+				for _, c := range cycle {
+					if c == common.ServConfig.RootTenantID {
+						cut = c
+						break
+					}
+					if c < cut {
+						cut = c
+					}
+				}
+				sort.Slice(cycle, func(i, j int) bool { return cycle[i] < cycle[j] })
+				rst.Cycles = append(rst.Cycles, cycle)
+				delete(parent, cut)
+				break
+			}
+			path[n] = len(chain)
+			chain = append(chain, n)
+			p, ok := parent[n]
+			if !ok {
+				break
+			}
+			n = p
+		}
+		for _, n := range chain {
+			done[n] = true
+		}
+	}
 
-	// 添加传递关系SQL到批处理
-	batch.Queue(`
-		INSERT INTO tenant_closure (ancestor_id, descendant_id, depth)
-		SELECT a.ancestor_id, b.descendant_id, a.depth + b.depth
-		FROM tenant_closure a
-		JOIN tenant_closure b ON a.descendant_id = b.ancestor_id
-		WHERE a.ancestor_id != b.descendant_id
-		ON CONFLICT (ancestor_id, descendant_id) DO NOTHING
-	`)
-
-	// 添加更新depth值SQL到批处理
-	batch.Queue(`
-		UPDATE tenant_closure c
-		SET depth = (
-			SELECT MIN(a.depth + b.depth)
-			FROM tenant_closure a
-			JOIN tenant_closure b ON a.descendant_id = b.ancestor_id
-			WHERE a.ancestor_id = c.ancestor_id AND b.descendant_id = c.descendant_id
-		)
-		WHERE EXISTS (
-			SELECT 1
-			FROM tenant_closure a
-			JOIN tenant_closure b ON a.descendant_id = b.ancestor_id
-			WHERE a.ancestor_id = c.ancestor_id AND b.descendant_id = c.descendant_id
-			AND a.depth + b.depth < c.depth
-		)
-	`)
-
-	// 执行批处理
-	results := tx.SendBatch(context.Background(), batch)
-	defer results.Close()
-
-	// 检查第一个SQL的结果
-	_, err = results.Exec()
-	if err != nil {
-		return fmt.Errorf("failed to ensure transitive relations: %w", err)
+	// 期望的闭包：每个租户沿父链到根
+	expected := make(map[closureKey]int)
+	for _, id := range ids {
+		depth := 0
+		for n := id; ; depth++ {
+			expected[closureKey{n, id}] = depth
+			p, ok := parent[n]
+			if !ok {
+				break
+			}
+			n = p
+		}
+	}
+
+	var inserts, deletes, updates []closureKey
+	for k, depth := range expected {
+		if d, ok := existing[k]; !ok {
+			inserts = append(inserts, k)
+		} else if d != depth {
+			updates = append(updates, k)
+		}
+	}
+	for k := range existing {
+		if _, ok := expected[k]; !ok {
+			deletes = append(deletes, k)
+		}
+	}
+	rst.Missing, rst.Extra, rst.WrongDepth = len(inserts), len(deletes), len(updates)
+	if dryRun || rst.OK() {
+		return rst, nil
 	}
 
-	// 检查第二个SQL的结果
-	_, err = results.Exec()
+	for _, k := range deletes {
+		if _, err = tx.Exec(ctx, closureSQL("DELETE FROM tenant_closure WHERE ancestor_id = ? AND descendant_id = ?"), k.ancestor, k.descendant); err != nil {
+			return nil, fmt.Errorf("failed to delete closure %d => %d: %w", k.ancestor, k.descendant, err)
+		}
+	}
+	for _, k := range updates {
+		if _, err = tx.Exec(ctx, closureSQL("UPDATE tenant_closure SET depth = ? WHERE ancestor_id = ? AND descendant_id = ?"), expected[k], k.ancestor, k.descendant); err != nil {
+			return nil, fmt.Errorf("failed to update closure %d => %d: %w", k.ancestor, k.descendant, err)
+		}
+	}
+	for _, k := range inserts {
+		if _, err = tx.Exec(ctx, closureSQL("INSERT INTO tenant_closure (ancestor_id, descendant_id, depth) VALUES (?, ?, ?)"), k.ancestor, k.descendant, expected[k]); err != nil {
+			return nil, fmt.Errorf("failed to insert closure %d => %d: %w", k.ancestor, k.descendant, err)
+		}
+	}
+
+	return rst, nil
+}
+
+// CheckAndRepairTenantClosureTable 检查并修复整个租户闭包表的数据一致性
+// 这是一个系统维护函数，可以用于修复数据库中可能存在的不一致问题
+func CheckAndRepairTenantClosureTable(tx database.Tx) error {
+	common.Logger.Sugar().Info("Starting full tenant closure table consistency check")
+
+	rst, err := TenantClosureRepair(tx, false)
 	if err != nil {
-		return fmt.Errorf("failed to update depth values: %w", err)
+		return err
 	}
 
-	common.Logger.Sugar().Info("Tenant closure table consistency check and repair completed successfully")
+	common.Logger.Sugar().Infof("Tenant closure table consistency check and repair completed: %+v", *rst)
 	return nil
 }
 
 // EnsureTenantClosureConsistency 确保特定租户与其父节点的闭包关系一致性
 // 检查并修复可能的数据问题，确保所有必要的关系都存在
-func EnsureTenantClosureConsistency(tx pgx.Tx, tenantID, parentID uint64) error {
+func EnsureTenantClosureConsistency(tx database.Tx, tenantID, parentID uint64) error {
 	if tenantID == 0 || parentID == 0 {
 		return nil // 根节点或无效ID，不需要检查
 	}
+	ctx := context.Background()
 
 	// 检查直接父子关系
-	var directRelationExists bool
-	err := tx.QueryRow(context.Background(), sqlCheckDirectRelation, parentID, tenantID).Scan(&directRelationExists)
+	var directRelation int
+	err := tx.QueryRow(ctx, closureSQL(sqlCheckDirectRelation), parentID, tenantID).Scan(&directRelation)
 	if err != nil {
 		return fmt.Errorf("failed to check direct relation: %w", err)
 	}
 
 	// 如果直接关系不存在，添加它
-	if !directRelationExists {
+	if directRelation == 0 {
 		common.Logger.Sugar().Warnf("Consistency check: Missing direct relation between %d and %d, adding it", parentID, tenantID)
-		_, err = tx.Exec(context.Background(), sqlInsertDirectRelation, parentID, tenantID)
+		_, err = tx.Exec(ctx, closureSQL(sqlInsertDirectRelation), parentID, tenantID)
 		if err != nil {
 			return fmt.Errorf("failed to add direct relation: %w", err)
 		}
 	}
 
 	// 检查父节点的所有祖先与子节点的关系
-	_, err = tx.Exec(context.Background(), `
+	_, err = tx.Exec(ctx, closureSQL(`
 		INSERT INTO tenant_closure (ancestor_id, descendant_id, depth)
-		SELECT a.ancestor_id, $2, a.depth + 1
+		SELECT a.ancestor_id, ?, a.depth + 1
 		FROM tenant_closure a
-		WHERE a.descendant_id = $1
+		WHERE a.descendant_id = ?
 		ON CONFLICT (ancestor_id, descendant_id) DO NOTHING
-	`, parentID, tenantID)
+	`), tenantID, parentID)
 	if err != nil {
 		return fmt.Errorf("failed to ensure ancestor relations: %w", err)
 	}
@@ -351,8 +404,8 @@ func EnsureTenantClosureConsistency(tx pgx.Tx, tenantID, parentID uint64) error
 }
 
 // TenantClosureUpdateSubtreeV2Safe 安全版本，增加了额外的检查和日志
-func TenantClosureUpdateSubtreeV2Safe(tx pgx.Tx, tenantID, newParentID uint64) error {
-	// 执行移动操作（使用优化版本，开启调试模式）
+func TenantClosureUpdateSubtreeV2Safe(tx database.Tx, tenantID, newParentID uint64) error {
+	// 执行移动操作（开启调试模式）
 	err := TenantClosureUpdateSubtree(tx, tenantID, newParentID, true)
 	if err != nil {
 		return err
@@ -366,12 +419,12 @@ func TenantClosureUpdateSubtreeV2Safe(tx pgx.Tx, tenantID, newParentID uint64) e
 		}
 
 		// 验证新的父子关系
-		var directRelationExists bool
-		err = tx.QueryRow(context.Background(), sqlCheckDirectRelation, newParentID, tenantID).Scan(&directRelationExists)
+		var directRelation int
+		err = tx.QueryRow(context.Background(), closureSQL(sqlCheckDirectRelation), newParentID, tenantID).Scan(&directRelation)
 		if err != nil {
 			return fmt.Errorf("failed to verify new parent relation: %w", err)
 		}
-		if !directRelationExists {
+		if directRelation == 0 {
 			return fmt.Errorf("failed to establish direct parent relation between %d and %d", newParentID, tenantID)
 		}
 		common.Logger.Sugar().Infof("Verified: tenant %d is now direct child of %d", tenantID, newParentID)
@@ -0,0 +1,264 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/database"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// 测试用租户树：
+//
+//	1
+//	├── 2
+//	│   ├── 4
+//	│   │   └── 6
+//	│   └── 5
+//	└── 3
+//	    └── 7
+var closureTestParents = map[uint64]uint64{2: 1, 3: 1, 4: 2, 5: 2, 6: 4, 7: 3}
+
+func initClosureTest(t *testing.T) {
+	t.Helper()
+	common.Logger = zap.NewNop()
+	dsn := filepath.Join(t.TempDir(), "passport.db")
+	if err := Init(&protos.OptionStruct{DBDriver: string(database.DriverSQLite3), DBDSN: dsn}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	db, err := database.NewSQLite3DB(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	common.DB = db
+
+	ctx := context.Background()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id := uint64(1); id <= 7; id++ {
+		if _, err = tx.Exec(ctx, "INSERT INTO tenants (id, tenant_name) VALUES (?, ?)", id, fmt.Sprintf("t%d", id)); err != nil {
+			t.Fatal(err)
+		}
+		if parent, ok := closureTestParents[id]; ok {
+			err = TenantClosureInsert(tx, parent, id)
+		} else {
+			_, err = tx.Exec(ctx, "INSERT INTO tenant_closure (ancestor_id, descendant_id, depth) VALUES (?, ?, 0)", id, id)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func closureRows(t *testing.T) map[closureKey]int {
+	t.Helper()
+	rows, err := common.DB.Query(context.Background(), "SELECT ancestor_id, descendant_id, depth FROM tenant_closure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	rst := make(map[closureKey]int)
+	for rows.Next() {
+		var k closureKey
+		var depth int
+		if err = rows.Scan(&k.ancestor, &k.descendant, &depth); err != nil {
+			t.Fatal(err)
+		}
+		rst[k] = depth
+	}
+	return rst
+}
+
+// expectedClosure 由直接父节点推出完整闭包
+func expectedClosure(parents map[uint64]uint64) map[closureKey]int {
+	rst := make(map[closureKey]int)
+	for id := uint64(1); id <= 7; id++ {
+		depth := 0
+		for n := id; ; depth++ {
+			rst[closureKey{n, id}] = depth
+			p, ok := parents[n]
+			if !ok {
+				break
+			}
+			n = p
+		}
+	}
+	return rst
+}
+
+func withParents(changes map[uint64]uint64) map[uint64]uint64 {
+	rst := make(map[uint64]uint64, len(closureTestParents))
+	for k, v := range closureTestParents {
+		rst[k] = v
+	}
+	for k, v := range changes {
+		if v == 0 {
+			delete(rst, k)
+		} else {
+			rst[k] = v
+		}
+	}
+	return rst
+}
+
+func inTx(t *testing.T, fn func(tx database.Tx) error) error {
+	t.Helper()
+	ctx := context.Background()
+	tx, err := common.DB.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = fn(tx); err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func TestTenantClosureUpdateSubtree(t *testing.T) {
+	cases := []struct {
+		name      string
+		tenant    uint64
+		newParent uint64
+		circular  bool
+		parents   map[uint64]uint64
+	}{
+		{name: "to_sibling_subtree", tenant: 4, newParent: 3, parents: withParents(map[uint64]uint64{4: 3})},
+		{name: "deeper", tenant: 3, newParent: 6, parents: withParents(map[uint64]uint64{3: 6})},
+		{name: "up_to_root", tenant: 6, newParent: 1, parents: withParents(map[uint64]uint64{6: 1})},
+		{name: "already_child", tenant: 5, newParent: 2, parents: withParents(nil)},
+		{name: "detach", tenant: 4, newParent: 0, parents: withParents(map[uint64]uint64{4: 0})},
+		{name: "into_own_subtree", tenant: 2, newParent: 6, circular: true, parents: withParents(nil)},
+		{name: "self", tenant: 2, newParent: 2, circular: true, parents: withParents(nil)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			initClosureTest(t)
+			err := inTx(t, func(tx database.Tx) error {
+				return TenantClosureUpdateSubtreeV2Safe(tx, tc.tenant, tc.newParent)
+			})
+			var circular *CircularReferenceError
+			if tc.circular != errors.As(err, &circular) {
+				t.Fatalf("err = %v, want circular %v", err, tc.circular)
+			}
+			if !tc.circular && err != nil {
+				t.Fatal(err)
+			}
+			if got, want := closureRows(t), expectedClosure(tc.parents); !reflect.DeepEqual(got, want) {
+				t.Fatalf("closure = %v\nwant %v", got, want)
+			}
+		})
+	}
+}
+
+func TestTenantClosureRepair(t *testing.T) {
+	cases := []struct {
+		name    string
+		corrupt []string
+		report  TenantClosureReport
+		parents map[uint64]uint64
+		detect  []uint64
+	}{
+		{
+			name:    "missing_transitive",
+			corrupt: []string{"DELETE FROM tenant_closure WHERE ancestor_id = 1 AND descendant_id = 6"},
+			report:  TenantClosureReport{Missing: 1},
+			parents: withParents(nil),
+		},
+		{
+			name:    "missing_self",
+			corrupt: []string{"DELETE FROM tenant_closure WHERE ancestor_id = 7 AND descendant_id = 7"},
+			report:  TenantClosureReport{Missing: 1},
+			parents: withParents(nil),
+		},
+		{
+			name:    "wrong_depth",
+			corrupt: []string{"UPDATE tenant_closure SET depth = 5 WHERE ancestor_id = 1 AND descendant_id = 6"},
+			report:  TenantClosureReport{WrongDepth: 1},
+			parents: withParents(nil),
+		},
+		{
+			name:    "stale_ancestor",
+			corrupt: []string{"INSERT INTO tenant_closure (ancestor_id, descendant_id, depth) VALUES (3, 6, 2)"},
+			report:  TenantClosureReport{Extra: 1},
+			parents: withParents(nil),
+		},
+		{
+			name:    "multi_parent",
+			corrupt: []string{"INSERT INTO tenant_closure (ancestor_id, descendant_id, depth) VALUES (3, 5, 1)"},
+			report:  TenantClosureReport{Extra: 1, MultiParents: []uint64{5}},
+			parents: withParents(nil),
+		},
+		{
+			name: "cycle",
+			corrupt: []string{
+				"DELETE FROM tenant_closure WHERE ancestor_id = 1 AND descendant_id = 2",
+				"INSERT INTO tenant_closure (ancestor_id, descendant_id, depth) VALUES (6, 2, 1)",
+			},
+			// 环 2 -> 4 -> 6 -> 2 从 ID 最小的 2 断开，2 成为新的根
+			report:  TenantClosureReport{Extra: 4, Cycles: [][]uint64{{2, 4, 6}}},
+			parents: withParents(map[uint64]uint64{2: 0}),
+			detect:  []uint64{2, 6},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			initClosureTest(t)
+			for _, sql := range tc.corrupt {
+				if _, err := common.DB.Exec(context.Background(), sql); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var detect []uint64
+			var report *TenantClosureReport
+			before := closureRows(t)
+			err := inTx(t, func(tx database.Tx) (err error) {
+				if detect, err = DetectCircularReference(tx); err != nil {
+					return
+				}
+				report, err = TenantClosureRepair(tx, true)
+				return
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(detect, tc.detect) {
+				t.Fatalf("DetectCircularReference = %v, want %v", detect, tc.detect)
+			}
+			tc.report.Tenants, tc.report.Rows = 7, len(before)
+			if !reflect.DeepEqual(*report, tc.report) {
+				t.Fatalf("report = %+v\nwant %+v", *report, tc.report)
+			}
+			if got := closureRows(t); !reflect.DeepEqual(got, before) {
+				t.Fatal("dry run must not write")
+			}
+
+			if err = inTx(t, CheckAndRepairTenantClosureTable); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := closureRows(t), expectedClosure(tc.parents); !reflect.DeepEqual(got, want) {
+				t.Fatalf("closure = %v\nwant %v", got, want)
+			}
+			if err = inTx(t, func(tx database.Tx) (err error) {
+				report, err = TenantClosureRepair(tx, true)
+				return
+			}); err != nil || !report.OK() {
+				t.Fatalf("after repair: %+v %v", report, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/database"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// TenantConfigurationForUpdate 在事务中读取租户配置与更新时间，PostgreSQL 下锁住该行直到事务结束；租户不存在时 found 为 false
+func TenantConfigurationForUpdate(tx database.Tx, tenantID uint64) (conf *protos.TenantConfiguration, updateTime *time.Time, found bool, err error) {
+	builder := sq.Select("configuration", "update_time").
+		From("tenants").
+		Where(sq.Eq{"id": tenantID}).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType()))
+	if common.DB.DriverType() == database.DriverPostgreSQL {
+		builder = builder.Suffix("FOR UPDATE")
+	}
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	rows, err := tx.Query(context.Background(), query, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("TenantConfigurationForUpdate ERR: %v %v\n", tenantID, err)
+		return nil, nil, false, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, nil, false, rows.Err()
+	}
+	conf = &protos.TenantConfiguration{}
+	if err = rows.Scan(conf, &updateTime); err != nil {
+		return nil, nil, false, err
+	}
+	return conf, updateTime, true, nil
+}
+
+// TenantConfigurationSet 在事务中写入租户配置与更新时间
+func TenantConfigurationSet(tx database.Tx, tenantID uint64, conf *protos.TenantConfiguration, updateTime time.Time) error {
+	query, args, err := sq.Update("tenants").
+		Set("configuration", conf).
+		Set("update_time", updateTime).
+		Where(sq.Eq{"id": tenantID}).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(context.Background(), query, args...); err != nil {
+		common.Logger.Sugar().Errorf("TenantConfigurationSet ERR: %v %v\n", tenantID, err)
+		return err
+	}
+	return nil
+}
+
+// TenantConfigVersionLast 租户最新的配置版本号，还没有版本时返回 0
+func TenantConfigVersionLast(tx database.Tx, tenantID uint64) (version uint64, err error) {
+	query, args, err := sq.Select("COALESCE(MAX(version), 0)").
+		From("tenant_config_versions").
+		Where(sq.Eq{"tenant_id": tenantID}).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.QueryRow(context.Background(), query, args...).Scan(&version); err != nil {
+		common.Logger.Sugar().Errorf("TenantConfigVersionLast ERR: %v %v\n", tenantID, err)
+		return 0, err
+	}
+	return version, nil
+}
+
+// TenantConfigVersionInsert 在事务中写入一个配置版本
+func TenantConfigVersionInsert(tx database.Tx, m *protos.TenantConfigVersion) error {
+	conf, err := json.Marshal(m.Configuration)
+	if err != nil {
+		return err
+	}
+	diff, err := json.Marshal(m.Diff)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := sq.Insert("tenant_config_versions").
+		Columns("tenant_id", "version", "configuration", "diff", "author_uid", "remark", "create_time").
+		Values(m.TenantID, m.Version, string(conf), string(diff), m.AuthorUID, m.Remark, m.CreateTime).
+		Suffix("RETURNING id").
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return err
+	}
+
+	if err = tx.QueryRow(context.Background(), query, args...).Scan(&m.ID); err != nil {
+		common.Logger.Sugar().Errorf("TenantConfigVersionInsert ERR: %v %v %v\n", m.TenantID, m.Version, err)
+		return err
+	}
+	return nil
+}
+
+// TenantConfigVersionGet 查询租户的某个配置版本（含完整配置），不存在时返回 nil
+func TenantConfigVersionGet(tenantID, version uint64) (*protos.TenantConfigVersion, error) {
+	query, args, err := sq.Select("id", "tenant_id", "version", "configuration", "diff", "author_uid", "remark", "create_time").
+		From("tenant_config_versions").
+		Where(sq.Eq{"tenant_id": tenantID, "version": version}).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := common.DB.Query(context.Background(), query, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("TenantConfigVersionGet ERR: %v %v %v\n", tenantID, version, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	one := &protos.TenantConfigVersion{}
+	var conf, diff string
+	if err = rows.Scan(&one.ID, &one.TenantID, &one.Version, &conf, &diff, &one.AuthorUID, &one.Remark, &one.CreateTime); err != nil {
+		return nil, err
+	}
+	one.Configuration = &protos.TenantConfiguration{}
+	if err = json.Unmarshal([]byte(conf), one.Configuration); err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal([]byte(diff), &one.Diff); err != nil {
+		return nil, err
+	}
+	return one, nil
+}
+
+// TenantConfigVersionList 分页查询租户的配置版本，新版本在前，不含完整配置
+func TenantConfigVersionList(tenantID, page, pageSize uint64) ([]protos.TenantConfigVersion, error) {
+	query, args, err := sq.Select("id", "tenant_id", "version", "diff", "author_uid", "remark", "create_time").
+		From("tenant_config_versions").
+		Where(sq.Eq{"tenant_id": tenantID}).
+		OrderBy("version DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := common.DB.Query(context.Background(), query, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("TenantConfigVersionList ERR: %v %v\n", tenantID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	rr := []protos.TenantConfigVersion{}
+	for rows.Next() {
+		var one protos.TenantConfigVersion
+		var diff string
+		if err = rows.Scan(&one.ID, &one.TenantID, &one.Version, &diff, &one.AuthorUID, &one.Remark, &one.CreateTime); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal([]byte(diff), &one.Diff); err != nil {
+			return nil, err
+		}
+		rr = append(rr, one)
+	}
+	return rr, rows.Err()
+}
@@ -0,0 +1,265 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/database"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// tenantMemberWhere 账号属于租户的条件：默认租户（users.tenant_id）或在 tenant_members 中有记录
+func tenantMemberWhere(uidCol, tenantCol string, tenantID uint64) sq.Sqlizer {
+	return sq.Or{
+		sq.Eq{tenantCol: tenantID},
+		sq.Expr(uidCol+" IN (SELECT tenant_members.uid FROM tenant_members WHERE tenant_members.tenant_id = ?)", tenantID),
+	}
+}
+
+// TenantMemberInsert 记录账号加入租户；已有记录时返回 false
+func TenantMemberInsert(tenantID, uid uint64) (bool, error) {
+	if tenantID == 0 || uid == 0 {
+		return false, common.ErrParam
+	}
+	query, args, err := sq.Insert("tenant_members").
+		Columns("tenant_id", "uid", "create_time").
+		Values(tenantID, uid, time.Now()).
+		Suffix("ON CONFLICT (tenant_id, uid) DO NOTHING").
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	rst, err := common.DB.Exec(context.Background(), query, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("TenantMemberInsert ERR: %v %v %v\n", tenantID, uid, err)
+		return false, err
+	}
+	n, _ := rst.RowsAffected()
+	return n == 1, nil
+}
+
+// TenantMemberDelete 删除账号在租户的成员记录，不改 users.tenant_id
+func TenantMemberDelete(tenantID, uid uint64) (int64, error) {
+	query, args, err := sq.Delete("tenant_members").
+		Where(sq.Eq{"tenant_id": tenantID, "uid": uid}).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	rst, err := common.DB.Exec(context.Background(), query, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("TenantMemberDelete ERR: %v %v %v\n", tenantID, uid, err)
+		return 0, err
+	}
+	return rst.RowsAffected()
+}
+
+// TenantMemberExists 账号是否属于租户（含默认租户）
+func TenantMemberExists(tenantID, uid uint64) (bool, error) {
+	if tenantID == 0 || uid == 0 {
+		return false, nil
+	}
+	query, args, err := sq.Select("COUNT(*)").From("users").
+		Where(sq.And{sq.Eq{"uid": uid}, tenantMemberWhere("users.uid", "users.tenant_id", tenantID)}).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	var n int64
+	if err = common.DB.QueryRow(context.Background(), query, args...).Scan(&n); err != nil {
+		common.Logger.Sugar().Errorf("TenantMemberExists ERR: %v %v %v\n", tenantID, uid, err)
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// TenantMemberGet 账号在租户的成员记录；没有记录（如早期数据的默认租户）时返回 nil
+func TenantMemberGet(tenantID, uid uint64) (*protos.TenantMember, error) {
+	query, args, err := sq.Select("tenant_id", "uid", "disabled", "ext").From("tenant_members").
+		Where(sq.Eq{"tenant_id": tenantID, "uid": uid}).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rr, err := queryTenantMembers(query, args)
+	if err != nil {
+		common.Logger.Sugar().Errorf("TenantMemberGet ERR: %v %v %v\n", tenantID, uid, err)
+		return nil, err
+	}
+	if len(rr) == 0 {
+		return nil, nil
+	}
+	return &rr[0], nil
+}
+
+// TenantMemberList 租户内一批账号的成员记录
+func TenantMemberList(tenantID uint64, uids []uint64) ([]protos.TenantMember, error) {
+	rr := []protos.TenantMember{}
+	if len(uids) == 0 {
+		return rr, nil
+	}
+	query, args, err := sq.Select("tenant_id", "uid", "disabled", "ext").From("tenant_members").
+		Where(sq.Eq{"tenant_id": tenantID, "uid": uids}).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	if rr, err = queryTenantMembers(query, args); err != nil {
+		common.Logger.Sugar().Errorf("TenantMemberList ERR: %v %v\n", tenantID, err)
+		return nil, err
+	}
+	return rr, nil
+}
+
+func queryTenantMembers(query string, args []interface{}) ([]protos.TenantMember, error) {
+	rows, err := common.DB.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rr := []protos.TenantMember{}
+	for rows.Next() {
+		var one protos.TenantMember
+		if err = rows.Scan(&one.TenantID, &one.UID, &one.Disabled, &one.Ext); err != nil {
+			return nil, err
+		}
+		rr = append(rr, one)
+	}
+	return rr, rows.Err()
+}
+
+// TenantMemberSetDisabled 写入账号在租户内的停用状态；默认租户没有成员记录时补上
+func TenantMemberSetDisabled(tenantID, uid uint64, disabled int8) error {
+	return tenantMemberUpsert(tenantID, uid, "disabled", disabled)
+}
+
+// TenantMemberSetExt 整体写入账号在租户内的扩展信息；默认租户没有成员记录时补上
+func TenantMemberSetExt(tenantID, uid uint64, ext protos.MapStruct) error {
+	if ext == nil {
+		ext = protos.MapStruct{}
+	}
+	return tenantMemberUpsert(tenantID, uid, "ext", ext)
+}
+
+func tenantMemberUpsert(tenantID, uid uint64, column string, value interface{}) error {
+	if tenantID == 0 || uid == 0 {
+		return common.ErrParam
+	}
+	query, args, err := sq.Insert("tenant_members").
+		Columns("tenant_id", "uid", column, "create_time").
+		Values(tenantID, uid, value, time.Now()).
+		Suffix("ON CONFLICT (tenant_id, uid) DO UPDATE SET " + column + " = EXCLUDED." + column).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err = common.DB.Exec(context.Background(), query, args...); err != nil {
+		common.Logger.Sugar().Errorf("tenantMemberUpsert ERR: %v %v %v %v\n", tenantID, uid, column, err)
+		return err
+	}
+	return nil
+}
+
+// TenantIDsByUser 账号所属的全部租户 ID（默认租户与 tenant_members 记录，含已删除的租户）
+func TenantIDsByUser(uid uint64) ([]uint64, error) {
+	query, args, err := sq.Select("tenant_id").From("users").
+		Where(sq.And{sq.Eq{"uid": uid}, sq.Gt{"tenant_id": 0}}).
+		Suffix("UNION SELECT tenant_id FROM tenant_members WHERE uid = ?", uid).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := common.DB.Query(context.Background(), query, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("TenantIDsByUser ERR: %v %v\n", uid, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []uint64{}
+	for rows.Next() {
+		var id uint64
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// TenantListByUser 账号加入的未删除租户，默认租户在前
+func TenantListByUser(uid uint64) ([]protos.UserTenant, error) {
+	query, args, err := sq.Select("t.id", "t.tenant_name", "t.tenant_type", "t.status", "t.trial_end_time",
+		"CASE WHEN t.id = u.tenant_id THEN 1 ELSE 0 END AS is_default").
+		From("tenants t").
+		Join("users u ON u.uid = ?", uid).
+		Where(sq.Eq{"t.delete_time": nil}).
+		Where(sq.Or{
+			sq.Expr("t.id = u.tenant_id"),
+			sq.Expr("t.id IN (SELECT tenant_members.tenant_id FROM tenant_members WHERE tenant_members.uid = ?)", uid),
+		}).
+		OrderBy("is_default DESC", "t.id").
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := common.DB.Query(context.Background(), query, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("TenantListByUser ERR: %v %v\n", uid, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	rr := []protos.UserTenant{}
+	for rows.Next() {
+		var one protos.Tenant
+		var isDefault int
+		if err = rows.Scan(&one.ID, &one.TenantName, &one.TenantType, &one.Status, &one.TrialEndTime, &isDefault); err != nil {
+			return nil, err
+		}
+		rr = append(rr, protos.UserTenant{
+			TenantID:   one.ID,
+			TenantName: one.TenantName,
+			TenantType: one.TenantType,
+			Status:     one.EffectiveStatus(time.Now()),
+			Default:    isDefault == 1,
+		})
+	}
+	return rr, rows.Err()
+}
+
+// UserMoveDefaultTenant 把默认租户为 tenantID 的账号改到它加入的其他租户（取 id 最小的）；uid 为 0 时处理全部账号。
+// 没有其他租户的账号不变，返回实际改动的行数。
+func UserMoveDefaultTenant(tenantID, uid uint64) (int64, error) {
+	other := "FROM tenant_members WHERE tenant_members.uid = users.uid AND tenant_members.tenant_id <> ?"
+	where := sq.And{sq.Eq{"tenant_id": tenantID}, sq.Expr("EXISTS (SELECT 1 "+other+")", tenantID)}
+	if uid > 0 {
+		where = append(where, sq.Eq{"uid": uid})
+	}
+	query, args, err := sq.Update("users").
+		Set("tenant_id", sq.Expr("(SELECT MIN(tenant_members.tenant_id) "+other+")", tenantID)).
+		Where(where).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	rst, err := common.DB.Exec(context.Background(), query, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("UserMoveDefaultTenant ERR: %v %v %v\n", tenantID, uid, err)
+		return 0, err
+	}
+	return rst.RowsAffected()
+}
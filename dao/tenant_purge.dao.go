@@ -0,0 +1,71 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/database"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// TenantPurgeInsert 记录一次租户物理删除
+func TenantPurgeInsert(m *protos.TenantPurge) error {
+	children, err := json.Marshal(m.Children)
+	if err != nil {
+		return err
+	}
+	if m.Children == nil {
+		children = []byte("[]")
+	}
+
+	sql, args, err := sq.Insert("tenant_purges").
+		Columns("tenant_id", "tenant_name", "delete_time", "purge_time", "users", "departments", "permissions", "children").
+		Values(m.TenantID, m.TenantName, m.DeleteTime, time.Now(), m.Users, m.Departments, m.Permissions, string(children)).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err = common.DB.Exec(context.Background(), sql, args...); err != nil {
+		common.Logger.Sugar().Errorf("TenantPurgeInsert ERR: %v %v\n", m.TenantID, err)
+		return err
+	}
+	return nil
+}
+
+// TenantPurgeList 分页查询清理记录，最近的在前
+func TenantPurgeList(page, pageSize uint64) ([]protos.TenantPurge, error) {
+	sql, args, err := sq.Select("id", "tenant_id", "tenant_name", "delete_time", "purge_time", "users", "departments", "permissions", "children").
+		From("tenant_purges").
+		OrderBy("id DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := common.DB.Query(context.Background(), sql, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("TenantPurgeList ERR: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rr []protos.TenantPurge
+	for rows.Next() {
+		var one protos.TenantPurge
+		var children string
+		if err = rows.Scan(&one.ID, &one.TenantID, &one.TenantName, &one.DeleteTime, &one.PurgeTime, &one.Users, &one.Departments, &one.Permissions, &children); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal([]byte(children), &one.Children); err != nil {
+			return nil, err
+		}
+		rr = append(rr, one)
+	}
+	return rr, rows.Err()
+}
@@ -0,0 +1,137 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/database"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// quotaCountTables 可以直接按 tenant_id 计数的配额资源；roles 存在租户配置里，由 service 计数
+var quotaCountTables = map[string]string{
+	protos.QuotaMembers:     "users",
+	protos.QuotaOrgs:        "organizations",
+	protos.QuotaDepartments: "departments",
+}
+
+// TenantQuotaList 查询租户已设置的配额
+func TenantQuotaList(tenantID uint64) ([]protos.TenantQuota, error) {
+	sql, args, err := sq.Select("resource", "quota_limit", "used").From("tenant_quotas").
+		Where(sq.Eq{"tenant_id": tenantID}).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := common.DB.Query(context.Background(), sql, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("TenantQuotaList ERR: %v %v\n", tenantID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rr []protos.TenantQuota
+	for rows.Next() {
+		var one protos.TenantQuota
+		if err = rows.Scan(&one.Resource, &one.Limit, &one.Used); err != nil {
+			return nil, err
+		}
+		rr = append(rr, one)
+	}
+	return rr, rows.Err()
+}
+
+// TenantQuotaUpsert 写入配额上限，同时用实际计数校准 used
+func TenantQuotaUpsert(tenantID uint64, resource string, limit, used int64) error {
+	sql, args, err := sq.Insert("tenant_quotas").
+		Columns("tenant_id", "resource", "quota_limit", "used", "update_time").
+		Values(tenantID, resource, limit, used, time.Now()).
+		Suffix("ON CONFLICT (tenant_id, resource) DO UPDATE SET quota_limit = excluded.quota_limit, used = excluded.used, update_time = excluded.update_time").
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = common.DB.Exec(context.Background(), sql, args...)
+	return err
+}
+
+// TenantQuotaReserve 占用 n 个配额：条件 UPDATE 在一条语句里完成检查与计数，并发下不会超额。
+// 超出上限返回 common.ErrQuotaExceeded；租户没有设置该项配额时不计数，counted 为 false。
+func TenantQuotaReserve(tenantID uint64, resource string, n int64) (counted bool, err error) {
+	sql, args, err := sq.Update("tenant_quotas").
+		Set("used", sq.Expr("used + ?", n)).
+		Where(sq.Eq{"tenant_id": tenantID, "resource": resource}).
+		Where("(quota_limit <= 0 OR used + ? <= quota_limit)", n).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	rst, err := common.DB.Exec(context.Background(), sql, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("TenantQuotaReserve ERR: %v %v %v\n", tenantID, resource, err)
+		return false, err
+	}
+	if n, _ := rst.RowsAffected(); n > 0 {
+		return true, nil
+	}
+
+	sql, args, err = sq.Select("COUNT(*)").From("tenant_quotas").
+		Where(sq.Eq{"tenant_id": tenantID, "resource": resource}).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return false, err
+	}
+	var rows int64
+	if err = common.DB.QueryRow(context.Background(), sql, args...).Scan(&rows); err != nil {
+		return false, err
+	}
+	if rows > 0 {
+		return false, common.ErrQuotaExceeded
+	}
+	return false, nil
+}
+
+// TenantQuotaAdjust 调整用量计数，结果不小于 0；租户没有设置该项配额时什么也不做
+func TenantQuotaAdjust(tenantID uint64, resource string, delta int64) error {
+	sql, args, err := sq.Update("tenant_quotas").
+		Set("used", sq.Expr("CASE WHEN used + ? > 0 THEN used + ? ELSE 0 END", delta, delta)).
+		Where(sq.Eq{"tenant_id": tenantID, "resource": resource}).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err = common.DB.Exec(context.Background(), sql, args...); err != nil {
+		common.Logger.Sugar().Errorf("TenantQuotaAdjust ERR: %v %v %v %v\n", tenantID, resource, delta, err)
+		return err
+	}
+	return nil
+}
+
+// TenantResourceCount 按表实际计数租户的成员、组织、部门
+func TenantResourceCount(tenantID uint64, resource string) (n int64, err error) {
+	table, ok := quotaCountTables[resource]
+	if !ok {
+		return 0, fmt.Errorf("unknown quota resource: %s", resource)
+	}
+
+	var where sq.Sqlizer = sq.Eq{"tenant_id": tenantID}
+	if resource == protos.QuotaMembers {
+		where = tenantMemberWhere("users.uid", "users.tenant_id", tenantID)
+	}
+	sql, args, err := sq.Select("COUNT(*)").From(table).
+		Where(where).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return 0, err
+	}
+	err = common.DB.QueryRow(context.Background(), sql, args...).Scan(&n)
+	return
+}
@@ -0,0 +1,150 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/database"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// TenantTemplateInsert 写入租户模板
+func TenantTemplateInsert(m *protos.TenantTemplate) (id uint64, err error) {
+	content, err := json.Marshal(m.Content)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	query, args, err := sq.Insert("tenant_templates").
+		Columns("name", "remark", "source_tenant_id", "content", "create_time", "update_time").
+		Values(m.Name, m.Remark, m.SourceTenantID, string(content), now, now).
+		Suffix("RETURNING id").
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = common.DB.QueryRow(context.Background(), query, args...).Scan(&id); err != nil {
+		common.Logger.Sugar().Errorf("TenantTemplateInsert ERR: %v %v\n", m.Name, err)
+		return 0, err
+	}
+	return id, nil
+}
+
+// TenantTemplateUpdate 修改模板名称、备注与内容
+func TenantTemplateUpdate(m *protos.TenantTemplate) (int64, error) {
+	content, err := json.Marshal(m.Content)
+	if err != nil {
+		return 0, err
+	}
+
+	query, args, err := sq.Update("tenant_templates").
+		Set("name", m.Name).
+		Set("remark", m.Remark).
+		Set("content", string(content)).
+		Set("update_time", time.Now()).
+		Where(sq.Eq{"id": m.ID}).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	rst, err := common.DB.Exec(context.Background(), query, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("TenantTemplateUpdate ERR: %v %v\n", m.ID, err)
+		return 0, err
+	}
+	return rst.RowsAffected()
+}
+
+// TenantTemplateDelete 删除模板
+func TenantTemplateDelete(id uint64) (int64, error) {
+	query, args, err := sq.Delete("tenant_templates").
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	rst, err := common.DB.Exec(context.Background(), query, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("TenantTemplateDelete ERR: %v %v\n", id, err)
+		return 0, err
+	}
+	return rst.RowsAffected()
+}
+
+// TenantTemplateGet 按 id 查询模板（含内容），不存在时返回 nil
+func TenantTemplateGet(id uint64) (*protos.TenantTemplate, error) {
+	return tenantTemplateGet(sq.Eq{"id": id})
+}
+
+// TenantTemplateGetByName 按名称查询模板（含内容），不存在时返回 nil
+func TenantTemplateGetByName(name string) (*protos.TenantTemplate, error) {
+	return tenantTemplateGet(sq.Eq{"name": name})
+}
+
+func tenantTemplateGet(where sq.Sqlizer) (*protos.TenantTemplate, error) {
+	query, args, err := sq.Select("id", "name", "remark", "source_tenant_id", "content", "create_time", "update_time").
+		From("tenant_templates").
+		Where(where).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := common.DB.Query(context.Background(), query, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("tenantTemplateGet ERR: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	one := &protos.TenantTemplate{}
+	var content string
+	if err = rows.Scan(&one.ID, &one.Name, &one.Remark, &one.SourceTenantID, &content, &one.CreateTime, &one.UpdateTime); err != nil {
+		return nil, err
+	}
+	one.Content = &protos.TenantTemplateContent{}
+	if err = json.Unmarshal([]byte(content), one.Content); err != nil {
+		return nil, err
+	}
+	return one, nil
+}
+
+// TenantTemplateList 分页查询模板，不含内容
+func TenantTemplateList(page, pageSize uint64) ([]protos.TenantTemplate, error) {
+	query, args, err := sq.Select("id", "name", "remark", "source_tenant_id", "create_time", "update_time").
+		From("tenant_templates").
+		OrderBy("id DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := common.DB.Query(context.Background(), query, args...)
+	if err != nil {
+		common.Logger.Sugar().Errorf("TenantTemplateList ERR: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	rr := []protos.TenantTemplate{}
+	for rows.Next() {
+		var one protos.TenantTemplate
+		if err = rows.Scan(&one.ID, &one.Name, &one.Remark, &one.SourceTenantID, &one.CreateTime, &one.UpdateTime); err != nil {
+			return nil, err
+		}
+		rr = append(rr, one)
+	}
+	return rr, rows.Err()
+}
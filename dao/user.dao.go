@@ -136,6 +136,26 @@ func UserUpdateExt(uid uint64, ext *protos.MapStruct) (rows int64, e error) {
 	return rows, nil
 }
 
+// UserUpdateExtTx 在事务中整体写入用户的 ext
+func UserUpdateExtTx(tx database.Tx, uid uint64, ext protos.MapStruct) error {
+	if ext == nil {
+		ext = protos.MapStruct{}
+	}
+	query, args, err := sq.Update("users").
+		Set("ext", ext).
+		Where(sq.Eq{"uid": uid}).
+		PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(context.Background(), query, args...); err != nil {
+		common.Logger.Sugar().Errorf("UserUpdateExtTx ERR: %v %v\n", uid, err)
+		return err
+	}
+	return nil
+}
+
 func UserUpdatePWD(UID uint64, oldPWD, newPWD string) (rows int64, e error) {
 	table := "users"
 	where := sq.Eq{
@@ -551,7 +571,7 @@ func UserLiteQuery(p *protos.UserReq, pageNo, pageSize uint64) (rr []protos.User
 		pageNo = 1
 	}
 
-	and := sq.And{sq.Eq{"tenant_id": p.TenantID}}
+	and := sq.And{tenantMemberWhere("users.uid", "users.tenant_id", p.TenantID)}
 	if p.UID != 0 {
 		and = append(and, sq.Eq{"uid": p.UID})
 	}
@@ -0,0 +1,49 @@
+// access_datascope.go 提供数据范围 custom 级别的部门列表配置接口。
+package access
+
+import (
+	"net/http"
+
+	gocommon "github.com/liuhengloveyou/go-common"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/face/core"
+	"github.com/liuhengloveyou/passport/v4/protos"
+	"github.com/liuhengloveyou/passport/v4/service"
+)
+
+// SetDataScopeDeps 设置角色在模块下的自定义部门列表；depIds 为空时清除。
+func SetDataScopeDeps(w http.ResponseWriter, r *http.Request) {
+	sessionUser, orgID, ok := sessionOrg(w, r)
+	if !ok {
+		return
+	}
+	req := &protos.DataScopeDepsReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, 10240); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if err := service.DataScopeSetCustomDeps(sessionUser.TenantID, orgID, req.Role, req.Module, req.DepIDs); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+}
+
+// GetDataScopeDeps 查询角色在模块下的自定义部门列表。
+func GetDataScopeDeps(w http.ResponseWriter, r *http.Request) {
+	sessionUser, orgID, ok := sessionOrg(w, r)
+	if !ok {
+		return
+	}
+	role := r.FormValue("role")
+	if role == "" {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	rst, err := service.DataScopeGetCustomDeps(sessionUser.TenantID, orgID, role, r.FormValue("module"))
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, rst)
+}
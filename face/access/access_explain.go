@@ -0,0 +1,74 @@
+// access_explain.go 提供访问决策解释接口，用于排查"您没有权限"。
+package access
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	gocommon "github.com/liuhengloveyou/go-common"
+	"github.com/liuhengloveyou/passport/v4/accessctl"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/face/core"
+	"github.com/liuhengloveyou/passport/v4/protos"
+	"github.com/liuhengloveyou/passport/v4/service"
+)
+
+// apiConfLookup 由 face/http 注入，复用 AccessFilter 判定是否需要鉴权的逻辑。
+var apiConfLookup func(obj string) *protos.ApiConfExplain
+
+// SetApiConfLookup 注入 ApiConf 查询函数。
+func SetApiConfLookup(fn func(obj string) *protos.ApiConfExplain) {
+	apiConfLookup = fn
+}
+
+// Explain 解释指定用户在组织内对 obj/act 的访问决策；uid 缺省为当前用户，org 缺省为 X-Org-Id。
+func Explain(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrTenantNotFound)
+		return
+	}
+	uid, _ := strconv.ParseUint(r.FormValue("uid"), 10, 64)
+	if uid == 0 {
+		uid = sessionUser.UID
+	}
+	orgID, _ := strconv.ParseUint(r.FormValue("org"), 10, 64)
+	if orgID == 0 {
+		orgID = core.ParseOrgID(r)
+	}
+	obj := strings.TrimSpace(r.FormValue("obj"))
+	act := strings.TrimSpace(r.FormValue("act"))
+	if obj == "" || act == "" {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+
+	// 只能解释本租户的用户与组织
+	if _, err := service.RequireOrg(sessionUser.TenantID, orgID); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	if uid != sessionUser.UID {
+		if err := service.UserInTenant(uid, sessionUser.TenantID); err != nil {
+			gocommon.HttpJsonErr(w, http.StatusOK, common.ErrUserNotFound)
+			return
+		}
+	}
+
+	rst, err := accessctl.Explain(uid, sessionUser.TenantID, orgID, obj, act)
+	if err != nil {
+		core.Logger().Sugar().Errorf("access.Explain ERR: %v %v %v %v\n", uid, orgID, obj, err)
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrService)
+		return
+	}
+	if err := service.UserInOrg(uid, sessionUser.TenantID, orgID); err != nil {
+		rst.OrgError = err.Error()
+	} else {
+		rst.OrgMember = true
+	}
+	if apiConfLookup != nil {
+		rst.ApiConf = apiConfLookup(obj)
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, rst)
+}
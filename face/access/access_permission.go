@@ -1,4 +1,4 @@
-// access_permission.go 提供权限点管理接口：创建、删除、查询。
+// access_permission.go 提供权限点管理接口：创建、删除、查询、树形字典。
 package access
 
 import (
@@ -33,7 +33,7 @@ func PermissionCreate(w http.ResponseWriter, r *http.Request) {
 	gocommon.HttpErr(w, http.StatusOK, 0, id)
 }
 
-// PermissionDelete 删除权限点。
+// PermissionDelete 删除权限点；有下级或被策略引用时需带 cascade=1 级联删除。
 func PermissionDelete(w http.ResponseWriter, r *http.Request) {
 	sessionUser := core.GetSessionUser(r)
 	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
@@ -41,7 +41,14 @@ func PermissionDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	id, _ := strconv.ParseUint(r.FormValue("id"), 10, 64)
-	if err := service.PermissionDelete(id, sessionUser.TenantID); err != nil {
+	cascade := r.FormValue("cascade") == "1" || r.FormValue("cascade") == "true"
+	dep, err := service.PermissionDelete(id, sessionUser.TenantID, cascade)
+	if err == common.ErrPermissionInUse {
+		// 返回依赖的下级权限点与策略，前端确认后带 cascade=1 重试
+		gocommon.HttpErr(w, http.StatusOK, common.ErrPermissionInUse.Code, dep)
+		return
+	}
+	if err != nil {
 		gocommon.HttpJsonErr(w, http.StatusOK, err)
 		return
 	}
@@ -62,3 +69,18 @@ func PermissionList(w http.ResponseWriter, r *http.Request) {
 	}
 	gocommon.HttpErr(w, http.StatusOK, 0, list)
 }
+
+// PermissionTree 以树形返回权限字典，供前端渲染勾选树。
+func PermissionTree(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+		return
+	}
+	tree, err := service.PermissionTree(sessionUser.TenantID, r.FormValue("domain"))
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, tree)
+}
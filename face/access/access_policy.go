@@ -1,4 +1,4 @@
-// access_policy.go 提供策略管理接口：策略增删与查询。
+// access_policy.go 提供策略管理接口：策略增删、查询与批量鉴权。
 package access
 
 import (
@@ -16,8 +16,8 @@ import (
 
 // policyRuleToDTO 将 Casbin `p` 规则字段序列转为 API 使用的 protos.Policy（不暴露域，域已在存储层按租户隔离）。
 // 支持：
-// - Passport 默认 RBAC with domains：sub, dom, obj, act
-// - 若底层返回将 ptype 拼入切片：p, sub, dom, obj, act（或 p, sub, obj, act）
+// - Passport 默认 RBAC with domains：sub, dom, obj, act[, eft]
+// - 若底层返回将 ptype 拼入切片：p, sub, dom, obj, act[, eft]（或 p, sub, obj, act）
 func policyRuleToDTO(rule []string) (protos.Policy, bool) {
 	if len(rule) < 4 {
 		return protos.Policy{}, false
@@ -27,22 +27,49 @@ func policyRuleToDTO(rule []string) (protos.Policy, bool) {
 		a[i] = strings.TrimSpace(s)
 	}
 	head := strings.ToLower(a[0])
-	if head == "p" && len(a) >= 5 {
-		if a[1] == "" || a[3] == "" || a[4] == "" {
-			return protos.Policy{}, false
-		}
-		return protos.Policy{Role: a[1], Obj: a[3], Act: a[4]}, true
+	if head == "p" && (len(a) >= 6 || (len(a) == 5 && !isPolicyEffect(a[4]))) {
+		a = a[1:]
+	} else if head == "p" && len(a) >= 4 {
+		// p, sub, obj, act[, eft]：补一个空 dom 对齐
+		a = append([]string{a[1], ""}, a[2:]...)
 	}
-	if head == "p" && len(a) >= 4 {
-		if a[1] == "" || a[2] == "" || a[3] == "" {
+
+	if a[0] == "" || a[2] == "" || a[3] == "" {
+		return protos.Policy{}, false
+	}
+	p := protos.Policy{Role: a[0], Obj: a[2], Act: a[3]}
+	if len(a) >= 5 {
+		if !isPolicyEffect(a[4]) {
 			return protos.Policy{}, false
 		}
-		return protos.Policy{Role: a[1], Obj: a[2], Act: a[3]}, true
+		p.Effect = a[4]
 	}
-	if a[0] == "" || a[2] == "" || a[3] == "" {
-		return protos.Policy{}, false
+	return p, true
+}
+
+// markTenantWide 策略行来自租户级域时标记 TenantWide。
+func markTenantWide(p *protos.Policy, rule []string) {
+	if len(rule) < 2 {
+		return
 	}
-	return protos.Policy{Role: a[0], Obj: a[2], Act: a[3]}, true
+	if _, orgID, ok := accessctl.ParseDomain(rule[1]); ok && orgID == 0 {
+		p.TenantWide = true
+	}
+}
+
+func isPolicyEffect(s string) bool {
+	return s == protos.PolicyEffectAllow || s == protos.PolicyEffectDeny
+}
+
+// policySubject 请求中 role 与 uid 二选一：uid 表示直接对用户下发策略。
+func policySubject(req *protos.PolicyReq) (string, bool) {
+	if req.Role != "" && req.UID == 0 {
+		return req.Role, true
+	}
+	if req.Role == "" && req.UID > 0 {
+		return accessctl.UserSubject(req.UID), true
+	}
+	return "", false
 }
 
 // AddPolicyToRole 为角色添加访问策略。
@@ -62,9 +89,32 @@ func AddPolicyToRole(w http.ResponseWriter, r *http.Request) {
 		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
 		return
 	}
-	if err := accessctl.AddPolicyToRole(sessionUser.TenantID, orgID, req.Role, req.Obj, req.Act); err != nil {
+	sub, ok := policySubject(req)
+	if !ok {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if req.TenantWide {
+		if !service.IsTenantRoot(sessionUser.UID, sessionUser.TenantID) {
+			gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+			return
+		}
+		if err := service.PolicyAddToTenant(sessionUser.TenantID, sub, req.Obj, req.Act, req.Effect); err != nil {
+			core.Logger().Error("AddPolicyToRole tenant ERR: ", zap.Error(err))
+			gocommon.HttpJsonErr(w, http.StatusOK, err)
+			return
+		}
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+		return
+	}
+	// 非 root 只能下发自己已拥有的权限
+	if err := service.CheckPolicyGrantable(sessionUser.UID, sessionUser.TenantID, orgID, req.Obj, req.Act); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	if err := service.PolicyAddToRole(sessionUser.TenantID, orgID, sub, req.Obj, req.Act, req.Effect); err != nil {
 		core.Logger().Error("AddPolicyToRole ERR: ", zap.Error(err))
-		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrService)
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
 		return
 	}
 	gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
@@ -87,7 +137,29 @@ func RemovePolicyFromRole(w http.ResponseWriter, r *http.Request) {
 		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
 		return
 	}
-	if err := accessctl.RemovePolicyFromRole(sessionUser.TenantID, orgID, req.Role, req.Obj, req.Act); err != nil {
+	sub, ok := policySubject(req)
+	if !ok {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if req.TenantWide {
+		if !service.IsTenantRoot(sessionUser.UID, sessionUser.TenantID) {
+			gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+			return
+		}
+		if err := accessctl.RemoveTenantPolicy(sessionUser.TenantID, sub, req.Obj, req.Act); err != nil {
+			gocommon.HttpJsonErr(w, http.StatusOK, common.ErrService)
+			return
+		}
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+		return
+	}
+	// allow 规则须自己能通过鉴权；deny 规则只有 root 能移除，且不能是针对自己的 deny
+	if err := service.CheckPolicyRemovable(sessionUser.UID, sessionUser.TenantID, orgID, sub, req.Obj, req.Act); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	if err := accessctl.RemovePolicyFromRole(sessionUser.TenantID, orgID, sub, req.Obj, req.Act); err != nil {
 		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrService)
 		return
 	}
@@ -115,6 +187,7 @@ func GetPolicy(w http.ResponseWriter, r *http.Request) {
 	policesNoDomain := make([]protos.Policy, 0, len(polices))
 	for _, row := range polices {
 		if p, ok := policyRuleToDTO(row); ok {
+			markTenantWide(&p, row)
 			policesNoDomain = append(policesNoDomain, p)
 		}
 	}
@@ -134,16 +207,35 @@ func GetPolicyForUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	roles := accessctl.GetRoleForUserInDomain(sessionUser.UID, sessionUser.TenantID, orgID)
-	if len(roles) == 0 {
-		gocommon.HttpErr(w, http.StatusOK, 0, nil)
-		return
-	}
+	// 直接下发给用户本人的策略（如 deny）
+	roles = append(roles, accessctl.UserSubject(sessionUser.UID))
 	policys := accessctl.GetFilteredPolicy(sessionUser.TenantID, orgID, roles)
 	out := make([]protos.Policy, 0, len(policys))
 	for _, row := range policys {
 		if p, ok := policyRuleToDTO(row); ok {
+			markTenantWide(&p, row)
 			out = append(out, p)
 		}
 	}
 	gocommon.HttpErr(w, http.StatusOK, 0, out)
 }
+
+// EnforceBatch 批量判定当前用户在当前组织内的 (obj, act) 列表，返回与请求顺序一致的 bool 数组。
+func EnforceBatch(w http.ResponseWriter, r *http.Request) {
+	sessionUser, orgID, ok := sessionOrg(w, r)
+	if !ok {
+		return
+	}
+	req := &protos.EnforceBatchReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, 20480); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	rst, err := accessctl.EnforceBatch(sessionUser.UID, sessionUser.TenantID, orgID, req.Items)
+	if err != nil {
+		core.Logger().Error("EnforceBatch ERR: ", zap.Error(err))
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrService)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, rst)
+}
@@ -38,7 +38,45 @@ func AddRoleForUser(w http.ResponseWriter, r *http.Request) {
 		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
 		return
 	}
-	if err := accessctl.AddRoleForUserInDomain(req.UID, sessionUser.TenantID, orgID, strings.TrimSpace(req.RoleValue)); err != nil {
+	if req.InheritToChildren {
+		// 继承授权不支持限时，也不能同时是租户级授权
+		if req.TenantWide || req.NotBefore != nil || req.ExpiresAt != nil {
+			gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+			return
+		}
+		if err := service.CheckRolesGrantable(sessionUser.UID, sessionUser.TenantID, orgID, []string{strings.TrimSpace(req.RoleValue)}); err != nil {
+			gocommon.HttpJsonErr(w, http.StatusOK, err)
+			return
+		}
+		if err := service.OrgAddInheritedRole(req.UID, sessionUser.TenantID, orgID, strings.TrimSpace(req.RoleValue)); err != nil {
+			gocommon.HttpJsonErr(w, http.StatusOK, err)
+			return
+		}
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+		return
+	}
+	if req.TenantWide {
+		// 租户级授权不支持限时
+		if req.NotBefore != nil || req.ExpiresAt != nil {
+			gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+			return
+		}
+		if !service.IsTenantRoot(sessionUser.UID, sessionUser.TenantID) {
+			gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+			return
+		}
+		if err := service.AddRoleForUserInAllOrgs(req.UID, sessionUser.TenantID, strings.TrimSpace(req.RoleValue)); err != nil {
+			gocommon.HttpJsonErr(w, http.StatusOK, err)
+			return
+		}
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+		return
+	}
+	if err := service.CheckRolesGrantable(sessionUser.UID, sessionUser.TenantID, orgID, []string{strings.TrimSpace(req.RoleValue)}); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	if err := service.RoleGrantAdd(req.UID, sessionUser.TenantID, orgID, strings.TrimSpace(req.RoleValue), req.NotBefore, req.ExpiresAt); err != nil {
 		gocommon.HttpJsonErr(w, http.StatusOK, err)
 		return
 	}
@@ -55,11 +93,21 @@ func UpdateRoleForUser(w http.ResponseWriter, r *http.Request) {
 		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
 		return
 	}
-	if err := accessctl.DeleteRoleForUserInDomain(req.UID, sessionUser.TenantID, orgID, strings.TrimSpace(req.RoleValue)); err != nil {
-		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrService)
+	if err := service.CheckRolesGrantable(sessionUser.UID, sessionUser.TenantID, orgID, []string{strings.TrimSpace(req.RoleValue), strings.TrimSpace(req.NewRoleValue)}); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
 		return
 	}
-	if err := accessctl.AddRoleForUserInDomain(req.UID, sessionUser.TenantID, orgID, strings.TrimSpace(req.NewRoleValue)); err != nil {
+	held := make([]string, 0)
+	for _, role := range accessctl.GetRoleForUserInDomain(req.UID, sessionUser.TenantID, orgID) {
+		if role != strings.TrimSpace(req.RoleValue) {
+			held = append(held, role)
+		}
+	}
+	if err := service.CheckExclusiveRoles(sessionUser.TenantID, held, []string{strings.TrimSpace(req.NewRoleValue)}); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	if err := service.RoleGrantReplace(req.UID, sessionUser.TenantID, orgID, strings.TrimSpace(req.RoleValue), strings.TrimSpace(req.NewRoleValue)); err != nil {
 		gocommon.HttpJsonErr(w, http.StatusOK, err)
 		return
 	}
@@ -76,8 +124,35 @@ func RemoveRoleForUser(w http.ResponseWriter, r *http.Request) {
 		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
 		return
 	}
-	if err := accessctl.DeleteRoleForUserInDomain(req.UID, sessionUser.TenantID, orgID, req.RoleValue); err != nil {
-		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrService)
+	// 收回与授予一样，只能操作自己可授予的角色
+	if !req.TenantWide {
+		if err := service.CheckRolesGrantable(sessionUser.UID, sessionUser.TenantID, orgID, []string{strings.TrimSpace(req.RoleValue)}); err != nil {
+			gocommon.HttpJsonErr(w, http.StatusOK, err)
+			return
+		}
+	}
+	if req.InheritToChildren {
+		if err := service.OrgRemoveInheritedRole(req.UID, sessionUser.TenantID, orgID, req.RoleValue); err != nil {
+			gocommon.HttpJsonErr(w, http.StatusOK, err)
+			return
+		}
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+		return
+	}
+	if req.TenantWide {
+		if !service.IsTenantRoot(sessionUser.UID, sessionUser.TenantID) {
+			gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+			return
+		}
+		if err := service.RemoveRoleForUserInAllOrgs(req.UID, sessionUser.TenantID, req.RoleValue); err != nil {
+			gocommon.HttpJsonErr(w, http.StatusOK, err)
+			return
+		}
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+		return
+	}
+	if err := service.RoleGrantRemove(req.UID, sessionUser.TenantID, orgID, req.RoleValue); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
 		return
 	}
 	gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
@@ -120,11 +195,22 @@ func GetRolesForUser(w http.ResponseWriter, r *http.Request) {
 	}
 	roles := accessctl.GetRoleForUserInDomain(iuid, sessionUser.TenantID, orgID)
 	rst := make([]protos.RoleStruct, len(roles))
-	rolesConfs := service.TenantGetRole(sessionUser.TenantID)
 	for i, role := range roles {
 		rst[i].RoleValue = role
+	}
+	// 限时授权附带有效期与剩余秒数，未生效的授权追加在末尾
+	rst = service.RoleGrantFill(iuid, sessionUser.TenantID, orgID, rst)
+	// 经自身或上级组织继承授权得到的角色标出授权所在的组织
+	inherited := accessctl.GetInheritedRolesForUser(iuid, sessionUser.TenantID, orgID)
+	for i := range rst {
+		if from, ok := inherited[rst[i].RoleValue]; ok {
+			rst[i].InheritToChildren, rst[i].InheritFromOrg = true, from
+		}
+	}
+	rolesConfs := service.TenantGetRole(sessionUser.TenantID)
+	for i := range rst {
 		for _, roleConf := range rolesConfs {
-			if role == roleConf.RoleValue {
+			if rst[i].RoleValue == roleConf.RoleValue {
 				rst[i].RoleTitle = roleConf.RoleTitle
 			}
 		}
@@ -76,6 +76,30 @@ func TestPolicyRuleToDTO(t *testing.T) {
 			want: protos.Policy{Role: "editor", Obj: "/devices", Act: "GET"},
 			ok:   true,
 		},
+		{
+			name: "with_effect",
+			rule: []string{"editor", "tenant-1-org-2", "/devices", "GET", "deny"},
+			want: protos.Policy{Role: "editor", Obj: "/devices", Act: "GET", Effect: protos.PolicyEffectDeny},
+			ok:   true,
+		},
+		{
+			name: "casbin_slice_with_ptype_effect",
+			rule: []string{"p", "uid-3", "tenant-1-org-2", "/devices", "GET", "allow"},
+			want: protos.Policy{Role: "uid-3", Obj: "/devices", Act: "GET", Effect: protos.PolicyEffectAllow},
+			ok:   true,
+		},
+		{
+			name: "casbin_slice_no_domain",
+			rule: []string{"p", "editor", "/devices", "GET"},
+			want: protos.Policy{Role: "editor", Obj: "/devices", Act: "GET"},
+			ok:   true,
+		},
+		{
+			name: "bad_effect",
+			rule: []string{"editor", "tenant-1-org-2", "/devices", "GET", "maybe"},
+			want: protos.Policy{},
+			ok:   false,
+		},
 		{
 			name: "short",
 			rule: []string{"a", "b"},
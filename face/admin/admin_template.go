@@ -0,0 +1,142 @@
+// admin_template.go 提供平台管理员租户模板接口：模板增删改查与从现有租户克隆模板。
+package admin
+
+import (
+	"net/http"
+	"strconv"
+
+	gocommon "github.com/liuhengloveyou/go-common"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/face/core"
+	"github.com/liuhengloveyou/passport/v4/protos"
+	"github.com/liuhengloveyou/passport/v4/service"
+	"go.uber.org/zap"
+)
+
+// 模板 JSON 可能包含较多组织与策略，请求体上限放宽到 1MB
+const templateBodyMaxLen = 1 << 20
+
+// AdminTemplateAdd 新增租户模板。
+func AdminTemplateAdd(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if err := authorizeAdminTenant(sessionUser, "AdminTemplateAdd", 0); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusUnauthorized, err)
+		return
+	}
+	req := &protos.TenantTemplateReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, templateBodyMaxLen); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+
+	m, err := service.TenantTemplateAdd(req)
+	if err != nil {
+		core.Logger().Error("AdminTemplateAdd ERR: ", zap.String("name", req.Name), zap.Error(err))
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, m)
+}
+
+// AdminTemplateUpdate 修改租户模板。
+func AdminTemplateUpdate(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if err := authorizeAdminTenant(sessionUser, "AdminTemplateUpdate", 0); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusUnauthorized, err)
+		return
+	}
+	req := &protos.TenantTemplateReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, templateBodyMaxLen); err != nil || req.ID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+
+	if err := service.TenantTemplateUpdate(req); err != nil {
+		core.Logger().Error("AdminTemplateUpdate ERR: ", zap.Uint64("id", req.ID), zap.Error(err))
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+}
+
+// AdminTemplateList 分页查询租户模板，不含内容。
+func AdminTemplateList(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if err := authorizeAdminTenant(sessionUser, "AdminTemplateList", 0); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusUnauthorized, err)
+		return
+	}
+	page, _ := strconv.ParseUint(r.FormValue("page"), 10, 64)
+	pageSize, _ := strconv.ParseUint(r.FormValue("pageSize"), 10, 64)
+
+	rr, err := service.TenantTemplateList(page, pageSize)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, rr)
+}
+
+// AdminTemplateGet 查询租户模板及内容。
+func AdminTemplateGet(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if err := authorizeAdminTenant(sessionUser, "AdminTemplateGet", 0); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusUnauthorized, err)
+		return
+	}
+	id, _ := strconv.ParseUint(r.FormValue("id"), 10, 64)
+	if id <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+
+	m, err := service.TenantTemplateGet(id)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, m)
+}
+
+// AdminTemplateDelete 删除租户模板，已按模板开通的租户不受影响。
+func AdminTemplateDelete(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if err := authorizeAdminTenant(sessionUser, "AdminTemplateDelete", 0); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusUnauthorized, err)
+		return
+	}
+	id, _ := strconv.ParseUint(r.FormValue("id"), 10, 64)
+	if id <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+
+	if err := service.TenantTemplateDelete(id); err != nil {
+		core.Logger().Error("AdminTemplateDelete ERR: ", zap.Uint64("id", id), zap.Error(err))
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+}
+
+// AdminTenantClone 把现有租户的结构（不含账号）快照为新模板。
+func AdminTenantClone(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	req := &protos.TenantCloneReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, 1024); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if err := authorizeAdminTenant(sessionUser, "AdminTenantClone", req.TenantID); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	m, err := service.AdminTenantClone(&sessionUser, req)
+	if err != nil {
+		core.Logger().Error("AdminTenantClone ERR: ", zap.Uint64("tenantID", req.TenantID), zap.String("name", req.Name), zap.Error(err))
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, m)
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	gocommon "github.com/liuhengloveyou/go-common"
+	"github.com/liuhengloveyou/passport/v4/accessctl"
 	"github.com/liuhengloveyou/passport/v4/common"
 	"github.com/liuhengloveyou/passport/v4/face/core"
 	"github.com/liuhengloveyou/passport/v4/protos"
@@ -44,6 +45,7 @@ func authorizeAdminTenant(sessionUser protos.User, action string, targetTenantID
 
 // AdminTenantNew 创建新租户；可同时在租户域内创建初始管理员账号（昵称+密码均提供时），
 // 该账号在租户域内绑定 root；否则仅创建租户并由当前操作者暂为归属。
+// 带 templateId 时按租户模板创建组织、部门、角色策略与数据范围。
 func AdminTenantNew(w http.ResponseWriter, r *http.Request) {
 	sessionUser := core.GetSessionUser(r)
 	core.Logger().Info("AdminTenantNew start",
@@ -75,6 +77,7 @@ func AdminTenantNew(w http.ResponseWriter, r *http.Request) {
 		zap.String("tenant_name", req.TenantName),
 		zap.String("tenant_type", req.TenantType),
 		zap.Uint64("parent_id", req.ParentID),
+		zap.Uint64("template_id", req.TemplateID),
 		zap.Bool("want_admin", wantAdmin),
 		zap.Bool("has_cellphone", req.Cellphone != ""),
 		zap.Bool("has_nickname", req.Nickname != ""),
@@ -207,7 +210,7 @@ func AdminSetParent(w http.ResponseWriter, r *http.Request) {
 	gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
 }
 
-// AdminTenantDelete 删除指定租户（不允许删除根租户）。
+// AdminTenantDelete 删除指定租户（不允许删除根租户）：先归档，恢复期内可用 admin/tenant/restore 恢复，过期后由清理任务物理删除。
 func AdminTenantDelete(w http.ResponseWriter, r *http.Request) {
 	sessionUser := core.GetSessionUser(r)
 	tenantID, _ := strconv.ParseUint(r.FormValue("tid"), 10, 64)
@@ -238,7 +241,46 @@ func AdminTenantDelete(w http.ResponseWriter, r *http.Request) {
 	gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
 }
 
-// AdminUpdateTenantConfiguration 按租户 ID 更新配置数据。
+// AdminTenantRestore 在恢复期内撤销租户删除。
+func AdminTenantRestore(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	tenantID, _ := strconv.ParseUint(r.FormValue("tid"), 10, 64)
+	if tenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if err := authorizeAdminTenant(sessionUser, "AdminTenantRestore", tenantID); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if err := service.AdminTenantRestore(&sessionUser, tenantID); err != nil {
+		core.Logger().Error("AdminTenantRestore ERR: ", zap.Uint64("tenantID", tenantID), zap.Error(err))
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+}
+
+// AdminTenantPurgeList 分页查询已物理删除租户的清理记录。
+func AdminTenantPurgeList(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if err := authorizeAdminTenant(sessionUser, "AdminTenantPurgeList", 0); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusUnauthorized, err)
+		return
+	}
+	page, _ := strconv.ParseUint(r.FormValue("page"), 10, 64)
+	pageSize, _ := strconv.ParseUint(r.FormValue("pageSize"), 10, 64)
+
+	rr, err := service.AdminTenantPurgeList(page, pageSize)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, rr)
+}
+
+// AdminUpdateTenantConfiguration 按租户 ID 增量更新配置数据；带 last_update_time 时做乐观锁。
 func AdminUpdateTenantConfiguration(w http.ResponseWriter, r *http.Request) {
 	sessionUser := core.GetSessionUser(r)
 	var req map[string]interface{}
@@ -256,7 +298,8 @@ func AdminUpdateTenantConfiguration(w http.ResponseWriter, r *http.Request) {
 		gocommon.HttpJsonErr(w, http.StatusUnauthorized, err)
 		return
 	}
-	if err := service.TenantUpdateConfiguration(uint64(tenantID), data); err != nil {
+	lastUpdateTime, _ := req["last_update_time"].(string)
+	if err := service.TenantUpdateConfiguration(uint64(tenantID), sessionUser.UID, lastUpdateTime, data); err != nil {
 		gocommon.HttpJsonErr(w, http.StatusOK, err)
 		return
 	}
@@ -346,3 +389,110 @@ func AdminTenantUpdate(w http.ResponseWriter, r *http.Request) {
 	)
 	gocommon.HttpErr(w, http.StatusOK, 0, "OK")
 }
+
+// AdminTenantSetStatus 变更租户生命周期状态（trial/active/suspended/expired/archived），需注明原因。
+func AdminTenantSetStatus(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	req := &protos.TenantStatusReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, 1024); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.TenantID <= 0 || req.Reason == "" {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if err := authorizeAdminTenant(sessionUser, "AdminTenantSetStatus", req.TenantID); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if err := service.AdminTenantSetStatus(&sessionUser, req); err != nil {
+		core.Logger().Error("AdminTenantSetStatus ERR: ", zap.Uint64("tenantID", req.TenantID), zap.String("status", req.Status), zap.Error(err))
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+}
+
+// AdminTenantSetQuota 设置租户配额（成员、组织、部门、角色数上限），返回设置后的用量。
+func AdminTenantSetQuota(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	req := &protos.TenantQuotaReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, 1024); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if req.TenantID <= 0 || len(req.Quotas) == 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if err := authorizeAdminTenant(sessionUser, "AdminTenantSetQuota", req.TenantID); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	usage, err := service.AdminTenantSetQuota(&sessionUser, req)
+	if err != nil {
+		core.Logger().Error("AdminTenantSetQuota ERR: ", zap.Uint64("tenantID", req.TenantID), zap.Any("quotas", req.Quotas), zap.Error(err))
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, usage)
+}
+
+// AdminTenantQuota 查询任一租户的配额与用量。
+func AdminTenantQuota(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	tenantID, _ := strconv.ParseUint(r.FormValue("tid"), 10, 64)
+	if tenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if err := authorizeAdminTenant(sessionUser, "AdminTenantQuota", tenantID); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	usage, err := service.TenantQuotaUsage(tenantID)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, usage)
+}
+
+// AdminTenantCollapseDomain 迁移：把租户各组织中重复的角色绑定与策略合并为租户级记录；migrateLegacy=true 时同时迁入老版本租户域的遗留数据。
+func AdminTenantCollapseDomain(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	tenantID, _ := strconv.ParseUint(r.FormValue("tid"), 10, 64)
+	if tenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if err := authorizeAdminTenant(sessionUser, "AdminTenantCollapseDomain", tenantID); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	migrateLegacy, _ := strconv.ParseBool(r.FormValue("migrateLegacy"))
+	rst, err := service.CollapseTenantDomain(tenantID, migrateLegacy)
+	if err != nil {
+		core.Logger().Error("AdminTenantCollapseDomain ERR: ", zap.Uint64("tenantID", tenantID), zap.Error(err))
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+
+	gocommon.HttpErr(w, http.StatusOK, 0, rst)
+}
+
+// AdminDecisionCacheStats 鉴权决策缓存命中率等统计。
+func AdminDecisionCacheStats(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID != common.ServConfig.RootTenantID {
+		gocommon.HttpJsonErr(w, http.StatusUnauthorized, common.ErrNoAuth)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, accessctl.DecisionCacheStats())
+}
@@ -0,0 +1,82 @@
+// admin_tenant_config.go 提供平台管理员的租户配置版本接口：版本历史、两个版本的差异与回滚。
+package admin
+
+import (
+	"net/http"
+	"strconv"
+
+	gocommon "github.com/liuhengloveyou/go-common"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/face/core"
+	"github.com/liuhengloveyou/passport/v4/protos"
+	"github.com/liuhengloveyou/passport/v4/service"
+	"go.uber.org/zap"
+)
+
+// AdminTenantConfigVersions 分页查询租户的配置版本（?tid=），新版本在前。
+func AdminTenantConfigVersions(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	tid, _ := strconv.ParseUint(r.FormValue("tid"), 10, 64)
+	if tid <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if err := authorizeAdminTenant(sessionUser, "AdminTenantConfigVersions", tid); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusUnauthorized, err)
+		return
+	}
+	page, _ := strconv.ParseUint(r.FormValue("page"), 10, 64)
+	pageSize, _ := strconv.ParseUint(r.FormValue("pageSize"), 10, 64)
+
+	rr, err := service.TenantConfigVersionList(tid, page, pageSize)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, rr)
+}
+
+// AdminTenantConfigDiff 查看租户两个配置版本之间的差异（?tid=&from=&to=）。
+func AdminTenantConfigDiff(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	tid, _ := strconv.ParseUint(r.FormValue("tid"), 10, 64)
+	from, _ := strconv.ParseUint(r.FormValue("from"), 10, 64)
+	to, _ := strconv.ParseUint(r.FormValue("to"), 10, 64)
+	if tid <= 0 || from <= 0 || to <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if err := authorizeAdminTenant(sessionUser, "AdminTenantConfigDiff", tid); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	rr, err := service.TenantConfigVersionDiff(tid, from, to)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, rr)
+}
+
+// AdminTenantConfigRollback 把租户配置回滚到指定版本，回滚记为一个新版本。
+func AdminTenantConfigRollback(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	req := &protos.TenantConfigRollbackReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, 1024); err != nil || req.TenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if err := authorizeAdminTenant(sessionUser, "AdminTenantConfigRollback", req.TenantID); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	m, err := service.TenantConfigRollback(req.TenantID, sessionUser.UID, req)
+	if err != nil {
+		core.Logger().Error("AdminTenantConfigRollback ERR: ", zap.Uint64("tenantID", req.TenantID), zap.Uint64("version", req.Version), zap.Error(err))
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, m)
+}
@@ -7,7 +7,6 @@ import (
 	"strings"
 
 	gocommon "github.com/liuhengloveyou/go-common"
-	"github.com/liuhengloveyou/passport/v4/accessctl"
 	"github.com/liuhengloveyou/passport/v4/common"
 	"github.com/liuhengloveyou/passport/v4/face/core"
 	"github.com/liuhengloveyou/passport/v4/face/tenant"
@@ -45,45 +44,11 @@ func UserAdd(w http.ResponseWriter, r *http.Request) {
 		gocommon.HttpJsonErr(w, http.StatusUnauthorized, err)
 		return
 	}
-	if _, err := service.AdminTenantTake(req.TenantID); err != nil {
-		gocommon.HttpJsonErr(w, http.StatusOK, err)
-		return
-	}
-
-	ext := req.Ext
-	if ext == nil {
-		ext = protos.MapStruct{}
-	}
-
-	userReq := &protos.UserReq{
-		UID:       0,
-		TenantID:  0,
-		Nickname:  req.Nickname,
-		Password:  req.Password,
-		Cellphone: req.Cellphone,
-		Email:     req.Email,
-		Roles:     []string{"root"},
-		DepIds:    nil,
-		Ext:       ext,
-	}
-
-	uid, err := service.AddUserService(userReq)
+	uid, err := service.TenantAdminUserAdd(&req)
 	if err != nil {
 		gocommon.HttpJsonErr(w, http.StatusOK, err)
 		return
 	}
-	if uid <= 0 {
-		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrService)
-		return
-	}
-	if err := service.TenantBindUser(uid, req.TenantID); err != nil {
-		gocommon.HttpJsonErr(w, http.StatusOK, err)
-		return
-	}
-	if err := service.AddRoleForUserInAllOrgs(uid, req.TenantID, "root"); err != nil {
-		gocommon.HttpJsonErr(w, http.StatusOK, err)
-		return
-	}
 
 	gocommon.HttpErr(w, http.StatusOK, 0, uid)
 }
@@ -226,44 +191,10 @@ func UserEdit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if req.Roles != nil {
-		orgs, oerr := service.OrgListByTenant(tenantID)
-		if oerr != nil {
-			gocommon.HttpJsonErr(w, http.StatusOK, oerr)
+		if err = service.TenantUserSetRoles(req.UID, tenantID, req.Roles); err != nil {
+			gocommon.HttpJsonErr(w, http.StatusOK, err)
 			return
 		}
-		newSet := make(map[string]struct{}, len(req.Roles))
-		for _, role := range req.Roles {
-			role = strings.TrimSpace(role)
-			if role != "" {
-				newSet[role] = struct{}{}
-			}
-		}
-		for i := range orgs {
-			oldRoles := accessctl.GetRoleForUserInDomain(req.UID, tenantID, orgs[i].ID)
-			oldSet := make(map[string]struct{}, len(oldRoles))
-			for _, role := range oldRoles {
-				role = strings.TrimSpace(role)
-				if role != "" {
-					oldSet[role] = struct{}{}
-				}
-			}
-			for role := range newSet {
-				if _, ok := oldSet[role]; ok {
-					delete(oldSet, role)
-					continue
-				}
-				if err = accessctl.AddRoleForUserInDomain(req.UID, tenantID, orgs[i].ID, role); err != nil {
-					gocommon.HttpJsonErr(w, http.StatusOK, common.ErrService)
-					return
-				}
-			}
-			for role := range oldSet {
-				if err = accessctl.DeleteRoleForUserInDomain(req.UID, tenantID, orgs[i].ID, role); err != nil {
-					gocommon.HttpJsonErr(w, http.StatusOK, common.ErrService)
-					return
-				}
-			}
-		}
 	}
 
 	pwd := strings.TrimSpace(req.Pwd)
@@ -44,13 +44,14 @@ func Logger() *zap.Logger {
 	return common.Logger
 }
 
+// ParseOrgID 请求的组织：优先取 X-Org-Id 请求头，没有时取会话的当前组织（user/switchOrg 或登录时的默认组织）。
 func ParseOrgID(r *http.Request) uint64 {
 	if r == nil {
 		return 0
 	}
 	s := strings.TrimSpace(r.Header.Get("X-Org-Id"))
 	if s == "" {
-		return 0
+		return GetSessionUser(r).OrgID
 	}
 	v, err := strconv.ParseUint(s, 10, 64)
 	if err != nil || v == 0 {
@@ -81,24 +82,32 @@ func GetSessionUser(r *http.Request) (sessionUser protos.User) {
 	return sess.Values[common.SessUserInfoKey].(protos.User)
 }
 
+// AuthFilter 会话是否有效；需要区分失败原因时用 AuthCheck。
 func AuthFilter(r *http.Request) (sess *sessions.Session, auth bool) {
-	var err error
-	sess, err = sessionStore.Get(r, common.ServConfig.SessionKey)
+	sess, err := AuthCheck(r)
+	return sess, err == nil
+}
+
+// AuthCheck 校验会话：未登录、账号停用、已不属于会话所在租户返回 ErrNoLogin；
+// 所属租户停用、过期、归档、删除分别返回 ErrTenantSuspended、ErrTenantExpired、ErrTenantArchived、ErrTenantDeleted，平台根租户不受限制。
+func AuthCheck(r *http.Request) (*sessions.Session, error) {
+	sess, err := sessionStore.Get(r, common.ServConfig.SessionKey)
 	if err != nil {
 		Logger().Error("session ERR: ", zap.Error(err))
-		return nil, false
+		return nil, common.ErrNoLogin
 	}
 
 	if sess.Values[common.SessUserInfoKey] == nil {
-		return nil, false
+		return nil, common.ErrNoLogin
 	}
-	if _, ok := sess.Values[common.SessUserInfoKey].(protos.User); !ok {
-		return nil, false
+	sessUser, ok := sess.Values[common.SessUserInfoKey].(protos.User)
+	if !ok {
+		return nil, common.ErrNoLogin
 	}
 
-	uid := sess.Values[common.SessUserInfoKey].(protos.User).UID
+	uid := sessUser.UID
 	if !protos.IsRealUserUID(uid) {
-		return nil, false
+		return nil, common.ErrNoLogin
 	}
 
 	userInfo, ok := loginUserCache.Load(uid)
@@ -109,17 +118,29 @@ func AuthFilter(r *http.Request) (sess *sessions.Session, auth bool) {
 	}
 	if cached == nil {
 		Logger().Sugar().Warnf("AuthFilter: user %v not found\n", uid)
-		return nil, false
+		return nil, common.ErrNoLogin
 	}
 
 	cached.CacheTime = time.Now().Unix()
 	loginUserCache.Store(uid, cached)
 
-	disabled, ok := cached.Ext["disabled"].(float64)
-	if ok && protos.UserDisableStatus(int8(disabled)) == protos.UserDisabled {
-		return nil, false
+	if service.UserLegacyDisabled(cached.Ext) {
+		return nil, common.ErrNoLogin
+	}
+	// 会话可能已切换到其他租户，移出该租户或在该租户被停用后会话随即失效
+	if sessUser.TenantID > 0 && service.UserInTenant(uid, sessUser.TenantID) != nil {
+		Logger().Sugar().Warnf("AuthFilter: user %v not in tenant %v\n", uid, sessUser.TenantID)
+		return nil, common.ErrNoLogin
+	}
+	if service.CheckTenantMemberEnabled(uid, sessUser.TenantID) != nil {
+		Logger().Sugar().Warnf("AuthFilter: user %v disabled in tenant %v\n", uid, sessUser.TenantID)
+		return nil, common.ErrNoLogin
+	}
+	if err = service.CheckTenantStatus(sessUser.TenantID); err != nil {
+		Logger().Sugar().Warnf("AuthFilter: user %v tenant %v: %v\n", uid, sessUser.TenantID, err)
+		return nil, err
 	}
-	return sess, true
+	return sess, nil
 }
 
 func ReadJSONBodyFromRequest(r *http.Request, dst interface{}, bodyMaxLen int) error {
@@ -16,6 +16,7 @@ import (
 	faceAdmin "github.com/liuhengloveyou/passport/v4/face/admin"
 	faceAli "github.com/liuhengloveyou/passport/v4/face/ali"
 	"github.com/liuhengloveyou/passport/v4/face/core"
+	facePartner "github.com/liuhengloveyou/passport/v4/face/partner"
 	faceSms "github.com/liuhengloveyou/passport/v4/face/sms"
 	faceTenant "github.com/liuhengloveyou/passport/v4/face/tenant"
 	"github.com/liuhengloveyou/passport/v4/face/user"
@@ -54,6 +55,9 @@ func init() {
 		"user/modify/getbackpwd": {Handler: user.UserGetBackPassword},
 		"user/modify/avatarForm": {Handler: user.UserModifyAvatarForm, NeedLogin: true},
 		"user/s/1":               {Handler: user.UserSearchLite},
+		"user/tenants":           {Handler: user.UserTenants, NeedLogin: true},
+		"user/switchTenant":      {Handler: user.UserSwitchTenant, NeedLogin: true},
+		"user/switchOrg":         {Handler: user.UserSwitchOrg, NeedLogin: true},
 
 		// 权限与访问控制接口
 		"access/addRoleForUser":       {Handler: faceAccess.AddRoleForUser, NeedLogin: true, NeedAccess: true},
@@ -66,9 +70,14 @@ func init() {
 		"access/removePolicyFromRole": {Handler: faceAccess.RemovePolicyFromRole, NeedLogin: true, NeedAccess: true},
 		"access/getPolicy":            {Handler: faceAccess.GetPolicy, NeedLogin: true, NeedAccess: true},
 		"access/getPolicyForUser":     {Handler: faceAccess.GetPolicyForUser, NeedLogin: true},
+		"access/enforceBatch":         {Handler: faceAccess.EnforceBatch, NeedLogin: true},
 		"access/createPermission":     {Handler: faceAccess.PermissionCreate, NeedLogin: true, NeedAccess: true},
 		"access/deletePermission":     {Handler: faceAccess.PermissionDelete, NeedLogin: true, NeedAccess: true},
 		"access/listPermission":       {Handler: faceAccess.PermissionList, NeedLogin: true, NeedAccess: true},
+		"access/treePermission":       {Handler: faceAccess.PermissionTree, NeedLogin: true, NeedAccess: true},
+		"access/explain":              {Handler: faceAccess.Explain, NeedLogin: true, NeedAccess: true},
+		"access/setDataScopeDeps":     {Handler: faceAccess.SetDataScopeDeps, NeedLogin: true, NeedAccess: true},
+		"access/getDataScopeDeps":     {Handler: faceAccess.GetDataScopeDeps, NeedLogin: true, NeedAccess: true},
 
 		// 租户与组织结构接口
 		"tenant/add":                  {Handler: faceTenant.Add, NeedLogin: true},
@@ -82,14 +91,34 @@ func init() {
 		"tenant/addRole":              {Handler: faceTenant.AddRole, NeedLogin: true, NeedAccess: true},
 		"tenant/delRole":              {Handler: faceTenant.DelRole, NeedLogin: true, NeedAccess: true},
 		"tenant/getRoles":             {Handler: faceTenant.GetRole, NeedLogin: true, NeedAccess: true},
+		"tenant/quota":                {Handler: faceTenant.Quota, NeedLogin: true, NeedAccess: true},
+		"tenant/setExclusiveRoles":    {Handler: faceTenant.SetExclusiveRoles, NeedLogin: true, NeedAccess: true},
+		"tenant/roleViolations":       {Handler: faceTenant.RoleViolations, NeedLogin: true, NeedAccess: true},
 		"tenant/updateConfiguration":  {Handler: faceTenant.UpdateConfiguration, NeedLogin: true, NeedAccess: true},
 		"tenant/loadConfiguration":    {Handler: faceTenant.LoadConfiguration, NeedLogin: true},
+		"tenant/config/versions":      {Handler: faceTenant.ConfigVersions, NeedLogin: true, NeedAccess: true},
+		"tenant/config/diff":          {Handler: faceTenant.ConfigDiff, NeedLogin: true, NeedAccess: true},
+		"tenant/config/rollback":      {Handler: faceTenant.ConfigRollback, NeedLogin: true, NeedAccess: true},
 		"tenant/tree/list":            {Handler: faceTenant.TreeList, NeedLogin: true},
+		"tenant/invite/create":        {Handler: faceTenant.InviteCreate, NeedLogin: true, NeedAccess: true},
+		"tenant/invite/list":          {Handler: faceTenant.InviteList, NeedLogin: true, NeedAccess: true},
+		"tenant/invite/resend":        {Handler: faceTenant.InviteResend, NeedLogin: true, NeedAccess: true},
+		"tenant/invite/revoke":        {Handler: faceTenant.InviteRevoke, NeedLogin: true, NeedAccess: true},
+		"tenant/invite/accept":        {Handler: faceTenant.InviteAccept},
 		"tenant/department/add":       {Handler: faceTenant.DepartmentAdd, NeedLogin: true, NeedAccess: true},
 		"tenant/department/delete":    {Handler: faceTenant.DepartmentDelete, NeedLogin: true, NeedAccess: true},
 		"tenant/department/update":    {Handler: faceTenant.DepartmentUpdate, NeedLogin: true, NeedAccess: true},
 		"tenant/department/updatecfg": {Handler: faceTenant.DepartmentUpdateConfig, NeedLogin: true, NeedAccess: true},
 		"tenant/department/list":      {Handler: faceTenant.DepartmentList, NeedLogin: true},
+		"org/add":                     {Handler: faceTenant.OrgAdd, NeedLogin: true, NeedAccess: true},
+		"org/tree":                    {Handler: faceTenant.OrgTree, NeedLogin: true},
+		"org/move":                    {Handler: faceTenant.OrgMove, NeedLogin: true, NeedAccess: true},
+		"org/updateConfiguration":     {Handler: faceTenant.OrgUpdateConfiguration, NeedLogin: true, NeedAccess: true},
+		"org/loadConfiguration":       {Handler: faceTenant.OrgLoadConfiguration, NeedLogin: true},
+		"org/effectiveConfiguration":  {Handler: faceTenant.OrgEffectiveConfiguration, NeedLogin: true},
+		"org/member/transfer":         {Handler: faceTenant.OrgMemberTransfer, NeedLogin: true, NeedAccess: true},
+		"org/member/bulkTransfer":     {Handler: faceTenant.OrgMemberBulkTransfer, NeedLogin: true, NeedAccess: true},
+		"org/member/transfers":        {Handler: faceTenant.OrgMemberTransferList, NeedLogin: true, NeedAccess: true},
 
 		// SAAS平台管理员接口
 		"admin/tenant/new": {Handler: faceAdmin.AdminTenantNew, NeedLogin: true, NeedAccess: false},
@@ -101,10 +130,43 @@ func init() {
 		"admin/tenant/query":              {Handler: faceAdmin.AdminTenantQuery, NeedLogin: true, NeedAccess: false},
 		"admin/tenant/setParent":          {Handler: faceAdmin.AdminSetParent, NeedLogin: true, NeedAccess: false},
 		"admin/tenant/delete":             {Handler: faceAdmin.AdminTenantDelete, NeedLogin: true, NeedAccess: false},
+		"admin/tenant/restore":            {Handler: faceAdmin.AdminTenantRestore, NeedLogin: true, NeedAccess: false},
+		"admin/tenant/purges":             {Handler: faceAdmin.AdminTenantPurgeList, NeedLogin: true, NeedAccess: false},
 		"admin/tenant/update":             {Handler: faceAdmin.AdminTenantUpdate, NeedLogin: true, NeedAccess: false},
+		"admin/tenant/setStatus":          {Handler: faceAdmin.AdminTenantSetStatus, NeedLogin: true, NeedAccess: false},
+		"admin/tenant/setQuota":           {Handler: faceAdmin.AdminTenantSetQuota, NeedLogin: true, NeedAccess: false},
+		"admin/tenant/quota":              {Handler: faceAdmin.AdminTenantQuota, NeedLogin: true, NeedAccess: false},
 		"admin/updateTenantConfiguration": {Handler: faceAdmin.AdminUpdateTenantConfiguration, NeedLogin: true, NeedAccess: false},
 		"admin/modifyUserPassword":        {Handler: faceAdmin.ModifyUserPassword, NeedLogin: true, NeedAccess: false},
 		"admin/tenant/update_config":      {Handler: faceAdmin.AdminTenantUpdateConfig, NeedLogin: true, NeedAccess: false},
+		"admin/tenant/config/versions":    {Handler: faceAdmin.AdminTenantConfigVersions, NeedLogin: true, NeedAccess: false},
+		"admin/tenant/config/diff":        {Handler: faceAdmin.AdminTenantConfigDiff, NeedLogin: true, NeedAccess: false},
+		"admin/tenant/config/rollback":    {Handler: faceAdmin.AdminTenantConfigRollback, NeedLogin: true, NeedAccess: false},
+		"admin/tenant/collapseDomain":     {Handler: faceAdmin.AdminTenantCollapseDomain, NeedLogin: true, NeedAccess: false},
+		"admin/tenant/clone":              {Handler: faceAdmin.AdminTenantClone, NeedLogin: true, NeedAccess: false},
+		"admin/template/add":              {Handler: faceAdmin.AdminTemplateAdd, NeedLogin: true, NeedAccess: false},
+		"admin/template/update":           {Handler: faceAdmin.AdminTemplateUpdate, NeedLogin: true, NeedAccess: false},
+		"admin/template/list":             {Handler: faceAdmin.AdminTemplateList, NeedLogin: true, NeedAccess: false},
+		"admin/template/get":              {Handler: faceAdmin.AdminTemplateGet, NeedLogin: true, NeedAccess: false},
+		"admin/template/delete":           {Handler: faceAdmin.AdminTemplateDelete, NeedLogin: true, NeedAccess: false},
+		"admin/access/cacheStats":         {Handler: faceAdmin.AdminDecisionCacheStats, NeedLogin: true, NeedAccess: false},
+
+		// 上级租户代管下级租户接口：须在上级租户内授权，目标租户须是调用者所在租户的后代
+		"partner/tenant/new":             {Handler: facePartner.TenantNew, NeedLogin: true, NeedAccess: true},
+		"partner/tenant/list":            {Handler: facePartner.TenantList, NeedLogin: true, NeedAccess: true},
+		"partner/tenant/get":             {Handler: facePartner.TenantGet, NeedLogin: true, NeedAccess: true},
+		"partner/tenant/setStatus":       {Handler: facePartner.TenantSetStatus, NeedLogin: true, NeedAccess: true},
+		"partner/tenant/delete":          {Handler: facePartner.TenantDelete, NeedLogin: true, NeedAccess: true},
+		"partner/tenant/restore":         {Handler: facePartner.TenantRestore, NeedLogin: true, NeedAccess: true},
+		"partner/tenant/config/load":     {Handler: facePartner.ConfigLoad, NeedLogin: true, NeedAccess: true},
+		"partner/tenant/config/update":   {Handler: facePartner.ConfigUpdate, NeedLogin: true, NeedAccess: true},
+		"partner/tenant/config/versions": {Handler: facePartner.ConfigVersions, NeedLogin: true, NeedAccess: true},
+		"partner/tenant/config/rollback": {Handler: facePartner.ConfigRollback, NeedLogin: true, NeedAccess: true},
+		"partner/user/list":              {Handler: facePartner.UserList, NeedLogin: true, NeedAccess: true},
+		"partner/user/add":               {Handler: facePartner.UserAdd, NeedLogin: true, NeedAccess: true},
+		"partner/user/disable":           {Handler: facePartner.UserDisable, NeedLogin: true, NeedAccess: true},
+		"partner/user/modifyPassword":    {Handler: facePartner.UserModifyPassword, NeedLogin: true, NeedAccess: true},
+		"partner/user/del":               {Handler: facePartner.UserDel, NeedLogin: true, NeedAccess: true},
 
 		// 短信验证码接口
 		"sms/sendUserAddSmsCode": {Handler: faceSms.SendUserAddSmsCode},
@@ -116,6 +178,8 @@ func init() {
 		"wx/bindCellphone":      {Handler: faceWx.WxMpBindCellphone, NeedLogin: true},
 		"wx/miniapp/updateInfo": {Handler: faceWx.WxMiniAppUserInfoUpdate, NeedLogin: true},
 	}
+
+	faceAccess.SetApiConfLookup(resolveApiConf)
 }
 
 func InitAndRunHttpApi(options *protos.OptionStruct) (handler http.Handler) {
@@ -131,6 +195,10 @@ func InitAndRunHttpApi(options *protos.OptionStruct) (handler http.Handler) {
 		if e := accessctl.InitAccessControl("rbac_with_domains_model.conf", common.ServConfig.DBDriver, common.ServConfig.DBDSN); e != nil {
 			panic(e)
 		}
+		// 限时角色授权：到点生效、过期移除
+		service.StartRoleGrantJob(time.Minute)
+		// 删除的租户过了恢复期后物理删除
+		service.StartTenantPurgeJob(time.Hour)
 	}
 
 	logger = common.Logger
@@ -172,6 +240,19 @@ func GetSessionUser(r *http.Request) protos.User {
 	return core.GetSessionUser(r)
 }
 
+// EnforceBatch 供嵌入方按当前会话用户与 X-Org-Id 批量鉴权，结果与 items 一一对应。
+func EnforceBatch(r *http.Request, items []protos.EnforceReq) ([]bool, error) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		return nil, common.ErrNoLogin
+	}
+	orgID, err := core.SessionOrgID(r, sessionUser.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	return accessctl.EnforceBatch(sessionUser.UID, sessionUser.TenantID, orgID, items)
+}
+
 func (p *PassportHttpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	origin := r.Header.Get("Origin")
 	if origin != "" {
@@ -207,15 +288,15 @@ func (p *PassportHttpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if apiHandler.NeedLogin {
-		sess, auth := core.AuthFilter(r)
-		if !auth && sess == nil {
+		sess, err := core.AuthCheck(r)
+		if err == common.ErrTenantSuspended || err == common.ErrTenantExpired || err == common.ErrTenantArchived || err == common.ErrTenantDeleted {
+			logger.Sugar().Infof("passport http api tenant status: %v %v %v %v\n", r.Method, apiName, r.URL, err)
+			gocommon.HttpJsonErr(w, http.StatusForbidden, err)
+			return
+		} else if err != nil {
 			logger.Sugar().Errorf("passport http api no login: %v %v %v\n", r.Method, apiName, r.URL)
 			gocommon.HttpErr(w, http.StatusUnauthorized, -1, "请登录")
 			return
-		} else if !auth && sess != nil {
-			logger.Sugar().Infof("passport http api no access: %v %v %v\n", r.Method, apiName, r.URL)
-			gocommon.HttpErr(w, http.StatusForbidden, -1, "您没有权限")
-			return
 		}
 		r = r.WithContext(context.WithValue(context.Background(), "session", sess))
 	}
@@ -264,20 +345,12 @@ func AccessFilter(r *http.Request) bool {
 		logger.Sugar().Errorf("passport http api no admin: %v %v\n", r.Method, r.URL)
 		return false
 	}
-	needAccess := false
-	if apiHandler, ok := apis[obj]; ok {
-		needAccess = apiHandler.NeedAccess
-	} else {
-		apiConf, ok := common.ServConfig.ApiConf[obj]
-		if !ok {
-			if apiConf, ok = common.ServConfig.ApiConf["*"]; !ok {
-				logger.Sugar().Errorf("passport http api no apiConf: %v %v\n", r.Method, r.URL)
-				return false
-			}
-		}
-		needAccess = apiConf.NeedAccess
+	apiConf := resolveApiConf(obj)
+	if apiConf.Source == "none" {
+		logger.Sugar().Errorf("passport http api no apiConf: %v %v\n", r.Method, r.URL)
+		return false
 	}
-	if needAccess {
+	if apiConf.NeedAccess {
 		orgID := core.ParseOrgID(r)
 		if orgID == 0 {
 			logger.Sugar().Errorf("passport http api no org: %v %v\n", r.Method, r.URL)
@@ -300,3 +373,17 @@ func AccessFilter(r *http.Request) bool {
 	logger.Sugar().Infof("passport http api no access: %v %v\n", r.Method, r.URL)
 	return true
 }
+
+// resolveApiConf 判定 obj 是否需要鉴权：passport 内置接口取 apis 表，其余取 ApiConf[obj]，再退回 ApiConf["*"]。
+func resolveApiConf(obj string) *protos.ApiConfExplain {
+	if apiHandler, ok := apis[obj]; ok {
+		return &protos.ApiConfExplain{Source: "passport", NeedAccess: apiHandler.NeedAccess}
+	}
+	if apiConf, ok := common.ServConfig.ApiConf[obj]; ok {
+		return &protos.ApiConfExplain{Source: "apiConf", NeedAccess: apiConf.NeedAccess}
+	}
+	if apiConf, ok := common.ServConfig.ApiConf["*"]; ok {
+		return &protos.ApiConfExplain{Source: "apiConf:*", NeedAccess: apiConf.NeedAccess}
+	}
+	return &protos.ApiConfExplain{Source: "none"}
+}
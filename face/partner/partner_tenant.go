@@ -0,0 +1,215 @@
+// Package partner 提供上级租户（代理商）代管下级租户的接口：开通与生命周期、配置、账号。
+// 路由须在上级租户内授权（NeedAccess），目标租户须是调用者所在租户的后代。
+package partner
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	gocommon "github.com/liuhengloveyou/go-common"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/face/core"
+	"github.com/liuhengloveyou/passport/v4/protos"
+	"github.com/liuhengloveyou/passport/v4/service"
+	"go.uber.org/zap"
+)
+
+// TenantNew 在当前租户或其下级租户（parentId）下开通子租户，可同时建管理员账号、按模板初始化。
+func TenantNew(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	req := &protos.NewTenantReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, 10240); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	req.TenantName = strings.TrimSpace(req.TenantName)
+	req.TenantType = strings.TrimSpace(req.TenantType)
+
+	uid, tid, err := service.PartnerTenantNew(&sessionUser, req)
+	if err != nil {
+		core.Logger().Error("partner.TenantNew ERR: ", zap.Uint64("operator_tenant_id", sessionUser.TenantID), zap.String("tenant_name", req.TenantName), zap.Error(err))
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, map[string]uint64{"uid": uid, "tenantId": tid})
+}
+
+// TenantList 分页查询下级租户（含 tid 自身，depth 为相对层级）；不带 tid 时从当前租户查起。
+func TenantList(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	tid, _ := strconv.ParseUint(r.FormValue("tid"), 10, 64)
+	hasTotal, _ := strconv.ParseUint(r.FormValue("hasTotal"), 10, 64)
+	page, _ := strconv.ParseUint(r.FormValue("page"), 10, 64)
+	pageSize, _ := strconv.ParseUint(r.FormValue("pageSize"), 10, 64)
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	if tid == 0 {
+		tid = sessionUser.TenantID
+	} else if err := service.PartnerCheckTenant(&sessionUser, tid); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+
+	rst, err := service.TenantTreeList(&sessionUser, tid, page, pageSize, hasTotal == 1)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, rst)
+}
+
+// TenantGet 查询下级租户详情（?tid=）。
+func TenantGet(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	tid, _ := strconv.ParseUint(r.FormValue("tid"), 10, 64)
+	if tid <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+
+	m, err := service.PartnerTenantGet(&sessionUser, tid)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, m)
+}
+
+// TenantSetStatus 变更下级租户生命周期状态，需注明原因。
+func TenantSetStatus(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	req := &protos.TenantStatusReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, 1024); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+
+	if err := service.PartnerTenantSetStatus(&sessionUser, req); err != nil {
+		core.Logger().Error("partner.TenantSetStatus ERR: ", zap.Uint64("tenantID", req.TenantID), zap.String("status", req.Status), zap.Error(err))
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+}
+
+// TenantDelete 软删除下级租户（?tid=），恢复期内可用 partner/tenant/restore 恢复。
+func TenantDelete(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	tid, _ := strconv.ParseUint(r.FormValue("tid"), 10, 64)
+	if tid <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+
+	if err := service.PartnerTenantDelete(&sessionUser, tid); err != nil {
+		core.Logger().Error("partner.TenantDelete ERR: ", zap.Uint64("tenantID", tid), zap.Error(err))
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+}
+
+// TenantRestore 在恢复期内撤销下级租户的删除（?tid=）。
+func TenantRestore(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	tid, _ := strconv.ParseUint(r.FormValue("tid"), 10, 64)
+	if tid <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+
+	if err := service.PartnerTenantRestore(&sessionUser, tid); err != nil {
+		core.Logger().Error("partner.TenantRestore ERR: ", zap.Uint64("tenantID", tid), zap.Error(err))
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+}
+
+// ConfigLoad 按 key 读取下级租户配置（?tid=&k=）。
+func ConfigLoad(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	tid, _ := strconv.ParseUint(r.FormValue("tid"), 10, 64)
+	if err := service.PartnerCheckTenant(&sessionUser, tid); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+
+	rst, err := service.TenantLoadConfiguration(tid, strings.TrimSpace(r.FormValue("k")))
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, rst)
+}
+
+// ConfigUpdate 按键增量更新下级租户配置，last_update_time 做乐观锁，修改记入配置版本。
+func ConfigUpdate(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	req := &protos.TenantConfigMergeReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, 1024); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if err := service.PartnerCheckTenant(&sessionUser, req.TenantID); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+
+	if err := service.TenantUpdateConfiguration(req.TenantID, sessionUser.UID, req.LastUpdateTime, req.Data); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+}
+
+// ConfigVersions 分页查询下级租户的配置版本（?tid=）。
+func ConfigVersions(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	tid, _ := strconv.ParseUint(r.FormValue("tid"), 10, 64)
+	if err := service.PartnerCheckTenant(&sessionUser, tid); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	page, _ := strconv.ParseUint(r.FormValue("page"), 10, 64)
+	pageSize, _ := strconv.ParseUint(r.FormValue("pageSize"), 10, 64)
+
+	rr, err := service.TenantConfigVersionList(tid, page, pageSize)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, rr)
+}
+
+// ConfigRollback 把下级租户配置回滚到指定版本。
+func ConfigRollback(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	req := &protos.TenantConfigRollbackReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, 1024); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if err := service.PartnerCheckTenant(&sessionUser, req.TenantID); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+
+	m, err := service.TenantConfigRollback(req.TenantID, sessionUser.UID, req)
+	if err != nil {
+		core.Logger().Error("partner.ConfigRollback ERR: ", zap.Uint64("tenantID", req.TenantID), zap.Uint64("version", req.Version), zap.Error(err))
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, m)
+}
@@ -0,0 +1,119 @@
+// partner_user.go 提供上级租户管理下级租户账号的接口。
+package partner
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	gocommon "github.com/liuhengloveyou/go-common"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/face/core"
+	"github.com/liuhengloveyou/passport/v4/protos"
+	"github.com/liuhengloveyou/passport/v4/service"
+)
+
+// UserList 分页查询下级租户的账号（?tid=）。
+func UserList(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	tid, _ := strconv.ParseUint(r.FormValue("tid"), 10, 64)
+	hasTotal, _ := strconv.ParseUint(r.FormValue("hasTotal"), 10, 64)
+	page, _ := strconv.ParseUint(r.FormValue("page"), 10, 64)
+	pageSize, _ := strconv.ParseUint(r.FormValue("pageSize"), 10, 64)
+	if err := service.PartnerCheckTenant(&sessionUser, tid); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+
+	rr, err := service.AdminUserList(tid, page, pageSize, hasTotal == 1, strings.TrimSpace(r.FormValue("nickname")))
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, rr)
+}
+
+// UserAdd 为下级租户新增管理员账号，并在该租户所有组织内绑定 root 角色；请求体 roles 忽略。
+func UserAdd(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	req := &protos.AdminUserAddReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, 16<<10); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	req.Nickname = strings.TrimSpace(req.Nickname)
+	req.Password = strings.TrimSpace(req.Password)
+	req.Cellphone = strings.TrimSpace(req.Cellphone)
+	req.Email = strings.TrimSpace(req.Email)
+	if err := service.PartnerCheckTenant(&sessionUser, req.TenantID); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+
+	uid, err := service.TenantAdminUserAdd(req)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, uid)
+}
+
+// UserDisable 启用或禁用账号在下级租户的成员身份。
+func UserDisable(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	req := &protos.PartnerUserReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, 1024); err != nil || !protos.UserDisableStatus(req.Disable).IsValid() {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if err := service.PartnerUserDisable(&sessionUser, req.TenantID, req.UID, protos.UserDisableStatus(req.Disable)); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+}
+
+// UserModifyPassword 重置下级租户账号的密码。
+func UserModifyPassword(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	req := &protos.PartnerUserReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, 1024); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	pwd := strings.TrimSpace(req.Password)
+	if len(pwd) < 4 || len(pwd) > 16 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if err := service.PartnerCheckAccount(&sessionUser, req.TenantID, req.UID); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+
+	if _, err := service.SetUserPWD(req.UID, req.TenantID, pwd); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+}
+
+// UserDel 把账号移出下级租户。
+func UserDel(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	req := &protos.PartnerUserReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, 1024); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if err := service.PartnerCheckAccount(&sessionUser, req.TenantID, req.UID); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+
+	if _, err := service.TenantUserDel(req.UID, req.TenantID); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+}
@@ -0,0 +1,75 @@
+// tenant_config.go 提供当前租户的配置版本接口：版本历史、两个版本的差异与回滚。
+package tenant
+
+import (
+	"net/http"
+	"strconv"
+
+	gocommon "github.com/liuhengloveyou/go-common"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/face/core"
+	"github.com/liuhengloveyou/passport/v4/protos"
+	"github.com/liuhengloveyou/passport/v4/service"
+)
+
+// ConfigVersions 分页查询当前租户的配置版本，新版本在前；updateTime 为修改配置时要提交的 last_update_time。
+func ConfigVersions(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+		return
+	}
+	page, _ := strconv.ParseUint(r.FormValue("page"), 10, 64)
+	pageSize, _ := strconv.ParseUint(r.FormValue("pageSize"), 10, 64)
+
+	rr, err := service.TenantConfigVersionList(sessionUser.TenantID, page, pageSize)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, rr)
+}
+
+// ConfigDiff 查看当前租户两个配置版本之间的差异（?from=&to=）。
+func ConfigDiff(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+		return
+	}
+	from, _ := strconv.ParseUint(r.FormValue("from"), 10, 64)
+	to, _ := strconv.ParseUint(r.FormValue("to"), 10, 64)
+	if from <= 0 || to <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+
+	rr, err := service.TenantConfigVersionDiff(sessionUser.TenantID, from, to)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, rr)
+}
+
+// ConfigRollback 把当前租户配置回滚到指定版本，回滚记为一个新版本。
+func ConfigRollback(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+		return
+	}
+	req := &protos.TenantConfigRollbackReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, 1024); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+
+	m, err := service.TenantConfigRollback(sessionUser.TenantID, sessionUser.UID, req)
+	if err != nil {
+		common.Logger.Sugar().Errorf("ConfigRollback TenantConfigRollback ERR: %v %v %v\n", sessionUser.TenantID, req.Version, err)
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, m)
+}
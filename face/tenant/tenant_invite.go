@@ -0,0 +1,119 @@
+// tenant_invite.go 提供成员邀请接口：按手机号或邮箱发出邀请，查询、重发、撤销与接受邀请。
+package tenant
+
+import (
+	"net/http"
+	"strconv"
+
+	gocommon "github.com/liuhengloveyou/go-common"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/face/core"
+	"github.com/liuhengloveyou/passport/v4/protos"
+	"github.com/liuhengloveyou/passport/v4/service"
+)
+
+// InviteCreate 邀请手机号或邮箱加入当前租户的组织，邀请人须是该组织成员且能授予所带角色。
+func InviteCreate(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+		return
+	}
+	req := &protos.InviteReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, 1024); err != nil {
+		common.Logger.Sugar().Errorf("InviteCreate ReadJSONBodyFromRequest ERR: %v %v\n", sessionUser.TenantID, err)
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if err := service.UserInOrg(sessionUser.UID, sessionUser.TenantID, req.OrgID); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	if err := service.CheckRolesGrantable(sessionUser.UID, sessionUser.TenantID, req.OrgID, req.Roles); err != nil {
+		common.Logger.Sugar().Errorf("InviteCreate CheckRolesGrantable ERR: %v %v %v %v\n", sessionUser.UID, sessionUser.TenantID, req.Roles, err)
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+
+	m, err := service.InviteCreate(&sessionUser, req)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, m)
+}
+
+// InviteList 分页查询当前租户的邀请，可按 status 筛选。
+func InviteList(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+		return
+	}
+	page, _ := strconv.ParseUint(r.FormValue("page"), 10, 64)
+	pageSize, _ := strconv.ParseUint(r.FormValue("pageSize"), 10, 64)
+
+	rr, err := service.InviteList(sessionUser.TenantID, r.FormValue("status"), page, pageSize)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, rr)
+}
+
+// InviteResend 重新发送邀请（?id），之前的链接失效。
+func InviteResend(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+		return
+	}
+	id, _ := strconv.ParseUint(r.FormValue("id"), 10, 64)
+	if id <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+
+	if err := service.InviteResend(&sessionUser, id); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+}
+
+// InviteRevoke 撤销待接受的邀请（?id）。
+func InviteRevoke(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+		return
+	}
+	id, _ := strconv.ParseUint(r.FormValue("id"), 10, 64)
+	if id <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+
+	if err := service.InviteRevoke(&sessionUser, id); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+}
+
+// InviteAccept 凭邀请链接中的 token 接受邀请，无需登录；返回加入租户的 uid。
+func InviteAccept(w http.ResponseWriter, r *http.Request) {
+	req := &protos.InviteAcceptReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, 1024); err != nil {
+		common.Logger.Sugar().Errorf("InviteAccept ReadJSONBodyFromRequest ERR: %v\n", err)
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+
+	uid, err := service.InviteAccept(req)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, uid)
+}
@@ -0,0 +1,265 @@
+// tenant_org.go 提供组织管理接口：新建、组织树查询、移动、组织配置与成员调动。
+package tenant
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	gocommon "github.com/liuhengloveyou/go-common"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/face/core"
+	"github.com/liuhengloveyou/passport/v4/protos"
+	"github.com/liuhengloveyou/passport/v4/service"
+)
+
+// orgCheckParent 顶级组织只有租户 root 可以建立或移入；否则上级组织须在调用者当前组织的子树中。
+func orgCheckParent(r *http.Request, sessionUser protos.User, parentID uint64) error {
+	if parentID == 0 {
+		if !service.IsTenantRoot(sessionUser.UID, sessionUser.TenantID) {
+			return common.ErrNoAuth
+		}
+		return nil
+	}
+	currentOrgID, err := core.SessionOrgID(r, sessionUser.TenantID)
+	if err != nil {
+		return err
+	}
+	return service.OrgCheckScope(sessionUser.UID, sessionUser.TenantID, currentOrgID, parentID)
+}
+
+// OrgAdd 在当前租户下新建组织，可指定上级组织。
+func OrgAdd(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+		return
+	}
+	var req protos.OrgAddReq
+	if err := core.ReadJSONBodyFromRequest(r, &req, 1024); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if err := orgCheckParent(r, sessionUser, req.ParentID); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	id, err := service.OrgCreateChild(sessionUser.TenantID, req.ParentID, req.Name)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, id)
+}
+
+// OrgTree 组织树；不传 id 时租户 root 得到整个租户，其他用户得到当前组织的子树。
+func OrgTree(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+		return
+	}
+	id, _ := strconv.ParseUint(r.FormValue("id"), 10, 64)
+	if id == 0 && service.IsTenantRoot(sessionUser.UID, sessionUser.TenantID) {
+		rst, err := service.OrgTree(sessionUser.TenantID, 0)
+		if err != nil {
+			gocommon.HttpJsonErr(w, http.StatusOK, err)
+			return
+		}
+		gocommon.HttpErr(w, http.StatusOK, 0, rst)
+		return
+	}
+
+	currentOrgID, err := core.SessionOrgID(r, sessionUser.TenantID)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	if id == 0 {
+		id = currentOrgID
+	} else if err = service.OrgCheckScope(sessionUser.UID, sessionUser.TenantID, currentOrgID, id); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	rst, err := service.OrgTree(sessionUser.TenantID, id)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, rst)
+}
+
+// OrgMove 把组织连同下级组织移到新的上级组织下；不能移到自身或下级组织之下。
+func OrgMove(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+		return
+	}
+	var req protos.OrgMoveReq
+	if err := core.ReadJSONBodyFromRequest(r, &req, 1024); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	currentOrgID, err := core.SessionOrgID(r, sessionUser.TenantID)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	if err = service.OrgCheckScope(sessionUser.UID, sessionUser.TenantID, currentOrgID, req.OrgID); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	if err = orgCheckParent(r, sessionUser, req.ParentID); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	if err = service.OrgMove(sessionUser.TenantID, req.OrgID, req.ParentID); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+}
+
+// OrgUpdateConfiguration 按键增量更新当前组织配置，请求体 {"last_update_time": "...", "data": {...}}；
+// last_update_time 与组织当前的更新时间不一致时返回 ErrModify。
+func OrgUpdateConfiguration(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+		return
+	}
+	orgID, err := core.SessionOrgID(r, sessionUser.TenantID)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	req := &protos.OrgConfigMergeReq{}
+	if err = core.ReadJSONBodyFromRequest(r, req, 1024); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if err = service.OrgUpdateConfiguration(sessionUser.TenantID, orgID, sessionUser.UID, req.LastUpdateTime, req.Data); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+}
+
+// OrgLoadConfiguration 按 key 读取当前组织自身的配置。
+func OrgLoadConfiguration(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+		return
+	}
+	orgID, err := core.SessionOrgID(r, sessionUser.TenantID)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	k := strings.TrimSpace(r.FormValue("k"))
+	confMap, err := service.OrgLoadConfiguration(sessionUser.TenantID, orgID, k)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, confMap)
+}
+
+// OrgEffectiveConfiguration 按 key 读取当前组织生效的配置：租户配置叠加组织配置。
+func OrgEffectiveConfiguration(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+		return
+	}
+	orgID, err := core.SessionOrgID(r, sessionUser.TenantID)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	k := strings.TrimSpace(r.FormValue("k"))
+	confMap, err := service.OrgEffectiveConfiguration(sessionUser.TenantID, orgID, k)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, confMap)
+}
+
+// OrgMemberTransfer 把一个成员从 fromOrgId 调到 toOrgId，两个组织都须在调用者当前组织的子树中。
+func OrgMemberTransfer(w http.ResponseWriter, r *http.Request) {
+	var req protos.OrgMemberTransferReq
+	if err := core.ReadJSONBodyFromRequest(r, &req, 4096); err != nil || req.UID == 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	req.UIDs = []uint64{req.UID}
+	orgTransferMembers(w, r, &req)
+}
+
+// OrgMemberBulkTransfer 批量调动成员（uids 最多 200 个），全部成功或全部不生效。
+func OrgMemberBulkTransfer(w http.ResponseWriter, r *http.Request) {
+	var req protos.OrgMemberTransferReq
+	if err := core.ReadJSONBodyFromRequest(r, &req, 8192); err != nil || len(req.UIDs) == 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	orgTransferMembers(w, r, &req)
+}
+
+func orgTransferMembers(w http.ResponseWriter, r *http.Request, req *protos.OrgMemberTransferReq) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+		return
+	}
+	currentOrgID, err := core.SessionOrgID(r, sessionUser.TenantID)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	for _, orgID := range []uint64{req.FromOrgID, req.ToOrgID} {
+		if err = service.OrgCheckScope(sessionUser.UID, sessionUser.TenantID, currentOrgID, orgID); err != nil {
+			gocommon.HttpJsonErr(w, http.StatusOK, err)
+			return
+		}
+	}
+	rr, err := service.OrgMemberTransfer(sessionUser.UID, sessionUser.TenantID, req)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, rr)
+}
+
+// OrgMemberTransferList 调入或调出组织的记录（?orgId&page&pageSize），不传 orgId 时查当前组织。
+func OrgMemberTransferList(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+		return
+	}
+	currentOrgID, err := core.SessionOrgID(r, sessionUser.TenantID)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	orgID, _ := strconv.ParseUint(r.FormValue("orgId"), 10, 64)
+	if orgID == 0 {
+		orgID = currentOrgID
+	} else if err = service.OrgCheckScope(sessionUser.UID, sessionUser.TenantID, currentOrgID, orgID); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	page, _ := strconv.ParseUint(r.FormValue("page"), 10, 64)
+	pageSize, _ := strconv.ParseUint(r.FormValue("pageSize"), 10, 64)
+
+	rr, err := service.OrgMemberTransferList(sessionUser.TenantID, orgID, page, pageSize)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, rr)
+}
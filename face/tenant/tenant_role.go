@@ -23,7 +23,19 @@ func AddRole(w http.ResponseWriter, r *http.Request) {
 		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
 		return
 	}
-	if err := service.TenantAddRole(sessionUser.TenantID, req); err != nil {
+	// 设置 grantable 时，非 root 只能把自己可授予的角色放进去，防止借新角色放大授权范围
+	if len(req.Grantable) > 0 {
+		orgID, err := core.SessionOrgID(r, sessionUser.TenantID)
+		if err != nil {
+			gocommon.HttpJsonErr(w, http.StatusOK, err)
+			return
+		}
+		if err := service.CheckRolesGrantable(sessionUser.UID, sessionUser.TenantID, orgID, req.Grantable); err != nil {
+			gocommon.HttpJsonErr(w, http.StatusOK, err)
+			return
+		}
+	}
+	if err := service.TenantAddRole(sessionUser.TenantID, sessionUser.UID, req); err != nil {
 		gocommon.HttpJsonErr(w, http.StatusOK, err)
 		return
 	}
@@ -42,7 +54,7 @@ func DelRole(w http.ResponseWriter, r *http.Request) {
 		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
 		return
 	}
-	if err := service.TenantDelRole(sessionUser.TenantID, req); err != nil {
+	if err := service.TenantDelRole(sessionUser.TenantID, sessionUser.UID, req); err != nil {
 		gocommon.HttpJsonErr(w, http.StatusOK, err)
 		return
 	}
@@ -59,3 +71,37 @@ func GetRole(w http.ResponseWriter, r *http.Request) {
 	roles := service.TenantGetRole(sessionUser.TenantID)
 	gocommon.HttpErr(w, http.StatusOK, 0, roles)
 }
+
+// SetExclusiveRoles 设置当前租户的互斥角色组（职责分离）。
+func SetExclusiveRoles(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+		return
+	}
+	req := protos.ExclusiveRolesReq{}
+	if err := core.ReadJSONBodyFromRequest(r, &req, 10240); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if err := service.TenantSetExclusiveRoles(sessionUser.TenantID, sessionUser.UID, req.Sets); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpJsonErr(w, http.StatusOK, common.ErrOK)
+}
+
+// RoleViolations 列出当前租户已存在的互斥角色冲突。
+func RoleViolations(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+		return
+	}
+	rst, err := service.ExclusiveRoleViolations(sessionUser.TenantID)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, rst)
+}
@@ -1,4 +1,4 @@
-// tenant_tenant.go 提供租户本身相关接口：创建、配置读写、租户树查询。
+// tenant_tenant.go 提供租户本身相关接口：创建、配置读写、配额用量、租户树查询。
 package tenant
 
 import (
@@ -39,19 +39,20 @@ func Add(w http.ResponseWriter, r *http.Request) {
 	gocommon.HttpErr(w, http.StatusOK, 0, uid)
 }
 
-// UpdateConfiguration 更新当前租户配置（请求体为 JSON 对象）。
+// UpdateConfiguration 按键增量更新当前租户配置，请求体 {"last_update_time": "...", "data": {...}}；
+// last_update_time 与配置当前的更新时间不一致时返回 ErrModify。
 func UpdateConfiguration(w http.ResponseWriter, r *http.Request) {
 	sessionUser := core.GetSessionUser(r)
 	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
 		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
 		return
 	}
-	var req map[string]interface{}
-	if err := core.ReadJSONBodyFromRequest(r, &req, 1024); err != nil {
+	req := &protos.TenantConfigMergeReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, 1024); err != nil {
 		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
 		return
 	}
-	if err := service.TenantUpdateConfiguration(sessionUser.TenantID, req); err != nil {
+	if err := service.TenantUpdateConfiguration(sessionUser.TenantID, sessionUser.UID, req.LastUpdateTime, req.Data); err != nil {
 		gocommon.HttpJsonErr(w, http.StatusOK, err)
 		return
 	}
@@ -74,6 +75,21 @@ func LoadConfiguration(w http.ResponseWriter, r *http.Request) {
 	gocommon.HttpErr(w, http.StatusOK, 0, confMap)
 }
 
+// Quota 查询当前租户各项配额与用量。
+func Quota(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrNoAuth)
+		return
+	}
+	usage, err := service.TenantQuotaUsage(sessionUser.TenantID)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, usage)
+}
+
 // TreeList 分页查询租户树节点，支持按父节点筛选。
 func TreeList(w http.ResponseWriter, r *http.Request) {
 	sessionUser := core.GetSessionUser(r)
@@ -37,6 +37,11 @@ func UserAdd(w http.ResponseWriter, r *http.Request) {
 		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
 		return
 	}
+	if err := service.CheckRolesGrantable(sessionUser.UID, sessionUser.TenantID, orgID, req.Roles); err != nil {
+		common.Logger.Sugar().Errorf("tenant.UserAdd roles not grantable: operator_uid=%d tenant=%d roles=%v", sessionUser.UID, sessionUser.TenantID, req.Roles)
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
 	common.Logger.Sugar().Infof("tenant.UserAdd start: operator_uid=%d tenant=%d req_uid=%d roles=%v depIds=%v disable=%d",
 		sessionUser.UID, sessionUser.TenantID, req.UID, req.Roles, req.DepIds, req.Disable)
 	if req.UID == 0 {
@@ -11,9 +11,9 @@ import (
 
 // UserAuth 校验当前会话登录状态并返回会话用户信息。
 func UserAuth(w http.ResponseWriter, r *http.Request) {
-	sess, auth := core.AuthFilter(r)
-	if !auth || sess == nil {
-		gocommon.HttpJsonErr(w, http.StatusUnauthorized, common.ErrNoLogin)
+	sess, err := core.AuthCheck(r)
+	if err != nil || sess == nil {
+		gocommon.HttpJsonErr(w, http.StatusUnauthorized, err)
 		return
 	}
 	gocommon.HttpErr(w, http.StatusOK, 0, sess.Values[common.SessUserInfoKey].(protos.User))
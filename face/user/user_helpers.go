@@ -2,9 +2,47 @@ package user
 
 import (
 	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/face/core"
 	"github.com/liuhengloveyou/passport/v4/protos"
 )
 
+// saveUserSession 为 sessionUser 新建会话；useCookie 为 false 时不下发 Set-Cookie，返回会话 token。
+func saveUserSession(w http.ResponseWriter, r *http.Request, sessionUser *protos.User, useCookie bool) (token string, err error) {
+	r.Header.Del("Cookie")
+	session, err := core.SessionStore().New(r, common.ServConfig.SessionKey)
+	if err != nil {
+		return "", err
+	}
+	session.Values[common.SessUserInfoKey] = sessionUser
+	session.Options.MaxAge = common.ServConfig.SessionExpire
+	session.Options.Domain = common.ServConfig.Domain
+	session.Options.Secure = false
+	session.Options.SameSite = http.SameSiteDefaultMode
+	if err = session.Save(r, w); err != nil {
+		return "", err
+	}
+	if !useCookie {
+		token = strings.Split(w.Header().Get("Set-Cookie"), ";")[0][len(common.ServConfig.SessionKey)+1:]
+		w.Header().Del("Set-Cookie")
+	}
+	return token, nil
+}
+
+// dropUserSession 作废当前会话，用于切换租户或组织后重新签发会话前。
+func dropUserSession(w http.ResponseWriter, r *http.Request) {
+	if old, err := core.SessionStore().New(r, common.ServConfig.SessionKey); err == nil {
+		old.Options.MaxAge = -1
+		if err = old.Save(r, w); err != nil {
+			core.Logger().Sugar().Warnf("dropUserSession ERR: %v\n", err)
+		}
+		w.Header().Del("Set-Cookie")
+	}
+}
+
 func normalizeUserExt(user *protos.User) {
 	if user == nil {
 		return
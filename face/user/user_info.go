@@ -8,7 +8,7 @@ import (
 	"github.com/liuhengloveyou/passport/v4/service"
 )
 
-// UserInfo 查询当前登录用户详情。
+// UserInfo 查询当前登录用户详情，附带用户在当前租户加入的组织与当前组织。
 func UserInfo(w http.ResponseWriter, r *http.Request) {
 	sessionUser := core.GetSessionUser(r)
 	if sessionUser.UID <= 0 {
@@ -24,5 +24,11 @@ func UserInfo(w http.ResponseWriter, r *http.Request) {
 		gocommon.HttpErr(w, http.StatusOK, -1, err.Error())
 		return
 	}
+	if rst != nil && sessionUser.TenantID > 0 {
+		rst.OrgID = orgID
+		if rst.Orgs, err = service.OrgListByUser(sessionUser.UID, sessionUser.TenantID); err != nil {
+			core.Logger().Sugar().Warnf("UserInfo orgs ERR: %v %v\n", sessionUser.UID, err)
+		}
+	}
 	gocommon.HttpErr(w, http.StatusOK, 0, rst)
 }
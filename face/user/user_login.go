@@ -29,25 +29,17 @@ func UserLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	normalizeUserExt(one)
-	r.Header.Del("Cookie")
-	session, err := core.SessionStore().New(r, common.ServConfig.SessionKey)
-	if err != nil {
-		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrSession)
-		return
-	}
 	sessionUser := &protos.User{UID: one.UID, TenantID: one.TenantID, Cellphone: one.Cellphone, Email: one.Email, Nickname: one.Nickname, AvatarURL: one.AvatarURL, CreateTime: one.CreateTime, UpdateTime: one.UpdateTime, LoginTime: one.LoginTime}
-	session.Values[common.SessUserInfoKey] = sessionUser
-	session.Options.MaxAge = common.ServConfig.SessionExpire
-	session.Options.Domain = common.ServConfig.Domain
-	session.Options.Secure = false
-	session.Options.SameSite = http.SameSiteDefaultMode
-	if err := session.Save(r, w); err != nil {
+	// 上次选择的组织作为当前组织，请求可以不带 X-Org-Id
+	sessionUser.OrgID = service.UserDefaultOrg(one.UID, one.TenantID)
+	one.OrgID = sessionUser.OrgID
+	token, err := saveUserSession(w, r, sessionUser, useCookie)
+	if err != nil {
 		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrSession)
 		return
 	}
 	if !useCookie {
-		one.SetExt("TOKEN", strings.Split(w.Header().Get("Set-Cookie"), ";")[0][len(common.ServConfig.SessionKey)+1:])
-		w.Header().Del("Set-Cookie")
+		one.SetExt("TOKEN", token)
 	}
 	gocommon.HttpErr(w, http.StatusOK, 0, one)
 }
@@ -0,0 +1,102 @@
+package user
+
+import (
+	"net/http"
+	"strings"
+
+	gocommon "github.com/liuhengloveyou/go-common"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/face/core"
+	"github.com/liuhengloveyou/passport/v4/protos"
+	"github.com/liuhengloveyou/passport/v4/service"
+)
+
+// UserTenants 查询当前账号加入的租户，并标出默认租户与当前会话所在租户。
+func UserTenants(w http.ResponseWriter, r *http.Request) {
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 {
+		gocommon.HttpErr(w, http.StatusUnauthorized, -1, "")
+		return
+	}
+
+	rr, err := service.UserTenants(sessionUser.UID)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+	for i := range rr {
+		rr[i].Current = rr[i].TenantID == sessionUser.TenantID
+	}
+	gocommon.HttpErr(w, http.StatusOK, 0, rr)
+}
+
+// UserSwitchTenant 切换到账号加入的其他租户：作废当前会话，按新租户重新签发会话。
+func UserSwitchTenant(w http.ResponseWriter, r *http.Request) {
+	useCookie := strings.ToLower(r.Header.Get("USE-COOKIE")) != "false"
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 {
+		gocommon.HttpErr(w, http.StatusUnauthorized, -1, "")
+		return
+	}
+	req := &protos.SwitchTenantReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, 1024); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+
+	tenant, err := service.SwitchTenant(sessionUser.UID, req.TenantID)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+
+	dropUserSession(w, r)
+	one := sessionUser
+	one.TenantID = tenant.ID
+	one.OrgID = service.UserDefaultOrg(sessionUser.UID, tenant.ID)
+	token, err := saveUserSession(w, r, &one, useCookie)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrSession)
+		return
+	}
+
+	one.Tenant = tenant
+	if !useCookie {
+		one.SetExt("TOKEN", token)
+	}
+	core.Logger().Sugar().Infof("UserSwitchTenant ok: %v %v => %v\n", sessionUser.UID, sessionUser.TenantID, tenant.ID)
+	gocommon.HttpErr(w, http.StatusOK, 0, one)
+}
+
+// UserSwitchOrg 切换当前组织：按新组织重新签发会话，之后的请求不带 X-Org-Id 时使用该组织；同时记为下次登录的默认组织。
+func UserSwitchOrg(w http.ResponseWriter, r *http.Request) {
+	useCookie := strings.ToLower(r.Header.Get("USE-COOKIE")) != "false"
+	sessionUser := core.GetSessionUser(r)
+	if sessionUser.UID <= 0 || sessionUser.TenantID <= 0 {
+		gocommon.HttpErr(w, http.StatusUnauthorized, -1, "")
+		return
+	}
+	req := &protos.SwitchOrgReq{}
+	if err := core.ReadJSONBodyFromRequest(r, req, 1024); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrParam)
+		return
+	}
+	if err := service.SwitchOrg(sessionUser.UID, sessionUser.TenantID, req.OrgID); err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, err)
+		return
+	}
+
+	dropUserSession(w, r)
+	one := sessionUser
+	one.OrgID = req.OrgID
+	token, err := saveUserSession(w, r, &one, useCookie)
+	if err != nil {
+		gocommon.HttpJsonErr(w, http.StatusOK, common.ErrSession)
+		return
+	}
+	if !useCookie {
+		one.SetExt("TOKEN", token)
+	}
+	core.Logger().Sugar().Infof("UserSwitchOrg ok: %v %v => %v\n", sessionUser.UID, sessionUser.OrgID, req.OrgID)
+	gocommon.HttpErr(w, http.StatusOK, 0, one)
+}
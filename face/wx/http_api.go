@@ -350,6 +350,9 @@ func SetWxUserToSession(w http.ResponseWriter, r *http.Request, userInfo *protos
 		return false
 	}
 	session.IsNew = true
+	if userInfo.OrgID == 0 {
+		userInfo.OrgID = service.UserDefaultOrg(userInfo.UID, userInfo.TenantID)
+	}
 	session.Values[common.SessUserInfoKey] = userInfo
 	session.Options.MaxAge = common.ServConfig.SessionExpire
 	session.Options.Domain = common.ServConfig.Domain
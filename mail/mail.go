@@ -0,0 +1,73 @@
+// Package mail 通过 SMTP 发送通知邮件，目前用于租户邀请。
+package mail
+
+import (
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/liuhengloveyou/go-errors"
+)
+
+var (
+	ErrMailNotInit = errors.NewError(-4100, "未启用邮件功能")
+	ErrMailConf    = errors.NewError(-4101, "邮件配置错误")
+)
+
+type smtpSender struct {
+	Addr     string // host:port
+	User     string
+	Password string
+	From     string
+}
+
+var defaultSender *smtpSender
+
+// Init 按配置启用邮件；配置项 smtp_addr、user、password、from，from 为空时用 user
+func Init(config map[string]interface{}) error {
+	tmp := &smtpSender{}
+	tmp.Addr, _ = config["smtp_addr"].(string)
+	tmp.User, _ = config["user"].(string)
+	tmp.Password, _ = config["password"].(string)
+	tmp.From, _ = config["from"].(string)
+	if tmp.From == "" {
+		tmp.From = tmp.User
+	}
+	if _, _, err := net.SplitHostPort(tmp.Addr); err != nil || tmp.From == "" {
+		return ErrMailConf
+	}
+
+	defaultSender = tmp
+	return nil
+}
+
+// Send 发送纯文本邮件
+func Send(to, subject, body string) error {
+	if defaultSender == nil {
+		return ErrMailNotInit
+	}
+	return defaultSender.send(to, subject, body)
+}
+
+func (p *smtpSender) send(to, subject, body string) error {
+	var auth smtp.Auth
+	if p.User != "" {
+		host, _, _ := net.SplitHostPort(p.Addr)
+		auth = smtp.PlainAuth("", p.User, p.Password, host)
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", p.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(body)
+
+	return smtp.SendMail(p.Addr, auth, p.From, []string{to}, []byte(msg.String()))
+}
@@ -11,6 +11,8 @@ import (
 
 const (
 	DepartmentExtKey = "deps"
+	// UserDisabledExtKey 早期数据写在 users.ext 的账号级停用状态；现在停用记在 tenant_members.disabled
+	UserDisabledExtKey = "disabled"
 
 	// RealUserMinUID 真实用户 UID 下限
 	RealUserMinUID = 10000
@@ -56,9 +58,11 @@ type User struct {
 	DeleteTime *time.Time `json:"deleteTime,omitempty" validate:"-" db:"delete_time"`
 	LoginTime  *time.Time `json:"loginTime,omitempty" validate:"-" db:"login_time"`
 
-	Tenant      *Tenant      `json:"tenant,omitempty" validate:"-" db:"tenant"`
-	Roles       []RoleStruct `json:"roles,omitempty" validate:"-"`
-	Departments []Department `json:"departments,omitempty" validate:"-"`
+	Tenant      *Tenant        `json:"tenant,omitempty" validate:"-" db:"tenant"`
+	Roles       []RoleStruct   `json:"roles,omitempty" validate:"-"`
+	Departments []Department   `json:"departments,omitempty" validate:"-"`
+	OrgID       uint64         `json:"orgId,omitempty" validate:"-" db:"-"` // 会话的当前组织，请求不带 X-Org-Id 时使用
+	Orgs        []Organization `json:"orgs,omitempty" validate:"-" db:"-"`  // 用户在当前租户加入的组织
 
 	WxOpenId *null.String `json:"wxopenid,omitempty" validate:"omitempty,min=2,max=64" db:"wx_openid"` // 微信
 
@@ -121,22 +125,220 @@ type Tenant struct {
 	Info          MapStruct            `json:"info,omitempty" db:"info"`
 	Configuration *TenantConfiguration `json:"configuration,omitempty" db:"configuration"`
 
+	Status       string     `json:"status,omitempty" validate:"-" db:"status"`
+	StatusReason string     `json:"statusReason,omitempty" validate:"-" db:"status_reason"`
+	TrialEndTime *time.Time `json:"trialEndTime,omitempty" validate:"-" db:"trial_end_time"`
+
+	// 软删除：删除时归档并记下原状态，恢复期内可恢复，过期后由清理任务物理删除
+	DeleteTime      *time.Time `json:"deleteTime,omitempty" validate:"-" db:"delete_time"`
+	PreDeleteStatus string     `json:"preDeleteStatus,omitempty" validate:"-" db:"pre_delete_status"`
+
 	Depth int `json:"depth,omitempty" validate:"-" db:"-"`
 }
 
+// 租户生命周期状态
+const (
+	TenantStatusTrial     = "trial"     // 试用，到 trial_end_time 后按 expired 处理
+	TenantStatusActive    = "active"    // 正常
+	TenantStatusSuspended = "suspended" // 停用，成员不能登录
+	TenantStatusExpired   = "expired"   // 过期，成员不能登录
+	TenantStatusArchived  = "archived"  // 归档，成员不能登录
+)
+
+// EffectiveStatus 计入试用期到期后的实际状态；老数据没有状态时视为 active。
+func (t *Tenant) EffectiveStatus(now time.Time) string {
+	switch {
+	case t.Status == "":
+		return TenantStatusActive
+	case t.Status == TenantStatusTrial && t.TrialEndTime != nil && !now.Before(*t.TrialEndTime):
+		return TenantStatusExpired
+	}
+	return t.Status
+}
+
+// TenantMember 账号在租户内的成员状态：Disabled 为租户内的停用状态（UserDisableStatus，0 表示未设置），
+// Ext 为租户内的扩展信息（部门 deps 仍记在 users.ext）。
+type TenantMember struct {
+	TenantID uint64    `json:"tenantId" db:"tenant_id"`
+	UID      uint64    `json:"uid" db:"uid"`
+	Disabled int8      `json:"disabled" db:"disabled"`
+	Ext      MapStruct `json:"ext,omitempty" db:"ext"`
+}
+
+// IsDisabled 账号在该租户内是否已停用
+func (m *TenantMember) IsDisabled() bool {
+	return m != nil && UserDisableStatus(m.Disabled) == UserDisabled
+}
+
+// UserTenant 账号加入的租户（HTTP user/tenants）；Default 为 users.tenant_id，Current 为当前会话所在租户。
+type UserTenant struct {
+	TenantID   uint64 `json:"tenantId"`
+	TenantName string `json:"tenantName"`
+	TenantType string `json:"tenantType,omitempty"`
+	Status     string `json:"status"`
+	Default    bool   `json:"default"`
+	Current    bool   `json:"current"`
+}
+
+// TenantPurge 清理任务物理删除租户的记录
+type TenantPurge struct {
+	ID          uint64     `json:"id" db:"id"`
+	TenantID    uint64     `json:"tenantId" db:"tenant_id"`
+	TenantName  string     `json:"tenantName" db:"tenant_name"`
+	DeleteTime  *time.Time `json:"deleteTime,omitempty" db:"delete_time"`
+	PurgeTime   *time.Time `json:"purgeTime,omitempty" db:"purge_time"`
+	Users       int64      `json:"users" db:"users"`                 // 删除的用户数
+	Departments int64      `json:"departments" db:"departments"`     // 删除的部门数
+	Permissions int64      `json:"permissions" db:"permissions"`     // 删除的权限项数
+	Children    []uint64   `json:"children,omitempty" db:"children"` // 移到根租户下的直接子租户
+}
+
+// 邀请状态；pending 过了有效期按过期处理
+const (
+	InviteStatusPending  = "pending"
+	InviteStatusAccepted = "accepted"
+	InviteStatusRevoked  = "revoked"
+	InviteStatusExpired  = "expired"
+)
+
+// 邀请发送渠道
+const (
+	InviteChannelSms   = "sms"
+	InviteChannelEmail = "email"
+)
+
+// Invitation 邀请手机号或邮箱加入租户的某个组织，接受后按 Roles、DepIds 授予成员关系。
+type Invitation struct {
+	ID         uint64     `json:"id" db:"id"`
+	TenantID   uint64     `json:"tenantId" db:"tenant_id"`
+	OrgID      uint64     `json:"orgId" db:"org_id"`
+	Target     string     `json:"target" db:"target"`   // 手机号或邮箱
+	Channel    string     `json:"channel" db:"channel"` // sms/email
+	Roles      []string   `json:"roles" db:"roles"`
+	DepIds     []uint64   `json:"depIds" db:"dep_ids"`
+	InviterUID uint64     `json:"inviterUid" db:"inviter_uid"`
+	Nonce      string     `json:"-" db:"nonce"` // 参与签名；重发时更换，旧链接随之失效
+	Status     string     `json:"status" db:"status"`
+	ExpireTime *time.Time `json:"expireTime,omitempty" db:"expire_time"`
+	AcceptUID  uint64     `json:"acceptUid,omitempty" db:"accept_uid"`
+	AcceptTime *time.Time `json:"acceptTime,omitempty" db:"accept_time"`
+	CreateTime *time.Time `json:"createTime,omitempty" db:"create_time"`
+	UpdateTime *time.Time `json:"updateTime,omitempty" db:"update_time"`
+}
+
+// EffectiveStatus 计入有效期后的实际状态
+func (m *Invitation) EffectiveStatus(now time.Time) string {
+	if m.Status == InviteStatusPending && m.ExpireTime != nil && !now.Before(*m.ExpireTime) {
+		return InviteStatusExpired
+	}
+	return m.Status
+}
+
+// 租户配额资源
+const (
+	QuotaMembers     = "members"     // 成员数（默认租户与 tenant_members）
+	QuotaOrgs        = "orgs"        // 组织数
+	QuotaDepartments = "departments" // 部门数
+	QuotaRoles       = "roles"       // 租户自定义角色数（configuration.roles）
+)
+
+// QuotaResources 全部配额资源，按展示顺序
+var QuotaResources = []string{QuotaMembers, QuotaOrgs, QuotaDepartments, QuotaRoles}
+
+// TenantQuota 租户某项资源的上限与当前用量；Limit 为 0 表示不限。
+type TenantQuota struct {
+	Resource string `json:"resource" db:"resource"`
+	Limit    int64  `json:"limit" db:"quota_limit"`
+	Used     int64  `json:"used" db:"used"`
+}
+
 // Organization 租户下的组织（业务侧门店对应的 Passport 实体，id 永不为 0）。
 type Organization struct {
 	ID         uint64     `json:"id" db:"id"`
 	TenantID   uint64     `json:"tenantId" db:"tenant_id"`
+	ParentID   uint64     `json:"parentId" db:"parent_id"` // 上级组织，0 表示租户下的顶级组织
 	Name       string     `json:"name" db:"name"`
 	CreateTime *time.Time `json:"createTime,omitempty" db:"create_time"`
 	UpdateTime *time.Time `json:"updateTime,omitempty" db:"update_time"`
+
+	Configuration MapStruct `json:"configuration,omitempty" db:"configuration"` // 组织配置，键与租户配置 more 相同时覆盖租户的值
+}
+
+// OrgNode 组织树节点
+type OrgNode struct {
+	Organization
+	Children []*OrgNode `json:"children,omitempty"`
+}
+
+// 组织成员调动方式
+const (
+	OrgTransferMove = "move" // 调出原组织
+	OrgTransferCopy = "copy" // 保留原组织的成员关系与角色
+)
+
+// OrgMemberTransfer 组织成员调动记录；FromRoles 为调动前在原组织直接持有的角色，ToRoles 为按映射在目标组织授予的角色
+type OrgMemberTransfer struct {
+	ID          uint64     `json:"id"`
+	TenantID    uint64     `json:"tenantId"`
+	UID         uint64     `json:"uid"`
+	FromOrgID   uint64     `json:"fromOrgId"`
+	ToOrgID     uint64     `json:"toOrgId"`
+	Mode        string     `json:"mode"`
+	FromRoles   []string   `json:"fromRoles"`
+	ToRoles     []string   `json:"toRoles"`
+	DepIds      []uint64   `json:"depIds"`
+	OperatorUID uint64     `json:"operatorUid"`
+	Remark      string     `json:"remark,omitempty"`
+	CreateTime  *time.Time `json:"createTime,omitempty"`
+}
+
+// TenantTemplate 租户模板：开通租户时按 Content 建好组织、部门、配置、角色策略与数据范围。
+type TenantTemplate struct {
+	ID             uint64                 `json:"id" db:"id"`
+	Name           string                 `json:"name" db:"name"`
+	Remark         string                 `json:"remark,omitempty" db:"remark"`
+	SourceTenantID uint64                 `json:"sourceTenantId,omitempty" db:"source_tenant_id"` // admin/tenant/clone 生成时的来源租户
+	Content        *TenantTemplateContent `json:"content,omitempty" db:"content"`
+	CreateTime     *time.Time             `json:"createTime,omitempty" db:"create_time"`
+	UpdateTime     *time.Time             `json:"updateTime,omitempty" db:"update_time"`
+}
+
+// TenantTemplateContent 模板内容，不含任何账号
+type TenantTemplateContent struct {
+	Configuration *TenantConfiguration `json:"configuration,omitempty"`
+	Policies      []Policy             `json:"policies,omitempty"` // 租户级角色策略，对所有组织生效
+	Orgs          []TemplateOrg        `json:"orgs,omitempty"`
+}
+
+// TemplateOrg 模板中的组织
+type TemplateOrg struct {
+	Name        string               `json:"name"`
+	Parent      string               `json:"parent,omitempty"` // 上级组织名，须是模板中的另一个组织
+	Departments []TemplateDepartment `json:"departments,omitempty"`
+	Policies    []Policy             `json:"policies,omitempty"`   // 组织内角色策略，含 data-scope/{module}/{level} 数据范围级别
+	DataScopes  []TemplateDataScope  `json:"dataScopes,omitempty"` // custom 级别的自定义部门
+}
+
+// TemplateDepartment 模板中的部门；ID 只在模板内有效，供 ParentID 与数据范围引用
+type TemplateDepartment struct {
+	ID       uint64    `json:"id"`
+	ParentID uint64    `json:"parentId,omitempty"`
+	Name     string    `json:"name"`
+	Config   MapStruct `json:"config,omitempty"`
+}
+
+// TemplateDataScope 角色在某模块下的自定义部门（role_data_scopes）
+type TemplateDataScope struct {
+	Role   string   `json:"role"`
+	Module string   `json:"module"`
+	DepIDs []uint64 `json:"depIds"`
 }
 
 // 租户配置字段
 type TenantConfiguration struct {
-	Roles []RoleStruct `json:"roles"` // 用户角色字典列表
-	More  MapStruct    `json:"more"`
+	Roles          []RoleStruct `json:"roles"`                    // 用户角色字典列表
+	ExclusiveRoles [][]string   `json:"exclusiveRoles,omitempty"` // 互斥角色组：同一组织内一个人最多持有每组中的一个角色
+	More           MapStruct    `json:"more"`
 }
 
 func (t TenantConfiguration) Value() (driver.Value, error) {
@@ -167,6 +369,39 @@ func (t *TenantConfiguration) Scan(src interface{}) error {
 	return json.Unmarshal(b, t)
 }
 
+// TenantConfigVersion 租户配置的一个版本。每次修改配置记一版，Configuration 为修改后的完整配置，Diff 为相对上一版的变更
+type TenantConfigVersion struct {
+	ID            uint64               `json:"id"`
+	TenantID      uint64               `json:"tenantId"`
+	Version       uint64               `json:"version"`
+	Configuration *TenantConfiguration `json:"configuration,omitempty"`
+	Diff          []ConfigChange       `json:"diff"`
+	AuthorUID     uint64               `json:"authorUid"`
+	Remark        string               `json:"remark,omitempty"`
+	CreateTime    *time.Time           `json:"createTime,omitempty"`
+}
+
+// 配置变更类型
+const (
+	ConfigChangeAdd     = "add"
+	ConfigChangeRemove  = "remove"
+	ConfigChangeReplace = "replace"
+)
+
+// ConfigChange 配置 JSON 中的一处变更，Path 为 JSON Pointer（如 /more/theme、/roles/2）
+type ConfigChange struct {
+	Op   string      `json:"op"`
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// TenantConfigVersionPage 配置版本列表（不含完整配置）。UpdateTime 为租户配置当前的更新时间，修改或回滚时作为 last_update_time 提交
+type TenantConfigVersionPage struct {
+	UpdateTime *time.Time            `json:"updateTime,omitempty"`
+	List       []TenantConfigVersion `json:"list"`
+}
+
 // t_tenant_closure 租户闭包表
 type TenantClosure struct {
 	ID           uint64 `json:"id" db:"id"`
@@ -181,6 +416,34 @@ type RoleStruct struct {
 	RoleValue string `json:"value" validate:"required,max=64"`
 
 	UID uint64 `json:"uid,omitempty" validate:"-"`
+
+	// 持有该角色的非 root 用户可以授予他人的角色列表
+	Grantable []string `json:"grantable,omitempty" validate:"omitempty,max=50,dive,max=64"`
+
+	// 租户级授权：对租户下所有组织（含之后新建的）生效，仅租户 root 可操作
+	TenantWide bool `json:"tenantWide,omitempty" validate:"-"`
+
+	// 继承授权：对当前组织及其所有下级组织（含之后新建或移入的）生效，不支持限时
+	InheritToChildren bool   `json:"inheritToChildren,omitempty" validate:"-"`
+	InheritFromOrg    uint64 `json:"inheritFromOrg,omitempty" validate:"-"` // 继承授权所在的组织，仅查询时返回
+
+	// 限时授权：生效/过期时间，为空表示立即生效/永久有效
+	NotBefore     *time.Time `json:"notBefore,omitempty" validate:"-"`
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty" validate:"-"`
+	RemainSeconds int64      `json:"remainSeconds,omitempty" validate:"-"` // 剩余有效秒数，仅查询时返回
+}
+
+// 限时角色授权，与 casbin_rule 中的 g 规则一一对应
+type RoleGrant struct {
+	ID         uint64     `json:"id" db:"id"`
+	TenantID   uint64     `json:"tenantId" db:"tenant_id"`
+	OrgID      uint64     `json:"orgId" db:"org_id"`
+	UID        uint64     `json:"uid" db:"uid"`
+	Role       string     `json:"role" db:"role"`
+	NotBefore  *time.Time `json:"notBefore,omitempty" db:"not_before"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty" db:"expires_at"`
+	Active     bool       `json:"active" db:"active"` // 是否已写入 casbin
+	CreateTime *time.Time `json:"createTime,omitempty" db:"create_time"`
 }
 
 // 权限条目
@@ -190,15 +453,99 @@ type PermissionStruct struct {
 	Domain     string     `json:"domain,omitempty" validate:"required,min=2,max=128" db:"domain"`
 	Title      string     `json:"title" validate:"required,min=2,max=128" db:"title"`
 	Value      string     `json:"value" validate:"required,min=2,max=256" db:"value"`
+	ParentID   uint64     `json:"parentId" validate:"-" db:"parent_id"`                                 // 上级权限点，0 为顶级
+	Type       string     `json:"type,omitempty" validate:"omitempty,oneof=menu page action" db:"type"` // menu / page / action
+	Actions    []string   `json:"actions,omitempty" validate:"max=20,dive,min=1,max=32" db:"actions"`   // 策略可用的 act，如 GET、POST；"*" 表示任意
 	CreateTime *time.Time `json:"createTime,omitempty" validate:"-" db:"create_time"`
 	UpdateTime *time.Time `json:"updateTime,omitempty" validate:"-" db:"update_time"`
 }
 
+// 权限点类型：菜单 → 页面 → 操作
+const (
+	PermissionTypeMenu   = "menu"
+	PermissionTypePage   = "page"
+	PermissionTypeAction = "action"
+)
+
+// 权限树节点，供前端渲染勾选树
+type PermissionNode struct {
+	PermissionStruct
+	Children []*PermissionNode `json:"children,omitempty"`
+}
+
+// 删除权限点时的依赖报告
+type PermissionDependents struct {
+	Children []PermissionStruct `json:"children,omitempty"` // 下级权限点
+	Policies []Policy           `json:"policies,omitempty"` // 引用这些权限点的策略
+}
+
+// 职责分离冲突：用户在组织内同时持有同一互斥组中的多个角色
+type RoleViolation struct {
+	UID   uint64   `json:"uid"`
+	OrgID uint64   `json:"orgId"`
+	Roles []string `json:"roles"` // 冲突的角色
+	Set   []string `json:"set"`   // 违反的互斥组
+}
+
+// 策略效果
+const (
+	PolicyEffectAllow = "allow"
+	PolicyEffectDeny  = "deny"
+)
+
 // 权限策略
 type Policy struct {
-	Role string `json:"role"`
-	Obj  string `json:"obj"`
-	Act  string `json:"act"`
+	Role   string `json:"role"`
+	Obj    string `json:"obj"`
+	Act    string `json:"act"`
+	Effect string `json:"effect,omitempty"` // allow / deny；老模型没有 eft 时为空
+	OrgID  uint64 `json:"orgId,omitempty"`
+
+	TenantWide bool `json:"tenantWide,omitempty"` // 租户级策略，对所有组织生效
+}
+
+// 租户级域迁移结果
+type TenantDomainCollapse struct {
+	Groupings         int `json:"groupings"`         // 合并出的租户级角色绑定
+	Policies          int `json:"policies"`          // 合并出的租户级策略
+	MigratedGroupings int `json:"migratedGroupings"` // 从老版本租户域迁入的角色绑定
+	MigratedPolicies  int `json:"migratedPolicies"`  // 从老版本租户域迁入的策略
+	LegacyGroupings   int `json:"legacyGroupings"`   // 老版本租户域中仍遗留的角色绑定，不参与鉴权
+	LegacyPolicies    int `json:"legacyPolicies"`    // 老版本租户域中仍遗留的策略，不参与鉴权
+}
+
+// 访问决策解释，排查"您没有权限"用
+type AccessExplain struct {
+	UID           uint64          `json:"uid"`
+	TenantID      uint64          `json:"tenantId"`
+	OrgID         uint64          `json:"orgId"`
+	Domain        string          `json:"domain"`
+	Obj           string          `json:"obj"`
+	Act           string          `json:"act"`
+	Allowed       bool            `json:"allowed"`
+	Roles         []string        `json:"roles"`
+	MatchedPolicy *Policy         `json:"matchedPolicy,omitempty"` // 命中的策略
+	RootBypass    bool            `json:"rootBypass"`              // 是否经 root 角色放行（root 未命中 deny 策略）
+	Reason        string          `json:"reason"`
+	ApiConf       *ApiConfExplain `json:"apiConf,omitempty"`
+	OrgMember     bool            `json:"orgMember"`
+	OrgError      string          `json:"orgError,omitempty"`
+}
+
+// AccessFilter 判定是否需要鉴权时使用的配置来源
+type ApiConfExplain struct {
+	Source     string `json:"source"` // passport: 内置接口表; apiConf: ApiConf[obj]; apiConf:*: ApiConf["*"]; none: 未配置
+	NeedAccess bool   `json:"needAccess"`
+}
+
+// 鉴权决策缓存统计
+type DecisionCacheStats struct {
+	Enabled       bool    `json:"enabled"`
+	Entries       int     `json:"entries"`
+	Hits          uint64  `json:"hits"`
+	Misses        uint64  `json:"misses"`
+	Invalidations uint64  `json:"invalidations"`
+	HitRate       float64 `json:"hitRate"`
 }
 
 // 部门
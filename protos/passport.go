@@ -17,6 +17,12 @@ type OptionStruct struct {
 	RootUserID   uint64 `yaml:"root_user_id"`   // -init 时写入的超级管理员 uid，默认 10000
 	RootTenantID uint64 `yaml:"root_tenant_id"` // admin 接口有权限的根租户；-init 默认 10000
 
+	RootDenyOverride bool `yaml:"root_deny_override"` // deny 策略是否对 root 角色也生效，默认 root 不受 deny 限制
+
+	DecisionCacheSize int `yaml:"decision_cache_size"` // 鉴权决策缓存条目上限；0 使用默认 100000，小于 0 关闭
+
+	TenantRestoreDays int `yaml:"tenant_restore_days"` // 删除租户后可恢复的天数，过后物理删除；0 使用默认 30
+
 	Domain           string `json:"domain"`
 	SessionKey       string `yaml:"session_key"`
 	SessionStoreType string `yaml:"session_store_type"` // 会话存储类型；"cookie/mem/reids"
@@ -25,6 +31,13 @@ type OptionStruct struct {
 	SmsDriveer string                 `yaml:"sms"`
 	SmsConf    map[string]interface{} `yaml:"sms_conf"`
 
+	// 邮件（SMTP），目前用于发送租户邀请
+	MailConf map[string]interface{} `yaml:"mail_conf"`
+
+	// 租户邀请
+	InviteURL         string `yaml:"invite_url"`          // 邀请链接前缀，token 直接拼在后面
+	InviteExpireHours int    `yaml:"invite_expire_hours"` // 邀请有效期（小时）；0 使用默认 72
+
 	// 微信开放平台
 	AppID     string `yaml:"wx_appid"`
 	AppSecret string `yaml:"wx_secret"`
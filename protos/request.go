@@ -1,5 +1,7 @@
 package protos
 
+import "time"
+
 type UserReq struct {
 	UID       uint64 `json:"uid" validate:"-"`
 	TenantID  uint64 `json:"tenant_id" validate:"-"`
@@ -36,9 +38,35 @@ type GetbackPwdReq struct {
 }
 
 type PolicyReq struct {
-	Role string `json:"role" validate:"required,max=100"`
-	Obj  string `json:"obj" validate:"required,min=1,max=100"`
-	Act  string `json:"act" validate:"required,min=1,max=10"`
+	Role   string `json:"role" validate:"omitempty,max=100"`
+	UID    uint64 `json:"uid,omitempty"` // 不填 role 时直接对该用户下发策略
+	Obj    string `json:"obj" validate:"required,min=1,max=100"`
+	Act    string `json:"act" validate:"required,min=1,max=10"`
+	Effect string `json:"effect,omitempty" validate:"omitempty,oneof=allow deny"`
+
+	TenantWide bool `json:"tenantWide,omitempty"` // 租户级策略，对所有组织生效，仅租户 root 可操作
+}
+
+type EnforceReq struct {
+	Obj string `json:"obj" validate:"required,min=1,max=100"`
+	Act string `json:"act" validate:"required,min=1,max=10"`
+}
+
+// 批量鉴权，前端菜单/按钮一次取回
+type EnforceBatchReq struct {
+	Items []EnforceReq `json:"items" validate:"required,min=1,max=200,dive"`
+}
+
+// 角色在某模块下的自定义数据范围（custom 级别的部门列表）
+type DataScopeDepsReq struct {
+	Role   string   `json:"role" validate:"required,max=100"`
+	Module string   `json:"module" validate:"omitempty,max=100"`
+	DepIDs []uint64 `json:"depIds" validate:"max=1000"`
+}
+
+// 互斥角色组配置
+type ExclusiveRolesReq struct {
+	Sets [][]string `json:"sets" validate:"max=50,dive,min=2,max=20,dive,required,max=64"`
 }
 
 type RoleReq struct {
@@ -77,6 +105,7 @@ type NewTenantReq struct {
 	Cellphone  string `json:"cellphone" validate:"omitempty,phone,len=11"`
 	Nickname   string `json:"nickname" validate:"omitempty,min=2,max=32"`
 	Password   string `json:"password" validate:"omitempty,min=6,max=64"`
+	TemplateID uint64 `json:"templateId" validate:"omitempty,min=1"` // 按租户模板初始化组织、部门、角色策略与数据范围
 
 	Info          MapStruct            `json:"info,omitempty" db:"info"`
 	Configuration *TenantConfiguration `json:"configuration,omitempty" db:"configuration"`
@@ -95,6 +124,108 @@ type UpdateTenantConfigReq struct {
 	Configuration  *TenantConfiguration `json:"configuration" validate:"required"`
 }
 
+// TenantConfigMergeReq 按键增量修改租户配置 more（HTTP tenant/updateConfiguration），值为 null 时删除该键。
+// LastUpdateTime 为读取配置时的更新时间，与当前不一致说明配置已被他人修改。
+type TenantConfigMergeReq struct {
+	TenantID       uint64                 `json:"tenant_id"` // 仅 partner 接口使用
+	LastUpdateTime string                 `json:"last_update_time" validate:"required"`
+	Data           map[string]interface{} `json:"data" validate:"required"`
+}
+
+// OrgConfigMergeReq 按键增量修改当前组织配置（HTTP org/updateConfiguration），值为 null 时删除该键。
+// LastUpdateTime 为组织的 updateTime（见 org/tree、user/info），与当前不一致说明组织已被他人修改。
+type OrgConfigMergeReq struct {
+	LastUpdateTime string                 `json:"last_update_time" validate:"required"`
+	Data           map[string]interface{} `json:"data" validate:"required"`
+}
+
+// TenantConfigRollbackReq 把租户配置回滚到指定版本（HTTP tenant/config/rollback、admin/tenant/config/rollback、partner/tenant/config/rollback）。
+type TenantConfigRollbackReq struct {
+	TenantID       uint64 `json:"tenant_id"` // 仅平台管理员与 partner 接口使用
+	Version        uint64 `json:"version" validate:"required,min=1"`
+	LastUpdateTime string `json:"last_update_time" validate:"required"`
+}
+
+// TenantStatusReq 平台管理员变更租户生命周期状态（HTTP admin/tenant/setStatus）。
+type TenantStatusReq struct {
+	TenantID     uint64     `json:"tenantId" validate:"required,min=1"`
+	Status       string     `json:"status" validate:"required,oneof=trial active suspended expired archived"`
+	Reason       string     `json:"reason" validate:"required,max=255"`
+	TrialEndTime *time.Time `json:"trialEndTime,omitempty"` // status 为 trial 时必填
+}
+
+// OrgAddReq 在当前租户下新建组织（HTTP org/add）。
+type OrgAddReq struct {
+	Name     string `json:"name" validate:"required,min=1,max=64"`
+	ParentID uint64 `json:"parentId" validate:"omitempty,min=1"` // 上级组织，为空时建为顶级组织
+}
+
+// OrgMoveReq 把组织连同其下级组织移到新的上级组织下（HTTP org/move）；ParentID 为 0 时移为顶级组织。
+type OrgMoveReq struct {
+	OrgID    uint64 `json:"orgId" validate:"required,min=1"`
+	ParentID uint64 `json:"parentId"`
+}
+
+// OrgMemberTransferReq 把成员从一个组织调到另一个组织（HTTP org/member/transfer 传 uid，org/member/bulkTransfer 传 uids）。
+// RoleMap 为原组织角色 => 目标组织角色，映射为空串的角色不带过去，未列出的角色原样带过去；限时授权的角色不带过去。
+// DepIds 为目标组织中的部门；Copy 为 false 时调出原组织，收回原组织的角色并清掉原组织的部门。
+type OrgMemberTransferReq struct {
+	UID       uint64            `json:"uid"`
+	UIDs      []uint64          `json:"uids" validate:"max=200"`
+	FromOrgID uint64            `json:"fromOrgId" validate:"required,min=1"`
+	ToOrgID   uint64            `json:"toOrgId" validate:"required,min=1,nefield=FromOrgID"`
+	Copy      bool              `json:"copy"`
+	RoleMap   map[string]string `json:"roleMap" validate:"max=50"`
+	DepIds    []uint64          `json:"depIds" validate:"max=50"`
+	Remark    string            `json:"remark" validate:"max=255"`
+}
+
+// TenantQuotaReq 平台管理员设置租户配额（HTTP admin/tenant/setQuota）；key 为资源名，值为上限，0 表示不限。
+type TenantQuotaReq struct {
+	TenantID uint64           `json:"tenantId" validate:"required,min=1"`
+	Quotas   map[string]int64 `json:"quotas" validate:"required,min=1"`
+}
+
+// TenantTemplateReq 平台管理员新增或修改租户模板（HTTP admin/template/add、admin/template/update）。
+type TenantTemplateReq struct {
+	ID      uint64                 `json:"id" validate:"omitempty,min=1"` // 修改时必填
+	Name    string                 `json:"name" validate:"required,min=2,max=64"`
+	Remark  string                 `json:"remark" validate:"max=255"`
+	Content *TenantTemplateContent `json:"content" validate:"required"`
+}
+
+// TenantCloneReq 把租户的结构（不含账号）快照为模板（HTTP admin/tenant/clone）。
+type TenantCloneReq struct {
+	TenantID uint64 `json:"tenantId" validate:"required,min=1"`
+	Name     string `json:"name" validate:"required,min=2,max=64"`
+	Remark   string `json:"remark" validate:"max=255"`
+}
+
+// SwitchTenantReq 切换当前会话所在租户（HTTP user/switchTenant）。
+type SwitchTenantReq struct {
+	TenantID uint64 `json:"tenantId" validate:"required,min=1"`
+}
+
+// SwitchOrgReq 切换当前会话所在组织（HTTP user/switchOrg）。
+type SwitchOrgReq struct {
+	OrgID uint64 `json:"orgId" validate:"required,min=1"`
+}
+
+// InviteReq 邀请成员（HTTP tenant/invite/create）；target 为手机号或邮箱。
+type InviteReq struct {
+	Target string   `json:"target" validate:"required,max=64"`
+	OrgID  uint64   `json:"orgId" validate:"required,min=1"`
+	Roles  []string `json:"roles" validate:"max=10"`
+	DepIds []uint64 `json:"depIds" validate:"-"`
+}
+
+// InviteAcceptReq 接受邀请（HTTP tenant/invite/accept）；目标账号不存在时用 password、nickname 创建。
+type InviteAcceptReq struct {
+	Token    string `json:"token" validate:"required,max=256"`
+	Password string `json:"password" validate:"omitempty,min=6,max=64"`
+	Nickname string `json:"nickname" validate:"omitempty,min=1,max=32"`
+}
+
 // AdminUserEditReq 平台管理员编辑租户用户（HTTP admin/user/edit）。
 type AdminUserEditReq struct {
 	UID         uint64   `json:"uid"`
@@ -106,7 +237,15 @@ type AdminUserEditReq struct {
 	MacAddr     *string  `json:"macAddr,omitempty"`
 }
 
-// AdminUserAddReq 平台管理员向指定租户新增租户管理员（HTTP admin/user/add）。
+// PartnerUserReq 上级租户管理下级租户的账号（HTTP partner/user/disable、partner/user/modifyPassword、partner/user/del）。
+type PartnerUserReq struct {
+	TenantID uint64 `json:"tenant_id" validate:"required,min=1"`
+	UID      uint64 `json:"uid" validate:"required,min=1"`
+	Disable  int8   `json:"disable,omitempty"`  // partner/user/disable：1 启用，2 禁用
+	Password string `json:"password,omitempty"` // partner/user/modifyPassword
+}
+
+// AdminUserAddReq 平台管理员向指定租户新增租户管理员（HTTP admin/user/add、partner/user/add）。
 // 请求体 roles 忽略；创建成功后在该租户域内固定绑定 root 角色。
 type AdminUserAddReq struct {
 	UID       uint64    `json:"uid"`
@@ -2,11 +2,11 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
-	"github.com/jackc/pgx/v5"
 	"github.com/liuhengloveyou/passport/v4/common"
 	"github.com/liuhengloveyou/passport/v4/dao"
 	"github.com/liuhengloveyou/passport/v4/database"
@@ -15,12 +15,10 @@ import (
 
 /*
 rootTenant操作，同时添加用户和租户，
+指定 TemplateID 时，租户建好后按模板创建组织、部门、角色策略与数据范围；请求未带配置时使用模板中的配置。
+按模板初始化失败时租户已创建，返回 ErrTemplateApply 与新租户 ID。
 */
 func AdminTenantNew(sess *protos.User, m *protos.NewTenantReq) (uid, tenantID uint64, e error) {
-	defer func() {
-		evictTenantCache(tenantID)
-	}()
-
 	if sess == nil {
 		return 0, 0, common.ErrService
 	}
@@ -28,6 +26,40 @@ func AdminTenantNew(sess *protos.User, m *protos.NewTenantReq) (uid, tenantID ui
 	if sess.TenantID != common.ServConfig.RootTenantID {
 		return 0, 0, common.ErrNoAuth
 	}
+	return createTenant(sess, m)
+}
+
+// createTenant 在 sess 所在租户（或其下级租户 ParentID）下开通租户，可按模板初始化；调用方负责鉴权。
+func createTenant(sess *protos.User, m *protos.NewTenantReq) (uid, tenantID uint64, e error) {
+	var tpl *protos.TenantTemplate
+	if m.TemplateID > 0 {
+		if tpl, e = TenantTemplateGet(m.TemplateID); e != nil {
+			return 0, 0, e
+		}
+		if e = validateTemplateContent(tpl.Content); e != nil {
+			return 0, 0, e
+		}
+		if m.Configuration == nil {
+			m.Configuration = tpl.Content.Configuration
+		}
+	}
+
+	if uid, tenantID, e = insertTenant(sess, m); e != nil || tpl == nil {
+		return
+	}
+	if err := applyTenantTemplate(tenantID, uid, tpl.Content); err != nil {
+		common.Logger.Sugar().Errorf("AdminTenantNew apply template %d to tenant %d ERR: %v\n", tpl.ID, tenantID, err)
+		return uid, tenantID, common.ErrTemplateApply
+	}
+	common.Logger.Sugar().Infof("AdminTenantNew: tenant %d created from template %d %s", tenantID, tpl.ID, tpl.Name)
+	return
+}
+
+// insertTenant 在事务中建租户、管理员账号与闭包记录；ParentID 须是 sess 所在租户或其后代。
+func insertTenant(sess *protos.User, m *protos.NewTenantReq) (uid, tenantID uint64, e error) {
+	defer func() {
+		evictTenantCache(tenantID)
+	}()
 
 	if m.TenantName == "" {
 		return 0, 0, common.ErrTenantNameNull
@@ -219,6 +251,17 @@ func AdminTenantSetParent(sessUser *protos.User, descendantId, ancestorId uint64
 		return common.ErrNoAuth
 	}
 
+	if err := TenantSetParent(descendantId, ancestorId); err != nil {
+		return err
+	}
+
+	// 插入操作日志
+	common.Logger.Sugar().Warnf("AdminTenantSetParent: user %d set tenant %d parent to %d", sessUser.UID, descendantId, ancestorId)
+	return nil
+}
+
+// TenantSetParent 把租户（连同整棵子树）移到新的父租户下；不做操作者鉴权，供管理接口与运维命令使用
+func TenantSetParent(descendantId, ancestorId uint64) error {
 	// 参数校验
 	if descendantId <= 0 || ancestorId <= 0 {
 		common.Logger.Sugar().Error("TenantSetParent param ERR: ", ancestorId, descendantId)
@@ -268,9 +311,7 @@ func AdminTenantSetParent(sessUser *protos.User, descendantId, ancestorId uint64
 	}
 
 	// 开始事务
-	tx, err := common.DBPool.BeginTx(context.Background(), pgx.TxOptions{
-		IsoLevel: pgx.Serializable,
-	})
+	tx, err := common.DB.Begin(context.Background())
 	if err != nil {
 		common.Logger.Sugar().Errorf("AdminTenantSetParent begin transaction ERR: %v\n", err)
 		return common.ErrService
@@ -283,20 +324,28 @@ func AdminTenantSetParent(sessUser *protos.User, descendantId, ancestorId uint64
 
 		}
 	}()
+	if common.DB.DriverType() == database.DriverPostgreSQL {
+		// SQLite 单连接天然串行，PostgreSQL 需要显式提升隔离级别
+		if _, err = tx.Exec(context.Background(), "SET TRANSACTION ISOLATION LEVEL SERIALIZABLE"); err != nil {
+			common.Logger.Sugar().Errorf("AdminTenantSetParent set isolation ERR: %v\n", err)
+			return common.ErrService
+		}
+	}
 
 	// 检查是否会形成循环引用（新父租户不能是当前租户的子孙节点）
 	if ancestorId != common.ServConfig.RootTenantID {
 		var count int
-		err = tx.QueryRow(context.Background(),
-			"SELECT COUNT(*) FROM tenant_closure WHERE ancestor_id = $1 AND descendant_id = $2",
-			descendantId, ancestorId).Scan(&count)
-		if err != nil {
+		query, args, _ := sq.Select("COUNT(*)").From("tenant_closure").
+			Where(sq.Eq{"ancestor_id": descendantId, "descendant_id": ancestorId}).
+			PlaceholderFormat(database.GetPlaceholderFormat(common.DB.DriverType())).ToSql()
+		if err = tx.QueryRow(context.Background(), query, args...).Scan(&count); err != nil {
 			common.Logger.Sugar().Errorf("AdminTenantSetParent check cycle ERR: %v\n", err)
 			return common.ErrService
 		}
 		if count > 0 {
 			common.Logger.Sugar().Errorf("AdminTenantSetParent cycle detected: new parent %d is descendant of %d", ancestorId, descendantId)
-			return common.ErrTenantCircularRef
+			err = common.ErrTenantCircularRef
+			return err
 		}
 	}
 
@@ -306,16 +355,12 @@ func AdminTenantSetParent(sessUser *protos.User, descendantId, ancestorId uint64
 		return err
 	}
 
-	// 插入操作日志
-	common.Logger.Sugar().Warnf("AdminTenantSetParent: user %d set tenant %d parent to %d", sessUser.UID, descendantId, ancestorId)
-
 	return nil
 }
 
-// AdminTenantUpdateConfig 更新租户配置
+// AdminTenantUpdateConfig 整体替换租户配置，LastUpdateTime 做乐观锁；每次修改记一个配置版本。
+// 角色字典按 tenantRoleReplace 检查配额和仍在使用的角色。
 func AdminTenantUpdateConfig(sessUser *protos.User, req *protos.UpdateTenantConfigReq) error {
-	defer evictTenantCache(req.TenantID)
-
 	// 权限检查：只有root租户的超级管理员或者租户自己的管理员才能更新配置
 	if sessUser.TenantID != common.ServConfig.RootTenantID && sessUser.TenantID != req.TenantID {
 		common.Logger.Sugar().Error("AdminTenantUpdateConfig auth ERR: ", sessUser.TenantID, req.TenantID)
@@ -328,37 +373,29 @@ func AdminTenantUpdateConfig(sessUser *protos.User, req *protos.UpdateTenantConf
 		return common.ErrParam
 	}
 
-	updateTime, err := time.Parse(time.RFC3339, req.LastUpdateTime)
+	updateTime, err := parseLastUpdateTime(req.LastUpdateTime)
 	if err != nil {
-		common.Logger.Sugar().Errorf("解析更新时间失败: %v", err)
-		return common.ErrParam
+		return err
 	}
 
-	// 检查tenant是否存在
-	tenant, err := dao.TenantGetByID(req.TenantID)
+	// 角色字典的配额与仍在使用的角色与回滚一样检查
+	roles, err := newTenantRoleReplace(req.TenantID, req.Configuration.Roles)
 	if err != nil {
-		common.Logger.Sugar().Errorf("AdminTenantUpdateConfig db ERR: %v\n", err)
-		return common.ErrService
-	}
-	if nil == tenant {
-		return common.ErrTenantNotFound
+		common.Logger.Sugar().Errorf("AdminTenantUpdateConfig roles ERR: %v\n", err)
+		return err
 	}
+	defer func() { roles.finish(err) }()
 
-	// 更新租户配置
-	tenant.Configuration = req.Configuration
-	tenant.UpdateTime = &updateTime
-	if err := dao.TenantUpdateConfiguration(tenant); err != nil {
-		common.Logger.Sugar().Errorf("AdminTenantUpdateConfig update ERR: %v\n", err)
-		// 根据错误类型返回不同的错误信息
-		switch err {
-		case common.ErrTenantNotFound:
-			return common.ErrTenantNotFound
-		case common.ErrModify:
-			// 更新时间不匹配
-			return common.ErrModify
-		default:
-			return common.ErrService
+	// 更新租户配置；租户不存在或更新时间不匹配时原样返回 ErrTenantNotFound / ErrModify
+	if _, err = saveTenantConfiguration(req.TenantID, sessUser.UID, updateTime, "", func(conf *protos.TenantConfiguration) error {
+		if err := roles.check(conf); err != nil {
+			return err
 		}
+		*conf = *req.Configuration
+		return nil
+	}); err != nil {
+		common.Logger.Sugar().Errorf("AdminTenantUpdateConfig update ERR: %v\n", err)
+		return err
 	}
 
 	// 记录操作日志
@@ -407,7 +444,7 @@ func AdminTenantUpdate(sessUser *protos.User, req *protos.UpdateTenantReq) error
 	return nil
 }
 
-// AdminTenantDelete 删除指定租户本身，并将其直接子租户挂到当前会话租户下。
+// AdminTenantDelete 软删除租户：归档并记下删除时间，成员随即不能登录；恢复期过后由清理任务物理删除，并把直接子租户挂到根租户下。
 func AdminTenantDelete(sessUser *protos.User, tenantID uint64) error {
 	if sessUser == nil || sessUser.UID <= 0 || sessUser.TenantID <= 0 || tenantID <= 0 {
 		common.Logger.Sugar().Error("AdminTenantDelete param ERR: ", sessUser.UID, sessUser.TenantID, tenantID)
@@ -417,50 +454,40 @@ func AdminTenantDelete(sessUser *protos.User, tenantID uint64) error {
 		common.Logger.Sugar().Error("AdminTenantDelete auth ERR: ", sessUser.UID, sessUser.TenantID)
 		return common.ErrNoAuth
 	}
+	return deleteTenant(sessUser, tenantID)
+}
+
+// deleteTenant 软删除租户，调用方负责鉴权；根租户不能删除。
+func deleteTenant(sessUser *protos.User, tenantID uint64) error {
 	if tenantID == common.ServConfig.RootTenantID {
 		common.Logger.Sugar().Error("AdminTenantDelete root tenant ERR: ", tenantID)
 		return common.ErrNoAuth
 	}
 
-	// 先检查租户是否存在（通过 service 层接口）
-	if _, err := TenantGetByIDService(tenantID); err != nil {
-		common.Logger.Sugar().Errorf("AdminTenantDelete TenantGetByIDService ERR: %v\n", err)
-		return err
-	}
-
-	// 查询该租户的后代节点，用于识别直接子租户（depth=1）。
-	subtree, err := collectTenantSubtree(tenantID)
+	tenant, err := dao.TenantGetByID(tenantID)
 	if err != nil {
-		common.Logger.Sugar().Errorf("AdminTenantDelete collectTenantSubtree ERR: %v\n", err)
+		common.Logger.Sugar().Errorf("AdminTenantDelete db ERR: %v\n", err)
 		return common.ErrService
 	}
-
-	// 把目标租户的直接子租户重新挂到当前会话租户下。
-	for _, child := range subtree {
-		if child.Depth != 1 || child.ID == tenantID {
-			continue
-		}
-		if err := AdminTenantSetParent(sessUser, child.ID, sessUser.TenantID); err != nil {
-			common.Logger.Sugar().Errorf("AdminTenantDelete reparent child ERR: child=%d parent=%d err=%v\n", child.ID, sessUser.TenantID, err)
-			return common.ErrService
-		}
+	if tenant == nil {
+		return common.ErrTenantNotFound
 	}
-
-	// 仅删除目标租户自身数据。
-	if e := deleteUsersByTenant(tenantID); e != nil {
-		common.Logger.Sugar().Errorf("AdminTenantDelete deleteUsersByTenant ERR: %v\n", e)
-		return common.ErrService
+	if tenant.DeleteTime != nil {
+		return common.ErrTenantDeleted
 	}
-	if e := deleteTenantRelatedData(tenantID); e != nil {
-		common.Logger.Sugar().Errorf("AdminTenantDelete deleteTenantRelatedData ERR: %v\n", e)
+
+	// 只做软删除：归档并记下删除时间，恢复期过后由 PurgeDeletedTenants 物理删除
+	defer evictTenantCache(tenantID)
+	now := time.Now()
+	rows, err := dao.TenantSoftDelete(tenantID, tenant.Status, fmt.Sprintf("deleted by %d", sessUser.UID), now)
+	if err != nil {
 		return common.ErrService
 	}
-	if e := deleteTenantRecord(tenantID); e != nil {
-		common.Logger.Sugar().Errorf("AdminTenantDelete deleteTenantRecord ERR: %v\n", e)
-		return common.ErrService
+	if rows == 0 {
+		return common.ErrModify
 	}
-	evictTenantCache(tenantID)
 
+	common.Logger.Sugar().Warnf("AdminTenantDelete: user %d deleted tenant %d %s, restorable until %v", sessUser.UID, tenantID, tenant.TenantName, now.Add(tenantRestoreWindow()))
 	return nil
 }
 
@@ -487,32 +514,35 @@ func collectTenantSubtree(ancestorID uint64) ([]protos.Tenant, error) {
 	return out, nil
 }
 
-func deleteUsersByTenant(tenantID uint64) error {
+func deleteUsersByTenant(tenantID uint64) (int64, error) {
+	var n int64
 	page := uint64(1)
 	pageSize := uint64(500)
 
 	for {
 		users, err := dao.UserQueryByTenant(tenantID, page, pageSize, "", nil)
 		if err != nil {
-			return err
+			return n, err
 		}
 		if len(users) == 0 {
 			break
 		}
 		for _, user := range users {
-			if _, err = TenantUserDel(user.UID, tenantID); err != nil {
-				return err
+			r, err := TenantUserDel(user.UID, tenantID)
+			if err != nil {
+				return n, err
 			}
+			n += r
 		}
 		if uint64(len(users)) < pageSize {
 			break
 		}
 	}
 
-	return nil
+	return n, nil
 }
 
-func deleteTenantRelatedData(tenantID uint64) error {
+func deleteTenantRelatedData(tenantID uint64) (departments, permissions int64, err error) {
 	placeholder := database.GetPlaceholderFormat(common.DB.DriverType())
 	ctx := context.Background()
 
@@ -521,24 +551,51 @@ func deleteTenantRelatedData(tenantID uint64) error {
 		PlaceholderFormat(placeholder).
 		ToSql()
 	if err != nil {
-		return err
+		return
 	}
-	if _, err = common.DB.Exec(ctx, delDepSQL, delDepArgs...); err != nil {
-		return err
+	rst, err := common.DB.Exec(ctx, delDepSQL, delDepArgs...)
+	if err != nil {
+		return
 	}
+	departments, _ = rst.RowsAffected()
 
 	delPermissionSQL, delPermissionArgs, err := sq.Delete("permission").
 		Where(sq.Eq{"tenant_id": tenantID}).
 		PlaceholderFormat(placeholder).
 		ToSql()
 	if err != nil {
-		return err
+		return
 	}
-	if _, err = common.DB.Exec(ctx, delPermissionSQL, delPermissionArgs...); err != nil {
-		return err
+	if rst, err = common.DB.Exec(ctx, delPermissionSQL, delPermissionArgs...); err != nil {
+		return
 	}
+	permissions, _ = rst.RowsAffected()
 
-	return nil
+	// 其余按 tenant_id 记录的数据：配额、成员、限时授权、数据范围、邀请、配置版本、组织及其成员与闭包、调动记录
+	for _, table := range []string{"tenant_quotas", "tenant_members", "role_grants", "role_data_scopes", "invitations",
+		"tenant_config_versions", "org_member_transfers", "org_members", "org_closure", "organizations"} {
+		query, args, e := sq.Delete(table).
+			Where(sq.Eq{"tenant_id": tenantID}).
+			PlaceholderFormat(placeholder).
+			ToSql()
+		if e != nil {
+			return departments, permissions, e
+		}
+		if _, err = common.DB.Exec(ctx, query, args...); err != nil {
+			return departments, permissions, fmt.Errorf("%s: %w", table, err)
+		}
+	}
+
+	// 直接子租户已移到根租户下，剩下的闭包行都指向该租户本身
+	delClosureSQL, delClosureArgs, err := sq.Delete("tenant_closure").
+		Where(sq.Or{sq.Eq{"ancestor_id": tenantID}, sq.Eq{"descendant_id": tenantID}}).
+		PlaceholderFormat(placeholder).
+		ToSql()
+	if err != nil {
+		return
+	}
+	_, err = common.DB.Exec(ctx, delClosureSQL, delClosureArgs...)
+	return
 }
 
 func deleteTenantRecord(tenantID uint64) error {
@@ -10,11 +10,13 @@ import (
 	"github.com/liuhengloveyou/passport/v4/protos"
 )
 
-// 数据范围：与 Casbin 功能权限并列。角色按模块配置 all / dept / self。
+// 数据范围：与 Casbin 功能权限并列。角色按模块配置 all / dept_tree / dept / custom / self。
 const (
-	DataScopeLevelAll  = "all"
-	DataScopeLevelDept = "dept"
-	DataScopeLevelSelf = "self"
+	DataScopeLevelAll      = "all"
+	DataScopeLevelDeptTree = "dept_tree" // 本部门及全部下级部门
+	DataScopeLevelDept     = "dept"
+	DataScopeLevelCustom   = "custom" // 角色按模块指定的部门列表（role_data_scopes）
+	DataScopeLevelSelf     = "self"
 
 	// Department.config 中部门负责人 UID 列表（并入「本部门」可见部门）
 	DepartmentLeaderUIDsKey = "leaderUids"
@@ -25,11 +27,28 @@ type DataScope struct {
 	Level  string   `json:"level"`
 	DepIDs []uint64 `json:"depIds,omitempty"`
 	UIDs   []uint64 `json:"uids,omitempty"`
+
+	// 解析时的上下文，转换 SQL 条件时使用
+	UID      uint64 `json:"-"`
+	TenantID uint64 `json:"-"`
+	OrgID    uint64 `json:"-"`
 }
 
+// dataScopeLevels 按范围从大到小排列，命中多个时取第一个
+var dataScopeLevels = []string{DataScopeLevelAll, DataScopeLevelDeptTree, DataScopeLevelDept, DataScopeLevelCustom, DataScopeLevelSelf}
+
 // ResolveDataScope 按角色为该 module 配置的数据范围解析。
-// Casbin：data-scope/{module}/all|dept|self + GET；未配置则默认 self。
+// Casbin：data-scope/{module}/all|dept_tree|dept|custom|self + GET；未配置则默认 self。
 func ResolveDataScope(uid, tenantID, orgID uint64, module string) (DataScope, error) {
+	scope, err := resolveDataScope(uid, tenantID, orgID, module)
+	if err != nil {
+		return scope, err
+	}
+	scope.UID, scope.TenantID, scope.OrgID = uid, tenantID, orgID
+	return scope, nil
+}
+
+func resolveDataScope(uid, tenantID, orgID uint64, module string) (DataScope, error) {
 	if uid == 0 || tenantID == 0 || orgID == 0 {
 		return DataScope{}, common.ErrParam
 	}
@@ -37,7 +56,7 @@ func ResolveDataScope(uid, tenantID, orgID uint64, module string) (DataScope, er
 		module = "default"
 	}
 
-	if isDataScopeRoot(uid, tenantID, orgID) {
+	if isOrgRoot(uid, tenantID, orgID) {
 		return DataScope{Level: DataScopeLevelAll}, nil
 	}
 
@@ -45,11 +64,27 @@ func ResolveDataScope(uid, tenantID, orgID uint64, module string) (DataScope, er
 	switch level {
 	case DataScopeLevelAll:
 		return DataScope{Level: DataScopeLevelAll}, nil
-	case DataScopeLevelDept:
-		depIDs, err := resolveUserDeptIDs(uid, tenantID, orgID)
+	case DataScopeLevelDeptTree, DataScopeLevelDept, DataScopeLevelCustom:
+		deps, err := DepartmentFind(0, tenantID, orgID, 0, 0)
+		if err != nil {
+			common.Logger.Sugar().Errorf("ResolveDataScope deps: %v", err)
+			return DataScope{}, common.ErrService
+		}
+
+		var depIDs []uint64
+		switch level {
+		case DataScopeLevelCustom:
+			depIDs, err = resolveCustomDeptIDs(uid, tenantID, orgID, module, deps)
+		default:
+			depIDs, err = resolveUserDeptIDs(uid, tenantID, deps)
+			if err == nil && level == DataScopeLevelDeptTree {
+				depIDs = departmentDescendants(deps, depIDs)
+			}
+		}
 		if err != nil {
 			return DataScope{}, err
 		}
+
 		members, err := memberUIDsInDeps(tenantID, orgID, depIDs)
 		if err != nil {
 			return DataScope{}, err
@@ -57,14 +92,14 @@ func ResolveDataScope(uid, tenantID, orgID uint64, module string) (DataScope, er
 		if !containsUint64(members, uid) {
 			members = append(members, uid)
 		}
-		return DataScope{Level: DataScopeLevelDept, DepIDs: depIDs, UIDs: members}, nil
+		return DataScope{Level: level, DepIDs: depIDs, UIDs: members}, nil
 	default:
 		return DataScope{Level: DataScopeLevelSelf, UIDs: []uint64{uid}}, nil
 	}
 }
 
 func resolveConfiguredLevel(uid, tenantID, orgID uint64, module string) string {
-	for _, lv := range []string{DataScopeLevelAll, DataScopeLevelDept, DataScopeLevelSelf} {
+	for _, lv := range dataScopeLevels {
 		obj := fmt.Sprintf("data-scope/%s/%s", module, lv)
 		ok, err := accessctl.Enforce(uid, tenantID, orgID, obj, "GET")
 		if err != nil {
@@ -78,7 +113,7 @@ func resolveConfiguredLevel(uid, tenantID, orgID uint64, module string) string {
 	return DataScopeLevelSelf
 }
 
-func resolveUserDeptIDs(uid, tenantID, orgID uint64) ([]uint64, error) {
+func resolveUserDeptIDs(uid, tenantID uint64, deps []protos.Department) ([]uint64, error) {
 	user, err := dao.UserQueryByID(uid)
 	if err != nil {
 		common.Logger.Sugar().Errorf("resolveUserDeptIDs user: %v", err)
@@ -97,17 +132,12 @@ func resolveUserDeptIDs(uid, tenantID, orgID uint64) ([]uint64, error) {
 		depIDs = append(depIDs, id)
 	}
 
-	deps, err := DepartmentFind(0, tenantID, orgID, 0, 0)
-	if err != nil {
-		common.Logger.Sugar().Errorf("resolveUserDeptIDs deps: %v", err)
-		return nil, common.ErrService
-	}
 	orgDep := make(map[uint64]struct{}, len(deps))
 	for i := range deps {
 		orgDep[deps[i].Id] = struct{}{}
 	}
 
-	if user != nil && user.TenantID == tenantID {
+	if user != nil && UserInTenant(uid, tenantID) == nil {
 		for _, id := range parseUint64Slice(user.Ext[protos.DepartmentExtKey]) {
 			if _, ok := orgDep[id]; ok {
 				add(id)
@@ -123,7 +153,121 @@ func resolveUserDeptIDs(uid, tenantID, orgID uint64) ([]uint64, error) {
 	return depIDs, nil
 }
 
-func isDataScopeRoot(uid, tenantID, orgID uint64) bool {
+// resolveCustomDeptIDs 合并用户在该组织内各角色为 module 配置的部门列表，只保留仍属于本组织的部门。
+func resolveCustomDeptIDs(uid, tenantID, orgID uint64, module string, deps []protos.Department) ([]uint64, error) {
+	roles := accessctl.GetRoleForUserInDomain(uid, tenantID, orgID)
+	byRole, err := dao.RoleDataScopeDeps(tenantID, orgID, module, roles)
+	if err != nil {
+		common.Logger.Sugar().Errorf("resolveCustomDeptIDs dao.RoleDataScopeDeps: %v", err)
+		return nil, common.ErrService
+	}
+
+	orgDep := make(map[uint64]struct{}, len(deps))
+	for i := range deps {
+		orgDep[deps[i].Id] = struct{}{}
+	}
+	depIDs := make([]uint64, 0)
+	for _, role := range roles {
+		for _, id := range byRole[role] {
+			if _, ok := orgDep[id]; ok && !containsUint64(depIDs, id) {
+				depIDs = append(depIDs, id)
+			}
+		}
+	}
+	return depIDs, nil
+}
+
+// departmentDescendants 返回 roots 及其全部下级部门（按层序，去重）。
+// 一次建好 parent -> children 索引后逐层展开，避免逐级查库；遇到环也不会死循环。
+func departmentDescendants(deps []protos.Department, roots []uint64) []uint64 {
+	children := make(map[uint64][]uint64, len(deps))
+	for i := range deps {
+		if deps[i].ParentID != deps[i].Id {
+			children[deps[i].ParentID] = append(children[deps[i].ParentID], deps[i].Id)
+		}
+	}
+
+	out := make([]uint64, 0, len(roots))
+	seen := make(map[uint64]struct{}, len(deps))
+	for _, id := range roots {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			out = append(out, id)
+		}
+	}
+	for i := 0; i < len(out); i++ {
+		for _, c := range children[out[i]] {
+			if _, ok := seen[c]; !ok {
+				seen[c] = struct{}{}
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+// DataScopeSetCustomDeps 设置角色在 module 下的自定义部门列表（custom 级别使用），部门须属于本组织。
+func DataScopeSetCustomDeps(tenantID, orgID uint64, role, module string, depIDs []uint64) error {
+	if tenantID == 0 || role == "" {
+		return common.ErrParam
+	}
+	if orgID == 0 {
+		return common.ErrOrgRequired
+	}
+	if module == "" {
+		module = "default"
+	}
+
+	if len(depIDs) > 0 {
+		deps, err := DepartmentFind(0, tenantID, orgID, 0, 0)
+		if err != nil {
+			common.Logger.Sugar().Errorf("DataScopeSetCustomDeps deps: %v", err)
+			return common.ErrService
+		}
+		orgDep := make(map[uint64]struct{}, len(deps))
+		for i := range deps {
+			orgDep[deps[i].Id] = struct{}{}
+		}
+		uniq := make([]uint64, 0, len(depIDs))
+		for _, id := range depIDs {
+			if _, ok := orgDep[id]; !ok {
+				common.Logger.Sugar().Warnf("DataScopeSetCustomDeps dep not in org: %v %v %v", tenantID, orgID, id)
+				return common.ErrParam
+			}
+			if !containsUint64(uniq, id) {
+				uniq = append(uniq, id)
+			}
+		}
+		depIDs = uniq
+	}
+
+	if err := dao.RoleDataScopeSetDeps(tenantID, orgID, role, module, depIDs); err != nil {
+		common.Logger.Sugar().Errorf("DataScopeSetCustomDeps dao.RoleDataScopeSetDeps: %v", err)
+		return common.ErrService
+	}
+	return nil
+}
+
+// DataScopeGetCustomDeps 查询角色在 module 下的自定义部门列表。
+func DataScopeGetCustomDeps(tenantID, orgID uint64, role, module string) ([]uint64, error) {
+	if orgID == 0 {
+		return nil, common.ErrOrgRequired
+	}
+	if module == "" {
+		module = "default"
+	}
+	byRole, err := dao.RoleDataScopeDeps(tenantID, orgID, module, []string{role})
+	if err != nil {
+		common.Logger.Sugar().Errorf("DataScopeGetCustomDeps dao.RoleDataScopeDeps: %v", err)
+		return nil, common.ErrService
+	}
+	if byRole[role] == nil {
+		return []uint64{}, nil
+	}
+	return byRole[role], nil
+}
+
+func isOrgRoot(uid, tenantID, orgID uint64) bool {
 	roles := accessctl.GetRoleForUserInDomain(uid, tenantID, orgID)
 	for _, r := range roles {
 		if r == "root" {
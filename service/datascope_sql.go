@@ -0,0 +1,133 @@
+package service
+
+import (
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"xorm.io/builder"
+
+	"github.com/liuhengloveyou/passport/v4/database"
+)
+
+// DataScopeInlineUIDLimit UID 超过该数量时不再展开 IN 列表，改为按部门成员子查询。
+var DataScopeInlineUIDLimit = 200
+
+// DataScopeColumns 业务表中参与数据范围过滤的列名（可带表别名）；为空的列不参与过滤。
+type DataScopeColumns struct {
+	OwnerUID string // 数据归属人，如 "o.owner_uid"
+	DepID    string // 数据所属部门，如 "o.dep_id"
+	OrgID    string // 数据所属组织，如 "o.org_id"
+}
+
+// DataScopeSqlizer 把数据范围转换为 squirrel 条件，配合 PlaceholderFormat(database.GetPlaceholderFormat(driver)) 使用。
+//
+//	scope, _ := service.ResolveDataScope(uid, tenantID, orgID, "orders")
+//	sq.Select("*").From("orders o").Where(service.DataScopeSqlizer(common.DB.DriverType(), scope, cols))
+func DataScopeSqlizer(driver database.DriverType, scope DataScope, cols DataScopeColumns) sq.Sqlizer {
+	var org sq.Sqlizer
+	if cols.OrgID != "" && scope.OrgID > 0 {
+		org = sq.Eq{cols.OrgID: scope.OrgID}
+	}
+
+	var cond sq.Sqlizer
+	switch scope.Level {
+	case DataScopeLevelAll:
+		if org == nil {
+			return sq.Expr("1=1")
+		}
+		return org
+	case DataScopeLevelSelf:
+		if cols.OwnerUID == "" || scope.UID == 0 {
+			return sq.Expr("1=0")
+		}
+		cond = sq.Eq{cols.OwnerUID: scope.UID}
+	case DataScopeLevelDeptTree, DataScopeLevelDept, DataScopeLevelCustom:
+		or := sq.Or{}
+		if cols.DepID != "" && len(scope.DepIDs) > 0 {
+			or = append(or, sq.Eq{cols.DepID: scope.DepIDs})
+		}
+		if cols.OwnerUID != "" {
+			if sql, args, ok := dataScopeMemberSubquery(driver, scope); ok {
+				or = append(or, sq.Expr(cols.OwnerUID+" IN ("+sql+")", args...), sq.Eq{cols.OwnerUID: scope.UID})
+			} else if len(scope.UIDs) > 0 {
+				or = append(or, sq.Eq{cols.OwnerUID: scope.UIDs})
+			}
+		}
+		if len(or) == 0 {
+			return sq.Expr("1=0")
+		}
+		cond = or
+	default:
+		return sq.Expr("1=0")
+	}
+
+	if org == nil {
+		return cond
+	}
+	return sq.And{org, cond}
+}
+
+// DataScopeCond 把数据范围转换为 xorm builder 条件，与 DataScopeSqlizer 语义一致。
+//
+//	builder.Dialect(builder.POSTGRES).Select("*").From("orders o").Where(service.DataScopeCond(driver, scope, cols))
+func DataScopeCond(driver database.DriverType, scope DataScope, cols DataScopeColumns) builder.Cond {
+	cond := builder.NewCond()
+	if cols.OrgID != "" && scope.OrgID > 0 {
+		cond = cond.And(builder.Eq{cols.OrgID: scope.OrgID})
+	}
+
+	switch scope.Level {
+	case DataScopeLevelAll:
+		return cond
+	case DataScopeLevelSelf:
+		if cols.OwnerUID == "" || scope.UID == 0 {
+			return builder.Expr("1=0")
+		}
+		return cond.And(builder.Eq{cols.OwnerUID: scope.UID})
+	case DataScopeLevelDeptTree, DataScopeLevelDept, DataScopeLevelCustom:
+		or := builder.NewCond()
+		if cols.DepID != "" && len(scope.DepIDs) > 0 {
+			or = or.Or(builder.In(cols.DepID, scope.DepIDs))
+		}
+		if cols.OwnerUID != "" {
+			if sql, args, ok := dataScopeMemberSubquery(driver, scope); ok {
+				or = or.Or(builder.Expr(cols.OwnerUID+" IN ("+sql+")", args...), builder.Eq{cols.OwnerUID: scope.UID})
+			} else if len(scope.UIDs) > 0 {
+				or = or.Or(builder.In(cols.OwnerUID, scope.UIDs))
+			}
+		}
+		if !or.IsValid() {
+			return builder.Expr("1=0")
+		}
+		return cond.And(or)
+	}
+
+	return builder.Expr("1=0")
+}
+
+// dataScopeMemberSubquery UID 过多时返回「组织内部门成员」子查询（占位符为 ?）。
+// 部门归属记录在 users.ext 的 deps 数组中，需按方言展开。
+func dataScopeMemberSubquery(driver database.DriverType, scope DataScope) (string, []interface{}, bool) {
+	if len(scope.UIDs) <= DataScopeInlineUIDLimit || len(scope.DepIDs) == 0 || scope.OrgID == 0 {
+		return "", nil, false
+	}
+
+	var elems string
+	switch driver {
+	case database.DriverPostgreSQL:
+		elems = "SELECT 1 FROM jsonb_array_elements_text(CASE WHEN jsonb_typeof(u.ext->'deps') = 'array' THEN u.ext->'deps' ELSE '[]'::jsonb END) AS d(v) WHERE d.v::BIGINT IN "
+	case database.DriverSQLite3:
+		elems = "SELECT 1 FROM json_each(u.ext, '$.deps') AS d WHERE CAST(d.value AS INTEGER) IN "
+	default:
+		return "", nil, false
+	}
+
+	args := make([]interface{}, 0, len(scope.DepIDs)+2)
+	args = append(args, scope.TenantID, scope.OrgID)
+	for _, id := range scope.DepIDs {
+		args = append(args, id)
+	}
+	sql := "SELECT m.uid FROM org_members m JOIN users u ON u.uid = m.uid WHERE m.tenant_id = ? AND m.org_id = ? AND EXISTS (" +
+		elems + "(" + strings.TrimSuffix(strings.Repeat("?,", len(scope.DepIDs)), ",") + "))"
+	return sql, args, true
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"xorm.io/builder"
+
+	"github.com/liuhengloveyou/passport/v4/database"
+)
+
+var testDataScopeCols = DataScopeColumns{OwnerUID: "o.owner_uid", DepID: "o.dep_id", OrgID: "o.org_id"}
+
+func TestDataScopeSqlizer(t *testing.T) {
+	defer func(n int) { DataScopeInlineUIDLimit = n }(DataScopeInlineUIDLimit)
+	DataScopeInlineUIDLimit = 2
+
+	dept := DataScope{Level: DataScopeLevelDept, UID: 1, TenantID: 3, OrgID: 7, DepIDs: []uint64{5}, UIDs: []uint64{1, 2}}
+	tree := DataScope{Level: DataScopeLevelDeptTree, UID: 1, TenantID: 3, OrgID: 7, DepIDs: []uint64{5, 6}, UIDs: []uint64{1, 2, 4}}
+	cases := []struct {
+		name   string
+		driver database.DriverType
+		scope  DataScope
+		cols   DataScopeColumns
+		sql    string
+		args   []interface{}
+	}{
+		{"all", database.DriverPostgreSQL, DataScope{Level: DataScopeLevelAll, OrgID: 7}, testDataScopeCols,
+			"SELECT id FROM orders o WHERE o.org_id = $1", []interface{}{uint64(7)}},
+		{"all_no_org_column", database.DriverSQLite3, DataScope{Level: DataScopeLevelAll, OrgID: 7}, DataScopeColumns{OwnerUID: "o.owner_uid"},
+			"SELECT id FROM orders o WHERE 1=1", nil},
+		{"self", database.DriverSQLite3, DataScope{Level: DataScopeLevelSelf, UID: 1, OrgID: 7}, testDataScopeCols,
+			"SELECT id FROM orders o WHERE (o.org_id = ? AND o.owner_uid = ?)", []interface{}{uint64(7), uint64(1)}},
+		{"dept_inline", database.DriverPostgreSQL, dept, testDataScopeCols,
+			"SELECT id FROM orders o WHERE (o.org_id = $1 AND (o.dep_id IN ($2) OR o.owner_uid IN ($3,$4)))",
+			[]interface{}{uint64(7), uint64(5), uint64(1), uint64(2)}},
+		{"dept_tree_subquery_postgres", database.DriverPostgreSQL, tree, testDataScopeCols,
+			"SELECT id FROM orders o WHERE (o.org_id = $1 AND (o.dep_id IN ($2,$3) OR o.owner_uid IN (SELECT m.uid FROM org_members m JOIN users u ON u.uid = m.uid WHERE m.tenant_id = $4 AND m.org_id = $5 AND EXISTS (SELECT 1 FROM jsonb_array_elements_text(CASE WHEN jsonb_typeof(u.ext->'deps') = 'array' THEN u.ext->'deps' ELSE '[]'::jsonb END) AS d(v) WHERE d.v::BIGINT IN ($6,$7))) OR o.owner_uid = $8))",
+			[]interface{}{uint64(7), uint64(5), uint64(6), uint64(3), uint64(7), uint64(5), uint64(6), uint64(1)}},
+		{"dept_tree_subquery_sqlite", database.DriverSQLite3, tree, DataScopeColumns{OwnerUID: "o.owner_uid"},
+			"SELECT id FROM orders o WHERE (o.owner_uid IN (SELECT m.uid FROM org_members m JOIN users u ON u.uid = m.uid WHERE m.tenant_id = ? AND m.org_id = ? AND EXISTS (SELECT 1 FROM json_each(u.ext, '$.deps') AS d WHERE CAST(d.value AS INTEGER) IN (?,?))) OR o.owner_uid = ?)",
+			[]interface{}{uint64(3), uint64(7), uint64(5), uint64(6), uint64(1)}},
+		{"no_usable_column", database.DriverSQLite3, dept, DataScopeColumns{OrgID: "o.org_id"},
+			"SELECT id FROM orders o WHERE 1=0", nil},
+		{"unknown_level", database.DriverPostgreSQL, DataScope{Level: "nobody"}, testDataScopeCols,
+			"SELECT id FROM orders o WHERE 1=0", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sql, args, err := sq.Select("id").From("orders o").Where(DataScopeSqlizer(tc.driver, tc.scope, tc.cols)).
+				PlaceholderFormat(database.GetPlaceholderFormat(tc.driver)).ToSql()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sql != tc.sql {
+				t.Fatalf("sql:\n got %s\nwant %s", sql, tc.sql)
+			}
+			if len(args) != len(tc.args) || (len(args) > 0 && !reflect.DeepEqual(args, tc.args)) {
+				t.Fatalf("args = %v, want %v", args, tc.args)
+			}
+		})
+	}
+}
+
+func TestDataScopeCond(t *testing.T) {
+	defer func(n int) { DataScopeInlineUIDLimit = n }(DataScopeInlineUIDLimit)
+	DataScopeInlineUIDLimit = 2
+
+	cases := []struct {
+		name    string
+		dialect string
+		scope   DataScope
+		sql     string
+		args    []interface{}
+	}{
+		{"all", builder.POSTGRES, DataScope{Level: DataScopeLevelAll, OrgID: 7},
+			"SELECT id FROM orders o WHERE o.org_id=$1", []interface{}{uint64(7)}},
+		{"self", builder.SQLITE, DataScope{Level: DataScopeLevelSelf, UID: 1, OrgID: 7},
+			"SELECT id FROM orders o WHERE o.org_id=? AND o.owner_uid=?", []interface{}{uint64(7), uint64(1)}},
+		{"custom_inline", builder.SQLITE, DataScope{Level: DataScopeLevelCustom, UID: 1, OrgID: 7, DepIDs: []uint64{5}, UIDs: []uint64{1, 2}},
+			"SELECT id FROM orders o WHERE o.org_id=? AND (o.dep_id IN (?) OR o.owner_uid IN (?,?))",
+			[]interface{}{uint64(7), uint64(5), uint64(1), uint64(2)}},
+		{"dept_subquery", builder.POSTGRES, DataScope{Level: DataScopeLevelDept, UID: 1, TenantID: 3, OrgID: 7, DepIDs: []uint64{5}, UIDs: []uint64{1, 2, 4}},
+			"SELECT id FROM orders o WHERE o.org_id=$1 AND (o.dep_id IN ($2) OR (o.owner_uid IN (SELECT m.uid FROM org_members m JOIN users u ON u.uid = m.uid WHERE m.tenant_id = $3 AND m.org_id = $4 AND EXISTS (SELECT 1 FROM jsonb_array_elements_text(CASE WHEN jsonb_typeof(u.ext->'deps') = 'array' THEN u.ext->'deps' ELSE '[]'::jsonb END) AS d(v) WHERE d.v::BIGINT IN ($5)))) OR o.owner_uid=$6)",
+			[]interface{}{uint64(7), uint64(5), uint64(3), uint64(7), uint64(5), uint64(1)}},
+		{"unknown_level", builder.SQLITE, DataScope{Level: "nobody"}, "SELECT id FROM orders o WHERE 1=0", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sql, args, err := builder.Dialect(tc.dialect).Select("id").From("orders o").
+				Where(DataScopeCond(database.DriverType(tc.dialect), tc.scope, testDataScopeCols)).ToSQL()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sql != tc.sql {
+				t.Fatalf("sql:\n got %s\nwant %s", sql, tc.sql)
+			}
+			if len(args) != len(tc.args) || (len(args) > 0 && !reflect.DeepEqual(args, tc.args)) {
+				t.Fatalf("args = %v, want %v", args, tc.args)
+			}
+		})
+	}
+}
+
+// TestDataScopeSubquerySQLite 在内存 SQLite 上执行子查询条件，确认与内联 UID 列表结果一致。
+func TestDataScopeSubquerySQLite(t *testing.T) {
+	db, err := database.NewSQLite3DB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	for _, s := range []string{
+		"CREATE TABLE users (uid INTEGER PRIMARY KEY, tenant_id INTEGER, ext TEXT)",
+		"CREATE TABLE org_members (org_id INTEGER, uid INTEGER, tenant_id INTEGER)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, owner_uid INTEGER, org_id INTEGER)",
+		// 1 本人；2、3 在部门 5/6；4 在其他部门；5 的 deps 为字符串；6 不在组织 7
+		`INSERT INTO users VALUES (1, 3, '{}'), (2, 3, '{"deps":[5]}'), (3, 3, '{"deps":[9,6]}'), (4, 3, '{"deps":[8]}'), (5, 3, '{"deps":["5"]}'), (6, 3, '{"deps":[5]}')`,
+		"INSERT INTO org_members VALUES (7, 1, 3), (7, 2, 3), (7, 3, 3), (7, 4, 3), (7, 5, 3), (8, 6, 3)",
+		"INSERT INTO orders VALUES (101, 1, 7), (102, 2, 7), (103, 3, 7), (104, 4, 7), (105, 5, 7), (106, 6, 7), (107, 2, 8)",
+	} {
+		if _, err := db.Exec(ctx, s); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+	}
+
+	defer func(n int) { DataScopeInlineUIDLimit = n }(DataScopeInlineUIDLimit)
+	DataScopeInlineUIDLimit = 1
+	scope := DataScope{Level: DataScopeLevelDeptTree, UID: 1, TenantID: 3, OrgID: 7, DepIDs: []uint64{5, 6}, UIDs: []uint64{1, 2, 3, 5}}
+	cols := DataScopeColumns{OwnerUID: "o.owner_uid", OrgID: "o.org_id"}
+
+	sql, args, err := sq.Select("o.id").From("orders o").Where(DataScopeSqlizer(database.DriverSQLite3, scope, cols)).OrderBy("o.id").ToSql()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query(ctx, sql, args...)
+	if err != nil {
+		t.Fatalf("%s: %v", sql, err)
+	}
+	defer rows.Close()
+	got := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, id)
+	}
+	if want := []int64{101, 102, 103, 105}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ids = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+func TestDepartmentDescendants(t *testing.T) {
+	// 1 ─┬─ 2 ── 4
+	//    └─ 3
+	// 5 ── 6；7 与 8 互为上级（脏数据）
+	deps := []protos.Department{
+		{Id: 1}, {Id: 2, ParentID: 1}, {Id: 3, ParentID: 1}, {Id: 4, ParentID: 2},
+		{Id: 5}, {Id: 6, ParentID: 5},
+		{Id: 7, ParentID: 8}, {Id: 8, ParentID: 7},
+	}
+	cases := []struct {
+		name  string
+		roots []uint64
+		want  []uint64
+	}{
+		{"tree", []uint64{1}, []uint64{1, 2, 3, 4}},
+		{"subtree", []uint64{2}, []uint64{2, 4}},
+		{"leaf", []uint64{3}, []uint64{3}},
+		{"overlap", []uint64{2, 1, 5}, []uint64{2, 1, 5, 4, 3, 6}},
+		{"cycle", []uint64{7}, []uint64{7, 8}},
+		{"empty", nil, []uint64{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := departmentDescendants(deps, tc.roots); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("departmentDescendants(%v) = %v, want %v", tc.roots, got, tc.want)
+			}
+		})
+	}
+}
@@ -13,8 +13,13 @@ func DepartmentCreate(m *protos.Department) (lastInsertId int64, e error) {
 	if m == nil || m.TenantID == 0 || m.OrgID == 0 {
 		return 0, common.ErrParam
 	}
-	lastInsertId, err := dao.DepartmentCreate(m)
+	release, err := reserveQuota(m.TenantID, protos.QuotaDepartments)
 	if err != nil {
+		return 0, err
+	}
+	lastInsertId, err = dao.DepartmentCreate(m)
+	if err != nil {
+		release()
 		common.Logger.Sugar().Errorf("DepartmentCreate ERR: %v %v\n", lastInsertId, err)
 		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" { // 唯一约束冲突
 			return -1, common.ErrPgDupKey
@@ -38,6 +43,7 @@ func DepartmentDelete(id, tenantID uint64) (e error) {
 		return
 	}
 	common.Logger.Sugar().Infof("DepartmentDelete: %v", rr)
+	releaseQuota(tenantID, protos.QuotaDepartments, rr)
 
 	return
 }
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"github.com/liuhengloveyou/passport/v4/accessctl"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// GrantableRoles 操作人在组织内可以授予的角色：所持各角色的 grantable 并集；root 永远不在其中。
+func GrantableRoles(operatorUID, tenantID, orgID uint64) map[string]bool {
+	rst := make(map[string]bool)
+	held := accessctl.GetRoleForUserInDomain(operatorUID, tenantID, orgID)
+	if len(held) == 0 {
+		return rst
+	}
+
+	for _, conf := range TenantGetRole(tenantID) {
+		if !containsString(held, conf.RoleValue) {
+			continue
+		}
+		for _, role := range conf.Grantable {
+			if role != "root" {
+				rst[role] = true
+			}
+		}
+	}
+
+	return rst
+}
+
+// CheckRolesGrantable 校验操作人能否授予这些角色：root 不受限，其他人只能授予 grantable 集合中的角色。
+func CheckRolesGrantable(operatorUID, tenantID, orgID uint64, roles []string) error {
+	if len(roles) == 0 {
+		return nil
+	}
+	if orgID > 0 && isOrgRoot(operatorUID, tenantID, orgID) {
+		return nil
+	}
+
+	grantable := GrantableRoles(operatorUID, tenantID, orgID)
+	for _, role := range roles {
+		if !grantable[role] {
+			common.Logger.Sugar().Warnf("CheckRolesGrantable denied: %v %v %v %v\n", operatorUID, tenantID, orgID, role)
+			return common.ErrNoAuth
+		}
+	}
+
+	return nil
+}
+
+// CheckPolicyGrantable 非 root 只能下发自己已经能通过鉴权的 (obj, act)。
+func CheckPolicyGrantable(operatorUID, tenantID, orgID uint64, obj, act string) error {
+	if isOrgRoot(operatorUID, tenantID, orgID) {
+		return nil
+	}
+
+	ok, err := accessctl.Enforce(operatorUID, tenantID, orgID, obj, act)
+	if err != nil {
+		common.Logger.Sugar().Errorf("CheckPolicyGrantable Enforce ERR: %v %v %v %v %v", operatorUID, tenantID, orgID, obj, err)
+		return common.ErrService
+	}
+	if !ok {
+		common.Logger.Sugar().Warnf("CheckPolicyGrantable denied: %v %v %v %v %v\n", operatorUID, tenantID, orgID, obj, act)
+		return common.ErrNoAuth
+	}
+
+	return nil
+}
+
+// CheckPolicyRemovable 移除组织域中 sub 的 (obj, act) 策略：allow 规则与下发一样须自己能通过鉴权；
+// deny 规则只有组织 root 能移除，且不能移除针对自己或自己持有角色的 deny，避免给自己提权。
+func CheckPolicyRemovable(operatorUID, tenantID, orgID uint64, sub, obj, act string) error {
+	effects, err := accessctl.GetPolicyEffects(tenantID, orgID, sub, obj, act)
+	if err != nil {
+		common.Logger.Sugar().Errorf("CheckPolicyRemovable ERR: %v %v %v %v %v %v", operatorUID, tenantID, orgID, sub, obj, err)
+		return common.ErrService
+	}
+	if !containsString(effects, protos.PolicyEffectDeny) {
+		return CheckPolicyGrantable(operatorUID, tenantID, orgID, obj, act)
+	}
+
+	if !isOrgRoot(operatorUID, tenantID, orgID) {
+		common.Logger.Sugar().Warnf("CheckPolicyRemovable denied: %v %v %v %v %v %v\n", operatorUID, tenantID, orgID, sub, obj, act)
+		return common.ErrNoAuth
+	}
+	if sub == accessctl.UserSubject(operatorUID) || containsString(accessctl.GetRoleForUserInDomain(operatorUID, tenantID, orgID), sub) {
+		common.Logger.Sugar().Warnf("CheckPolicyRemovable self deny: %v %v %v %v %v %v\n", operatorUID, tenantID, orgID, sub, obj, act)
+		return common.ErrNoAuth
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for i := 0; i < len(list); i++ {
+		if list[i] == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/liuhengloveyou/passport/v4/accessctl"
+	"github.com/liuhengloveyou/passport/v4/cache"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// initGrantTest 在 initServiceTest 之上准备授权测试的数据：租户 20001 的角色字典，
+// 用户 1、2、3 在组织 30001 分别是 root、manager、clerk，用户 9 只是租户成员。
+func initGrantTest(t *testing.T) (tenantID, orgID uint64) {
+	t.Helper()
+	db := initServiceTest(t)
+
+	tenantID, orgID = 20001, 30001
+	// GetRoleForUserInDomain 会校验用户所属租户
+	if _, err := db.Exec(context.Background(), "INSERT INTO users (uid, tenant_id, password) VALUES (1, ?, ''), (2, ?, ''), (3, ?, ''), (9, ?, '')",
+		tenantID, tenantID, tenantID, tenantID); err != nil {
+		t.Fatal(err)
+	}
+	cache.SetTenantCache(&protos.Tenant{ID: tenantID, Configuration: &protos.TenantConfiguration{Roles: []protos.RoleStruct{
+		{RoleValue: "root"},
+		{RoleValue: "manager", Grantable: []string{"clerk", "root"}},
+		{RoleValue: "clerk"},
+		{RoleValue: "auditor"},
+	}}})
+
+	for uid, role := range map[uint64]string{1: "root", 2: "manager", 3: "clerk"} {
+		if err := accessctl.AddRoleForUserInDomain(uid, tenantID, orgID, role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := accessctl.AddPolicyToRole(tenantID, orgID, "manager", "/orders", "GET"); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestCheckRolesGrantable(t *testing.T) {
+	tenantID, orgID := initGrantTest(t)
+	defer cache.DelTenantCache(tenantID)
+
+	cases := []struct {
+		name     string
+		operator uint64
+		roles    []string
+		want     error
+	}{
+		{"root_any", 1, []string{"root", "auditor"}, nil},
+		{"manager_grantable", 2, []string{"clerk"}, nil},
+		{"manager_escalate_root", 2, []string{"root"}, common.ErrNoAuth},
+		{"manager_not_in_set", 2, []string{"clerk", "auditor"}, common.ErrNoAuth},
+		{"manager_self_role", 2, []string{"manager"}, common.ErrNoAuth},
+		{"clerk_nothing", 3, []string{"clerk"}, common.ErrNoAuth},
+		{"outsider", 9, []string{"clerk"}, common.ErrNoAuth},
+		{"empty", 3, nil, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := CheckRolesGrantable(tc.operator, tenantID, orgID, tc.roles); got != tc.want {
+				t.Fatalf("CheckRolesGrantable(%d, %v) = %v, want %v", tc.operator, tc.roles, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckPolicyGrantable(t *testing.T) {
+	tenantID, orgID := initGrantTest(t)
+	defer cache.DelTenantCache(tenantID)
+
+	cases := []struct {
+		name     string
+		operator uint64
+		obj, act string
+		want     error
+	}{
+		{"root_any", 1, "/admin", "POST", nil},
+		{"manager_held", 2, "/orders", "GET", nil},
+		{"manager_escalate_act", 2, "/orders", "DELETE", common.ErrNoAuth},
+		{"manager_escalate_obj", 2, "/admin", "GET", common.ErrNoAuth},
+		{"clerk_nothing", 3, "/orders", "GET", common.ErrNoAuth},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := CheckPolicyGrantable(tc.operator, tenantID, orgID, tc.obj, tc.act); got != tc.want {
+				t.Fatalf("CheckPolicyGrantable(%d, %s %s) = %v, want %v", tc.operator, tc.obj, tc.act, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckPolicyRemovable(t *testing.T) {
+	tenantID, orgID := initGrantTest(t)
+	defer cache.DelTenantCache(tenantID)
+
+	for _, p := range [][]string{
+		{"clerk", "/orders", "GET", protos.PolicyEffectAllow},
+		{"clerk", "/orders", "POST", protos.PolicyEffectAllow},
+		{accessctl.UserSubject(2), "/reports", "GET", protos.PolicyEffectDeny},
+		{"manager", "/reports", "POST", protos.PolicyEffectDeny},
+		{"clerk", "/admin", "GET", protos.PolicyEffectDeny},
+		{"root", "/admin", "DELETE", protos.PolicyEffectDeny},
+	} {
+		if err := accessctl.AddPolicyWithEffect(tenantID, orgID, p[0], p[1], p[2], p[3]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name     string
+		operator uint64
+		sub      string
+		obj, act string
+		want     error
+	}{
+		{"manager_allow_held", 2, "clerk", "/orders", "GET", nil},
+		{"manager_allow_escalate", 2, "clerk", "/orders", "POST", common.ErrNoAuth},
+		{"manager_lift_own_deny", 2, accessctl.UserSubject(2), "/reports", "GET", common.ErrNoAuth},
+		{"manager_lift_role_deny", 2, "manager", "/reports", "POST", common.ErrNoAuth},
+		{"manager_lift_other_deny", 2, "clerk", "/admin", "GET", common.ErrNoAuth},
+		{"root_lift_deny", 1, accessctl.UserSubject(2), "/reports", "GET", nil},
+		{"root_lift_own_role_deny", 1, "root", "/admin", "DELETE", common.ErrNoAuth},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := CheckPolicyRemovable(tc.operator, tenantID, orgID, tc.sub, tc.obj, tc.act); got != tc.want {
+				t.Fatalf("CheckPolicyRemovable(%d, %s %s %s) = %v, want %v", tc.operator, tc.sub, tc.obj, tc.act, got, tc.want)
+			}
+		})
+	}
+}
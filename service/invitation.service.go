@@ -0,0 +1,295 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/mail"
+	"github.com/liuhengloveyou/passport/v4/protos"
+	"github.com/liuhengloveyou/passport/v4/sms"
+)
+
+const defaultInviteExpireHours = 72
+
+// deliverInvite 按渠道发送邀请链接；测试时替换
+var deliverInvite = func(m *protos.Invitation, tenantName, token string) error {
+	link := common.ServConfig.InviteURL + token
+	if m.Channel == protos.InviteChannelSms {
+		return sms.SendInviteSms(m.Target, tenantName, link)
+	}
+
+	subject := fmt.Sprintf("邀请你加入%s", tenantName)
+	body := fmt.Sprintf("%s 邀请你加入。请在 %s 前打开下面的链接接受邀请：\r\n\r\n%s\r\n", tenantName, m.ExpireTime.Format("2006-01-02 15:04"), link)
+	return mail.Send(m.Target, subject, body)
+}
+
+func inviteExpireTime(now time.Time) time.Time {
+	hours := common.ServConfig.InviteExpireHours
+	if hours <= 0 {
+		hours = defaultInviteExpireHours
+	}
+	// 签名只取到秒，避免数据库时间精度不同导致验签失败
+	return now.Add(time.Duration(hours) * time.Hour).Truncate(time.Second)
+}
+
+func inviteNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func inviteSign(m *protos.Invitation) string {
+	mac := hmac.New(sha256.New, []byte(common.SYS_PWD))
+	fmt.Fprintf(mac, "%d|%d|%d|%s|%d|%s", m.ID, m.TenantID, m.OrgID, m.Target, m.ExpireTime.Unix(), m.Nonce)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// inviteToken 邀请链接里的 token：id.nonce.签名
+func inviteToken(m *protos.Invitation) string {
+	return fmt.Sprintf("%d.%s.%s", m.ID, m.Nonce, inviteSign(m))
+}
+
+// parseInviteToken 校验签名并取出邀请；重发后旧 token 的 nonce 对不上，同样无效
+func parseInviteToken(token string) (*protos.Invitation, error) {
+	parts := strings.SplitN(strings.TrimSpace(token), ".", 3)
+	if len(parts) != 3 {
+		return nil, common.ErrInviteInvalid
+	}
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil || id == 0 {
+		return nil, common.ErrInviteInvalid
+	}
+
+	m, err := dao.InvitationGet(id)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	if m == nil || m.ExpireTime == nil || m.Nonce != parts[1] || !hmac.Equal([]byte(inviteSign(m)), []byte(parts[2])) {
+		return nil, common.ErrInviteInvalid
+	}
+	return m, nil
+}
+
+// inviteTarget 规整手机号或邮箱并判断发送渠道
+func inviteTarget(target string) (string, string, error) {
+	target = strings.TrimSpace(strings.ToLower(target))
+	if strings.Contains(target, "@") {
+		if err := common.Validate.Var(target, "email,max=64"); err != nil {
+			return "", "", common.ErrParam
+		}
+		return target, protos.InviteChannelEmail, nil
+	}
+	if err := common.Validate.Var(target, "phone,len=11"); err != nil {
+		return "", "", common.ErrParam
+	}
+	return target, protos.InviteChannelSms, nil
+}
+
+// InviteCreate 邀请手机号或邮箱加入当前租户的组织。发送失败时邀请仍然保留，可以重发。
+func InviteCreate(inviter *protos.User, req *protos.InviteReq) (*protos.Invitation, error) {
+	target, channel, err := inviteTarget(req.Target)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = RequireOrg(inviter.TenantID, req.OrgID); err != nil {
+		return nil, err
+	}
+	tenant, err := getTenantByIDCached(inviter.TenantID)
+	if err != nil {
+		common.Logger.Sugar().Errorf("InviteCreate tenant ERR: %v\n", err)
+		return nil, common.ErrService
+	}
+	if tenant == nil {
+		return nil, common.ErrTenantNotFound
+	}
+	nonce, err := inviteNonce()
+	if err != nil {
+		return nil, common.ErrService
+	}
+
+	expireTime := inviteExpireTime(time.Now())
+	m := &protos.Invitation{
+		TenantID:   inviter.TenantID,
+		OrgID:      req.OrgID,
+		Target:     target,
+		Channel:    channel,
+		Roles:      req.Roles,
+		DepIds:     req.DepIds,
+		InviterUID: inviter.UID,
+		Nonce:      nonce,
+		Status:     protos.InviteStatusPending,
+		ExpireTime: &expireTime,
+	}
+	if m.ID, err = dao.InvitationInsert(m); err != nil {
+		return nil, common.ErrService
+	}
+	common.Logger.Sugar().Infof("InviteCreate: user %d invited %s to tenant %d org %d roles %v", inviter.UID, target, inviter.TenantID, req.OrgID, req.Roles)
+
+	if err = deliverInvite(m, tenant.TenantName, inviteToken(m)); err != nil {
+		common.Logger.Sugar().Errorf("InviteCreate deliver ERR: %v %v\n", m.ID, err)
+		return m, common.ErrInviteSend
+	}
+	return m, nil
+}
+
+// InviteList 分页查询当前租户的邀请；status 可选 pending/accepted/revoked/expired。
+func InviteList(tenantID uint64, status string, page, pageSize uint64) ([]protos.Invitation, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	now := time.Now()
+	rr, err := dao.InvitationList(tenantID, status, now, page, pageSize)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	for i := range rr {
+		rr[i].Status = rr[i].EffectiveStatus(now)
+	}
+	return rr, nil
+}
+
+// requireInvite 取当前租户的邀请
+func requireInvite(tenantID, id uint64) (*protos.Invitation, error) {
+	m, err := dao.InvitationGet(id)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	if m == nil || m.TenantID != tenantID {
+		return nil, common.ErrInviteNotFound
+	}
+	return m, nil
+}
+
+// InviteResend 重新发送待接受（含已过期）的邀请：更换 nonce、顺延有效期，之前发出的链接失效。
+func InviteResend(operator *protos.User, id uint64) error {
+	m, err := requireInvite(operator.TenantID, id)
+	if err != nil {
+		return err
+	}
+	if m.Status != protos.InviteStatusPending {
+		return common.ErrInviteInvalid
+	}
+	tenant, err := getTenantByIDCached(m.TenantID)
+	if err != nil || tenant == nil {
+		return common.ErrService
+	}
+
+	if m.Nonce, err = inviteNonce(); err != nil {
+		return common.ErrService
+	}
+	expireTime := inviteExpireTime(time.Now())
+	m.ExpireTime = &expireTime
+	rows, err := dao.InvitationRenew(m.ID, m.Nonce, expireTime)
+	if err != nil {
+		return common.ErrService
+	}
+	if rows == 0 {
+		return common.ErrInviteInvalid
+	}
+
+	common.Logger.Sugar().Infof("InviteResend: user %d resent invite %d to %s", operator.UID, m.ID, m.Target)
+	if err = deliverInvite(m, tenant.TenantName, inviteToken(m)); err != nil {
+		common.Logger.Sugar().Errorf("InviteResend deliver ERR: %v %v\n", m.ID, err)
+		return common.ErrInviteSend
+	}
+	return nil
+}
+
+// InviteRevoke 撤销待接受的邀请。
+func InviteRevoke(operator *protos.User, id uint64) error {
+	if _, err := requireInvite(operator.TenantID, id); err != nil {
+		return err
+	}
+	rows, err := dao.InvitationSetStatus(id, protos.InviteStatusPending, protos.InviteStatusRevoked, 0)
+	if err != nil {
+		return common.ErrService
+	}
+	if rows == 0 {
+		return common.ErrInviteInvalid
+	}
+
+	common.Logger.Sugar().Infof("InviteRevoke: user %d revoked invite %d", operator.UID, id)
+	return nil
+}
+
+// InviteAccept 接受邀请：手机号或邮箱已有账号时直接绑定，否则用 password 创建账号，
+// 再通过 TenantUserAdd 加入组织并授予邀请中的角色与部门。返回账号 uid。
+// 本次新建的账号在加入租户失败时连同已写入的成员关系一并删除，邀请恢复为待接受，可以直接重试。
+func InviteAccept(req *protos.InviteAcceptReq) (uid uint64, err error) {
+	m, err := parseInviteToken(req.Token)
+	if err != nil {
+		return 0, err
+	}
+	if m.EffectiveStatus(time.Now()) != protos.InviteStatusPending {
+		return 0, common.ErrInviteInvalid
+	}
+	if err = CheckTenantStatus(m.TenantID); err != nil {
+		return 0, err
+	}
+
+	q := &protos.UserReq{}
+	if m.Channel == protos.InviteChannelSms {
+		q.Cellphone = m.Target
+	} else {
+		q.Email = m.Target
+	}
+	user, err := dao.UserQueryOne(q)
+	if err != nil {
+		common.Logger.Sugar().Errorf("InviteAccept query user ERR: %v\n", err)
+		return 0, common.ErrService
+	}
+
+	if user == nil {
+		q.Password, q.Nickname = req.Password, req.Nickname
+		if uid, err = AddUserService(q); err != nil {
+			return 0, err
+		}
+		created := uid
+		defer func() {
+			if err == nil {
+				return
+			}
+			if _, e := TenantUserDel(created, m.TenantID); e != nil {
+				common.Logger.Sugar().Errorf("InviteAccept undo TenantUserDel ERR: %v %v %v\n", created, m.TenantID, e)
+			}
+			if _, e := dao.UserDelete(created, 0); e != nil {
+				common.Logger.Sugar().Errorf("InviteAccept undo UserDelete ERR: %v %v\n", created, e)
+			}
+		}()
+	} else {
+		uid = user.UID
+	}
+
+	// 先占下邀请，防止同一链接并发接受
+	rows, err := dao.InvitationSetStatus(m.ID, protos.InviteStatusPending, protos.InviteStatusAccepted, uid)
+	if err != nil {
+		return 0, common.ErrService
+	}
+	if rows == 0 {
+		return 0, common.ErrInviteInvalid
+	}
+	if err = TenantUserAdd(uid, m.TenantID, m.OrgID, m.DepIds, m.Roles, 0); err != nil {
+		if _, e := dao.InvitationSetStatus(m.ID, protos.InviteStatusAccepted, protos.InviteStatusPending, 0); e != nil {
+			common.Logger.Sugar().Errorf("InviteAccept rollback ERR: %v %v\n", m.ID, e)
+		}
+		return 0, err
+	}
+
+	common.Logger.Sugar().Infof("InviteAccept: uid %d accepted invite %d to tenant %d org %d", uid, m.ID, m.TenantID, m.OrgID)
+	return uid, nil
+}
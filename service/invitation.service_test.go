@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/liuhengloveyou/passport/v4/cache"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+func TestInvitation(t *testing.T) {
+	initServiceTest(t)
+	const tid = 20006
+	defer cache.DelTenantCache(tid)
+
+	var token string
+	saved := deliverInvite
+	deliverInvite = func(m *protos.Invitation, tenantName, tk string) error {
+		token = tk
+		return nil
+	}
+	defer func() { deliverInvite = saved }()
+
+	ctx := context.Background()
+	if _, err := common.DB.Exec(ctx, `INSERT INTO tenants (id, tenant_name, configuration) VALUES (?, 'invite', '{"roles":[{"value":"root"},{"value":"clerk"}]}')`, tid); err != nil {
+		t.Fatal(err)
+	}
+	orgID, err := OrgCreate(tid, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.DelOrgCache(orgID)
+	inviter := &protos.User{UID: 1, TenantID: tid}
+
+	if _, err := InviteCreate(inviter, &protos.InviteReq{Target: "not-a-phone", OrgID: orgID}); err != common.ErrParam {
+		t.Fatalf("bad target = %v", err)
+	}
+	m, err := InviteCreate(inviter, &protos.InviteReq{Target: " New@Example.com ", OrgID: orgID, Roles: []string{"clerk"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Target != "new@example.com" || m.Channel != protos.InviteChannelEmail || token == "" {
+		t.Fatalf("invite = %+v token = %q", m, token)
+	}
+
+	// 重发后旧链接失效
+	old := token
+	if err := InviteResend(inviter, m.ID); err != nil {
+		t.Fatal(err)
+	}
+	if old == token {
+		t.Fatal("resend kept the token")
+	}
+	if _, err := InviteAccept(&protos.InviteAcceptReq{Token: old, Password: "123456"}); err != common.ErrInviteInvalid {
+		t.Fatalf("old token = %v", err)
+	}
+	if err := InviteResend(&protos.User{UID: 1, TenantID: 20001}, m.ID); err != common.ErrInviteNotFound {
+		t.Fatalf("resend from other tenant = %v", err)
+	}
+
+	uid, err := InviteAccept(&protos.InviteAcceptReq{Token: token, Password: "123456"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UserInOrg(uid, tid, orgID); err != nil {
+		t.Fatalf("UserInOrg = %v", err)
+	}
+	if _, err := InviteAccept(&protos.InviteAcceptReq{Token: token, Password: "123456"}); err != common.ErrInviteInvalid {
+		t.Fatalf("accept twice = %v", err)
+	}
+	rr, err := InviteList(tid, protos.InviteStatusAccepted, 1, 10)
+	if err != nil || len(rr) != 1 || rr[0].AcceptUID != uid {
+		t.Fatalf("accepted list = %+v %v", rr, err)
+	}
+
+	t.Run("revoke", func(t *testing.T) {
+		m, err := InviteCreate(inviter, &protos.InviteReq{Target: "revoke@example.com", OrgID: orgID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := InviteRevoke(inviter, m.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := InviteRevoke(inviter, m.ID); err != common.ErrInviteInvalid {
+			t.Fatalf("revoke twice = %v", err)
+		}
+		if _, err := InviteAccept(&protos.InviteAcceptReq{Token: token, Password: "123456"}); err != common.ErrInviteInvalid {
+			t.Fatalf("accept revoked = %v", err)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		m, err := InviteCreate(inviter, &protos.InviteReq{Target: "late@example.com", OrgID: orgID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := common.DB.Exec(ctx, "UPDATE invitations SET expire_time = ? WHERE id = ?", time.Now().Add(-time.Hour), m.ID); err != nil {
+			t.Fatal(err)
+		}
+		if rr, err := InviteList(tid, protos.InviteStatusExpired, 1, 10); err != nil || len(rr) != 1 || rr[0].Status != protos.InviteStatusExpired {
+			t.Fatalf("expired list = %+v %v", rr, err)
+		}
+		// 过期时间参与签名，改库后的 token 同样无效；重发后可以接受
+		if _, err := InviteAccept(&protos.InviteAcceptReq{Token: token, Password: "123456"}); err != common.ErrInviteInvalid {
+			t.Fatalf("accept expired = %v", err)
+		}
+		if err := InviteResend(inviter, m.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := InviteAccept(&protos.InviteAcceptReq{Token: token, Password: "123456"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// 加入租户失败时删除本次新建的账号，邀请仍可接受
+	t.Run("rollback", func(t *testing.T) {
+		m, err := InviteCreate(inviter, &protos.InviteReq{Target: "quota@example.com", OrgID: orgID, Roles: []string{"clerk"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := common.DB.Exec(ctx, "INSERT INTO tenant_quotas (tenant_id, resource, quota_limit, used) VALUES (?, ?, 1, 1)", tid, protos.QuotaMembers); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := InviteAccept(&protos.InviteAcceptReq{Token: token, Password: "123456"}); err != common.ErrQuotaExceeded {
+			t.Fatalf("accept over quota = %v", err)
+		}
+		if user, err := dao.UserQueryOne(&protos.UserReq{Email: m.Target}); err != nil || user != nil {
+			t.Fatalf("account after failed accept = %+v %v", user, err)
+		}
+		if rr, err := InviteList(tid, protos.InviteStatusPending, 1, 10); err != nil || len(rr) != 1 || rr[0].ID != m.ID {
+			t.Fatalf("pending list = %+v %v", rr, err)
+		}
+
+		if _, err := common.DB.Exec(ctx, "DELETE FROM tenant_quotas WHERE tenant_id = ?", tid); err != nil {
+			t.Fatal(err)
+		}
+		uid, err := InviteAccept(&protos.InviteAcceptReq{Token: token, Password: "123456"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := UserInOrg(uid, tid, orgID); err != nil {
+			t.Fatalf("UserInOrg = %v", err)
+		}
+	})
+}
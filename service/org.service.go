@@ -10,7 +10,13 @@ import (
 	"github.com/liuhengloveyou/passport/v4/protos"
 )
 
+// OrgCreate 新建顶级组织。
 func OrgCreate(tenantID uint64, name string) (uint64, error) {
+	return OrgCreateChild(tenantID, 0, name)
+}
+
+// OrgCreateChild 在 parentID 之下新建组织，parentID 为 0 时建为顶级组织。
+func OrgCreateChild(tenantID, parentID uint64, name string) (uint64, error) {
 	name = strings.TrimSpace(name)
 	if tenantID == 0 || name == "" {
 		return 0, common.ErrParam
@@ -19,6 +25,11 @@ func OrgCreate(tenantID uint64, name string) (uint64, error) {
 	if err != nil {
 		return 0, err
 	}
+	if parentID > 0 {
+		if _, err = RequireOrg(tenantID, parentID); err != nil {
+			return 0, err
+		}
+	}
 
 	if got, err := dao.OrgGetByTenantName(tenantID, name); err != nil {
 		common.Logger.Sugar().Errorf("OrgCreate get ERR: %v", err)
@@ -33,11 +44,17 @@ func OrgCreate(tenantID uint64, name string) (uint64, error) {
 		return 0, common.ErrService
 	}
 
-	id, err := dao.OrgInsert(&protos.Organization{TenantID: tenantID, Name: name})
+	release, err := reserveQuota(tenantID, protos.QuotaOrgs)
+	if err != nil {
+		return 0, err
+	}
+	id, err := dao.OrgInsert(&protos.Organization{TenantID: tenantID, ParentID: parentID, Name: name})
 	if err != nil || id == 0 {
+		release()
 		common.Logger.Sugar().Errorf("OrgCreate insert ERR: %v %v", id, err)
 		return 0, common.MapPostgresOrgInsertError(err)
 	}
+	accessctl.OrgTreeChanged(tenantID)
 
 	if tenant.UID > 0 {
 		if err = dao.OrgMemberInsert(id, tenant.UID, tenantID); err != nil {
@@ -51,19 +68,47 @@ func OrgCreate(tenantID uint64, name string) (uint64, error) {
 		}
 	}
 
-	fromDomain := accessctl.LegacyTenantDomain(tenantID)
-	for i := range existing {
-		if existing[i].ID > 0 {
-			fromDomain = accessctl.Domain(tenantID, existing[i].ID)
-			break
+	// 下级组织沿用上级组织的策略，其他组织沿用已有组织的策略；老版本租户域中的遗留策略不复制，见 CollapseTenantDomain
+	fromDomain := ""
+	if parentID > 0 {
+		fromDomain = accessctl.Domain(tenantID, parentID)
+	} else {
+		for i := range existing {
+			if existing[i].ID > 0 {
+				fromDomain = accessctl.Domain(tenantID, existing[i].ID)
+				break
+			}
 		}
 	}
 	if err = accessctl.CopyPolicies(fromDomain, accessctl.Domain(tenantID, id)); err != nil {
 		common.Logger.Sugar().Warnf("OrgCreate copy policy ERR: %v", err)
 	}
+
+	// 租户级角色与上级组织的继承授权对新组织自动生效，这里只需补上组织成员关系
+	uids := accessctl.GetUsersInTenantDomain(tenantID)
+	if parentID > 0 {
+		uids = append(uids, accessctl.GetUsersWithInheritedRoles(tenantID, parentID)...)
+	}
+	if err = addOrgMembers(tenantID, []uint64{id}, uids); err != nil {
+		return 0, err
+	}
 	return id, nil
 }
 
+// addOrgMembers 把用户加入各组织，已是成员的忽略
+func addOrgMembers(tenantID uint64, orgIDs, uids []uint64) error {
+	for _, orgID := range orgIDs {
+		for _, uid := range uids {
+			if err := dao.OrgMemberInsert(orgID, uid, tenantID); err != nil {
+				common.Logger.Sugar().Errorf("addOrgMembers ERR: %v %v %v", orgID, uid, err)
+				return common.ErrService
+			}
+			cache.DelOrgMemberCache(orgID, uid)
+		}
+	}
+	return nil
+}
+
 func OrgGet(orgID uint64) (*protos.Organization, error) {
 	if orgID == 0 {
 		return nil, common.ErrOrgRequired
@@ -152,6 +197,33 @@ func OrgListByUser(uid, tenantID uint64) ([]protos.Organization, error) {
 	return rr, nil
 }
 
+// SwitchOrg 切换当前组织：须是该组织成员；同时记为用户在该租户的默认组织，下次登录沿用。
+func SwitchOrg(uid, tenantID, orgID uint64) error {
+	if orgID == 0 {
+		return common.ErrOrgRequired
+	}
+	if err := UserInOrg(uid, tenantID, orgID); err != nil {
+		return err
+	}
+	if err := dao.OrgMemberSetDefault(uid, tenantID, orgID); err != nil {
+		return common.ErrService
+	}
+	common.Logger.Sugar().Infof("SwitchOrg: uid %d tenant %d switched to org %d", uid, tenantID, orgID)
+	return nil
+}
+
+// UserDefaultOrg 用户在租户内的默认组织，登录或切换租户时作为当前组织；不属于任何组织时返回 0。
+func UserDefaultOrg(uid, tenantID uint64) uint64 {
+	if uid == 0 || tenantID == 0 {
+		return 0
+	}
+	orgID, err := dao.OrgMemberDefault(uid, tenantID)
+	if err != nil {
+		return 0
+	}
+	return orgID
+}
+
 func OrgAddMember(orgID, uid, tenantID uint64) error {
 	if orgID == 0 || uid == 0 || tenantID == 0 {
 		return common.ErrParam
@@ -184,28 +256,46 @@ func OrgRemoveMember(orgID, uid, tenantID uint64) error {
 	return nil
 }
 
+// OrgDelete 删除组织；还有下级组织时返回 ErrOrgHasChildren，需先移走或删除下级组织。
 func OrgDelete(tenantID, orgID uint64) error {
 	if _, err := RequireOrg(tenantID, orgID); err != nil {
 		return err
 	}
-	uids, err := dao.OrgMemberUIDs(orgID)
+	children, err := dao.OrgChildCount(orgID)
 	if err != nil {
 		return common.ErrService
 	}
-	if err = accessctl.RemoveDomain(accessctl.Domain(tenantID, orgID)); err != nil {
-		common.Logger.Sugar().Errorf("OrgDelete domain ERR: %v", err)
+	if children > 0 {
+		return common.ErrOrgHasChildren
+	}
+	uids, err := dao.OrgMemberUIDs(orgID)
+	if err != nil {
 		return common.ErrService
 	}
-	if err = dao.DepartmentDeleteByOrg(tenantID, orgID); err != nil {
+	for _, dom := range []string{accessctl.Domain(tenantID, orgID), accessctl.InheritDomain(tenantID, orgID)} {
+		if err = accessctl.RemoveDomain(dom); err != nil {
+			common.Logger.Sugar().Errorf("OrgDelete domain ERR: %v %v", dom, err)
+			return common.ErrService
+		}
+	}
+	deps, err := dao.DepartmentDeleteByOrg(tenantID, orgID)
+	if err != nil {
 		return common.ErrService
 	}
+	releaseQuota(tenantID, protos.QuotaDepartments, deps)
 	if err = dao.OrgMemberDeleteByOrg(orgID); err != nil {
 		return common.ErrService
 	}
 	if err = dao.OrgDelete(orgID, tenantID); err != nil {
 		return common.ErrService
 	}
+	if err = dao.OrgClosureDeleteByOrg(orgID); err != nil {
+		return common.ErrService
+	}
+	releaseQuota(tenantID, protos.QuotaOrgs, 1)
 	cache.DelOrgCache(orgID)
+	cache.DelOrgConfigCache(tenantID, orgID)
+	accessctl.OrgTreeChanged(tenantID)
 	for _, uid := range uids {
 		cache.DelOrgMemberCache(orgID, uid)
 	}
@@ -216,6 +306,11 @@ func UserHasRoleInTenant(uid, tenantID uint64, role string) bool {
 	if uid == 0 || tenantID == 0 || role == "" {
 		return false
 	}
+	for _, r := range accessctl.GetRoleForUserInTenant(uid, tenantID) {
+		if r == role {
+			return true
+		}
+	}
 	orgs, err := dao.OrgListByUser(uid, tenantID)
 	if err != nil {
 		return false
@@ -231,7 +326,15 @@ func UserHasRoleInTenant(uid, tenantID uint64, role string) bool {
 	return false
 }
 
+// AddRoleForUserInAllOrgs 在租户级域授予角色（对之后新建的组织同样生效），并把用户加入租户下所有组织。
 func AddRoleForUserInAllOrgs(uid, tenantID uint64, role string) error {
+	if uid == 0 || tenantID == 0 || role == "" {
+		return common.ErrParam
+	}
+	if err := UserInTenant(uid, tenantID); err != nil {
+		return err
+	}
+
 	orgs, err := dao.OrgListByTenant(tenantID)
 	if err != nil {
 		return common.ErrService
@@ -241,9 +344,88 @@ func AddRoleForUserInAllOrgs(uid, tenantID uint64, role string) error {
 			return common.ErrService
 		}
 		cache.DelOrgMemberCache(orgs[i].ID, uid)
-		if err = accessctl.AddRoleForUserInDomain(uid, tenantID, orgs[i].ID, role); err != nil {
-			return common.ErrService
+	}
+	if err = accessctl.AddRoleForUserInTenant(uid, tenantID, role); err != nil {
+		common.Logger.Sugar().Errorf("AddRoleForUserInAllOrgs ERR: %v %v %v %v", uid, tenantID, role, err)
+		if err == common.ErrRoleExclusive {
+			return err
 		}
+		return common.ErrService
 	}
 	return nil
 }
+
+// RemoveRoleForUserInAllOrgs 撤销租户级角色；组织成员关系保持不变。
+func RemoveRoleForUserInAllOrgs(uid, tenantID uint64, role string) error {
+	if uid == 0 || tenantID == 0 || role == "" {
+		return common.ErrParam
+	}
+	if err := accessctl.DeleteRoleForUserInTenant(uid, tenantID, role); err != nil {
+		common.Logger.Sugar().Errorf("RemoveRoleForUserInAllOrgs ERR: %v %v %v %v", uid, tenantID, role, err)
+		return common.ErrService
+	}
+	return nil
+}
+
+// IsTenantRoot 租户所有者或持有租户级 root 角色；只有他们可以维护租户级角色与策略。
+func IsTenantRoot(uid, tenantID uint64) bool {
+	if uid == 0 || tenantID == 0 {
+		return false
+	}
+	if tenant, err := TenantGetByIDService(tenantID); err == nil && tenant != nil && tenant.UID == uid {
+		return true
+	}
+	return containsString(accessctl.GetRoleForUserInTenant(uid, tenantID), "root")
+}
+
+// CollapseTenantDomain 迁移：把在租户所有组织中重复的角色绑定与策略合并为租户级记录。
+// 限时授权（role_grants）按组织到期回收，不参与合并。
+// 老版本租户域中的遗留数据不参与鉴权，只在 migrateLegacy 为 true 时移到租户级域，否则只报告条数。
+func CollapseTenantDomain(tenantID uint64, migrateLegacy bool) (*protos.TenantDomainCollapse, error) {
+	if tenantID == 0 {
+		return nil, common.ErrParam
+	}
+	orgs, err := dao.OrgListByTenant(tenantID)
+	if err != nil {
+		common.Logger.Sugar().Errorf("CollapseTenantDomain OrgListByTenant ERR: %v %v", tenantID, err)
+		return nil, common.ErrService
+	}
+	grants, err := dao.RoleGrantListByTenant(tenantID)
+	if err != nil {
+		common.Logger.Sugar().Errorf("CollapseTenantDomain RoleGrantListByTenant ERR: %v %v", tenantID, err)
+		return nil, common.ErrService
+	}
+	timed := make(map[string]bool, len(grants))
+	for i := range grants {
+		timed[accessctl.UserSubject(grants[i].UID)+"\x00"+grants[i].Role] = true
+	}
+
+	rst := &protos.TenantDomainCollapse{}
+	if migrateLegacy {
+		if rst.MigratedGroupings, rst.MigratedPolicies, err = accessctl.MigrateLegacyTenantDomain(tenantID); err != nil {
+			common.Logger.Sugar().Errorf("CollapseTenantDomain MigrateLegacyTenantDomain ERR: %v %v", tenantID, err)
+			return nil, common.ErrService
+		}
+	}
+
+	orgIDs := make([]uint64, 0, len(orgs))
+	for i := range orgs {
+		orgIDs = append(orgIDs, orgs[i].ID)
+	}
+	rst.Groupings, rst.Policies, err = accessctl.CollapseTenantDomain(tenantID, orgIDs, func(sub, role string) bool {
+		return timed[sub+"\x00"+role]
+	})
+	if err != nil {
+		common.Logger.Sugar().Errorf("CollapseTenantDomain ERR: %v %v", tenantID, err)
+		return nil, common.ErrService
+	}
+
+	gs, ps, err := accessctl.LegacyTenantDomainRules(tenantID)
+	if err != nil {
+		common.Logger.Sugar().Errorf("CollapseTenantDomain LegacyTenantDomainRules ERR: %v %v", tenantID, err)
+		return nil, common.ErrService
+	}
+	rst.LegacyGroupings, rst.LegacyPolicies = len(gs), len(ps)
+	common.Logger.Sugar().Infof("CollapseTenantDomain: %v %+v\n", tenantID, *rst)
+	return rst, nil
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/liuhengloveyou/passport/v4/cache"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// OrgLoadConfiguration 按 key 读取组织自身的配置，key 为空时返回全部。
+func OrgLoadConfiguration(tenantID, orgID uint64, key string) (interface{}, error) {
+	org, err := RequireOrg(tenantID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		return org.Configuration[key], nil
+	}
+	return org.Configuration, nil
+}
+
+// OrgEffectiveConfiguration 组织生效的配置：租户配置 more 叠加组织配置，同名键取组织的值；key 为空时返回全部。
+// 返回的是缓存的副本，调用方可以修改。
+func OrgEffectiveConfiguration(tenantID, orgID uint64, key string) (interface{}, error) {
+	conf, hit := cache.GetOrgConfigCache(tenantID, orgID)
+	if !hit {
+		org, err := RequireOrg(tenantID, orgID)
+		if err != nil {
+			return nil, err
+		}
+		tenant, err := getTenantByIDCached(tenantID)
+		if err != nil {
+			return nil, common.ErrService
+		}
+		if tenant == nil {
+			return nil, common.ErrTenantNotFound
+		}
+
+		conf = make(protos.MapStruct)
+		if tenant.Configuration != nil {
+			for k, v := range tenant.Configuration.More {
+				conf[k] = v
+			}
+		}
+		for k, v := range org.Configuration {
+			conf[k] = v
+		}
+		cache.SetOrgConfigCache(tenantID, orgID, conf)
+	}
+
+	conf, err := cloneMapStruct(conf)
+	if err != nil {
+		common.Logger.Sugar().Errorf("OrgEffectiveConfiguration clone ERR: %v %v\n", orgID, err)
+		return nil, common.ErrService
+	}
+	if key != "" {
+		return conf[key], nil
+	}
+	return conf, nil
+}
+
+func cloneMapStruct(m protos.MapStruct) (protos.MapStruct, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	c := make(protos.MapStruct)
+	if err = json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// OrgUpdateConfiguration 增量更新组织配置键值，值为 nil 时删除该键；uid 为操作人。
+// lastUpdateTime 与组织当前的更新时间不一致返回 ErrModify。
+func OrgUpdateConfiguration(tenantID, orgID, uid uint64, lastUpdateTime string, data map[string]interface{}) (err error) {
+	if len(data) <= 0 || len(data) > 100 {
+		common.Logger.Sugar().Error("OrgUpdateConfiguration param len ERR: ", len(data))
+		return common.ErrParam
+	}
+	for k := range data {
+		if len(k) > 64 {
+			common.Logger.Sugar().Error("OrgUpdateConfiguration param k len")
+			return common.ErrParam
+		}
+	}
+	expect, err := parseLastUpdateTime(lastUpdateTime)
+	if err != nil {
+		return err
+	}
+	if _, err = RequireOrg(tenantID, orgID); err != nil {
+		return err
+	}
+
+	defer cache.DelOrgCache(orgID)
+	defer cache.DelOrgConfigCache(tenantID, orgID)
+
+	ctx := context.Background()
+	tx, err := common.DB.Begin(ctx)
+	if err != nil {
+		common.Logger.Sugar().Errorf("OrgUpdateConfiguration Begin ERR: %v\n", err)
+		return common.ErrService
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	conf, updateTime, found, err := dao.OrgConfigurationForUpdate(tx, orgID)
+	if err != nil {
+		return common.ErrService
+	}
+	if !found {
+		return common.ErrOrgNotFound
+	}
+	if !sameUpdateTime(expect, updateTime) {
+		common.Logger.Sugar().Warnf("OrgUpdateConfiguration modified: org %d expect %v got %v", orgID, expect, updateTime)
+		return common.ErrModify
+	}
+
+	if conf == nil {
+		conf = make(protos.MapStruct, len(data))
+	}
+	for k, v := range data {
+		if v != nil {
+			conf[k] = v
+		} else {
+			delete(conf, k)
+		}
+	}
+	if len(conf) > 100 {
+		common.Logger.Sugar().Errorf("org.Configuration too len: %d\n", len(conf))
+		return common.ErrParam
+	}
+
+	if err = dao.OrgConfigurationSet(tx, orgID, conf, time.Now()); err != nil {
+		return common.ErrService
+	}
+	if err = tx.Commit(ctx); err != nil {
+		common.Logger.Sugar().Errorf("OrgUpdateConfiguration Commit ERR: %v\n", err)
+		return common.ErrService
+	}
+
+	common.Logger.Sugar().Infof("OrgUpdateConfiguration: user %d updated org %d configuration", uid, orgID)
+	return nil
+}
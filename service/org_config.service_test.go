@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/liuhengloveyou/passport/v4/cache"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+func TestOrgConfiguration(t *testing.T) {
+	initServiceTest(t)
+	const tid = 20014
+	defer cache.DelTenantCache(tid)
+
+	if _, err := common.DB.Exec(context.Background(), `INSERT INTO tenants (id, tenant_name, configuration) VALUES (?, 'stores', '{"roles":[{"value":"root"}],"more":{"hours":"9-18","header":"ACME"}}')`, tid); err != nil {
+		t.Fatal(err)
+	}
+	orgID, err := OrgCreate(tid, "store-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.DelOrgCache(orgID)
+	defer cache.DelOrgConfigCache(tid, orgID)
+	lastUpdate := func() string {
+		org, err := OrgGet(orgID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return org.UpdateTime.Format(time.RFC3339Nano)
+	}
+	effective := func(key string) interface{} {
+		v, err := OrgEffectiveConfiguration(tid, orgID, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	if got := effective("hours"); got != "9-18" {
+		t.Fatalf("inherited hours = %v", got)
+	}
+	stale := lastUpdate()
+	if err = OrgUpdateConfiguration(tid, orgID, 1, stale, map[string]interface{}{"hours": "10-22"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = OrgUpdateConfiguration(tid, orgID, 1, stale, map[string]interface{}{"hours": "8-20"}); err != common.ErrModify {
+		t.Fatalf("stale update = %v, want ErrModify", err)
+	}
+	if got, _ := OrgLoadConfiguration(tid, orgID, "hours"); got != "10-22" {
+		t.Fatalf("org hours = %v", got)
+	}
+	if got := effective("hours"); got != "10-22" {
+		t.Fatalf("effective hours = %v", got)
+	}
+
+	// 租户配置变化后生效配置随之更新，组织覆盖的键不受影响
+	if err = TenantUpdateConfiguration(tid, 1, "", map[string]interface{}{"header": "ACME Ltd", "hours": "0-24"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := effective("header"); got != "ACME Ltd" {
+		t.Fatalf("effective header = %v", got)
+	}
+	if got := effective("hours"); got != "10-22" {
+		t.Fatalf("effective hours after tenant change = %v", got)
+	}
+
+	// 删除组织的键后回到租户的值
+	if err = OrgUpdateConfiguration(tid, orgID, 1, lastUpdate(), map[string]interface{}{"hours": nil}); err != nil {
+		t.Fatal(err)
+	}
+	if got := effective("hours"); got != "0-24" {
+		t.Fatalf("effective hours after delete = %v", got)
+	}
+
+	// 修改返回值不影响缓存
+	effective("").(protos.MapStruct)["hours"] = "tampered"
+	if got := effective("hours"); got != "0-24" {
+		t.Fatalf("cached hours after caller mutation = %v", got)
+	}
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/liuhengloveyou/passport/v4/accessctl"
+	"github.com/liuhengloveyou/passport/v4/cache"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// orgTransferPlan 一个成员调动前算好的变更
+type orgTransferPlan struct {
+	rec       protos.OrgMemberTransfer
+	inherited []string // 原组织上的继承授权，调出时收回
+	ext       protos.MapStruct
+	extDirty  bool
+}
+
+// OrgMemberTransfer 把 req.UIDs 从 FromOrgID 调到 ToOrgID，operatorUID 为操作人；全部成功或全部不生效，返回调动记录。
+// 角色按 RoleMap 映射后授予，须是操作人在目标组织可授予的角色；调出（Copy 为 false）时还须能收回原组织的角色。
+// casbin 与业务库不在同一事务中：先改角色，数据库事务失败时再把角色改回去。
+func OrgMemberTransfer(operatorUID, tenantID uint64, req *protos.OrgMemberTransferReq) (rr []protos.OrgMemberTransfer, err error) {
+	uids := make([]uint64, 0, len(req.UIDs))
+	for _, uid := range req.UIDs {
+		if uid > 0 && !containsUint64(uids, uid) {
+			uids = append(uids, uid)
+		}
+	}
+	if len(uids) == 0 || len(uids) > 200 || req.FromOrgID == req.ToOrgID {
+		return nil, common.ErrParam
+	}
+	if _, err = RequireOrg(tenantID, req.FromOrgID); err != nil {
+		return nil, err
+	}
+	if _, err = RequireOrg(tenantID, req.ToOrgID); err != nil {
+		return nil, err
+	}
+	move := !req.Copy
+
+	fromDeps, err := orgDepartmentSet(tenantID, req.FromOrgID)
+	if err != nil {
+		return nil, err
+	}
+	toDeps, err := orgDepartmentSet(tenantID, req.ToOrgID)
+	if err != nil {
+		return nil, err
+	}
+	depIDs := make([]uint64, 0, len(req.DepIds))
+	for _, id := range req.DepIds {
+		if id == 0 || containsUint64(depIDs, id) {
+			continue
+		}
+		if _, ok := toDeps[id]; !ok {
+			return nil, common.ErrParam
+		}
+		depIDs = append(depIDs, id)
+	}
+
+	plans := make([]orgTransferPlan, 0, len(uids))
+	for _, uid := range uids {
+		p, err := planOrgTransfer(operatorUID, tenantID, uid, req, fromDeps, toDeps, depIDs)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, *p)
+	}
+
+	// 先改角色，失败时倒序撤回已做的变更
+	var undo []func()
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}()
+	for i := range plans {
+		if undo, err = applyOrgTransferRoles(tenantID, &plans[i].rec, plans[i].inherited, move, undo); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx := context.Background()
+	tx, err := common.DB.Begin(ctx)
+	if err != nil {
+		common.Logger.Sugar().Errorf("OrgMemberTransfer Begin ERR: %v\n", err)
+		return nil, common.ErrService
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	now := time.Now()
+	for i := range plans {
+		p := &plans[i]
+		if err = dao.OrgMemberTransfer(tx, tenantID, p.rec.UID, req.FromOrgID, req.ToOrgID, move); err != nil {
+			return nil, common.ErrService
+		}
+		if p.extDirty {
+			if err = dao.UserUpdateExtTx(tx, p.rec.UID, p.ext); err != nil {
+				return nil, common.ErrService
+			}
+		}
+		p.rec.CreateTime = &now
+		if err = dao.OrgMemberTransferInsert(tx, &p.rec); err != nil {
+			return nil, common.ErrService
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		common.Logger.Sugar().Errorf("OrgMemberTransfer Commit ERR: %v\n", err)
+		return nil, common.ErrService
+	}
+
+	rr = make([]protos.OrgMemberTransfer, 0, len(plans))
+	for i := range plans {
+		cache.DelOrgMemberCache(req.FromOrgID, plans[i].rec.UID)
+		cache.DelOrgMemberCache(req.ToOrgID, plans[i].rec.UID)
+		rr = append(rr, plans[i].rec)
+		common.Logger.Sugar().Infof("OrgMemberTransfer: operator %d %s uid %d tenant %d org %d => %d roles %v => %v",
+			operatorUID, plans[i].rec.Mode, plans[i].rec.UID, tenantID, req.FromOrgID, req.ToOrgID, plans[i].rec.FromRoles, plans[i].rec.ToRoles)
+	}
+	return rr, nil
+}
+
+// planOrgTransfer 校验一个成员能否调动，并算出目标组织的角色与调动后的部门
+func planOrgTransfer(operatorUID, tenantID, uid uint64, req *protos.OrgMemberTransferReq, fromDeps, toDeps map[uint64]struct{}, depIDs []uint64) (*orgTransferPlan, error) {
+	if err := UserInOrg(uid, tenantID, req.FromOrgID); err != nil {
+		return nil, err
+	}
+	grants, err := dao.RoleGrantListByUser(tenantID, req.FromOrgID, uid)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	limited := make(map[string]bool, len(grants))
+	for _, g := range grants {
+		limited[g.Role] = true
+	}
+
+	fromRoles := accessctl.GetDirectRolesForUserInDomain(uid, tenantID, req.FromOrgID)
+	toRoles := make([]string, 0, len(fromRoles))
+	for _, role := range fromRoles {
+		if limited[role] {
+			continue
+		}
+		if mapped, ok := req.RoleMap[role]; ok {
+			role = mapped
+		}
+		if role != "" && !containsString(toRoles, role) {
+			toRoles = append(toRoles, role)
+		}
+	}
+	if err = CheckRolesGrantable(operatorUID, tenantID, req.ToOrgID, toRoles); err != nil {
+		return nil, err
+	}
+	if err = CheckExclusiveRoles(tenantID, accessctl.GetRoleForUserInDomain(uid, tenantID, req.ToOrgID), toRoles); err != nil {
+		return nil, err
+	}
+
+	p := &orgTransferPlan{
+		rec: protos.OrgMemberTransfer{
+			TenantID:    tenantID,
+			UID:         uid,
+			FromOrgID:   req.FromOrgID,
+			ToOrgID:     req.ToOrgID,
+			Mode:        protos.OrgTransferCopy,
+			FromRoles:   fromRoles,
+			ToRoles:     toRoles,
+			DepIds:      depIDs,
+			OperatorUID: operatorUID,
+			Remark:      req.Remark,
+		},
+	}
+	if !req.Copy {
+		p.rec.Mode = protos.OrgTransferMove
+		for role, orgID := range accessctl.GetInheritedRolesForUser(uid, tenantID, req.FromOrgID) {
+			if orgID == req.FromOrgID {
+				p.inherited = append(p.inherited, role)
+			}
+		}
+		if err = CheckRolesGrantable(operatorUID, tenantID, req.FromOrgID, append(append([]string{}, fromRoles...), p.inherited...)); err != nil {
+			return nil, err
+		}
+	}
+
+	userInfo, err := dao.UserQueryByID(uid)
+	if err != nil || userInfo == nil {
+		return nil, common.ErrNull
+	}
+	oldDeps := parseUint64Slice(userInfo.Ext[protos.DepartmentExtKey])
+	deps := make([]uint64, 0, len(oldDeps)+len(depIDs))
+	for _, id := range oldDeps {
+		if _, inFrom := fromDeps[id]; inFrom && !req.Copy {
+			continue
+		}
+		if _, inTo := toDeps[id]; inTo && len(depIDs) > 0 {
+			continue
+		}
+		deps = append(deps, id)
+	}
+	deps = append(deps, depIDs...)
+	if len(deps) != len(oldDeps) || len(depIDs) > 0 {
+		p.ext, p.extDirty = userInfo.Ext, true
+		if p.ext == nil {
+			p.ext = protos.MapStruct{}
+		}
+		if len(deps) == 0 {
+			delete(p.ext, protos.DepartmentExtKey)
+		} else {
+			p.ext[protos.DepartmentExtKey] = deps
+		}
+	}
+	return p, nil
+}
+
+// applyOrgTransferRoles 在目标组织授予映射后的角色，调出时收回原组织的角色与继承授权；做过的变更追加到 undo
+func applyOrgTransferRoles(tenantID uint64, rec *protos.OrgMemberTransfer, inherited []string, move bool, undo []func()) ([]func(), error) {
+	uid, fromOrgID, toOrgID := rec.UID, rec.FromOrgID, rec.ToOrgID
+
+	held := accessctl.GetDirectRolesForUserInDomain(uid, tenantID, toOrgID)
+	for _, role := range rec.ToRoles {
+		if containsString(held, role) {
+			continue
+		}
+		if err := accessctl.AddRoleForUserInDomain(uid, tenantID, toOrgID, role); err != nil {
+			common.Logger.Sugar().Errorf("OrgMemberTransfer AddRoleForUserInDomain ERR: %v %v %v %v", uid, toOrgID, role, err)
+			if err == common.ErrRoleExclusive {
+				return undo, err
+			}
+			return undo, common.ErrService
+		}
+		role := role
+		undo = append(undo, func() {
+			if err := accessctl.DeleteRoleForUserInDomain(uid, tenantID, toOrgID, role); err != nil {
+				common.Logger.Sugar().Errorf("OrgMemberTransfer undo add ERR: %v %v %v %v", uid, toOrgID, role, err)
+			}
+		})
+	}
+	if !move {
+		return undo, nil
+	}
+
+	for _, role := range rec.FromRoles {
+		if err := accessctl.DeleteRoleForUserInDomain(uid, tenantID, fromOrgID, role); err != nil {
+			common.Logger.Sugar().Errorf("OrgMemberTransfer DeleteRoleForUserInDomain ERR: %v %v %v %v", uid, fromOrgID, role, err)
+			return undo, common.ErrService
+		}
+		role := role
+		undo = append(undo, func() {
+			if err := accessctl.AddRoleForUserInDomain(uid, tenantID, fromOrgID, role); err != nil {
+				common.Logger.Sugar().Errorf("OrgMemberTransfer undo delete ERR: %v %v %v %v", uid, fromOrgID, role, err)
+			}
+		})
+	}
+	for _, role := range inherited {
+		if err := accessctl.DeleteInheritedRoleForUser(uid, tenantID, fromOrgID, role); err != nil {
+			common.Logger.Sugar().Errorf("OrgMemberTransfer DeleteInheritedRoleForUser ERR: %v %v %v %v", uid, fromOrgID, role, err)
+			return undo, common.ErrService
+		}
+		role := role
+		undo = append(undo, func() {
+			if err := accessctl.AddInheritedRoleForUser(uid, tenantID, fromOrgID, role); err != nil {
+				common.Logger.Sugar().Errorf("OrgMemberTransfer undo inherited ERR: %v %v %v %v", uid, fromOrgID, role, err)
+			}
+		})
+	}
+	return undo, nil
+}
+
+// orgDepartmentSet 组织下的全部部门 ID
+func orgDepartmentSet(tenantID, orgID uint64) (map[uint64]struct{}, error) {
+	deps, err := DepartmentFind(0, tenantID, orgID, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[uint64]struct{}, len(deps))
+	for i := range deps {
+		set[deps[i].Id] = struct{}{}
+	}
+	return set, nil
+}
+
+// OrgMemberTransferList 分页查询调入或调出组织的记录，最近的在前；orgID 为 0 时查询整个租户。
+func OrgMemberTransferList(tenantID, orgID, page, pageSize uint64) ([]protos.OrgMemberTransfer, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	rr, err := dao.OrgMemberTransferList(tenantID, orgID, page, pageSize)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	return rr, nil
+}
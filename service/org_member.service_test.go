@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/liuhengloveyou/passport/v4/accessctl"
+	"github.com/liuhengloveyou/passport/v4/cache"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+func TestOrgMemberTransfer(t *testing.T) {
+	initServiceTest(t)
+	const tid = 20027
+	defer cache.DelTenantCache(tid)
+	for _, sql := range []string{
+		"INSERT INTO tenants (id, tenant_name) VALUES (20027, 'transfer')",
+		"INSERT INTO users (uid, tenant_id, password) VALUES (1, 20027, ''), (2, 20027, ''), (3, 20027, '')",
+	} {
+		if _, err := common.DB.Exec(context.Background(), sql); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cache.SetTenantCache(&protos.Tenant{ID: tid, Configuration: &protos.TenantConfiguration{Roles: []protos.RoleStruct{
+		{RoleValue: "root"},
+		{RoleValue: "manager", Grantable: []string{"clerk"}},
+		{RoleValue: "clerk"},
+		{RoleValue: "auditor"},
+	}}})
+
+	from, err := OrgCreate(tid, "store-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.DelOrgCache(from)
+	to, err := OrgCreate(tid, "store-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.DelOrgCache(to)
+	for _, uid := range []uint64{1, 2, 3} {
+		defer cache.DelOrgMemberCache(from, uid)
+		defer cache.DelOrgMemberCache(to, uid)
+	}
+
+	depFrom, err := DepartmentCreate(&protos.Department{TenantID: tid, OrgID: from, Name: "front"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	depTo, err := DepartmentCreate(&protos.Department{TenantID: tid, OrgID: to, Name: "back"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = TenantUserAdd(3, tid, from, []uint64{uint64(depFrom)}, []string{"clerk", "auditor"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, org := range []uint64{from, to} {
+		if err = TenantUserAdd(1, tid, org, nil, []string{"root"}, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err = TenantUserAdd(2, tid, org, nil, []string{"manager"}, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// manager 不能把 auditor 带到目标组织，整体不生效
+	req := &protos.OrgMemberTransferReq{UIDs: []uint64{3}, FromOrgID: from, ToOrgID: to}
+	if _, err = OrgMemberTransfer(2, tid, req); err != common.ErrNoAuth {
+		t.Fatalf("transfer ungrantable role = %v", err)
+	}
+	if err = UserInOrg(3, tid, to); err != common.ErrNoAuth {
+		t.Fatalf("member added after failed transfer: %v", err)
+	}
+
+	req.RoleMap = map[string]string{"auditor": ""}
+	req.DepIds = []uint64{uint64(depTo)}
+	rr, err := OrgMemberTransfer(1, tid, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rr) != 1 || rr[0].Mode != protos.OrgTransferMove || len(rr[0].ToRoles) != 1 || rr[0].ToRoles[0] != "clerk" {
+		t.Fatalf("transfer records = %+v", rr)
+	}
+	if err = UserInOrg(3, tid, from); err != common.ErrNoAuth {
+		t.Fatalf("still in source org: %v", err)
+	}
+	if err = UserInOrg(3, tid, to); err != nil {
+		t.Fatalf("not in target org: %v", err)
+	}
+	if roles := accessctl.GetDirectRolesForUserInDomain(3, tid, from); len(roles) != 0 {
+		t.Fatalf("source roles = %v", roles)
+	}
+	if roles := accessctl.GetDirectRolesForUserInDomain(3, tid, to); len(roles) != 1 || roles[0] != "clerk" {
+		t.Fatalf("target roles = %v", roles)
+	}
+	user, err := dao.UserQueryByID(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deps := parseUint64Slice(user.Ext[protos.DepartmentExtKey]); len(deps) != 1 || deps[0] != uint64(depTo) {
+		t.Fatalf("deps = %v", deps)
+	}
+
+	// copy 保留原组织
+	if _, err = OrgMemberTransfer(1, tid, &protos.OrgMemberTransferReq{UIDs: []uint64{3}, FromOrgID: to, ToOrgID: from, Copy: true}); err != nil {
+		t.Fatal(err)
+	}
+	if UserInOrg(3, tid, from) != nil || UserInOrg(3, tid, to) != nil {
+		t.Fatal("copy should keep both memberships")
+	}
+	list, err := OrgMemberTransferList(tid, from, 1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Mode != protos.OrgTransferCopy || list[1].ToOrgID != to {
+		t.Fatalf("transfer list = %+v", list)
+	}
+}
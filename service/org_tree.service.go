@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+
+	"github.com/liuhengloveyou/passport/v4/accessctl"
+	"github.com/liuhengloveyou/passport/v4/cache"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+func init() {
+	// 组织继承授权按闭包表查上级组织
+	accessctl.SetOrgAncestors(dao.OrgClosureAncestors)
+}
+
+// OrgCheckScope 租户 root 可以管理租户内任意组织；其他用户只能管理当前组织及其下级组织。
+func OrgCheckScope(uid, tenantID, currentOrgID, orgID uint64) error {
+	if _, err := RequireOrg(tenantID, orgID); err != nil {
+		return err
+	}
+	if IsTenantRoot(uid, tenantID) {
+		return nil
+	}
+	depth, err := dao.OrgClosureDepth(currentOrgID, orgID)
+	if err != nil {
+		return common.ErrService
+	}
+	if depth < 0 {
+		common.Logger.Sugar().Warnf("OrgCheckScope denied: user %d org %d is not under %d", uid, orgID, currentOrgID)
+		return common.ErrNoAuth
+	}
+	return nil
+}
+
+// OrgTree 组织树；rootID 为 0 时返回租户下的全部组织，否则返回以 rootID 为根的子树。
+func OrgTree(tenantID, rootID uint64) ([]*protos.OrgNode, error) {
+	var (
+		orgs []protos.Organization
+		err  error
+	)
+	if rootID == 0 {
+		orgs, err = dao.OrgListByTenant(tenantID)
+	} else {
+		if _, err = RequireOrg(tenantID, rootID); err != nil {
+			return nil, err
+		}
+		orgs, err = dao.OrgListByAncestor(rootID)
+	}
+	if err != nil {
+		return nil, common.ErrService
+	}
+	return buildOrgTree(orgs), nil
+}
+
+// buildOrgTree 按 parent_id 组装成树；上级不在列表中的组织作为根
+func buildOrgTree(orgs []protos.Organization) []*protos.OrgNode {
+	nodes := make(map[uint64]*protos.OrgNode, len(orgs))
+	for i := range orgs {
+		nodes[orgs[i].ID] = &protos.OrgNode{Organization: orgs[i]}
+	}
+	roots := make([]*protos.OrgNode, 0)
+	for i := range orgs {
+		node := nodes[orgs[i].ID]
+		if parent, ok := nodes[node.ParentID]; ok && node.ParentID != node.ID {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return roots
+}
+
+// OrgMove 把组织连同下级组织移到 parentID 之下，parentID 为 0 时移为顶级组织。
+// 新上级组织链上的继承授权随即对移入的组织生效，持有人补上成员关系；原上级的继承授权不再生效，成员关系保持不变。
+func OrgMove(tenantID, orgID, parentID uint64) error {
+	org, err := RequireOrg(tenantID, orgID)
+	if err != nil {
+		return err
+	}
+	if parentID > 0 {
+		if _, err = RequireOrg(tenantID, parentID); err != nil {
+			return err
+		}
+		depth, err := dao.OrgClosureDepth(orgID, parentID)
+		if err != nil {
+			return common.ErrService
+		}
+		if depth >= 0 {
+			return common.ErrOrgCircularRef
+		}
+	}
+	if org.ParentID == parentID {
+		return nil
+	}
+
+	ctx := context.Background()
+	tx, err := common.DB.Begin(ctx)
+	if err != nil {
+		common.Logger.Sugar().Errorf("OrgMove Begin ERR: %v\n", err)
+		return common.ErrService
+	}
+	if err = dao.OrgClosureMove(tx, orgID, parentID); err != nil {
+		tx.Rollback(ctx)
+		return common.ErrService
+	}
+	if err = tx.Commit(ctx); err != nil {
+		common.Logger.Sugar().Errorf("OrgMove Commit ERR: %v\n", err)
+		return common.ErrService
+	}
+	cache.DelOrgCache(orgID)
+	accessctl.OrgTreeChanged(tenantID)
+	common.Logger.Sugar().Infof("OrgMove: tenant %d org %d parent %d => %d", tenantID, orgID, org.ParentID, parentID)
+
+	if parentID == 0 {
+		return nil
+	}
+	subtree, err := dao.OrgClosureDescendants(orgID)
+	if err != nil {
+		return common.ErrService
+	}
+	return addOrgMembers(tenantID, subtree, accessctl.GetUsersWithInheritedRoles(tenantID, parentID))
+}
+
+// OrgAddInheritedRole 在组织上授予继承到下级组织的角色，并把用户加入该组织及所有下级组织。
+func OrgAddInheritedRole(uid, tenantID, orgID uint64, role string) error {
+	if uid == 0 || role == "" {
+		return common.ErrParam
+	}
+	if err := UserInTenant(uid, tenantID); err != nil {
+		return err
+	}
+	if _, err := RequireOrg(tenantID, orgID); err != nil {
+		return err
+	}
+	subtree, err := dao.OrgClosureDescendants(orgID)
+	if err != nil {
+		return common.ErrService
+	}
+
+	if err = accessctl.AddInheritedRoleForUser(uid, tenantID, orgID, role); err != nil {
+		common.Logger.Sugar().Errorf("OrgAddInheritedRole ERR: %v %v %v %v %v", uid, tenantID, orgID, role, err)
+		if err == common.ErrRoleExclusive || err == common.ErrOrgInheritUnsupported {
+			return err
+		}
+		return common.ErrService
+	}
+	return addOrgMembers(tenantID, subtree, []uint64{uid})
+}
+
+// OrgRemoveInheritedRole 收回组织上的继承授权；下级组织的成员关系保持不变。
+func OrgRemoveInheritedRole(uid, tenantID, orgID uint64, role string) error {
+	if uid == 0 || role == "" {
+		return common.ErrParam
+	}
+	if err := accessctl.DeleteInheritedRoleForUser(uid, tenantID, orgID, role); err != nil {
+		common.Logger.Sugar().Errorf("OrgRemoveInheritedRole ERR: %v %v %v %v %v", uid, tenantID, orgID, role, err)
+		return common.ErrService
+	}
+	return nil
+}
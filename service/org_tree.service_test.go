@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/liuhengloveyou/passport/v4/accessctl"
+	"github.com/liuhengloveyou/passport/v4/cache"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+func TestOrgTreeInherit(t *testing.T) {
+	initServiceTest(t)
+	const tid = 20024
+	if _, err := common.DB.Exec(context.Background(), "INSERT INTO users (uid, tenant_id, password) VALUES (9, ?, '')", tid); err != nil {
+		t.Fatal(err)
+	}
+	cache.SetTenantCache(&protos.Tenant{ID: tid, Configuration: &protos.TenantConfiguration{Roles: []protos.RoleStruct{{RoleValue: "auditor"}}}})
+
+	region, err := OrgCreate(tid, "east")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.DelOrgCache(region)
+	store, err := OrgCreateChild(tid, region, "east-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.DelOrgCache(store)
+	other, err := OrgCreate(tid, "west")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.DelOrgCache(other)
+
+	tree, err := OrgTree(tid, region)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree) != 1 || tree[0].ID != region || len(tree[0].Children) != 1 || tree[0].Children[0].ID != store {
+		t.Fatalf("OrgTree = %+v", tree)
+	}
+	if err = OrgMove(tid, region, store); err != common.ErrOrgCircularRef {
+		t.Fatalf("move under own child = %v", err)
+	}
+	if err = OrgDelete(tid, region); err != common.ErrOrgHasChildren {
+		t.Fatalf("delete with children = %v", err)
+	}
+
+	// 区域经理：在区域上授予继承角色，门店内按门店的策略放行
+	if err = accessctl.AddPolicyToRole(tid, store, "auditor", "/stock", "GET"); err != nil {
+		t.Fatal(err)
+	}
+	if err = OrgAddInheritedRole(9, tid, region, "auditor"); err != nil {
+		t.Fatal(err)
+	}
+	if err = UserInOrg(9, tid, store); err != nil {
+		t.Fatalf("inherited role holder not added to store: %v", err)
+	}
+	if ok, _ := accessctl.Enforce(9, tid, store, "/stock", "GET"); !ok {
+		t.Fatal("inherited role denied in store")
+	}
+	if from := accessctl.GetInheritedRolesForUser(9, tid, store)["auditor"]; from != region {
+		t.Fatalf("inherited from = %d", from)
+	}
+
+	// 门店移出区域后继承授权不再生效
+	if err = OrgMove(tid, store, other); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := accessctl.Enforce(9, tid, store, "/stock", "GET"); ok {
+		t.Fatal("inherited role still effective after move")
+	}
+	if err = OrgMove(tid, store, region); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := accessctl.Enforce(9, tid, store, "/stock", "GET"); !ok {
+		t.Fatal("inherited role denied after moving back")
+	}
+	if err = OrgRemoveInheritedRole(9, tid, region, "auditor"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := accessctl.Enforce(9, tid, store, "/stock", "GET"); ok {
+		t.Fatal("inherited role still effective after removal")
+	}
+}
+
+func TestOrgCreateLegacyTenantDomain(t *testing.T) {
+	initServiceTest(t)
+	const tid, org = 20025, 30025
+	if _, err := common.DB.Exec(context.Background(), "INSERT INTO users (uid, tenant_id, password) VALUES (2, ?, '')", tid); err != nil {
+		t.Fatal(err)
+	}
+	cache.SetTenantCache(&protos.Tenant{ID: tid, Configuration: &protos.TenantConfiguration{Roles: []protos.RoleStruct{{RoleValue: "manager"}}}})
+	if err := accessctl.AddPolicyToRole(tid, org, "manager", "/orders", "GET"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 老版本租户域中遗留一条 manager 的策略
+	if err := accessctl.CopyPolicies(accessctl.Domain(tid, org), accessctl.LegacyTenantDomain(tid)); err != nil {
+		t.Fatal(err)
+	}
+	first, err := OrgCreate(tid, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ps := accessctl.GetFilteredPolicy(tid, first, nil); len(ps) != 0 {
+		t.Fatalf("legacy policies copied into first org: %v", ps)
+	}
+
+	rst, err := CollapseTenantDomain(tid, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rst.LegacyPolicies != 1 || rst.MigratedPolicies != 0 {
+		t.Fatalf("report = %+v", rst)
+	}
+	if err = accessctl.AddRoleForUserInDomain(2, tid, first, "manager"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := accessctl.Enforce(2, tid, first, "/orders", "GET"); ok {
+		t.Fatal("legacy policy applies before migration")
+	}
+
+	if rst, err = CollapseTenantDomain(tid, true); err != nil {
+		t.Fatal(err)
+	}
+	if rst.LegacyPolicies != 0 || rst.MigratedPolicies != 1 {
+		t.Fatalf("migrate = %+v", rst)
+	}
+	if ok, _ := accessctl.Enforce(2, tid, first, "/orders", "GET"); !ok {
+		t.Fatal("migrated policy denied")
+	}
+}
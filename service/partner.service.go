@@ -0,0 +1,129 @@
+package service
+
+import (
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// PartnerCheckTenant 上级租户（代理商）只能管理自己的下级租户：tenantID 须是 sess 所在租户的后代，不含自身。
+// 接口本身的权限由 partner/* 路由在上级租户内的 Casbin 策略控制。
+func PartnerCheckTenant(sess *protos.User, tenantID uint64) error {
+	if sess == nil || sess.UID == 0 || sess.TenantID == 0 || tenantID == 0 {
+		return common.ErrNoAuth
+	}
+	if tenantID == sess.TenantID {
+		common.Logger.Sugar().Warnf("PartnerCheckTenant self: user %d tenant %d", sess.UID, tenantID)
+		return common.ErrNoAuth
+	}
+	depth, err := dao.TenantClosureIsDescendant(sess.TenantID, tenantID)
+	if err != nil {
+		common.Logger.Sugar().Errorf("PartnerCheckTenant ERR: %v %v %v\n", sess.TenantID, tenantID, err)
+		return common.ErrService
+	}
+	if depth < 1 {
+		common.Logger.Sugar().Warnf("PartnerCheckTenant denied: tenant %d is not under %d (user %d)", tenantID, sess.TenantID, sess.UID)
+		return common.ErrNoAuth
+	}
+	return nil
+}
+
+// PartnerCheckUser 账号须属于 sess 的某个下级租户 tenantID。
+func PartnerCheckUser(sess *protos.User, tenantID, uid uint64) error {
+	if err := PartnerCheckTenant(sess, tenantID); err != nil {
+		return err
+	}
+	return UserInTenant(uid, tenantID)
+}
+
+// PartnerCheckAccount 修改账号级数据（密码、移出后可能删除账号）前，账号的默认租户和加入的所有租户都须在 sess 的下级租户中；
+// 账号同时属于上级租户或子树外的租户时拒绝，避免代理商借下级租户接管账号。
+func PartnerCheckAccount(sess *protos.User, tenantID, uid uint64) error {
+	if err := PartnerCheckUser(sess, tenantID, uid); err != nil {
+		return err
+	}
+	ids, err := dao.TenantIDsByUser(uid)
+	if err != nil {
+		return common.ErrService
+	}
+	for _, id := range ids {
+		if id == tenantID {
+			continue
+		}
+		if err = PartnerCheckTenant(sess, id); err != nil {
+			common.Logger.Sugar().Warnf("PartnerCheckAccount denied: user %d also in tenant %d outside %d", uid, id, sess.TenantID)
+			return err
+		}
+	}
+	return nil
+}
+
+// PartnerUserDisable 停用或启用账号在下级租户的成员身份。账号可能同时属于子树外的租户，
+// 停用只记在 tenantID 的成员记录上，不影响账号在其他租户登录。
+func PartnerUserDisable(sess *protos.User, tenantID, uid uint64, disabled protos.UserDisableStatus) error {
+	if err := PartnerCheckUser(sess, tenantID, uid); err != nil {
+		return err
+	}
+	if err := TenantUserDisabledService(uid, tenantID, disabled); err != nil {
+		return err
+	}
+	common.Logger.Sugar().Infof("PartnerUserDisable: user %d of tenant %d set user %d in tenant %d to %v", sess.UID, sess.TenantID, uid, tenantID, disabled)
+	return nil
+}
+
+// PartnerTenantNew 在当前租户或其下级租户（ParentID）下开通子租户，可按模板初始化。
+func PartnerTenantNew(sess *protos.User, m *protos.NewTenantReq) (uid, tenantID uint64, e error) {
+	if sess == nil || sess.UID == 0 || sess.TenantID == 0 {
+		return 0, 0, common.ErrNoAuth
+	}
+	if m.ParentID > 0 && m.ParentID != sess.TenantID {
+		if e = PartnerCheckTenant(sess, m.ParentID); e != nil {
+			return 0, 0, e
+		}
+	}
+
+	if uid, tenantID, e = createTenant(sess, m); e != nil {
+		return
+	}
+	common.Logger.Sugar().Infof("PartnerTenantNew: user %d of tenant %d created tenant %d %s", sess.UID, sess.TenantID, tenantID, m.TenantName)
+	return
+}
+
+// PartnerTenantGet 查询下级租户详情。
+func PartnerTenantGet(sess *protos.User, tenantID uint64) (*protos.Tenant, error) {
+	if err := PartnerCheckTenant(sess, tenantID); err != nil {
+		return nil, err
+	}
+	tenant, err := dao.TenantGetByID(tenantID)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	if tenant == nil {
+		return nil, common.ErrTenantNotFound
+	}
+	return tenant, nil
+}
+
+// PartnerTenantSetStatus 变更下级租户生命周期状态，规则同平台管理员。
+func PartnerTenantSetStatus(sess *protos.User, req *protos.TenantStatusReq) error {
+	if err := PartnerCheckTenant(sess, req.TenantID); err != nil {
+		return err
+	}
+	return setTenantStatus(sess, req)
+}
+
+// PartnerTenantDelete 软删除下级租户，恢复期内可恢复。
+func PartnerTenantDelete(sess *protos.User, tenantID uint64) error {
+	if err := PartnerCheckTenant(sess, tenantID); err != nil {
+		return err
+	}
+	return deleteTenant(sess, tenantID)
+}
+
+// PartnerTenantRestore 在恢复期内撤销下级租户的删除。
+func PartnerTenantRestore(sess *protos.User, tenantID uint64) error {
+	if err := PartnerCheckTenant(sess, tenantID); err != nil {
+		return err
+	}
+	return restoreTenant(sess, tenantID)
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/liuhengloveyou/passport/v4/cache"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+func TestPartnerScope(t *testing.T) {
+	initServiceTest(t)
+	const tid, otherTid = 20012, 20013
+	defer cache.DelTenantCache(tid)
+	defer cache.DelTenantCache(otherTid)
+
+	ctx := context.Background()
+	for _, sql := range []string{
+		"INSERT INTO tenants (id, tenant_name, status) VALUES (20012, 'reseller', 'active'), (20013, 'other', 'active')",
+		"INSERT INTO tenant_closure (ancestor_id, descendant_id, depth) VALUES (20012, 20012, 0), (20013, 20013, 0)",
+	} {
+		if _, err := common.DB.Exec(ctx, sql); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sess := &protos.User{UID: 1, TenantID: tid}
+
+	_, child, err := PartnerTenantNew(sess, &protos.NewTenantReq{TenantName: "shop-a", TenantType: "shop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.DelTenantCache(child)
+	_, grandchild, err := PartnerTenantNew(sess, &protos.NewTenantReq{TenantName: "shop-a-1", TenantType: "shop", ParentID: child})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.DelTenantCache(grandchild)
+	if _, _, err = PartnerTenantNew(sess, &protos.NewTenantReq{TenantName: "stray", TenantType: "shop", ParentID: otherTid}); err != common.ErrNoAuth {
+		t.Fatalf("create under foreign tenant = %v", err)
+	}
+
+	for id, want := range map[uint64]error{child: nil, grandchild: nil, tid: common.ErrNoAuth, otherTid: common.ErrNoAuth} {
+		if got := PartnerCheckTenant(sess, id); got != want {
+			t.Fatalf("PartnerCheckTenant(%d) = %v, want %v", id, got, want)
+		}
+	}
+	// 下级租户不能反过来管理上级
+	if err = PartnerCheckTenant(&protos.User{UID: 2, TenantID: child}, tid); err != common.ErrNoAuth {
+		t.Fatalf("child managing parent = %v", err)
+	}
+
+	if err = PartnerTenantSetStatus(sess, &protos.TenantStatusReq{TenantID: grandchild, Status: protos.TenantStatusSuspended, Reason: "unpaid"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := CheckTenantStatus(grandchild); got != common.ErrTenantSuspended {
+		t.Fatalf("CheckTenantStatus = %v", got)
+	}
+	if err = PartnerTenantSetStatus(sess, &protos.TenantStatusReq{TenantID: otherTid, Status: protos.TenantStatusSuspended, Reason: "x"}); err != common.ErrNoAuth {
+		t.Fatalf("suspend foreign tenant = %v", err)
+	}
+	if err = PartnerTenantDelete(sess, child); err != nil {
+		t.Fatal(err)
+	}
+	if err = PartnerTenantRestore(sess, child); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = common.DB.Exec(ctx, "INSERT INTO users (uid, tenant_id, password) VALUES (31, ?, ''), (32, ?, '')", child, otherTid); err != nil {
+		t.Fatal(err)
+	}
+	if err = PartnerCheckUser(sess, child, 31); err != nil {
+		t.Fatal(err)
+	}
+	if err = PartnerCheckUser(sess, child, 32); err != common.ErrNoAuth {
+		t.Fatalf("user of foreign tenant = %v", err)
+	}
+	if err = PartnerUserDisable(sess, otherTid, 32, protos.UserDisabled); err != common.ErrNoAuth {
+		t.Fatalf("disable in foreign tenant = %v", err)
+	}
+
+	// 默认租户在子树外的账号加入下级租户：停用只作用于下级租户的成员身份
+	if err = TenantBindUser(32, child); err != nil {
+		t.Fatal(err)
+	}
+	if err = PartnerUserDisable(sess, child, 32, protos.UserDisabled); err != nil {
+		t.Fatal(err)
+	}
+	if err = CheckTenantMemberEnabled(32, child); err != common.ErrDisable {
+		t.Fatalf("member of child = %v", err)
+	}
+	if err = CheckTenantMemberEnabled(32, otherTid); err != nil {
+		t.Fatalf("member of foreign default tenant = %v", err)
+	}
+
+	// 账号级修改（重置密码、移出）要求账号的所有租户都在子树中
+	if _, err = common.DB.Exec(ctx, "INSERT INTO users (uid, tenant_id, password) VALUES (33, ?, '')", tid); err != nil {
+		t.Fatal(err)
+	}
+	if err = TenantBindUser(33, child); err != nil {
+		t.Fatal(err)
+	}
+	if err = TenantBindUser(31, grandchild); err != nil {
+		t.Fatal(err)
+	}
+	for uid, want := range map[uint64]error{31: nil, 32: common.ErrNoAuth, 33: common.ErrNoAuth} {
+		if got := PartnerCheckAccount(sess, child, uid); got != want {
+			t.Fatalf("PartnerCheckAccount(%d) = %v, want %v", uid, got, want)
+		}
+	}
+	if _, err = SetUserPWD(31, child, "secret1"); err != nil {
+		t.Fatal(err)
+	}
+}
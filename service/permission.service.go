@@ -1,30 +1,117 @@
 package service
 
 import (
+	"strings"
+
+	"github.com/liuhengloveyou/passport/v4/accessctl"
 	"github.com/liuhengloveyou/passport/v4/common"
 	"github.com/liuhengloveyou/passport/v4/dao"
 	"github.com/liuhengloveyou/passport/v4/protos"
 )
 
+// TenantConfStrictPolicy 租户配置 More 中的严格模式开关：开启后策略 obj 必须是已登记的权限值，act 必须是该权限点登记的操作。
+const TenantConfStrictPolicy = "strictPolicy"
+
+// 权限点层级：菜单 → 页面 → 操作
+var permissionTypeLevel = map[string]int{
+	protos.PermissionTypeMenu:   1,
+	protos.PermissionTypePage:   2,
+	protos.PermissionTypeAction: 3,
+}
+
 func PermissionCreate(model *protos.PermissionStruct) (int64, error) {
+	if model.Type == "" {
+		model.Type = protos.PermissionTypeAction
+	}
+	if _, ok := permissionTypeLevel[model.Type]; !ok {
+		return -1, common.ErrParam
+	}
+
+	if model.ParentID > 0 {
+		parent, err := dao.PermissionGet(model.ParentID, model.TenantID)
+		if err != nil {
+			common.Logger.Sugar().Errorf("PermissionCreate dao.PermissionGet ERR: %v\n", err)
+			return -1, common.ErrService
+		}
+		// 上级必须同租户同 domain，且层级不低于当前节点；操作点下不能再挂子节点
+		if parent == nil || parent.Domain != model.Domain || parent.Type == protos.PermissionTypeAction ||
+			permissionTypeLevel[parent.Type] > permissionTypeLevel[model.Type] {
+			common.Logger.Sugar().Errorf("PermissionCreate parent ERR: %v %v\n", model, parent)
+			return -1, common.ErrPermissionParent
+		}
+	}
+
 	return dao.PermissionCreate(model)
 }
 
-func PermissionDelete(id, tenantID uint64) error {
+// PermissionDelete 删除权限点。
+// 权限点有下级节点或被策略引用时：cascade 为 false 返回依赖报告与 ErrPermissionInUse；
+// cascade 为 true 连同整棵子树与相关策略一并删除；删除权限点失败时恢复已删除的策略。
+func PermissionDelete(id, tenantID uint64, cascade bool) (*protos.PermissionDependents, error) {
 	if id <= 0 {
 		common.Logger.Sugar().Errorf("PermissionDelete id ERR: %v\n", id)
-		return common.ErrParam
+		return nil, common.ErrParam
 	}
-	row, err := dao.PermissionDelete(id, tenantID)
+
+	all, err := dao.PermissionList(tenantID, "")
 	if err != nil {
-		common.Logger.Sugar().Errorf("dao.PermissionDelete ERR: %v\n", err)
-		return common.ErrService
+		common.Logger.Sugar().Errorf("dao.PermissionList ERR: %v\n", err)
+		return nil, common.ErrService
 	}
-	if row != 1 {
-		common.Logger.Sugar().Warnf("PermissionDelete row: %v\n", row)
+	subtree := permissionSubtree(all, id)
+	if len(subtree) == 0 {
+		common.Logger.Sugar().Warnf("PermissionDelete not found: %v %v\n", tenantID, id)
+		return nil, nil
 	}
 
-	return nil
+	ids := make([]uint64, len(subtree))
+	values := make([]string, len(subtree))
+	for i := 0; i < len(subtree); i++ {
+		ids[i] = subtree[i].ID
+		values[i] = subtree[i].Value
+	}
+
+	rules := accessctl.GetTenantPoliciesByObj(tenantID, values)
+	if !cascade && (len(subtree) > 1 || len(rules) > 0) {
+		dep := &protos.PermissionDependents{Children: subtree[1:]}
+		for _, rule := range rules {
+			_, orgID, _ := accessctl.ParseDomain(rule[1])
+			policy := protos.Policy{Role: rule[0], Obj: rule[2], Act: rule[3], OrgID: orgID}
+			if len(rule) >= 5 {
+				policy.Effect = rule[4]
+			}
+			dep.Policies = append(dep.Policies, policy)
+		}
+		return dep, common.ErrPermissionInUse
+	}
+
+	// 先删策略再删权限点；任一步失败都把已删除的策略写回，不留下引用已删除权限点的策略，也不丢策略
+	var removed [][]string
+	undo := func() {
+		if err := accessctl.RestorePolicies(removed); err != nil {
+			common.Logger.Sugar().Errorf("PermissionDelete undo ERR: %v %v %v\n", tenantID, removed, err)
+		}
+	}
+	if len(rules) > 0 {
+		if removed, err = accessctl.RemoveTenantPoliciesByObj(tenantID, values); err != nil {
+			common.Logger.Sugar().Errorf("PermissionDelete RemoveTenantPoliciesByObj ERR: %v %v\n", values, err)
+			undo()
+			return nil, common.ErrService
+		}
+		common.Logger.Sugar().Infof("PermissionDelete removed policies: %v %v %d\n", tenantID, values, len(removed))
+	}
+
+	row, err := dao.PermissionDeleteByIDs(tenantID, ids)
+	if err != nil {
+		common.Logger.Sugar().Errorf("dao.PermissionDeleteByIDs ERR: %v\n", err)
+		undo()
+		return nil, common.ErrService
+	}
+	if row != int64(len(ids)) {
+		common.Logger.Sugar().Warnf("PermissionDelete row: %v %v\n", row, ids)
+	}
+
+	return nil, nil
 }
 
 func PermissionList(tenantID uint64, domain string) (rr []protos.PermissionStruct, err error) {
@@ -35,3 +122,136 @@ func PermissionList(tenantID uint64, domain string) (rr []protos.PermissionStruc
 
 	return
 }
+
+// PermissionTree 以树形返回权限字典（菜单 → 页面 → 操作），domain 为空时返回全部。
+func PermissionTree(tenantID uint64, domain string) ([]*protos.PermissionNode, error) {
+	rr, err := PermissionList(tenantID, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	return BuildPermissionTree(rr), nil
+}
+
+// BuildPermissionTree 按 ParentID 组装权限树；上级不在列表中的节点挂到顶层，保持输入顺序。
+func BuildPermissionTree(list []protos.PermissionStruct) []*protos.PermissionNode {
+	nodes := make(map[uint64]*protos.PermissionNode, len(list))
+	for i := 0; i < len(list); i++ {
+		nodes[list[i].ID] = &protos.PermissionNode{PermissionStruct: list[i]}
+	}
+
+	roots := make([]*protos.PermissionNode, 0)
+	for i := 0; i < len(list); i++ {
+		node := nodes[list[i].ID]
+		if parent, ok := nodes[list[i].ParentID]; ok && list[i].ParentID != list[i].ID {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	return roots
+}
+
+// permissionSubtree 返回以 id 为根的子树（首元素为根节点本身），id 不存在时返回空。
+func permissionSubtree(list []protos.PermissionStruct, id uint64) (out []protos.PermissionStruct) {
+	children := make(map[uint64][]protos.PermissionStruct, len(list))
+	for i := 0; i < len(list); i++ {
+		if list[i].ID == id {
+			out = append(out, list[i])
+		}
+		children[list[i].ParentID] = append(children[list[i].ParentID], list[i])
+	}
+	if len(out) == 0 {
+		return nil
+	}
+
+	seen := map[uint64]bool{id: true}
+	for i := 0; i < len(out); i++ {
+		for _, c := range children[out[i].ID] {
+			if !seen[c.ID] {
+				seen[c.ID] = true
+				out = append(out, c)
+			}
+		}
+	}
+
+	return
+}
+
+// TenantStrictPolicy 租户是否开启了策略严格模式。
+func TenantStrictPolicy(tenantID uint64) bool {
+	tenant, err := getTenantByIDCached(tenantID)
+	if err != nil || tenant == nil || tenant.Configuration == nil || tenant.Configuration.More == nil {
+		return false
+	}
+
+	switch v := tenant.Configuration.More[TenantConfStrictPolicy].(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		v = strings.ToLower(strings.TrimSpace(v))
+		return v == "1" || v == "true"
+	}
+
+	return false
+}
+
+// CheckPolicyDictionary 严格模式下校验策略 obj 必须在权限字典中登记，act 必须在该条目的 actions 中（"*" 表示任意）。
+func CheckPolicyDictionary(tenantID uint64, obj, act string) error {
+	if !TenantStrictPolicy(tenantID) {
+		return nil
+	}
+
+	entries, err := dao.PermissionListByValue(tenantID, obj)
+	if err != nil {
+		common.Logger.Sugar().Errorf("CheckPolicyDictionary dao.PermissionListByValue ERR: %v\n", err)
+		return common.ErrService
+	}
+	if len(entries) == 0 {
+		common.Logger.Sugar().Warnf("CheckPolicyDictionary not registered: %v %v\n", tenantID, obj)
+		return common.ErrPermissionNotFound
+	}
+	for i := range entries {
+		if containsString(entries[i].Actions, act) || containsString(entries[i].Actions, "*") {
+			return nil
+		}
+	}
+
+	common.Logger.Sugar().Warnf("CheckPolicyDictionary act not registered: %v %v %v\n", tenantID, obj, act)
+	return common.ErrPermissionActInvalid
+}
+
+// PolicyAddToRole 为角色（或用户 sub）添加 allow / deny 策略，严格模式下先校验权限字典。
+func PolicyAddToRole(tenantID, orgID uint64, role, obj, act, eft string) error {
+	if err := CheckPolicyDictionary(tenantID, obj, act); err != nil {
+		return err
+	}
+	if err := accessctl.AddPolicyWithEffect(tenantID, orgID, role, obj, act, eft); err != nil {
+		common.Logger.Sugar().Errorf("PolicyAddToRole ERR: %v %v %v %v %v %v\n", tenantID, orgID, role, obj, eft, err)
+		if err == common.ErrParam || err == common.ErrPolicyDenyUnsupported || err == common.ErrOrgRequired {
+			return err
+		}
+		return common.ErrService
+	}
+
+	return nil
+}
+
+// PolicyAddToTenant 在租户级域添加策略，对租户下所有组织生效。
+func PolicyAddToTenant(tenantID uint64, role, obj, act, eft string) error {
+	if err := CheckPolicyDictionary(tenantID, obj, act); err != nil {
+		return err
+	}
+	if err := accessctl.AddTenantPolicyWithEffect(tenantID, role, obj, act, eft); err != nil {
+		common.Logger.Sugar().Errorf("PolicyAddToTenant ERR: %v %v %v %v %v\n", tenantID, role, obj, eft, err)
+		if err == common.ErrParam || err == common.ErrPolicyDenyUnsupported {
+			return err
+		}
+		return common.ErrService
+	}
+
+	return nil
+}
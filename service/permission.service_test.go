@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/liuhengloveyou/passport/v4/accessctl"
+	"github.com/liuhengloveyou/passport/v4/cache"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+func TestCheckPolicyDictionary(t *testing.T) {
+	initServiceTest(t)
+	const tid = 20015
+	cache.SetTenantCache(&protos.Tenant{ID: tid, Configuration: &protos.TenantConfiguration{More: protos.MapStruct{TenantConfStrictPolicy: true}}})
+
+	for _, m := range []*protos.PermissionStruct{
+		{TenantID: tid, Domain: "shop", Title: "orders", Value: "/orders", Type: protos.PermissionTypePage, Actions: []string{"GET", "POST"}},
+		{TenantID: tid, Domain: "shop", Title: "reports", Value: "/reports", Type: protos.PermissionTypePage, Actions: []string{"*"}},
+		{TenantID: tid, Domain: "shop", Title: "legacy", Value: "/legacy", Type: protos.PermissionTypePage},
+	} {
+		if _, err := PermissionCreate(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		obj, act string
+		want     error
+	}{
+		{"/orders", "GET", nil},
+		{"/orders", "DELETE", common.ErrPermissionActInvalid},
+		{"/reports", "DELETE", nil},
+		{"/legacy", "GET", common.ErrPermissionActInvalid},
+		{"/unknown", "GET", common.ErrPermissionNotFound},
+	}
+	for _, tc := range cases {
+		if got := CheckPolicyDictionary(tid, tc.obj, tc.act); got != tc.want {
+			t.Errorf("CheckPolicyDictionary(%s %s) = %v, want %v", tc.obj, tc.act, got, tc.want)
+		}
+	}
+	if err := PolicyAddToRole(tid, 30001, "clerk", "/orders", "DELETE", ""); err != common.ErrPermissionActInvalid {
+		t.Fatalf("PolicyAddToRole unregistered act = %v", err)
+	}
+}
+
+func TestPermissionDeleteRestoresPolicies(t *testing.T) {
+	initServiceTest(t)
+	const tid, org = 20032, 30032
+
+	parent, err := PermissionCreate(&protos.PermissionStruct{TenantID: tid, Domain: "shop", Title: "orders", Value: "/orders", Type: protos.PermissionTypePage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = PermissionCreate(&protos.PermissionStruct{TenantID: tid, ParentID: uint64(parent), Domain: "shop", Title: "refund", Value: "/orders/refund"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range []error{
+		accessctl.AddPolicyToRole(tid, org, "clerk", "/orders", "GET"),
+		accessctl.AddPolicyWithEffect(tid, org, "clerk", "/orders/refund", "POST", protos.PolicyEffectDeny),
+		accessctl.AddTenantPolicyWithEffect(tid, "manager", "/orders/refund", "POST", protos.PolicyEffectAllow),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	values := []string{"/orders", "/orders/refund"}
+	before := accessctl.GetTenantPoliciesByObj(tid, values)
+	if len(before) != 3 {
+		t.Fatalf("policies = %v", before)
+	}
+
+	// 权限点删除失败：已删除的策略原样写回
+	ctx := context.Background()
+	if _, err = common.DB.Exec(ctx, "CREATE TRIGGER permission_no_delete BEFORE DELETE ON permission BEGIN SELECT RAISE(ABORT, 'no delete'); END"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = PermissionDelete(uint64(parent), tid, true); err != common.ErrService {
+		t.Fatalf("PermissionDelete = %v, want ErrService", err)
+	}
+	after := accessctl.GetTenantPoliciesByObj(tid, values)
+	if len(after) != len(before) {
+		t.Fatalf("policies after failed delete = %v, want %v", after, before)
+	}
+	for i := range before {
+		if strings.Join(after[i], ",") != strings.Join(before[i], ",") {
+			t.Fatalf("policy %d = %v, want %v", i, after[i], before[i])
+		}
+	}
+
+	if _, err = common.DB.Exec(ctx, "DROP TRIGGER permission_no_delete"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = PermissionDelete(uint64(parent), tid, true); err != nil {
+		t.Fatal(err)
+	}
+	if rules := accessctl.GetTenantPoliciesByObj(tid, values); len(rules) != 0 {
+		t.Fatalf("policies after delete = %v", rules)
+	}
+	if rr, err := PermissionList(tid, ""); err != nil || len(rr) != 0 {
+		t.Fatalf("permissions after delete = %v %v", rr, err)
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"sort"
+
+	"github.com/liuhengloveyou/passport/v4/accessctl"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+func init() {
+	accessctl.SetRoleConstraint(checkRoleExclusive)
+}
+
+// TenantExclusiveRoles 租户配置的互斥角色组。
+func TenantExclusiveRoles(tenantID uint64) [][]string {
+	tenant, err := getTenantByIDCached(tenantID)
+	if err != nil || tenant == nil || tenant.Configuration == nil {
+		return nil
+	}
+	return tenant.Configuration.ExclusiveRoles
+}
+
+// TenantSetExclusiveRoles 设置互斥角色组；组内角色须在角色字典中，且每组至少两个不同角色。uid 为操作人，记入配置版本。
+func TenantSetExclusiveRoles(tenantID, uid uint64, sets [][]string) error {
+	_, err := saveTenantConfiguration(tenantID, uid, nil, "修改互斥角色组", func(conf *protos.TenantConfiguration) error {
+		dict := make(map[string]bool, len(conf.Roles))
+		for _, role := range conf.Roles {
+			dict[role.RoleValue] = true
+		}
+		for _, set := range sets {
+			seen := make(map[string]bool, len(set))
+			for _, role := range set {
+				if !dict[role] || seen[role] {
+					common.Logger.Sugar().Errorf("TenantSetExclusiveRoles role ERR: %v %v\n", tenantID, set)
+					return common.ErrParam
+				}
+				seen[role] = true
+			}
+			if len(seen) < 2 {
+				return common.ErrParam
+			}
+		}
+
+		conf.ExclusiveRoles = sets
+		return nil
+	})
+	if err != nil {
+		common.Logger.Sugar().Errorf("TenantSetExclusiveRoles update ERR: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// CheckExclusiveRoles 依次授予 roles 前的职责分离校验：held 为已持有的角色。
+func CheckExclusiveRoles(tenantID uint64, held, roles []string) error {
+	sets := TenantExclusiveRoles(tenantID)
+	if len(sets) == 0 {
+		return nil
+	}
+
+	all := append([]string{}, held...)
+	for _, role := range roles {
+		if other := exclusiveConflict(sets, role, all); other != "" {
+			common.Logger.Sugar().Warnf("CheckExclusiveRoles conflict: %v %v %v\n", tenantID, role, other)
+			return common.ErrRoleExclusive
+		}
+		all = append(all, role)
+	}
+	return nil
+}
+
+func checkRoleExclusive(tenantID uint64, role string, held []string) error {
+	return CheckExclusiveRoles(tenantID, held, []string{role})
+}
+
+// exclusiveConflict 返回 held 中与 role 同处一个互斥组的角色，没有冲突时返回空串。
+func exclusiveConflict(sets [][]string, role string, held []string) string {
+	for _, set := range sets {
+		if !containsString(set, role) {
+			continue
+		}
+		for _, h := range held {
+			if h != role && containsString(set, h) {
+				return h
+			}
+		}
+	}
+	return ""
+}
+
+// ExclusiveRoleViolations 列出租户内已存在的职责分离冲突（配置互斥组之前授予的角色不会被自动收回）。
+func ExclusiveRoleViolations(tenantID uint64) ([]protos.RoleViolation, error) {
+	rst := make([]protos.RoleViolation, 0)
+	sets := TenantExclusiveRoles(tenantID)
+	if len(sets) == 0 {
+		return rst, nil
+	}
+
+	orgs, err := OrgListByTenant(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	for _, org := range orgs {
+		for _, set := range sets {
+			held := make(map[uint64][]string)
+			for _, role := range set {
+				for _, uid := range accessctl.GetUsersForRoleInDomain(role, tenantID, org.ID) {
+					held[uid] = append(held[uid], role)
+				}
+			}
+
+			uids := make([]uint64, 0, len(held))
+			for uid, roles := range held {
+				if len(roles) > 1 {
+					uids = append(uids, uid)
+				}
+			}
+			sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+			for _, uid := range uids {
+				rst = append(rst, protos.RoleViolation{UID: uid, OrgID: org.ID, Roles: held[uid], Set: set})
+			}
+		}
+	}
+
+	return rst, nil
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/liuhengloveyou/passport/v4/accessctl"
+	"github.com/liuhengloveyou/passport/v4/cache"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+func TestExclusiveRoles(t *testing.T) {
+	initServiceTest(t)
+	const tenantID, orgID = 20022, 30022
+	if _, err := common.DB.Exec(context.Background(), "INSERT INTO users (uid, tenant_id, password) VALUES (3, ?, ''), (9, ?, '')", tenantID, tenantID); err != nil {
+		t.Fatal(err)
+	}
+
+	// 配置互斥组之前已同时持有 cashier 与 auditor 的历史数据
+	const legacyUID = 9
+	for _, role := range []string{"cashier", "auditor"} {
+		if err := accessctl.AddRoleForUserInDomain(legacyUID, tenantID, orgID, role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cache.SetTenantCache(&protos.Tenant{ID: tenantID, Configuration: &protos.TenantConfiguration{
+		Roles:          []protos.RoleStruct{{RoleValue: "cashier"}, {RoleValue: "auditor"}, {RoleValue: "clerk"}},
+		ExclusiveRoles: [][]string{{"cashier", "auditor"}},
+	}})
+	if _, err := common.DB.Exec(context.Background(), "INSERT INTO organizations (id, tenant_id, name) VALUES (?, ?, 'store')", orgID, tenantID); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		uid  uint64
+		role string
+		want error
+	}{
+		{"first_of_set", 3, "cashier", nil},
+		{"conflict", 3, "auditor", common.ErrRoleExclusive},
+		{"unrelated", 3, "manager", nil},
+		{"regrant_held", 3, "cashier", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := accessctl.AddRoleForUserInDomain(tc.uid, tenantID, orgID, tc.role); got != tc.want {
+				t.Fatalf("AddRoleForUserInDomain(%d, %s) = %v, want %v", tc.uid, tc.role, got, tc.want)
+			}
+		})
+	}
+
+	if err := CheckExclusiveRoles(tenantID, nil, []string{"clerk", "auditor", "cashier"}); err != common.ErrRoleExclusive {
+		t.Fatalf("CheckExclusiveRoles batch = %v", err)
+	}
+	if err := CheckExclusiveRoles(tenantID, []string{"cashier"}, []string{"clerk"}); err != nil {
+		t.Fatalf("CheckExclusiveRoles unrelated = %v", err)
+	}
+
+	got, err := ExclusiveRoleViolations(tenantID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []protos.RoleViolation{{UID: legacyUID, OrgID: orgID, Roles: []string{"cashier", "auditor"}, Set: []string{"cashier", "auditor"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ExclusiveRoleViolations = %+v, want %+v", got, want)
+	}
+}
+
+func TestTenantUserSetRoles(t *testing.T) {
+	initServiceTest(t)
+	const tenantID = 20023
+	if _, err := common.DB.Exec(context.Background(), "INSERT INTO users (uid, tenant_id, password) VALUES (9, ?, '')", tenantID); err != nil {
+		t.Fatal(err)
+	}
+	cache.SetTenantCache(&protos.Tenant{ID: tenantID, Configuration: &protos.TenantConfiguration{
+		Roles:          []protos.RoleStruct{{RoleValue: "root"}, {RoleValue: "manager"}, {RoleValue: "clerk"}, {RoleValue: "auditor"}, {RoleValue: "cashier"}},
+		ExclusiveRoles: [][]string{{"clerk", "auditor"}},
+	}})
+
+	var orgs []uint64
+	for _, name := range []string{"store-a", "store-b"} {
+		org, err := OrgCreate(tenantID, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = TenantUserAdd(9, tenantID, org, nil, []string{"manager"}, 0); err != nil {
+			t.Fatal(err)
+		}
+		orgs = append(orgs, org)
+	}
+	// 只在 store-b 经继承授权持有 auditor
+	if err := OrgAddInheritedRole(9, tenantID, orgs[1], "auditor"); err != nil {
+		t.Fatal(err)
+	}
+
+	// store-b 中 clerk 与继承来的 auditor 冲突，store-a 也不能被改动
+	if err := TenantUserSetRoles(9, tenantID, []string{"clerk"}); err != common.ErrRoleExclusive {
+		t.Fatalf("TenantUserSetRoles conflict = %v", err)
+	}
+	for _, org := range orgs {
+		if got := accessctl.GetDirectRolesForUserInDomain(9, tenantID, org); !reflect.DeepEqual(got, []string{"manager"}) {
+			t.Fatalf("org %d roles after failed edit = %v", org, got)
+		}
+	}
+
+	if err := TenantUserSetRoles(9, tenantID, []string{" cashier ", "cashier"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, org := range orgs {
+		if got := accessctl.GetDirectRolesForUserInDomain(9, tenantID, org); !reflect.DeepEqual(got, []string{"cashier"}) {
+			t.Fatalf("org %d roles = %v", org, got)
+		}
+	}
+}
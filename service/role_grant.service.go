@@ -0,0 +1,208 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/liuhengloveyou/passport/v4/accessctl"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+var roleGrantJobOnce sync.Once
+
+// RoleGrantAdd 为用户授予组织内角色；notBefore/expiresAt 都为空时为永久授权。
+// 未到生效时间的授权只落库，由后台任务到点写入 casbin；过期后由后台任务移除。
+func RoleGrantAdd(uid, tenantID, orgID uint64, role string, notBefore, expiresAt *time.Time) error {
+	if uid == 0 || role == "" {
+		return common.ErrParam
+	}
+	if orgID == 0 {
+		return common.ErrOrgRequired
+	}
+
+	now := time.Now()
+	if notBefore == nil && expiresAt == nil {
+		// 改为永久授权时清掉原有期限
+		if _, err := dao.RoleGrantDelete(tenantID, orgID, uid, role); err != nil {
+			common.Logger.Sugar().Errorf("RoleGrantAdd dao.RoleGrantDelete ERR: %v\n", err)
+			return common.ErrService
+		}
+		return accessctl.AddRoleForUserInDomain(uid, tenantID, orgID, role)
+	}
+	if expiresAt != nil && (!expiresAt.After(now) || (notBefore != nil && !expiresAt.After(*notBefore))) {
+		common.Logger.Sugar().Errorf("RoleGrantAdd time ERR: %v %v %v\n", notBefore, expiresAt, now)
+		return common.ErrParam
+	}
+
+	if err := CheckExclusiveRoles(tenantID, accessctl.GetRoleForUserInDomain(uid, tenantID, orgID), []string{role}); err != nil {
+		return err
+	}
+
+	grant := &protos.RoleGrant{
+		TenantID:  tenantID,
+		OrgID:     orgID,
+		UID:       uid,
+		Role:      role,
+		NotBefore: notBefore,
+		ExpiresAt: expiresAt,
+		Active:    notBefore == nil || !notBefore.After(now),
+	}
+	if err := dao.RoleGrantUpsert(grant); err != nil {
+		common.Logger.Sugar().Errorf("RoleGrantAdd dao.RoleGrantUpsert ERR: %v\n", err)
+		return common.ErrService
+	}
+
+	if grant.Active {
+		return accessctl.AddRoleForUserInDomain(uid, tenantID, orgID, role)
+	}
+	// 尚未生效：若之前有永久授权需先收回
+	return accessctl.DeleteRoleForUserInDomain(uid, tenantID, orgID, role)
+}
+
+// RoleGrantRemove 收回组织内角色，同时删除限时授权记录。
+func RoleGrantRemove(uid, tenantID, orgID uint64, role string) error {
+	if orgID == 0 {
+		return common.ErrOrgRequired
+	}
+	if _, err := dao.RoleGrantDelete(tenantID, orgID, uid, role); err != nil {
+		common.Logger.Sugar().Errorf("RoleGrantRemove dao.RoleGrantDelete ERR: %v\n", err)
+		return common.ErrService
+	}
+	if err := accessctl.DeleteRoleForUserInDomain(uid, tenantID, orgID, role); err != nil {
+		common.Logger.Sugar().Errorf("RoleGrantRemove DeleteRoleForUserInDomain ERR: %v\n", err)
+		return common.ErrService
+	}
+	return nil
+}
+
+// RoleGrantReplace 把组织内角色 oldRole 换成 newRole；oldRole 是限时授权时 newRole 沿用同样的期限。
+// 已过期但后台任务尚未处理的授权不能替换。
+func RoleGrantReplace(uid, tenantID, orgID uint64, oldRole, newRole string) error {
+	if uid == 0 || oldRole == "" || newRole == "" {
+		return common.ErrParam
+	}
+	if orgID == 0 {
+		return common.ErrOrgRequired
+	}
+	grants, err := dao.RoleGrantListByUser(tenantID, orgID, uid)
+	if err != nil {
+		common.Logger.Sugar().Errorf("RoleGrantReplace dao.RoleGrantListByUser ERR: %v\n", err)
+		return common.ErrService
+	}
+	var notBefore, expiresAt *time.Time
+	for _, g := range grants {
+		if g.Role == oldRole {
+			if g.ExpiresAt != nil && !g.ExpiresAt.After(time.Now()) {
+				return common.ErrParam
+			}
+			notBefore, expiresAt = g.NotBefore, g.ExpiresAt
+			break
+		}
+	}
+
+	if err = RoleGrantRemove(uid, tenantID, orgID, oldRole); err != nil {
+		return err
+	}
+	if err = RoleGrantAdd(uid, tenantID, orgID, newRole, notBefore, expiresAt); err != nil {
+		// 新角色授予失败时恢复原授权
+		if e := RoleGrantAdd(uid, tenantID, orgID, oldRole, notBefore, expiresAt); e != nil {
+			common.Logger.Sugar().Errorf("RoleGrantReplace restore ERR: %v %v %v %v\n", uid, orgID, oldRole, e)
+		}
+		return err
+	}
+	return nil
+}
+
+// RoleGrantFill 为角色列表补充有效期；尚未生效的授权追加在末尾。
+func RoleGrantFill(uid, tenantID, orgID uint64, roles []protos.RoleStruct) []protos.RoleStruct {
+	grants, err := dao.RoleGrantListByUser(tenantID, orgID, uid)
+	if err != nil {
+		common.Logger.Sugar().Errorf("RoleGrantFill dao.RoleGrantListByUser ERR: %v\n", err)
+		return roles
+	}
+
+	now := time.Now()
+	for _, g := range grants {
+		i := 0
+		for ; i < len(roles); i++ {
+			if roles[i].RoleValue == g.Role {
+				break
+			}
+		}
+		if i == len(roles) {
+			if g.Active {
+				continue // casbin 中已被移除，等待后台任务清理
+			}
+			roles = append(roles, protos.RoleStruct{RoleValue: g.Role})
+		}
+		roles[i].NotBefore = g.NotBefore
+		roles[i].ExpiresAt = g.ExpiresAt
+		if g.ExpiresAt != nil && g.ExpiresAt.After(now) {
+			roles[i].RemainSeconds = int64(g.ExpiresAt.Sub(now).Seconds())
+		}
+	}
+
+	return roles
+}
+
+// RoleGrantSweep 处理到期的限时授权：过期的移除，到生效时间的写入 casbin。返回处理条数。
+func RoleGrantSweep(now time.Time) (n int) {
+	grants, err := dao.RoleGrantListDue(now)
+	if err != nil {
+		common.Logger.Sugar().Errorf("RoleGrantSweep dao.RoleGrantListDue ERR: %v\n", err)
+		return
+	}
+
+	for _, g := range grants {
+		if g.ExpiresAt != nil && !g.ExpiresAt.After(now) {
+			if err = accessctl.DeleteRoleForUserInDomain(g.UID, g.TenantID, g.OrgID, g.Role); err != nil {
+				common.Logger.Sugar().Errorf("RoleGrantSweep DeleteRoleForUserInDomain ERR: %v %v\n", g, err)
+				continue
+			}
+			if err = dao.RoleGrantDeleteByID(g.ID); err != nil {
+				common.Logger.Sugar().Errorf("RoleGrantSweep dao.RoleGrantDeleteByID ERR: %v %v\n", g.ID, err)
+				continue
+			}
+			common.Logger.Sugar().Infof("RoleGrantSweep expired: %v %v %v %v %v\n", g.TenantID, g.OrgID, g.UID, g.Role, g.ExpiresAt)
+			n++
+			continue
+		}
+
+		// 生效前用户已离开组织，授权作废
+		if err = UserInOrg(g.UID, g.TenantID, g.OrgID); err != nil {
+			_ = dao.RoleGrantDeleteByID(g.ID)
+			common.Logger.Sugar().Warnf("role grant dropped, user not in org: %v %v\n", g, err)
+			continue
+		}
+		if err = accessctl.AddRoleForUserInDomain(g.UID, g.TenantID, g.OrgID, g.Role); err != nil {
+			common.Logger.Sugar().Errorf("RoleGrantSweep AddRoleForUserInDomain ERR: %v %v\n", g, err)
+			continue
+		}
+		if err = dao.RoleGrantSetActive(g.ID); err != nil {
+			common.Logger.Sugar().Errorf("RoleGrantSweep dao.RoleGrantSetActive ERR: %v %v\n", g.ID, err)
+			continue
+		}
+		common.Logger.Sugar().Infof("RoleGrantSweep activated: %v %v %v %v %v\n", g.TenantID, g.OrgID, g.UID, g.Role, g.NotBefore)
+		n++
+	}
+
+	return
+}
+
+// StartRoleGrantJob 启动限时授权的后台处理任务，重复调用只启动一次。
+func StartRoleGrantJob(interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	roleGrantJobOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for now := range ticker.C {
+				RoleGrantSweep(now)
+			}
+		}()
+	})
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/liuhengloveyou/passport/v4/accessctl"
+	"github.com/liuhengloveyou/passport/v4/cache"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// initRoleGrantTest 租户 20021 的角色字典为 clerk、manager、auditor；用户 3 在组织 30021 持有 clerk，用户 9 只是租户成员。
+func initRoleGrantTest(t *testing.T) (tenantID, orgID uint64) {
+	t.Helper()
+	db := initServiceTest(t)
+
+	tenantID, orgID = 20021, 30021
+	if _, err := db.Exec(context.Background(), "INSERT INTO users (uid, tenant_id, password) VALUES (3, ?, ''), (9, ?, '')", tenantID, tenantID); err != nil {
+		t.Fatal(err)
+	}
+	cache.SetTenantCache(&protos.Tenant{ID: tenantID, Configuration: &protos.TenantConfiguration{Roles: []protos.RoleStruct{
+		{RoleValue: "clerk"},
+		{RoleValue: "manager"},
+		{RoleValue: "auditor"},
+	}}})
+	if err := accessctl.AddRoleForUserInDomain(3, tenantID, orgID, "clerk"); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestRoleGrantAdd(t *testing.T) {
+	tid, org := initRoleGrantTest(t)
+	now := time.Now()
+	later, past := now.Add(time.Hour), now.Add(-time.Hour)
+
+	if err := RoleGrantAdd(3, tid, org, "auditor", nil, &past); err != common.ErrParam {
+		t.Fatalf("expired grant = %v", err)
+	}
+	if err := RoleGrantAdd(3, tid, org, "auditor", &later, nil); err != nil {
+		t.Fatal(err)
+	}
+	if containsString(accessctl.GetDirectRolesForUserInDomain(3, tid, org), "auditor") {
+		t.Fatal("pending grant written to casbin")
+	}
+	grants, err := dao.RoleGrantListByUser(tid, org, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grants) != 1 || grants[0].Active {
+		t.Fatalf("grants = %+v", grants)
+	}
+
+	// 改为永久授权清掉期限
+	if err = RoleGrantAdd(3, tid, org, "auditor", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !containsString(accessctl.GetDirectRolesForUserInDomain(3, tid, org), "auditor") {
+		t.Fatal("permanent grant missing")
+	}
+	if grants, _ = dao.RoleGrantListByUser(tid, org, 3); len(grants) != 0 {
+		t.Fatalf("grants after permanent = %+v", grants)
+	}
+}
+
+func TestRoleGrantSweep(t *testing.T) {
+	tid, _ := initRoleGrantTest(t)
+	org, err := OrgCreate(tid, "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = TenantUserAdd(3, tid, org, nil, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	soon, later := now.Add(time.Hour), now.Add(3*time.Hour)
+
+	if err = RoleGrantAdd(3, tid, org, "auditor", &soon, &later); err != nil {
+		t.Fatal(err)
+	}
+	if err = RoleGrantAdd(3, tid, org, "manager", nil, &soon); err != nil {
+		t.Fatal(err)
+	}
+	// 用户 9 不在组织中，生效时授权作废
+	if err = RoleGrantAdd(9, tid, org, "auditor", &soon, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := RoleGrantSweep(now.Add(2 * time.Hour)); n != 2 {
+		t.Fatalf("sweep = %d", n)
+	}
+	roles := accessctl.GetDirectRolesForUserInDomain(3, tid, org)
+	if !containsString(roles, "auditor") || containsString(roles, "manager") {
+		t.Fatalf("roles after sweep = %v", roles)
+	}
+	if roles = accessctl.GetDirectRolesForUserInDomain(9, tid, org); len(roles) != 0 {
+		t.Fatalf("outsider roles = %v", roles)
+	}
+	grants, err := dao.RoleGrantListByTenant(tid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grants) != 1 || grants[0].Role != "auditor" || grants[0].UID != 3 || !grants[0].Active {
+		t.Fatalf("grants after sweep = %+v", grants)
+	}
+}
+
+func TestRoleGrantFill(t *testing.T) {
+	tid, org := initRoleGrantTest(t)
+	now := time.Now()
+	soon, later := now.Add(time.Hour), now.Add(3*time.Hour)
+
+	if err := RoleGrantAdd(3, tid, org, "auditor", nil, &later); err != nil {
+		t.Fatal(err)
+	}
+	if err := RoleGrantAdd(3, tid, org, "manager", &soon, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	roles := make([]protos.RoleStruct, 0)
+	for _, role := range accessctl.GetDirectRolesForUserInDomain(3, tid, org) {
+		roles = append(roles, protos.RoleStruct{RoleValue: role})
+	}
+	roles = RoleGrantFill(3, tid, org, roles)
+	if len(roles) != 3 {
+		t.Fatalf("roles = %+v", roles)
+	}
+	for _, r := range roles {
+		switch r.RoleValue {
+		case "clerk":
+			if r.ExpiresAt != nil || r.NotBefore != nil {
+				t.Fatalf("permanent role = %+v", r)
+			}
+		case "auditor":
+			if r.ExpiresAt == nil || r.RemainSeconds <= 0 {
+				t.Fatalf("limited role = %+v", r)
+			}
+		case "manager":
+			if r.NotBefore == nil {
+				t.Fatalf("pending role = %+v", r)
+			}
+		default:
+			t.Fatalf("unexpected role %+v", r)
+		}
+	}
+}
+
+func TestRoleGrantReplace(t *testing.T) {
+	tid, org := initRoleGrantTest(t)
+	later := time.Now().Add(time.Hour)
+
+	if err := RoleGrantAdd(3, tid, org, "auditor", nil, &later); err != nil {
+		t.Fatal(err)
+	}
+	if err := RoleGrantReplace(3, tid, org, "auditor", "manager"); err != nil {
+		t.Fatal(err)
+	}
+	roles := accessctl.GetDirectRolesForUserInDomain(3, tid, org)
+	if containsString(roles, "auditor") || !containsString(roles, "manager") {
+		t.Fatalf("roles = %v", roles)
+	}
+	grants, err := dao.RoleGrantListByUser(tid, org, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grants) != 1 || grants[0].Role != "manager" || grants[0].ExpiresAt == nil || !grants[0].ExpiresAt.Equal(later) {
+		t.Fatalf("grants = %+v", grants)
+	}
+
+	// 永久授权替换后仍是永久
+	if err = RoleGrantReplace(3, tid, org, "clerk", "auditor"); err != nil {
+		t.Fatal(err)
+	}
+	if grants, _ = dao.RoleGrantListByUser(tid, org, 3); len(grants) != 1 || grants[0].Role != "manager" {
+		t.Fatalf("grants after permanent replace = %+v", grants)
+	}
+}
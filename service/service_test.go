@@ -0,0 +1,47 @@
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/liuhengloveyou/passport/v4/accessctl"
+	"github.com/liuhengloveyou/passport/v4/cache"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/database"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// casbinDBSeq 区分同名测试的多次运行（-count），上一次的 casbin 内存库仍被旧连接持有
+var casbinDBSeq atomic.Int64
+
+// initServiceTest 每个测试独立的业务库（临时目录下的 sqlite 文件）与 casbin 内存库（按 t.Name() 命名），
+// 并清空进程内缓存；只建表，不写任何数据。
+func initServiceTest(t *testing.T) database.DB {
+	t.Helper()
+	common.Logger = zap.NewNop()
+	cache.Clear()
+	t.Cleanup(cache.Clear)
+
+	dsn := filepath.Join(t.TempDir(), "passport.db")
+	if err := dao.Init(&protos.OptionStruct{DBDriver: string(database.DriverSQLite3), DBDSN: dsn}); err != nil {
+		t.Fatalf("dao.Init: %v", err)
+	}
+	db, err := database.NewSQLite3DB(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	common.DB = db
+
+	casbinDSN := fmt.Sprintf("file:%s-%d?mode=memory&cache=shared", strings.NewReplacer("/", "_", " ", "_").Replace(t.Name()), casbinDBSeq.Add(1))
+	if err := accessctl.InitAccessControl("../rbac_with_domains_model.conf", "sqlite3", casbinDSN); err != nil {
+		t.Fatalf("InitAccessControl: %v", err)
+	}
+	return db
+}
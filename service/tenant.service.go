@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/liuhengloveyou/passport/v4/accessctl"
 	"github.com/liuhengloveyou/passport/v4/cache"
@@ -16,6 +17,8 @@ func evictTenantCache(tenantIDs ...uint64) {
 	for _, id := range tenantIDs {
 		if id > 0 {
 			cache.DelTenantCache(id)
+			// 组织生效配置包含租户配置
+			cache.DelTenantOrgConfigCache(id)
 		}
 	}
 }
@@ -109,10 +112,8 @@ func TenantAdd(m *protos.Tenant) (tenantID uint64, e error) {
 	return
 }
 
-// TenantAddRole 为指定租户新增角色定义。
-func TenantAddRole(tenantId uint64, role protos.RoleStruct) error {
-	defer evictTenantCache(tenantId)
-
+// TenantAddRole 为指定租户新增角色定义，uid 为操作人，记入配置版本。
+func TenantAddRole(tenantId, uid uint64, role protos.RoleStruct) error {
 	tenant, err := getTenantByIDCached(tenantId)
 	if err != nil {
 		common.Logger.Sugar().Errorf("TenantAddRole db ERR: %v\n", err)
@@ -123,52 +124,70 @@ func TenantAddRole(tenantId uint64, role protos.RoleStruct) error {
 		return common.ErrTenantNotFound
 	}
 
-	if len(tenant.Configuration.Roles) > 100 {
-		common.Logger.Sugar().Errorf("TenantAddRole Configuration.Roles to long: %v\n", len(tenant.Configuration.Roles))
+	// 先按缓存的配置预检，写入时再按库里最新的配置校验一次
+	if tenant.Configuration != nil {
+		if err = checkAddRole(tenant.Configuration.Roles, role); err != nil {
+			return err
+		}
+	}
+
+	release, err := reserveQuota(tenantId, protos.QuotaRoles)
+	if err != nil {
+		return err
+	}
+	_, err = saveTenantConfiguration(tenantId, uid, nil, "新增角色 "+role.RoleValue, func(conf *protos.TenantConfiguration) error {
+		if err := checkAddRole(conf.Roles, role); err != nil {
+			return err
+		}
+		conf.Roles = append(conf.Roles, role)
+		return nil
+	})
+	if err != nil {
+		release()
+		return err
+	}
+	return nil
+}
+
+func checkAddRole(roles []protos.RoleStruct, role protos.RoleStruct) error {
+	if len(roles) > 100 {
+		common.Logger.Sugar().Errorf("TenantAddRole Configuration.Roles to long: %v\n", len(roles))
 		return common.ErrService
 	}
-	for i := 0; i < len(tenant.Configuration.Roles); i++ {
-		if tenant.Configuration.Roles[i].RoleTitle == role.RoleTitle || tenant.Configuration.Roles[i].RoleValue == role.RoleValue {
-			common.Logger.Sugar().Errorf("TenantAddRole dup: %v %v\n", role, tenant.Configuration.Roles[i])
+	for i := 0; i < len(roles); i++ {
+		if roles[i].RoleTitle == role.RoleTitle || roles[i].RoleValue == role.RoleValue {
+			common.Logger.Sugar().Errorf("TenantAddRole dup: %v %v\n", role, roles[i])
 			return common.ErrPgDupKey
 		}
 	}
-
-	tenant.Configuration.Roles = append(tenant.Configuration.Roles, role)
-
-	return dao.TenantUpdateConfiguration(tenant)
+	return nil
 }
 
-// TenantDelRole 删除指定租户的角色定义（不允许删除 root 角色）。
-func TenantDelRole(tenantId uint64, role protos.RoleStruct) error {
-	defer evictTenantCache(tenantId)
-
+// TenantDelRole 删除指定租户的角色定义（不允许删除 root 角色），uid 为操作人，记入配置版本。
+func TenantDelRole(tenantId, uid uint64, role protos.RoleStruct) error {
 	common.Logger.Sugar().Debugf("TenantDelRole: %v\n", role)
 	if role.RoleValue == "root" {
 		common.Logger.Sugar().Errorf("TenantDelRole root\n")
 		return common.ErrService
 	}
-	tenant, err := getTenantByIDCached(tenantId)
-	if err != nil {
-		common.Logger.Sugar().Errorf("TenantDelRole db ERR: %v\n", err)
-		return common.ErrService
-	}
-	common.Logger.Sugar().Infof("tenant: %v\n", tenant)
-	if nil == tenant {
-		return common.ErrTenantNotFound
-	}
-	common.Logger.Sugar().Infof("tenant: %v %v\n", role, tenant.Configuration.Roles)
 
-	i := 0
-	for ; i < len(tenant.Configuration.Roles); i++ {
-		if tenant.Configuration.Roles[i].RoleValue == role.RoleValue {
-			break
+	deleted := false
+	_, err := saveTenantConfiguration(tenantId, uid, nil, "删除角色 "+role.RoleValue, func(conf *protos.TenantConfiguration) error {
+		for i := 0; i < len(conf.Roles); i++ {
+			if conf.Roles[i].RoleValue == role.RoleValue {
+				conf.Roles = append(conf.Roles[:i], conf.Roles[i+1:]...)
+				deleted = true
+				break
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		common.Logger.Sugar().Errorf("TenantDelRole ERR: %v %v %v\n", tenantId, role, err)
+		return err
 	}
-
-	if i < len(tenant.Configuration.Roles) {
-		tenant.Configuration.Roles = append(tenant.Configuration.Roles[:i], tenant.Configuration.Roles[i+1:]...)
-		return dao.TenantUpdateConfiguration(tenant)
+	if deleted {
+		releaseQuota(tenantId, protos.QuotaRoles, 1)
 	}
 
 	return nil
@@ -181,7 +200,7 @@ func TenantGetRole(tenantId uint64) (roles []protos.RoleStruct) {
 		common.Logger.Sugar().Errorf("TenantAddRole db ERR: %v\n", err)
 		return
 	}
-	if nil == tenant {
+	if nil == tenant || nil == tenant.Configuration {
 		return
 	}
 
@@ -209,10 +228,9 @@ func TenantLoadConfiguration(tenantId uint64, key string) (interface{}, error) {
 	return tenant.Configuration.More, nil
 }
 
-// TenantUpdateConfiguration 增量更新租户配置键值。
-func TenantUpdateConfiguration(tenantId uint64, data map[string]interface{}) error {
-	defer evictTenantCache(tenantId)
-
+// TenantUpdateConfiguration 增量更新租户配置键值，值为 nil 时删除该键；uid 为操作人，记入配置版本。
+// lastUpdateTime 非空时做乐观锁，与配置当前的更新时间不一致返回 ErrModify。
+func TenantUpdateConfiguration(tenantId, uid uint64, lastUpdateTime string, data map[string]interface{}) error {
 	if len(data) <= 0 || len(data) > 100 {
 		common.Logger.Sugar().Error("UpdateTenantConfiguration param len ERR: ", len(data))
 		return common.ErrParam
@@ -224,35 +242,33 @@ func TenantUpdateConfiguration(tenantId uint64, data map[string]interface{}) err
 		}
 	}
 
-	tenant, err := getTenantByIDCached(tenantId)
-	if err != nil {
-		common.Logger.Sugar().Errorf("TenantUpdateConfiguration db ERR: %v\n", err)
-		return common.ErrService
-	}
-	if nil == tenant {
-		return common.ErrTenantNotFound
-	}
-
-	if tenant.Configuration == nil {
-		tenant.Configuration = &protos.TenantConfiguration{}
-	}
-	if tenant.Configuration.More == nil {
-		tenant.Configuration.More = make(protos.MapStruct, 1)
+	var expect *time.Time
+	if lastUpdateTime != "" {
+		t, err := parseLastUpdateTime(lastUpdateTime)
+		if err != nil {
+			return err
+		}
+		expect = t
 	}
 
-	for k, v := range data {
-		if v != nil {
-			if len(tenant.Configuration.More) > 100 {
-				common.Logger.Sugar().Errorf("tenant.Configuration.More too len: %d\n", len(tenant.Configuration.More))
-				return common.ErrService
+	_, err := saveTenantConfiguration(tenantId, uid, expect, "", func(conf *protos.TenantConfiguration) error {
+		if conf.More == nil {
+			conf.More = make(protos.MapStruct, 1)
+		}
+		for k, v := range data {
+			if v != nil {
+				if len(conf.More) > 100 {
+					common.Logger.Sugar().Errorf("tenant.Configuration.More too len: %d\n", len(conf.More))
+					return common.ErrService
+				}
+				conf.More[k] = v
+			} else {
+				delete(conf.More, k)
 			}
-			tenant.Configuration.More[k] = v
-		} else {
-			delete(tenant.Configuration.More, k)
 		}
-	}
-
-	return dao.TenantUpdateConfiguration(tenant)
+		return nil
+	})
+	return err
 }
 
 // getTenantUserRoles 获取租户内用户角色并补全角色标题信息。
@@ -1,32 +1,71 @@
 package service
 
 import (
+	"strings"
+
 	"github.com/liuhengloveyou/passport/v4/accessctl"
+	"github.com/liuhengloveyou/passport/v4/cache"
 	"github.com/liuhengloveyou/passport/v4/common"
 	"github.com/liuhengloveyou/passport/v4/dao"
 	"github.com/liuhengloveyou/passport/v4/protos"
 	"go.uber.org/zap"
 )
 
+// TenantBindUser 平台管理员把账号加入租户，账号可以同时属于多个租户。
 func TenantBindUser(uid, currTenantID uint64) error {
 	if uid == 0 || currTenantID == 0 {
 		return common.ErrParam
 	}
-	row, e := dao.UserUpdateTenantID(uid, currTenantID, 0)
+	added, e := addTenantMember(uid, currTenantID)
 	if e != nil {
-		return common.ErrService
+		return e
 	}
-	if row == 1 {
-		return nil
+	if added {
+		// 平台管理员绑定不受成员配额限制，但要计入用量
+		if e = dao.TenantQuotaAdjust(currTenantID, protos.QuotaMembers, 1); e != nil {
+			common.Logger.Sugar().Errorf("TenantBindUser quota ERR: %v", e)
+		}
 	}
-	userInfo, qErr := dao.UserQueryByID(uid)
-	if qErr != nil || userInfo == nil {
-		return common.ErrService
+	return nil
+}
+
+// TenantAdminUserAdd 新建账号作为租户管理员：加入租户并在租户所有组织内绑定 root 角色。调用方负责鉴权。
+func TenantAdminUserAdd(req *protos.AdminUserAddReq) (uid uint64, e error) {
+	if req.TenantID == 0 || req.TenantID == common.ServConfig.RootTenantID {
+		return 0, common.ErrParam
 	}
-	if userInfo.TenantID != currTenantID {
-		return common.ErrService
+	if req.Nickname == "" || req.Password == "" {
+		return 0, common.ErrParam
 	}
-	return nil
+	if _, e = AdminTenantTake(req.TenantID); e != nil {
+		return 0, e
+	}
+
+	ext := req.Ext
+	if ext == nil {
+		ext = protos.MapStruct{}
+	}
+	uid, e = AddUserService(&protos.UserReq{
+		Nickname:  req.Nickname,
+		Password:  req.Password,
+		Cellphone: req.Cellphone,
+		Email:     req.Email,
+		Roles:     []string{"root"},
+		Ext:       ext,
+	})
+	if e != nil {
+		return 0, e
+	}
+	if uid <= 0 {
+		return 0, common.ErrService
+	}
+	if e = TenantBindUser(uid, req.TenantID); e != nil {
+		return 0, e
+	}
+	if e = AddRoleForUserInAllOrgs(uid, req.TenantID, "root"); e != nil {
+		return 0, e
+	}
+	return uid, nil
 }
 
 func TenantUserAdd(uid, currTenantID, orgID uint64, depIds []uint64, roles []string, disable protos.UserDisableStatus) (e error) {
@@ -36,23 +75,27 @@ func TenantUserAdd(uid, currTenantID, orgID uint64, depIds []uint64, roles []str
 	if _, e = RequireOrg(currTenantID, orgID); e != nil {
 		return e
 	}
-
-	row, e := dao.UserUpdateTenantID(uid, currTenantID, 0)
-	if e != nil {
-		common.Logger.Sugar().Error("TenantUserAdd db ERR: ", e)
-		return common.ErrService
+	// 先整体校验职责分离，避免加入组织后角色授予到一半失败
+	if e = CheckExclusiveRoles(currTenantID, accessctl.GetRoleForUserInDomain(uid, currTenantID, orgID), roles); e != nil {
+		return e
 	}
-	if row != 1 {
-		userInfo, qErr := dao.UserQueryByID(uid)
-		if qErr != nil || userInfo == nil {
-			common.Logger.Sugar().Error("TenantUserAdd UserUpdateTenantID query ERR: ", row, qErr)
-			return common.ErrService
+
+	// 已是本租户成员时不占用新的成员配额
+	if e = UserInTenant(uid, currTenantID); e == common.ErrNoAuth {
+		release, err := reserveQuota(currTenantID, protos.QuotaMembers)
+		if err != nil {
+			return err
 		}
-		if userInfo.TenantID != currTenantID {
-			common.Logger.Sugar().Error("TenantUserAdd UserUpdateTenantID tenant mismatch: ", row, userInfo.TenantID, currTenantID)
-			return common.ErrService
+		added, err := addTenantMember(uid, currTenantID)
+		if err != nil || !added {
+			release()
 		}
-		common.Logger.Sugar().Warnf("TenantUserAdd UserUpdateTenantID skipped: uid=%d already in tenant=%d", uid, currTenantID)
+		if err != nil {
+			common.Logger.Sugar().Error("TenantUserAdd addTenantMember ERR: ", err)
+			return err
+		}
+	} else if e != nil {
+		return e
 	}
 
 	if e = OrgAddMember(orgID, uid, currTenantID); e != nil {
@@ -62,6 +105,9 @@ func TenantUserAdd(uid, currTenantID, orgID uint64, depIds []uint64, roles []str
 	for _, role := range roles {
 		if e = accessctl.AddRoleForUserInDomain(uid, currTenantID, orgID, role); e != nil {
 			common.Logger.Sugar().Errorf("TenantUserAdd AddRoleForUserInDomain ERR: %v", e)
+			if e == common.ErrRoleExclusive {
+				return e
+			}
 			return common.ErrService
 		}
 	}
@@ -92,10 +138,11 @@ func TenantUserDel(uid, currTenantID uint64) (r int64, e error) {
 		}
 	}
 
-	if r, e = dao.UserDelete(uid, currTenantID); e != nil {
+	if r, e = removeTenantMember(uid, currTenantID); e != nil {
 		common.Logger.Sugar().Errorf("TenantUserDel ERR: %v", e)
 		return 0, common.ErrService
 	}
+	releaseQuota(currTenantID, protos.QuotaMembers, r)
 
 	common.Logger.Warn("TenantUserDel: ", zap.Uint64("uid", uid), zap.Uint64("tid", currTenantID), zap.Int64("r", r), zap.Any("e", e))
 
@@ -154,7 +201,34 @@ func TenantUserGet(tenantID, orgID, page, pageSize uint64, nickname string, uids
 		depSet[departments[j].Id] = departments[j]
 	}
 
+	memberUIDs := make([]uint64, len(rr))
+	for i := range rr {
+		memberUIDs[i] = rr[i].UID
+	}
+	members, err := dao.TenantMemberList(tenantID, memberUIDs)
+	if err != nil {
+		e = common.ErrService
+		return
+	}
+	memberSet := make(map[uint64]protos.TenantMember, len(members))
+	for _, m := range members {
+		memberSet[m.UID] = m
+	}
+
 	for i := 0; i < len(rr); i++ {
+		// 租户内的扩展信息和停用状态叠加到 ext 上返回
+		if m, ok := memberSet[rr[i].UID]; ok {
+			if rr[i].Ext == nil {
+				rr[i].Ext = protos.MapStruct{}
+			}
+			for k, v := range m.Ext {
+				rr[i].Ext[k] = v
+			}
+			if m.Disabled != 0 {
+				rr[i].Ext[protos.UserDisabledExtKey] = m.Disabled
+			}
+		}
+
 		if rr[i].Roles, e = getTenantUserRoles(rr[i].UID, rr[i].TenantID, orgID); e != nil {
 			common.Logger.Sugar().Warnf("TenantUserGet getTenantUserRole ERR: %v", e)
 		}
@@ -178,6 +252,96 @@ func TenantUserGet(tenantID, orgID, page, pageSize uint64, nickname string, uids
 	return
 }
 
+// TenantUserSetRoles 把用户在租户所有组织中直接持有的角色整体替换为 roles。
+// 先按每个组织替换后实际生效的角色（含租户级角色与继承授权）做职责分离校验，全部通过才修改；
+// 修改中途失败时撤回已做的变更。
+func TenantUserSetRoles(uid, tenantID uint64, roles []string) (err error) {
+	newRoles := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if role = strings.TrimSpace(role); role != "" && !containsString(newRoles, role) {
+			newRoles = append(newRoles, role)
+		}
+	}
+	if err = CheckExclusiveRoles(tenantID, nil, newRoles); err != nil {
+		return err
+	}
+	orgs, err := OrgListByTenant(tenantID)
+	if err != nil {
+		return err
+	}
+
+	type orgChange struct {
+		orgID       uint64
+		add, remove []string
+	}
+	changes := make([]orgChange, 0, len(orgs))
+	for i := range orgs {
+		c := orgChange{orgID: orgs[i].ID}
+		direct := accessctl.GetDirectRolesForUserInDomain(uid, tenantID, c.orgID)
+		for _, role := range direct {
+			if !containsString(newRoles, role) {
+				c.remove = append(c.remove, role)
+			}
+		}
+		held := make([]string, 0)
+		for _, role := range accessctl.GetRoleForUserInDomain(uid, tenantID, c.orgID) {
+			if !containsString(c.remove, role) {
+				held = append(held, role)
+			}
+		}
+		for _, role := range newRoles {
+			if !containsString(direct, role) {
+				c.add = append(c.add, role)
+			}
+		}
+		if err = CheckExclusiveRoles(tenantID, held, c.add); err != nil {
+			return err
+		}
+		changes = append(changes, c)
+	}
+
+	var undo []func()
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}()
+	for _, c := range changes {
+		orgID := c.orgID
+		for _, role := range c.remove {
+			if err = accessctl.DeleteRoleForUserInDomain(uid, tenantID, orgID, role); err != nil {
+				common.Logger.Sugar().Errorf("TenantUserSetRoles DeleteRoleForUserInDomain ERR: %v %v %v %v", uid, orgID, role, err)
+				return common.ErrService
+			}
+			role := role
+			undo = append(undo, func() {
+				if err := accessctl.AddRoleForUserInDomain(uid, tenantID, orgID, role); err != nil {
+					common.Logger.Sugar().Errorf("TenantUserSetRoles undo delete ERR: %v %v %v %v", uid, orgID, role, err)
+				}
+			})
+		}
+		for _, role := range c.add {
+			if err = accessctl.AddRoleForUserInDomain(uid, tenantID, orgID, role); err != nil {
+				common.Logger.Sugar().Errorf("TenantUserSetRoles AddRoleForUserInDomain ERR: %v %v %v %v", uid, orgID, role, err)
+				if err != common.ErrRoleExclusive {
+					err = common.ErrService
+				}
+				return err
+			}
+			role := role
+			undo = append(undo, func() {
+				if err := accessctl.DeleteRoleForUserInDomain(uid, tenantID, orgID, role); err != nil {
+					common.Logger.Sugar().Errorf("TenantUserSetRoles undo add ERR: %v %v %v %v", uid, orgID, role, err)
+				}
+			})
+		}
+	}
+	return nil
+}
+
 func TenantUserDisabledService(uid, currTenantID uint64, disabled protos.UserDisableStatus) (e error) {
 	if uid <= 0 {
 		common.Logger.Sugar().Errorf("TenantUserDisabledService ERR: %d %v %v", uid, currTenantID, disabled)
@@ -188,7 +352,33 @@ func TenantUserDisabledService(uid, currTenantID uint64, disabled protos.UserDis
 		return common.ErrParam
 	}
 
-	return TenantUpdateUserExt(uid, currTenantID, "disabled", int8(disabled))
+	if e = UserInTenant(uid, currTenantID); e != nil {
+		common.Logger.Sugar().Errorf("TenantUserDisabledService tenant ERR: %v %v %v", uid, currTenantID, e)
+		return e
+	}
+
+	// 停用只作用于账号在该租户的成员身份，不影响账号在其他租户登录
+	if e = dao.TenantMemberSetDisabled(currTenantID, uid, int8(disabled)); e != nil {
+		return common.ErrService
+	}
+	cache.SetTenantMemberDisabledCache(currTenantID, uid, disabled == protos.UserDisabled)
+
+	// 早期数据把停用写在 users.ext（账号级），由默认租户改写时一并清掉，之后以成员状态为准
+	userInfo, e := dao.UserQueryByID(uid)
+	if e != nil || userInfo == nil {
+		common.Logger.Sugar().Errorf("TenantUserDisabledService query user ERR: %v %v", uid, e)
+		return common.ErrService
+	}
+	if _, legacy := userInfo.Ext[protos.UserDisabledExtKey]; legacy && userInfo.TenantID == currTenantID {
+		delete(userInfo.Ext, protos.UserDisabledExtKey)
+		if _, e = dao.UserUpdateExt(uid, &userInfo.Ext); e != nil {
+			common.Logger.Sugar().Errorf("TenantUserDisabledService clear legacy ERR: %v %v", uid, e)
+			return common.ErrService
+		}
+	}
+
+	common.Logger.Sugar().Infof("TenantUserDisabledService: %v %v %v", uid, currTenantID, disabled)
+	return nil
 }
 
 func TenantUserSetDepartment(uid, tenantId, orgID uint64, departmentIds []uint64) error {
@@ -221,8 +411,8 @@ func TenantUserSetDepartment(uid, tenantId, orgID uint64, departmentIds []uint64
 	if e != nil || userInfo == nil {
 		return common.ErrNull
 	}
-	if userInfo.TenantID != tenantId {
-		return common.ErrNoAuth
+	if e = UserInTenant(uid, tenantId); e != nil {
+		return e
 	}
 
 	kept := make([]uint64, 0)
@@ -244,15 +434,20 @@ func TenantUserSetDepartment(uid, tenantId, orgID uint64, departmentIds []uint64
 	return TenantUpdateUserExt(uid, tenantId, "deps", kept)
 }
 
+// TenantUpdateUserExt 修改账号在租户内的扩展信息，记在 tenant_members.ext。
+// 部门（deps）的部门 ID 全局唯一、按组织分段替换，仍记在 users.ext；停用状态走 TenantUserDisabledService。
 func TenantUpdateUserExt(uid, currTenantID uint64, k string, v interface{}) error {
 	if uid <= 0 {
 		common.Logger.Sugar().Errorf("TenantUpdateUserExt ERR: %d %v %v", uid, k, v)
 		return common.ErrParam
 	}
-	if k == "" {
+	if k == "" || k == protos.UserDisabledExtKey {
 		common.Logger.Sugar().Errorf("TenantUpdateUserExt ERR: %d %v %v", uid, k, v)
 		return common.ErrParam
 	}
+	if k != protos.DepartmentExtKey {
+		return tenantMemberUpdateExt(uid, currTenantID, k, v)
+	}
 
 	userInfo, e := dao.UserQueryByID(uid)
 	if e != nil {
@@ -260,9 +455,12 @@ func TenantUpdateUserExt(uid, currTenantID uint64, k string, v interface{}) erro
 		return common.ErrNull
 	}
 
-	if userInfo.TenantID != currTenantID {
-		common.Logger.Sugar().Errorf("TenantUpdateUserExt tenant ERR: %v %v %v", uid, currTenantID, userInfo)
-		return common.ErrNoAuth
+	if userInfo == nil {
+		return common.ErrNull
+	}
+	if e = UserInTenant(uid, currTenantID); e != nil {
+		common.Logger.Sugar().Errorf("TenantUpdateUserExt tenant ERR: %v %v %v", uid, currTenantID, e)
+		return e
 	}
 
 	common.Logger.Sugar().Infof("TenantUpdateUserExt: %v %v %v %v", uid, currTenantID, k, v)
@@ -287,3 +485,30 @@ func TenantUpdateUserExt(uid, currTenantID uint64, k string, v interface{}) erro
 
 	return nil
 }
+
+func tenantMemberUpdateExt(uid, tenantID uint64, k string, v interface{}) error {
+	if e := UserInTenant(uid, tenantID); e != nil {
+		common.Logger.Sugar().Errorf("TenantUpdateUserExt tenant ERR: %v %v %v", uid, tenantID, e)
+		return e
+	}
+	member, e := dao.TenantMemberGet(tenantID, uid)
+	if e != nil {
+		return common.ErrService
+	}
+
+	ext := protos.MapStruct{}
+	if member != nil && member.Ext != nil {
+		ext = member.Ext
+	}
+	common.Logger.Sugar().Infof("TenantUpdateUserExt: %v %v %v %v", uid, tenantID, k, v)
+	if v == nil {
+		delete(ext, k)
+	} else {
+		ext[k] = v
+	}
+
+	if e = dao.TenantMemberSetExt(tenantID, uid, ext); e != nil {
+		return common.ErrService
+	}
+	return nil
+}
@@ -0,0 +1,392 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/liuhengloveyou/passport/v4/accessctl"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// saveTenantConfiguration 修改租户配置并记一个版本，配置与版本在同一事务中写入。
+// expect 非空时做乐观锁：与库里的 update_time 不一致返回 ErrModify。
+// change 在事务内修改读到的最新配置，不能再访问数据库（SQLite 只有一个连接）；返回的错误原样透出。
+// 配置没有变化时不写库，返回的版本为 nil。
+func saveTenantConfiguration(tenantID, authorUID uint64, expect *time.Time, remark string, change func(conf *protos.TenantConfiguration) error) (ver *protos.TenantConfigVersion, err error) {
+	defer evictTenantCache(tenantID)
+
+	ctx := context.Background()
+	tx, err := common.DB.Begin(ctx)
+	if err != nil {
+		common.Logger.Sugar().Errorf("saveTenantConfiguration Begin ERR: %v\n", err)
+		return nil, common.ErrService
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	conf, updateTime, found, err := dao.TenantConfigurationForUpdate(tx, tenantID)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	if !found {
+		return nil, common.ErrTenantNotFound
+	}
+	if expect != nil && !sameUpdateTime(expect, updateTime) {
+		common.Logger.Sugar().Warnf("saveTenantConfiguration modified: tenant %d expect %v got %v", tenantID, expect, updateTime)
+		return nil, common.ErrModify
+	}
+
+	old, err := cloneConfiguration(conf)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	if err = change(conf); err != nil {
+		return nil, err
+	}
+	diff, err := diffConfiguration(old, conf)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	if len(diff) == 0 {
+		tx.Rollback(ctx)
+		return nil, nil
+	}
+
+	now := time.Now()
+	if err = dao.TenantConfigurationSet(tx, tenantID, conf, now); err != nil {
+		return nil, common.ErrService
+	}
+
+	last, err := dao.TenantConfigVersionLast(tx, tenantID)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	if last == 0 {
+		// 第一次修改前的配置记为版本 1，这样也能回滚到最初的配置
+		base := &protos.TenantConfigVersion{TenantID: tenantID, Version: 1, Configuration: old, Diff: []protos.ConfigChange{}, Remark: "初始版本", CreateTime: updateTime}
+		if base.CreateTime == nil {
+			base.CreateTime = &now
+		}
+		if err = dao.TenantConfigVersionInsert(tx, base); err != nil {
+			return nil, common.ErrService
+		}
+		last = 1
+	}
+
+	ver = &protos.TenantConfigVersion{
+		TenantID:      tenantID,
+		Version:       last + 1,
+		Configuration: conf,
+		Diff:          diff,
+		AuthorUID:     authorUID,
+		Remark:        remark,
+		CreateTime:    &now,
+	}
+	if err = dao.TenantConfigVersionInsert(tx, ver); err != nil {
+		return nil, common.ErrService
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		common.Logger.Sugar().Errorf("saveTenantConfiguration Commit ERR: %v\n", err)
+		return nil, common.ErrService
+	}
+
+	common.Logger.Sugar().Infof("saveTenantConfiguration: user %d saved tenant %d configuration version %d", authorUID, tenantID, ver.Version)
+	return ver, nil
+}
+
+// parseLastUpdateTime 解析客户端提交的 last_update_time（RFC3339）
+func parseLastUpdateTime(s string) (*time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		common.Logger.Sugar().Errorf("解析更新时间失败: %v", err)
+		return nil, common.ErrParam
+	}
+	return &t, nil
+}
+
+// sameUpdateTime 按微秒比较更新时间：PostgreSQL 只保存到微秒
+func sameUpdateTime(expect, got *time.Time) bool {
+	if got == nil {
+		return false
+	}
+	return expect.Truncate(time.Microsecond).Equal(got.Truncate(time.Microsecond))
+}
+
+func cloneConfiguration(conf *protos.TenantConfiguration) (*protos.TenantConfiguration, error) {
+	b, err := json.Marshal(conf)
+	if err != nil {
+		return nil, err
+	}
+	c := &protos.TenantConfiguration{}
+	if err = json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// diffConfiguration 按 JSON 结构比较两份配置：对象按键、数组按下标逐项比较。
+func diffConfiguration(from, to *protos.TenantConfiguration) ([]protos.ConfigChange, error) {
+	a, err := configurationJSON(from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := configurationJSON(to)
+	if err != nil {
+		return nil, err
+	}
+	changes := []protos.ConfigChange{}
+	diffJSON("", a, b, &changes)
+	return changes, nil
+}
+
+func configurationJSON(conf *protos.TenantConfiguration) (interface{}, error) {
+	if conf == nil {
+		conf = &protos.TenantConfiguration{}
+	}
+	b, err := json.Marshal(conf)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	err = json.Unmarshal(b, &v)
+	return v, err
+}
+
+func diffJSON(path string, a, b interface{}, changes *[]protos.ConfigChange) {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(k)
+			x, inA := av[k]
+			y, inB := bv[k]
+			switch {
+			case !inA:
+				*changes = append(*changes, protos.ConfigChange{Op: protos.ConfigChangeAdd, Path: p, New: y})
+			case !inB:
+				*changes = append(*changes, protos.ConfigChange{Op: protos.ConfigChangeRemove, Path: p, Old: x})
+			default:
+				diffJSON(p, x, y, changes)
+			}
+		}
+		return
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(av) || i < len(bv); i++ {
+			p := path + "/" + strconv.Itoa(i)
+			switch {
+			case i >= len(av):
+				*changes = append(*changes, protos.ConfigChange{Op: protos.ConfigChangeAdd, Path: p, New: bv[i]})
+			case i >= len(bv):
+				*changes = append(*changes, protos.ConfigChange{Op: protos.ConfigChangeRemove, Path: p, Old: av[i]})
+			default:
+				diffJSON(p, av[i], bv[i], changes)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, protos.ConfigChange{Op: protos.ConfigChangeReplace, Path: path, Old: a, New: b})
+	}
+}
+
+// tenantRoleReplace 整体替换租户配置时的角色字典检查：角色变多时按差额占用角色配额，超出返回 ErrQuotaExceeded；
+// 会删掉仍有角色绑定、策略或限时授权的角色时返回 ErrRoleInUse，需先收回这些授权。
+type tenantRoleReplace struct {
+	tenantID      uint64
+	roles         []protos.RoleStruct
+	before, after int
+	granted       map[string]bool
+	release       func()
+}
+
+// newTenantRoleReplace 在 saveTenantConfiguration 之前调用：事务内不能访问数据库，租户配置和限时授权先查出来
+func newTenantRoleReplace(tenantID uint64, roles []protos.RoleStruct) (*tenantRoleReplace, error) {
+	tenant, err := dao.TenantGetByID(tenantID)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	if tenant == nil {
+		return nil, common.ErrTenantNotFound
+	}
+	grants, err := dao.RoleGrantListByTenant(tenantID)
+	if err != nil {
+		return nil, common.ErrService
+	}
+
+	r := &tenantRoleReplace{tenantID: tenantID, roles: roles, after: len(roles), granted: make(map[string]bool, len(grants))}
+	for i := range grants {
+		r.granted[grants[i].Role] = true
+	}
+	if tenant.Configuration != nil {
+		r.before = len(tenant.Configuration.Roles)
+	}
+	if r.release, err = reserveQuotaN(tenantID, protos.QuotaRoles, int64(r.after-r.before)); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// check 在 saveTenantConfiguration 的修改函数中调用，conf 是事务内读到的当前配置
+func (r *tenantRoleReplace) check(conf *protos.TenantConfiguration) error {
+	if len(conf.Roles) != r.before {
+		// 读取之后配置又被改过，乐观锁会拦下；这里保证配额按同一份配置计算
+		return common.ErrModify
+	}
+	for _, role := range conf.Roles {
+		if containsRoleValue(r.roles, role.RoleValue) {
+			continue
+		}
+		inUse, err := accessctl.RoleInUse(r.tenantID, role.RoleValue)
+		if err != nil {
+			common.Logger.Sugar().Errorf("tenantRoleReplace RoleInUse ERR: %v %v %v\n", r.tenantID, role.RoleValue, err)
+			return common.ErrService
+		}
+		if inUse || r.granted[role.RoleValue] {
+			common.Logger.Sugar().Warnf("tenantRoleReplace role in use: tenant %d role %s", r.tenantID, role.RoleValue)
+			return common.ErrRoleInUse
+		}
+	}
+	return nil
+}
+
+// finish 保存之后调用：失败时归还占用的配额，成功且角色变少时扣减用量
+func (r *tenantRoleReplace) finish(err error) {
+	if err != nil {
+		r.release()
+		return
+	}
+	if r.after < r.before {
+		releaseQuota(r.tenantID, protos.QuotaRoles, int64(r.before-r.after))
+	}
+}
+
+// TenantConfigVersionList 分页查询租户配置版本，新版本在前；同时返回配置当前的更新时间。
+func TenantConfigVersionList(tenantID, page, pageSize uint64) (*protos.TenantConfigVersionPage, error) {
+	if tenantID == 0 {
+		return nil, common.ErrParam
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	tenant, err := dao.TenantGetByID(tenantID)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	if tenant == nil {
+		return nil, common.ErrTenantNotFound
+	}
+	rr, err := dao.TenantConfigVersionList(tenantID, page, pageSize)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	return &protos.TenantConfigVersionPage{UpdateTime: tenant.UpdateTime, List: rr}, nil
+}
+
+// TenantConfigVersionDiff 两个配置版本之间的差异（从 from 变到 to）。
+func TenantConfigVersionDiff(tenantID, from, to uint64) ([]protos.ConfigChange, error) {
+	if tenantID == 0 || from == 0 || to == 0 {
+		return nil, common.ErrParam
+	}
+	a, err := getTenantConfigVersion(tenantID, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := getTenantConfigVersion(tenantID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := diffConfiguration(a.Configuration, b.Configuration)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	return changes, nil
+}
+
+// TenantConfigRollback 把租户配置恢复成指定版本的内容，回滚本身也记为一个新版本。
+// 角色字典变多时按差额占用角色配额，超出返回 ErrQuotaExceeded；
+// 会删掉仍有角色绑定、策略或限时授权的角色时返回 ErrRoleInUse，需先收回这些授权。
+func TenantConfigRollback(tenantID, authorUID uint64, req *protos.TenantConfigRollbackReq) (ver *protos.TenantConfigVersion, err error) {
+	if tenantID == 0 || req.Version == 0 {
+		return nil, common.ErrParam
+	}
+	expect, err := parseLastUpdateTime(req.LastUpdateTime)
+	if err != nil {
+		return nil, err
+	}
+	target, err := getTenantConfigVersion(tenantID, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := newTenantRoleReplace(tenantID, target.Configuration.Roles)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { roles.finish(err) }()
+
+	ver, err = saveTenantConfiguration(tenantID, authorUID, expect, fmt.Sprintf("回滚到版本 %d", req.Version), func(conf *protos.TenantConfiguration) error {
+		if err := roles.check(conf); err != nil {
+			return err
+		}
+		*conf = *target.Configuration
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ver, nil
+}
+
+func containsRoleValue(roles []protos.RoleStruct, value string) bool {
+	for i := range roles {
+		if roles[i].RoleValue == value {
+			return true
+		}
+	}
+	return false
+}
+
+func getTenantConfigVersion(tenantID, version uint64) (*protos.TenantConfigVersion, error) {
+	m, err := dao.TenantConfigVersionGet(tenantID, version)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	if m == nil {
+		return nil, common.ErrConfigVersionNotFound
+	}
+	return m, nil
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/liuhengloveyou/passport/v4/accessctl"
+	"github.com/liuhengloveyou/passport/v4/cache"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+func TestTenantConfigVersion(t *testing.T) {
+	initServiceTest(t)
+	const tid = 20011
+	defer cache.DelTenantCache(tid)
+
+	if _, err := common.DB.Exec(context.Background(), `INSERT INTO tenants (id, tenant_name, configuration) VALUES (?, 'versioned', '{"roles":[{"value":"root"}],"more":{"theme":"dark"}}')`, tid); err != nil {
+		t.Fatal(err)
+	}
+	lastUpdate := func() string {
+		page, err := TenantConfigVersionList(tid, 1, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return page.UpdateTime.Format(time.RFC3339Nano)
+	}
+
+	stale := lastUpdate()
+	if err := TenantUpdateConfiguration(tid, 1, stale, map[string]interface{}{"theme": "light", "lang": "zh"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := TenantUpdateConfiguration(tid, 1, stale, map[string]interface{}{"theme": "blue"}); err != common.ErrModify {
+		t.Fatalf("stale update = %v, want ErrModify", err)
+	}
+	if err := TenantAddRole(tid, 2, protos.RoleStruct{RoleTitle: "店员", RoleValue: "clerk"}); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := TenantConfigVersionList(tid, 1, 20)
+	if err != nil || len(page.List) != 3 {
+		t.Fatalf("versions = %+v %v", page, err)
+	}
+	if v := page.List[0]; v.Version != 3 || v.AuthorUID != 2 || len(v.Diff) != 1 || v.Diff[0].Op != protos.ConfigChangeAdd || v.Diff[0].Path != "/roles/1" {
+		t.Fatalf("latest version = %+v", v)
+	}
+
+	diff, err := TenantConfigVersionDiff(tid, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"/more/lang": protos.ConfigChangeAdd, "/more/theme": protos.ConfigChangeReplace}
+	if len(diff) != len(want) {
+		t.Fatalf("diff = %+v", diff)
+	}
+	for _, c := range diff {
+		if want[c.Path] != c.Op {
+			t.Fatalf("diff = %+v", diff)
+		}
+	}
+	if _, err = TenantConfigVersionDiff(tid, 1, 9); err != common.ErrConfigVersionNotFound {
+		t.Fatalf("unknown version = %v", err)
+	}
+
+	if _, err = TenantConfigRollback(tid, 3, &protos.TenantConfigRollbackReq{Version: 1, LastUpdateTime: stale}); err != common.ErrModify {
+		t.Fatalf("stale rollback = %v, want ErrModify", err)
+	}
+	ver, err := TenantConfigRollback(tid, 3, &protos.TenantConfigRollbackReq{Version: 1, LastUpdateTime: lastUpdate()})
+	if err != nil || ver == nil || ver.Version != 4 {
+		t.Fatalf("rollback = %+v %v", ver, err)
+	}
+	if roles := TenantGetRole(tid); len(roles) != 1 {
+		t.Fatalf("roles after rollback = %+v", roles)
+	}
+	if theme, _ := TenantLoadConfiguration(tid, "theme"); theme != "dark" {
+		t.Fatalf("theme after rollback = %v", theme)
+	}
+}
+
+func TestTenantConfigRollbackRoles(t *testing.T) {
+	initServiceTest(t)
+	const tid, org = 20016, 30016
+	defer cache.DelTenantCache(tid)
+
+	if _, err := common.DB.Exec(context.Background(), `INSERT INTO tenants (id, tenant_name, configuration) VALUES (?, 'rollback', '{"roles":[{"value":"root"}]}')`, tid); err != nil {
+		t.Fatal(err)
+	}
+	for _, role := range []string{"clerk", "auditor"} {
+		if err := TenantAddRole(tid, 1, protos.RoleStruct{RoleTitle: role, RoleValue: role}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dao.TenantQuotaUpsert(tid, protos.QuotaRoles, 3, 3); err != nil {
+		t.Fatal(err)
+	}
+	rollback := func(version uint64) error {
+		page, err := TenantConfigVersionList(tid, 1, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = TenantConfigRollback(tid, 1, &protos.TenantConfigRollbackReq{Version: version, LastUpdateTime: page.UpdateTime.Format(time.RFC3339Nano)})
+		return err
+	}
+	used := func() int64 {
+		rr, err := TenantQuotaUsage(tid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, one := range rr {
+			if one.Resource == protos.QuotaRoles {
+				return one.Used
+			}
+		}
+		return -1
+	}
+
+	// 还有策略引用的角色不能被回滚删掉
+	if err := accessctl.AddPolicyToRole(tid, org, "clerk", "/orders", "GET"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rollback(1); err != common.ErrRoleInUse {
+		t.Fatalf("rollback dropping used role = %v", err)
+	}
+	if roles := TenantGetRole(tid); len(roles) != 3 || used() != 3 {
+		t.Fatalf("roles = %+v used = %d", roles, used())
+	}
+	if err := accessctl.RemovePolicyFromRole(tid, org, "clerk", "/orders", "GET"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rollback(1); err != nil {
+		t.Fatal(err)
+	}
+	if roles := TenantGetRole(tid); len(roles) != 1 || used() != 1 {
+		t.Fatalf("roles = %+v used = %d", roles, used())
+	}
+
+	// 回滚增加的角色按差额占用配额
+	if err := dao.TenantQuotaUpsert(tid, protos.QuotaRoles, 2, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := rollback(3); err != common.ErrQuotaExceeded {
+		t.Fatalf("rollback over quota = %v", err)
+	}
+	if roles := TenantGetRole(tid); len(roles) != 1 || used() != 1 {
+		t.Fatalf("roles = %+v used = %d", roles, used())
+	}
+	if err := rollback(2); err != nil {
+		t.Fatal(err)
+	}
+	if roles := TenantGetRole(tid); len(roles) != 2 || used() != 2 {
+		t.Fatalf("roles = %+v used = %d", roles, used())
+	}
+}
+
+func TestAdminTenantUpdateConfigRoles(t *testing.T) {
+	initServiceTest(t)
+	const tid, org = 20028, 30028
+	defer cache.DelTenantCache(tid)
+
+	if _, err := common.DB.Exec(context.Background(), `INSERT INTO tenants (id, tenant_name, configuration) VALUES (?, 'replace', '{"roles":[{"value":"root"}]}')`, tid); err != nil {
+		t.Fatal(err)
+	}
+	if err := TenantAddRole(tid, 1, protos.RoleStruct{RoleTitle: "clerk", RoleValue: "clerk"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dao.TenantQuotaUpsert(tid, protos.QuotaRoles, 2, 2); err != nil {
+		t.Fatal(err)
+	}
+	sess := &protos.User{UID: 1, TenantID: tid}
+	update := func(roles ...string) error {
+		page, err := TenantConfigVersionList(tid, 1, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conf := &protos.TenantConfiguration{}
+		for _, role := range roles {
+			conf.Roles = append(conf.Roles, protos.RoleStruct{RoleTitle: role, RoleValue: role})
+		}
+		return AdminTenantUpdateConfig(sess, &protos.UpdateTenantConfigReq{TenantID: tid, Configuration: conf, LastUpdateTime: page.UpdateTime.Format(time.RFC3339Nano)})
+	}
+	used := func() int64 {
+		rr, err := TenantQuotaUsage(tid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, one := range rr {
+			if one.Resource == protos.QuotaRoles {
+				return one.Used
+			}
+		}
+		return -1
+	}
+
+	// 整体替换同样受 max_roles 限制
+	if err := update("root", "clerk", "auditor"); err != common.ErrQuotaExceeded {
+		t.Fatalf("replace over quota = %v", err)
+	}
+	if roles := TenantGetRole(tid); len(roles) != 2 || used() != 2 {
+		t.Fatalf("roles = %+v used = %d", roles, used())
+	}
+
+	// 不能删掉还有策略引用的角色
+	if err := accessctl.AddPolicyToRole(tid, org, "clerk", "/orders", "GET"); err != nil {
+		t.Fatal(err)
+	}
+	if err := update("root"); err != common.ErrRoleInUse {
+		t.Fatalf("replace dropping used role = %v", err)
+	}
+	if err := accessctl.RemovePolicyFromRole(tid, org, "clerk", "/orders", "GET"); err != nil {
+		t.Fatal(err)
+	}
+	if err := update("root"); err != nil {
+		t.Fatal(err)
+	}
+	if roles := TenantGetRole(tid); len(roles) != 1 || used() != 1 {
+		t.Fatalf("roles = %+v used = %d", roles, used())
+	}
+}
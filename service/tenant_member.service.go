@@ -0,0 +1,138 @@
+package service
+
+import (
+	"github.com/liuhengloveyou/passport/v4/cache"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// UserInTenant 账号是否属于租户：默认租户（users.tenant_id）或通过 tenant_members 加入的租户。
+func UserInTenant(uid, tenantID uint64) error {
+	if uid == 0 || tenantID == 0 {
+		return common.ErrNoAuth
+	}
+	if in, hit := cache.GetTenantMemberCache(tenantID, uid); hit {
+		if in {
+			return nil
+		}
+		return common.ErrNoAuth
+	}
+	ok, err := dao.TenantMemberExists(tenantID, uid)
+	if err != nil {
+		return common.ErrService
+	}
+	if !ok {
+		return common.ErrNoAuth
+	}
+	cache.SetTenantMemberCache(tenantID, uid, true)
+	return nil
+}
+
+// CheckTenantMemberEnabled 账号在租户内没有被停用。停用只按租户记，不影响账号在其他租户登录。
+func CheckTenantMemberEnabled(uid, tenantID uint64) error {
+	if uid == 0 || tenantID == 0 {
+		return nil
+	}
+	disabled, hit := cache.GetTenantMemberDisabledCache(tenantID, uid)
+	if !hit {
+		member, err := dao.TenantMemberGet(tenantID, uid)
+		if err != nil {
+			return common.ErrService
+		}
+		disabled = member.IsDisabled()
+		cache.SetTenantMemberDisabledCache(tenantID, uid, disabled)
+	}
+	if disabled {
+		return common.ErrDisable
+	}
+	return nil
+}
+
+// addTenantMember 把账号加入租户，还没有默认租户时设为默认租户。返回 added 表示之前不是该租户成员。
+func addTenantMember(uid, tenantID uint64) (added bool, err error) {
+	userInfo, err := dao.UserQueryByID(uid)
+	if err != nil || userInfo == nil {
+		common.Logger.Sugar().Errorf("addTenantMember query user ERR: %v %v", uid, err)
+		return false, common.ErrService
+	}
+	member, err := dao.TenantMemberExists(tenantID, uid)
+	if err != nil {
+		return false, common.ErrService
+	}
+
+	if _, err = dao.TenantMemberInsert(tenantID, uid); err != nil {
+		return false, common.ErrService
+	}
+	if userInfo.TenantID == 0 {
+		if _, err = dao.UserUpdateTenantID(uid, tenantID, 0); err != nil {
+			common.Logger.Sugar().Errorf("addTenantMember set default tenant ERR: %v %v %v", uid, tenantID, err)
+			return false, common.ErrService
+		}
+	}
+	cache.SetTenantMemberCache(tenantID, uid, true)
+	return !member, nil
+}
+
+// removeTenantMember 把账号移出租户。默认租户是该租户时改到账号加入的其他租户；
+// 没有其他租户时按原来的方式删除账号。返回移出的成员数（0 或 1）。
+func removeTenantMember(uid, tenantID uint64) (int64, error) {
+	defer cache.DelTenantMemberCache(tenantID, uid)
+
+	n, err := dao.TenantMemberDelete(tenantID, uid)
+	if err != nil {
+		return 0, err
+	}
+	moved, err := dao.UserMoveDefaultTenant(tenantID, uid)
+	if err != nil {
+		return 0, err
+	}
+	if moved > 0 {
+		common.Logger.Sugar().Infof("removeTenantMember: uid %d default tenant moved away from %d", uid, tenantID)
+		return 1, nil
+	}
+
+	deleted, err := dao.UserDelete(uid, tenantID)
+	if err != nil {
+		return 0, err
+	}
+	if deleted > 0 || n > 0 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// UserTenants 账号加入的租户列表，默认租户在前。
+func UserTenants(uid uint64) ([]protos.UserTenant, error) {
+	rr, err := dao.TenantListByUser(uid)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	return rr, nil
+}
+
+// SwitchTenant 校验账号可以切换到目标租户：须是该租户成员、在该租户没有被停用，且租户状态允许登录。
+func SwitchTenant(uid, tenantID uint64) (*protos.Tenant, error) {
+	if tenantID == 0 {
+		return nil, common.ErrParam
+	}
+	if err := UserInTenant(uid, tenantID); err != nil {
+		return nil, err
+	}
+	if err := CheckTenantMemberEnabled(uid, tenantID); err != nil {
+		return nil, err
+	}
+	if err := CheckTenantStatus(tenantID); err != nil {
+		return nil, err
+	}
+	tenant, err := getTenantByIDCached(tenantID)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	if tenant == nil {
+		return nil, common.ErrTenantNotFound
+	}
+
+	common.Logger.Sugar().Infof("SwitchTenant: uid %d switched to tenant %d", uid, tenantID)
+	return tenant, nil
+}
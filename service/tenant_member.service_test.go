@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/liuhengloveyou/passport/v4/cache"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+func TestTenantMembership(t *testing.T) {
+	initServiceTest(t)
+	const tidA, tidB, uid = 20007, 20008, 31
+	defer cache.DelTenantCache(tidA)
+	defer cache.DelTenantCache(tidB)
+
+	ctx := context.Background()
+	for _, sql := range []string{
+		"INSERT INTO tenants (id, tenant_name) VALUES (20007, 'member-a'), (20008, 'member-b')",
+		// 老数据：只有 users.tenant_id，没有成员记录
+		"INSERT INTO users (uid, tenant_id, password) VALUES (31, 20007, '')",
+	} {
+		if _, err := common.DB.Exec(ctx, sql); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := UserInTenant(uid, tidA); err != nil {
+		t.Fatalf("default tenant = %v", err)
+	}
+	if err := UserInTenant(uid, tidB); err != common.ErrNoAuth {
+		t.Fatalf("other tenant = %v", err)
+	}
+	if _, err := SwitchTenant(uid, tidB); err != common.ErrNoAuth {
+		t.Fatalf("switch before join = %v", err)
+	}
+
+	if err := TenantBindUser(uid, tidB); err != nil {
+		t.Fatal(err)
+	}
+	rr, err := UserTenants(uid)
+	if err != nil || len(rr) != 2 || rr[0].TenantID != tidA || !rr[0].Default || rr[1].TenantID != tidB || rr[1].Default {
+		t.Fatalf("UserTenants = %+v %v", rr, err)
+	}
+	if tenant, err := SwitchTenant(uid, tidB); err != nil || tenant.ID != tidB {
+		t.Fatalf("SwitchTenant = %v %v", tenant, err)
+	}
+
+	// 移出默认租户时默认租户改到另一个，账号保留
+	if n, err := TenantUserDel(uid, tidA); err != nil || n != 1 {
+		t.Fatalf("leave default tenant = %v %v", n, err)
+	}
+	if err := UserInTenant(uid, tidA); err != common.ErrNoAuth {
+		t.Fatalf("after leave = %v", err)
+	}
+	if user, err := dao.UserQueryByID(uid); err != nil || user == nil || user.TenantID != tidB {
+		t.Fatalf("default tenant moved = %+v %v", user, err)
+	}
+
+	// 最后一个租户：按原来的方式删除账号
+	if n, err := TenantUserDel(uid, tidB); err != nil || n != 1 {
+		t.Fatalf("leave last tenant = %v %v", n, err)
+	}
+	if user, err := dao.UserQueryByID(uid); err != nil || user != nil {
+		t.Fatalf("user after leaving all tenants = %+v %v", user, err)
+	}
+}
+
+func TestTenantMemberDisabled(t *testing.T) {
+	initServiceTest(t)
+	const tidA, tidB, uid = 20017, 20018, 33
+	defer cache.DelTenantCache(tidA)
+	defer cache.DelTenantCache(tidB)
+
+	ctx := context.Background()
+	for _, sql := range []string{
+		"INSERT INTO tenants (id, tenant_name) VALUES (20017, 'disable-a'), (20018, 'disable-b')",
+		"INSERT INTO users (uid, tenant_id, nickname, password, ext) VALUES (33, 20017, 'consultant', '" + common.EncryPWD("secret1") + "', '{\"title\":\"顾问\"}')",
+	} {
+		if _, err := common.DB.Exec(ctx, sql); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := TenantBindUser(uid, tidB); err != nil {
+		t.Fatal(err)
+	}
+	login := &protos.UserReq{Nickname: "consultant", Password: "secret1"}
+
+	// 在 B 停用：A 的登录和会话不受影响，切到 B 被拒绝
+	if err := TenantUserDisabledService(uid, tidB, protos.UserDisabled); err != nil {
+		t.Fatal(err)
+	}
+	if one, err := UserLogin(login); err != nil || one.TenantID != tidA {
+		t.Fatalf("login to tenant A = %+v %v", one, err)
+	}
+	if err := CheckTenantMemberEnabled(uid, tidA); err != nil {
+		t.Fatalf("tenant A = %v", err)
+	}
+	if _, err := SwitchTenant(uid, tidB); err != common.ErrDisable {
+		t.Fatalf("switch to disabled tenant = %v", err)
+	}
+	if user, err := dao.UserQueryByID(uid); err != nil || user.Ext["disabled"] != nil {
+		t.Fatalf("account ext = %+v %v", user, err)
+	}
+
+	// 租户内的扩展信息不写到账号上
+	if err := TenantUpdateUserExt(uid, tidB, "title", "外部顾问"); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := dao.UserQueryByID(uid); user.Ext["title"] != "顾问" {
+		t.Fatalf("account ext = %+v", user.Ext)
+	}
+	if err := TenantUpdateUserExt(uid, tidB, "disabled", 1); err != common.ErrParam {
+		t.Fatalf("disabled through ext = %v", err)
+	}
+
+	// 在默认租户 A 停用（A 没有成员记录）后登录被拒绝，启用后恢复
+	if err := TenantUserDisabledService(uid, tidA, protos.UserDisabled); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UserLogin(login); err != common.ErrDisable {
+		t.Fatalf("login disabled in default tenant = %v", err)
+	}
+	if err := TenantUserDisabledService(uid, tidA, protos.UserEnabled); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UserLogin(login); err != nil {
+		t.Fatalf("login after enable = %v", err)
+	}
+}
+
+func TestSwitchOrgDefault(t *testing.T) {
+	initServiceTest(t)
+	const tid = 20026
+	defer cache.DelTenantCache(tid)
+	for _, sql := range []string{
+		"INSERT INTO tenants (id, tenant_name) VALUES (20026, 'switch-org')",
+		"INSERT INTO users (uid, tenant_id, password) VALUES (2, 20026, '')",
+	} {
+		if _, err := common.DB.Exec(context.Background(), sql); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var orgs []uint64
+	for _, name := range []string{"store-a", "store-b", "store-c"} {
+		id, err := OrgCreate(tid, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cache.DelOrgCache(id)
+		orgs = append(orgs, id)
+	}
+	if got := UserDefaultOrg(2, tid); got != 0 {
+		t.Fatalf("default org without membership = %d", got)
+	}
+	for _, id := range orgs[:2] {
+		if err := OrgAddMember(id, 2, tid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 没有选过时取 ID 最小的组织
+	if got := UserDefaultOrg(2, tid); got != orgs[0] {
+		t.Fatalf("default org = %d, want %d", got, orgs[0])
+	}
+	if err := SwitchOrg(2, tid, orgs[2]); err != common.ErrNoAuth {
+		t.Fatalf("switch to foreign org = %v", err)
+	}
+	if err := SwitchOrg(2, tid, orgs[1]); err != nil {
+		t.Fatal(err)
+	}
+	if got := UserDefaultOrg(2, tid); got != orgs[1] {
+		t.Fatalf("default org after switch = %d, want %d", got, orgs[1])
+	}
+	// 离开默认组织后回到剩下的组织
+	if err := OrgRemoveMember(orgs[1], 2, tid); err != nil {
+		t.Fatal(err)
+	}
+	if got := UserDefaultOrg(2, tid); got != orgs[0] {
+		t.Fatalf("default org after leaving = %d, want %d", got, orgs[0])
+	}
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/liuhengloveyou/passport/v4/accessctl"
+	"github.com/liuhengloveyou/passport/v4/cache"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+const defaultTenantRestoreDays = 30
+
+var tenantPurgeJobOnce sync.Once
+
+// tenantRestoreWindow 删除租户后可恢复的时长，配置 tenant_restore_days
+func tenantRestoreWindow() time.Duration {
+	days := common.ServConfig.TenantRestoreDays
+	if days <= 0 {
+		days = defaultTenantRestoreDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// AdminTenantRestore 平台管理员在恢复期内撤销租户删除，状态回到删除前。
+func AdminTenantRestore(sessUser *protos.User, tenantID uint64) error {
+	if common.ServConfig.RootTenantID <= 0 || sessUser.TenantID != common.ServConfig.RootTenantID {
+		common.Logger.Sugar().Error("AdminTenantRestore auth ERR: ", sessUser.TenantID)
+		return common.ErrNoAuth
+	}
+	return restoreTenant(sessUser, tenantID)
+}
+
+// restoreTenant 在恢复期内撤销租户删除，调用方负责鉴权。
+func restoreTenant(sessUser *protos.User, tenantID uint64) error {
+	if tenantID == 0 {
+		return common.ErrParam
+	}
+
+	tenant, err := dao.TenantGetByID(tenantID)
+	if err != nil {
+		common.Logger.Sugar().Errorf("AdminTenantRestore db ERR: %v\n", err)
+		return common.ErrService
+	}
+	if tenant == nil {
+		return common.ErrTenantNotFound
+	}
+	if tenant.DeleteTime == nil {
+		return common.ErrTenantStatus
+	}
+	if time.Now().After(tenant.DeleteTime.Add(tenantRestoreWindow())) {
+		return common.ErrTenantRestoreExpired
+	}
+
+	to := tenant.PreDeleteStatus
+	if to == "" {
+		to = protos.TenantStatusActive
+	}
+	defer evictTenantCache(tenantID)
+	rows, err := dao.TenantRestore(tenantID, to, fmt.Sprintf("restored by %d", sessUser.UID))
+	if err != nil {
+		return common.ErrService
+	}
+	if rows == 0 {
+		return common.ErrModify
+	}
+
+	common.Logger.Sugar().Warnf("AdminTenantRestore: user %d restored tenant %d %s to %s", sessUser.UID, tenantID, tenant.TenantName, to)
+	return nil
+}
+
+// PurgeDeletedTenants 物理删除恢复期已过的租户，返回本次清理记录。单个租户失败不影响其他租户。
+func PurgeDeletedTenants(now time.Time) (rr []protos.TenantPurge, err error) {
+	deleted, err := dao.TenantListDeleted()
+	if err != nil {
+		return nil, common.ErrService
+	}
+
+	window := tenantRestoreWindow()
+	for _, one := range deleted {
+		if one.DeleteTime == nil || now.Before(one.DeleteTime.Add(window)) {
+			continue
+		}
+		record, err := purgeTenant(one.ID, now, window)
+		if err != nil {
+			common.Logger.Sugar().Errorf("PurgeDeletedTenants ERR: %v %v\n", one.ID, err)
+			continue
+		}
+		if record != nil {
+			rr = append(rr, *record)
+		}
+	}
+	return rr, nil
+}
+
+// purgeTenant 执行原来的硬删除：直接子租户移到根租户下，再删除用户、部门、权限、组织、授权等按租户记录的数据，
+// casbin 中该租户的所有域，最后删除租户记录
+func purgeTenant(tenantID uint64, now time.Time, window time.Duration) (*protos.TenantPurge, error) {
+	// 列表之后可能已被恢复，重新确认
+	tenant, err := dao.TenantGetByID(tenantID)
+	if err != nil || tenant == nil || tenant.DeleteTime == nil || now.Before(tenant.DeleteTime.Add(window)) {
+		return nil, err
+	}
+	defer evictTenantCache(tenantID)
+
+	record := &protos.TenantPurge{TenantID: tenantID, TenantName: tenant.TenantName, DeleteTime: tenant.DeleteTime}
+	if rootID := common.ServConfig.RootTenantID; rootID > 0 {
+		subtree, err := collectTenantSubtree(tenantID)
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range subtree {
+			if child.Depth != 1 || child.ID == tenantID {
+				continue
+			}
+			if err = TenantSetParent(child.ID, rootID); err != nil {
+				return nil, fmt.Errorf("reparent child %d: %w", child.ID, err)
+			}
+			record.Children = append(record.Children, child.ID)
+		}
+	}
+
+	if record.Users, err = deleteUsersByTenant(tenantID); err != nil {
+		return nil, fmt.Errorf("deleteUsersByTenant: %w", err)
+	}
+	// 组织缓存在删除组织记录前取出 ID 逐个清掉
+	orgs, err := dao.OrgListByTenant(tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("OrgListByTenant: %w", err)
+	}
+	if record.Departments, record.Permissions, err = deleteTenantRelatedData(tenantID); err != nil {
+		return nil, fmt.Errorf("deleteTenantRelatedData: %w", err)
+	}
+	for _, org := range orgs {
+		cache.DelOrgCache(org.ID)
+		cache.DelOrgConfigCache(tenantID, org.ID)
+	}
+	if err = deleteTenantDomains(tenantID); err != nil {
+		return nil, fmt.Errorf("deleteTenantDomains: %w", err)
+	}
+	if err = deleteTenantRecord(tenantID); err != nil {
+		return nil, fmt.Errorf("deleteTenantRecord: %w", err)
+	}
+	if err = dao.TenantPurgeInsert(record); err != nil {
+		common.Logger.Sugar().Errorf("purgeTenant record ERR: %v %v\n", tenantID, err)
+	}
+
+	common.Logger.Sugar().Warnf("purgeTenant: %v %v deleted at %v, users %d departments %d permissions %d children %v\n",
+		tenantID, tenant.TenantName, tenant.DeleteTime, record.Users, record.Departments, record.Permissions, record.Children)
+	return record, nil
+}
+
+// deleteTenantDomains 删除租户在 casbin 中的所有域（租户级域、组织域、继承域、老版本租户域）
+func deleteTenantDomains(tenantID uint64) error {
+	doms, err := accessctl.TenantDomains(tenantID)
+	if err != nil {
+		return err
+	}
+	for _, dom := range doms {
+		if err = accessctl.RemoveDomain(dom); err != nil {
+			return fmt.Errorf("%s: %w", dom, err)
+		}
+	}
+	accessctl.OrgTreeChanged(tenantID)
+	return nil
+}
+
+// AdminTenantPurgeList 分页查询租户清理记录。
+func AdminTenantPurgeList(page, pageSize uint64) ([]protos.TenantPurge, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 50
+	}
+	rr, err := dao.TenantPurgeList(page, pageSize)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	return rr, nil
+}
+
+// StartTenantPurgeJob 启动已删除租户的后台清理任务，重复调用只启动一次。
+func StartTenantPurgeJob(interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	tenantPurgeJobOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for now := range ticker.C {
+				PurgeDeletedTenants(now)
+			}
+		}()
+	})
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/liuhengloveyou/passport/v4/accessctl"
+	"github.com/liuhengloveyou/passport/v4/cache"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+func TestTenantSoftDelete(t *testing.T) {
+	initServiceTest(t)
+	const tid, childTid, rootTid = 20004, 20005, 20009
+	savedRoot, savedDays := common.ServConfig.RootTenantID, common.ServConfig.TenantRestoreDays
+	common.ServConfig.RootTenantID, common.ServConfig.TenantRestoreDays = rootTid, 1
+	defer func() { common.ServConfig.RootTenantID, common.ServConfig.TenantRestoreDays = savedRoot, savedDays }()
+	defer cache.DelTenantCache(tid)
+	defer cache.DelTenantCache(childTid)
+
+	ctx := context.Background()
+	for _, sql := range []string{
+		"INSERT INTO tenants (id, tenant_name, status) VALUES (20004, 'purge', 'suspended'), (20005, 'purge-child', 'active'), (20009, 'root', 'active')",
+		"INSERT INTO tenant_closure (ancestor_id, descendant_id, depth) VALUES (20004, 20004, 0), (20005, 20005, 0), (20009, 20009, 0), (20004, 20005, 1)",
+		"INSERT INTO users (uid, tenant_id, password) VALUES (21, 20004, ''), (22, 20004, '')",
+	} {
+		if _, err := common.DB.Exec(ctx, sql); err != nil {
+			t.Fatal(err)
+		}
+	}
+	operator := &protos.User{UID: 1, TenantID: rootTid}
+
+	if err := AdminTenantDelete(operator, tid); err != nil {
+		t.Fatal(err)
+	}
+	if got := CheckTenantStatus(tid); got != common.ErrTenantDeleted {
+		t.Fatalf("CheckTenantStatus = %v, want ErrTenantDeleted", got)
+	}
+	if err := AdminTenantDelete(operator, tid); err != common.ErrTenantDeleted {
+		t.Fatalf("delete twice = %v", err)
+	}
+	if err := AdminTenantSetStatus(operator, &protos.TenantStatusReq{TenantID: tid, Status: protos.TenantStatusActive, Reason: "x"}); err != common.ErrTenantDeleted {
+		t.Fatalf("AdminTenantSetStatus = %v", err)
+	}
+
+	// 恢复到删除前的状态
+	if err := AdminTenantRestore(operator, tid); err != nil {
+		t.Fatal(err)
+	}
+	if got := CheckTenantStatus(tid); got != common.ErrTenantSuspended {
+		t.Fatalf("restored CheckTenantStatus = %v, want ErrTenantSuspended", got)
+	}
+	if err := AdminTenantRestore(operator, tid); err != common.ErrTenantStatus {
+		t.Fatalf("restore twice = %v", err)
+	}
+
+	if err := AdminTenantDelete(operator, tid); err != nil {
+		t.Fatal(err)
+	}
+	if rr, err := PurgeDeletedTenants(time.Now()); err != nil || len(rr) != 0 {
+		t.Fatalf("purge within window = %v %v", rr, err)
+	}
+
+	// 恢复期已过
+	if _, err := common.DB.Exec(ctx, "UPDATE tenants SET delete_time = ? WHERE id = ?", time.Now().Add(-48*time.Hour), tid); err != nil {
+		t.Fatal(err)
+	}
+	if err := AdminTenantRestore(operator, tid); err != common.ErrTenantRestoreExpired {
+		t.Fatalf("restore after window = %v", err)
+	}
+
+	rr, err := PurgeDeletedTenants(time.Now())
+	if err != nil || len(rr) != 1 {
+		t.Fatalf("purge = %v %v", rr, err)
+	}
+	if rr[0].TenantID != tid || rr[0].Users != 2 || len(rr[0].Children) != 1 || rr[0].Children[0] != childTid {
+		t.Fatalf("purge record = %+v", rr[0])
+	}
+	if tenant, err := dao.TenantGetByID(tid); err != nil || tenant != nil {
+		t.Fatalf("purged tenant = %v %v", tenant, err)
+	}
+	if child, err := dao.TenantGetByID(childTid); err != nil || child == nil || child.ParentID != rootTid {
+		t.Fatalf("child = %+v %v", child, err)
+	}
+	logs, err := AdminTenantPurgeList(1, 10)
+	if err != nil || len(logs) != 1 || logs[0].TenantName != "purge" || logs[0].Users != 2 {
+		t.Fatalf("purge log = %+v %v", logs, err)
+	}
+}
+
+func TestTenantPurgeRemovesTenantData(t *testing.T) {
+	initServiceTest(t)
+	const tid, otherTid, rootTid, org = 20029, 20030, 20031, 30029
+	savedRoot, savedDays := common.ServConfig.RootTenantID, common.ServConfig.TenantRestoreDays
+	common.ServConfig.RootTenantID, common.ServConfig.TenantRestoreDays = rootTid, 1
+	defer func() { common.ServConfig.RootTenantID, common.ServConfig.TenantRestoreDays = savedRoot, savedDays }()
+	defer cache.DelTenantCache(tid)
+
+	ctx := context.Background()
+	for _, sql := range []string{
+		"INSERT INTO tenants (id, tenant_name, status) VALUES (20029, 'purge', 'active'), (20030, 'other', 'active'), (20031, 'root', 'active')",
+		"INSERT INTO tenant_closure (ancestor_id, descendant_id, depth) VALUES (20029, 20029, 0), (20030, 20030, 0), (20031, 20031, 0), (20031, 20029, 1)",
+		"INSERT INTO users (uid, tenant_id, password) VALUES (41, 20029, ''), (42, 20030, '')",
+		"INSERT INTO tenant_members (tenant_id, uid) VALUES (20029, 41), (20029, 42), (20030, 42)",
+		"INSERT INTO tenant_quotas (tenant_id, resource) VALUES (20029, 'users')",
+		"INSERT INTO organizations (id, tenant_id, name) VALUES (30029, 20029, 'org')",
+		"INSERT INTO org_members (org_id, uid, tenant_id) VALUES (30029, 41, 20029)",
+		"INSERT INTO org_closure (tenant_id, ancestor_id, descendant_id, depth) VALUES (20029, 30029, 30029, 0)",
+		"INSERT INTO org_member_transfers (tenant_id, uid, from_org_id, to_org_id, mode) VALUES (20029, 41, 30029, 30029, 'move')",
+		"INSERT INTO role_grants (tenant_id, org_id, uid, role) VALUES (20029, 30029, 41, 'clerk')",
+		"INSERT INTO role_data_scopes (tenant_id, org_id, role, module) VALUES (20029, 30029, 'clerk', 'orders')",
+		"INSERT INTO invitations (tenant_id, org_id, target, channel, nonce, expire_time) VALUES (20029, 30029, 'a@b.c', 'email', 'n', CURRENT_TIMESTAMP)",
+		"INSERT INTO tenant_config_versions (tenant_id, version) VALUES (20029, 1)",
+	} {
+		if _, err := common.DB.Exec(ctx, sql); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, err := range []error{
+		accessctl.AddPolicyToRole(tid, org, "clerk", "/orders", "GET"),
+		accessctl.AddRoleForUserInDomain(41, tid, org, "clerk"),
+		accessctl.AddInheritedRoleForUser(41, tid, org, "clerk"),
+		accessctl.AddTenantPolicyWithEffect(tid, "clerk", "/stock", "GET", "allow"),
+		accessctl.AddRoleForUserInTenant(41, tid, "clerk"),
+		accessctl.AddTenantPolicyWithEffect(otherTid, "clerk", "/orders", "GET", "allow"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := AdminTenantDelete(&protos.User{UID: 1, TenantID: rootTid}, tid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := common.DB.Exec(ctx, "UPDATE tenants SET delete_time = ? WHERE id = ?", time.Now().Add(-48*time.Hour), tid); err != nil {
+		t.Fatal(err)
+	}
+	if rr, err := PurgeDeletedTenants(time.Now()); err != nil || len(rr) != 1 {
+		t.Fatalf("purge = %v %v", rr, err)
+	}
+
+	for _, table := range []string{"users", "tenant_members", "tenant_quotas", "organizations", "org_members", "org_closure",
+		"org_member_transfers", "role_grants", "role_data_scopes", "invitations", "tenant_config_versions"} {
+		var n int
+		if err := common.DB.QueryRow(ctx, "SELECT COUNT(*) FROM "+table+" WHERE tenant_id = ?", tid).Scan(&n); err != nil || n != 0 {
+			t.Fatalf("%s rows = %d %v", table, n, err)
+		}
+	}
+	var n int
+	if err := common.DB.QueryRow(ctx, "SELECT COUNT(*) FROM tenant_closure WHERE ancestor_id = ? OR descendant_id = ?", tid, tid).Scan(&n); err != nil || n != 0 {
+		t.Fatalf("tenant_closure rows = %d %v", n, err)
+	}
+	if doms, err := accessctl.TenantDomains(tid); err != nil || len(doms) != 0 {
+		t.Fatalf("casbin domains = %v %v", doms, err)
+	}
+
+	// 其他租户的数据不受影响
+	if err := common.DB.QueryRow(ctx, "SELECT COUNT(*) FROM tenant_members WHERE tenant_id = ?", otherTid).Scan(&n); err != nil || n != 1 {
+		t.Fatalf("other tenant members = %d %v", n, err)
+	}
+	if doms, err := accessctl.TenantDomains(otherTid); err != nil || len(doms) != 1 {
+		t.Fatalf("other casbin domains = %v %v", doms, err)
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// reserveQuota 写入资源前先占用配额，写入失败时调用 release 归还。
+// 占用是 tenant_quotas 上的一条条件 UPDATE，检查与计数不可分割；没有设置该项配额的租户不计数。
+func reserveQuota(tenantID uint64, resource string) (release func(), err error) {
+	return reserveQuotaN(tenantID, resource, 1)
+}
+
+// reserveQuotaN 一次占用 n 个配额，要么全部占用要么不占用
+func reserveQuotaN(tenantID uint64, resource string, n int64) (release func(), err error) {
+	release = func() {}
+	if n <= 0 {
+		return release, nil
+	}
+	counted, err := dao.TenantQuotaReserve(tenantID, resource, n)
+	if err == common.ErrQuotaExceeded {
+		common.Logger.Sugar().Warnf("quota exceeded: tenant %d %s", tenantID, resource)
+		return release, err
+	}
+	if err != nil {
+		return release, common.ErrService
+	}
+	if counted {
+		release = func() { releaseQuota(tenantID, resource, n) }
+	}
+	return release, nil
+}
+
+// releaseQuota 资源删除后扣减用量计数
+func releaseQuota(tenantID uint64, resource string, n int64) {
+	if n <= 0 {
+		return
+	}
+	if err := dao.TenantQuotaAdjust(tenantID, resource, -n); err != nil {
+		common.Logger.Sugar().Errorf("releaseQuota ERR: %v %v %v %v\n", tenantID, resource, n, err)
+	}
+}
+
+// countQuotaResource 资源的实际数量，设置配额时用来校准计数
+func countQuotaResource(tenantID uint64, resource string) (int64, error) {
+	if resource != protos.QuotaRoles {
+		return dao.TenantResourceCount(tenantID, resource)
+	}
+
+	tenant, err := dao.TenantGetByID(tenantID)
+	if err != nil || tenant == nil || tenant.Configuration == nil {
+		return 0, err
+	}
+	return int64(len(tenant.Configuration.Roles)), nil
+}
+
+// TenantQuotaUsage 租户各项资源的上限与当前用量；没有设置配额的资源 limit 为 0，用量按实际数量统计。
+func TenantQuotaUsage(tenantID uint64) ([]protos.TenantQuota, error) {
+	if tenantID == 0 {
+		return nil, common.ErrParam
+	}
+	rows, err := dao.TenantQuotaList(tenantID)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	set := make(map[string]protos.TenantQuota, len(rows))
+	for _, one := range rows {
+		set[one.Resource] = one
+	}
+
+	rst := make([]protos.TenantQuota, 0, len(protos.QuotaResources))
+	for _, resource := range protos.QuotaResources {
+		if one, ok := set[resource]; ok {
+			rst = append(rst, one)
+			continue
+		}
+		used, err := countQuotaResource(tenantID, resource)
+		if err != nil {
+			common.Logger.Sugar().Errorf("TenantQuotaUsage count ERR: %v %v %v\n", tenantID, resource, err)
+			return nil, common.ErrService
+		}
+		rst = append(rst, protos.TenantQuota{Resource: resource, Used: used})
+	}
+	return rst, nil
+}
+
+// AdminTenantSetQuota 平台管理员设置租户配额，同时按实际数量校准用量计数。
+// 上限低于当前用量时已有数据不受影响，只是不能再新增。
+func AdminTenantSetQuota(sessUser *protos.User, req *protos.TenantQuotaReq) ([]protos.TenantQuota, error) {
+	if common.ServConfig.RootTenantID <= 0 || sessUser.TenantID != common.ServConfig.RootTenantID {
+		common.Logger.Sugar().Error("AdminTenantSetQuota auth ERR: ", sessUser.TenantID)
+		return nil, common.ErrNoAuth
+	}
+	if req.TenantID == 0 || len(req.Quotas) == 0 {
+		return nil, common.ErrParam
+	}
+	for resource, limit := range req.Quotas {
+		if !containsString(protos.QuotaResources, resource) || limit < 0 {
+			common.Logger.Sugar().Errorf("AdminTenantSetQuota param ERR: %v %v\n", resource, limit)
+			return nil, common.ErrParam
+		}
+	}
+
+	tenant, err := dao.TenantGetByID(req.TenantID)
+	if err != nil {
+		common.Logger.Sugar().Errorf("AdminTenantSetQuota db ERR: %v\n", err)
+		return nil, common.ErrService
+	}
+	if tenant == nil {
+		return nil, common.ErrTenantNotFound
+	}
+
+	for resource, limit := range req.Quotas {
+		used, err := countQuotaResource(req.TenantID, resource)
+		if err != nil {
+			common.Logger.Sugar().Errorf("AdminTenantSetQuota count ERR: %v %v %v\n", req.TenantID, resource, err)
+			return nil, common.ErrService
+		}
+		if err = dao.TenantQuotaUpsert(req.TenantID, resource, limit, used); err != nil {
+			common.Logger.Sugar().Errorf("AdminTenantSetQuota upsert ERR: %v %v %v\n", req.TenantID, resource, err)
+			return nil, common.ErrService
+		}
+	}
+
+	common.Logger.Sugar().Warnf("AdminTenantSetQuota: user %d set tenant %d quotas %v", sessUser.UID, req.TenantID, req.Quotas)
+	return TenantQuotaUsage(req.TenantID)
+}
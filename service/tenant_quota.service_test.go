@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/liuhengloveyou/passport/v4/cache"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+func TestTenantQuota(t *testing.T) {
+	initServiceTest(t)
+	const tid, rootTid = 20003, 20009
+	saved := common.ServConfig.RootTenantID
+	common.ServConfig.RootTenantID = rootTid
+	defer func() { common.ServConfig.RootTenantID = saved }()
+	defer cache.DelTenantCache(tid)
+
+	ctx := context.Background()
+	if _, err := common.DB.Exec(ctx, `INSERT INTO tenants (id, tenant_name, configuration) VALUES (?, 'quota', '{"roles":[{"value":"root"}]}')`, tid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := common.DB.Exec(ctx, "INSERT INTO users (uid, tenant_id, password) VALUES (11, 0, ''), (12, 0, '')"); err != nil {
+		t.Fatal(err)
+	}
+	operator := &protos.User{UID: 1, TenantID: rootTid}
+
+	used := func(t *testing.T, resource string, want int64) {
+		t.Helper()
+		usage, err := TenantQuotaUsage(tid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, one := range usage {
+			if one.Resource == resource {
+				if one.Used != want {
+					t.Fatalf("%s used = %d, want %d", resource, one.Used, want)
+				}
+				return
+			}
+		}
+		t.Fatalf("%s missing in %v", resource, usage)
+	}
+
+	// 未设置配额时不限，用量按实际数量统计
+	orgID, err := OrgCreate(tid, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	used(t, protos.QuotaOrgs, 1)
+	used(t, protos.QuotaRoles, 1)
+
+	if _, err := AdminTenantSetQuota(&protos.User{UID: 1, TenantID: tid}, &protos.TenantQuotaReq{TenantID: tid, Quotas: map[string]int64{protos.QuotaOrgs: 1}}); err != common.ErrNoAuth {
+		t.Fatalf("non-root operator = %v", err)
+	}
+	if _, err := AdminTenantSetQuota(operator, &protos.TenantQuotaReq{TenantID: tid, Quotas: map[string]int64{"stores": 1}}); err != common.ErrParam {
+		t.Fatalf("unknown resource = %v", err)
+	}
+	usage, err := AdminTenantSetQuota(operator, &protos.TenantQuotaReq{TenantID: tid, Quotas: map[string]int64{
+		protos.QuotaMembers: 1, protos.QuotaOrgs: 1, protos.QuotaDepartments: 1, protos.QuotaRoles: 1,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage[1] != (protos.TenantQuota{Resource: protos.QuotaOrgs, Limit: 1, Used: 1}) {
+		t.Fatalf("usage = %v", usage)
+	}
+
+	t.Run("orgs", func(t *testing.T) {
+		if _, err := OrgCreate(tid, "b"); err != common.ErrQuotaExceeded {
+			t.Fatalf("OrgCreate = %v, want ErrQuotaExceeded", err)
+		}
+		used(t, protos.QuotaOrgs, 1)
+	})
+
+	t.Run("members", func(t *testing.T) {
+		if err := TenantUserAdd(11, tid, orgID, nil, nil, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := TenantUserAdd(12, tid, orgID, nil, nil, 0); err != common.ErrQuotaExceeded {
+			t.Fatalf("TenantUserAdd = %v, want ErrQuotaExceeded", err)
+		}
+		// 已是成员的用户再次加入不占配额
+		if err := TenantUserAdd(11, tid, orgID, nil, nil, 0); err != nil {
+			t.Fatalf("re-add member = %v", err)
+		}
+		used(t, protos.QuotaMembers, 1)
+
+		if _, err := TenantUserDel(11, tid); err != nil {
+			t.Fatal(err)
+		}
+		used(t, protos.QuotaMembers, 0)
+		if err := TenantUserAdd(12, tid, orgID, nil, nil, 0); err != nil {
+			t.Fatal(err)
+		}
+		used(t, protos.QuotaMembers, 1)
+	})
+
+	t.Run("departments", func(t *testing.T) {
+		id, err := DepartmentCreate(&protos.Department{TenantID: tid, OrgID: orgID, Name: "d1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = DepartmentCreate(&protos.Department{TenantID: tid, OrgID: orgID, Name: "d2"}); err != common.ErrQuotaExceeded {
+			t.Fatalf("DepartmentCreate = %v, want ErrQuotaExceeded", err)
+		}
+		if err = DepartmentDelete(uint64(id), tid); err != nil {
+			t.Fatal(err)
+		}
+		used(t, protos.QuotaDepartments, 0)
+		if _, err = DepartmentCreate(&protos.Department{TenantID: tid, OrgID: orgID, Name: "d2"}); err != nil {
+			t.Fatal(err)
+		}
+		used(t, protos.QuotaDepartments, 1)
+	})
+
+	t.Run("roles", func(t *testing.T) {
+		if err := TenantAddRole(tid, 1, protos.RoleStruct{RoleTitle: "店员", RoleValue: "clerk"}); err != common.ErrQuotaExceeded {
+			t.Fatalf("TenantAddRole = %v, want ErrQuotaExceeded", err)
+		}
+		used(t, protos.QuotaRoles, 1)
+	})
+
+	t.Run("org_delete", func(t *testing.T) {
+		if err := OrgDelete(tid, orgID); err != nil {
+			t.Fatal(err)
+		}
+		used(t, protos.QuotaOrgs, 0)
+		used(t, protos.QuotaDepartments, 0)
+		if _, err := OrgCreate(tid, "b"); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
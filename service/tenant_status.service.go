@@ -0,0 +1,108 @@
+package service
+
+import (
+	"time"
+
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// tenantStatusTransitions 允许的状态变更；trial 到 trial 用于延长试用期
+var tenantStatusTransitions = map[string][]string{
+	protos.TenantStatusTrial:     {protos.TenantStatusTrial, protos.TenantStatusActive, protos.TenantStatusSuspended, protos.TenantStatusExpired, protos.TenantStatusArchived},
+	protos.TenantStatusActive:    {protos.TenantStatusSuspended, protos.TenantStatusExpired, protos.TenantStatusArchived},
+	protos.TenantStatusSuspended: {protos.TenantStatusActive, protos.TenantStatusExpired, protos.TenantStatusArchived},
+	protos.TenantStatusExpired:   {protos.TenantStatusTrial, protos.TenantStatusActive, protos.TenantStatusArchived},
+	protos.TenantStatusArchived:  {},
+}
+
+// CheckTenantStatus 租户成员能否登录、访问；停用、过期、归档、删除分别返回不同错误码，平台根租户不受限制。
+func CheckTenantStatus(tenantID uint64) error {
+	if tenantID == 0 || tenantID == common.ServConfig.RootTenantID {
+		return nil
+	}
+	tenant, err := getTenantByIDCached(tenantID)
+	if err != nil {
+		common.Logger.Sugar().Errorf("CheckTenantStatus db ERR: %v %v\n", tenantID, err)
+		return common.ErrService
+	}
+	if tenant == nil {
+		return nil
+	}
+	if tenant.DeleteTime != nil {
+		return common.ErrTenantDeleted
+	}
+
+	switch tenant.EffectiveStatus(time.Now()) {
+	case protos.TenantStatusSuspended:
+		return common.ErrTenantSuspended
+	case protos.TenantStatusExpired:
+		return common.ErrTenantExpired
+	case protos.TenantStatusArchived:
+		return common.ErrTenantArchived
+	}
+	return nil
+}
+
+// AdminTenantSetStatus 平台管理员变更租户生命周期状态，需注明原因。
+func AdminTenantSetStatus(sessUser *protos.User, req *protos.TenantStatusReq) error {
+	if common.ServConfig.RootTenantID <= 0 || sessUser.TenantID != common.ServConfig.RootTenantID {
+		common.Logger.Sugar().Error("AdminTenantSetStatus auth ERR: ", sessUser.TenantID)
+		return common.ErrNoAuth
+	}
+	return setTenantStatus(sessUser, req)
+}
+
+// setTenantStatus 按状态机变更租户状态，调用方负责鉴权。
+func setTenantStatus(sessUser *protos.User, req *protos.TenantStatusReq) error {
+	if req.TenantID == 0 || req.Reason == "" {
+		return common.ErrParam
+	}
+	if req.TenantID == common.ServConfig.RootTenantID {
+		return common.ErrTenantStatus
+	}
+
+	var trialEndTime *time.Time
+	if req.Status == protos.TenantStatusTrial {
+		if req.TrialEndTime == nil || !req.TrialEndTime.After(time.Now()) {
+			common.Logger.Sugar().Errorf("AdminTenantSetStatus trialEndTime ERR: %v\n", req.TrialEndTime)
+			return common.ErrParam
+		}
+		trialEndTime = req.TrialEndTime
+	}
+
+	tenant, err := dao.TenantGetByID(req.TenantID)
+	if err != nil {
+		common.Logger.Sugar().Errorf("AdminTenantSetStatus db ERR: %v\n", err)
+		return common.ErrService
+	}
+	if tenant == nil {
+		return common.ErrTenantNotFound
+	}
+	if tenant.DeleteTime != nil {
+		// 删除中的租户只能通过 admin/tenant/restore 恢复
+		return common.ErrTenantDeleted
+	}
+	from := tenant.Status
+	if from == "" {
+		from = protos.TenantStatusActive
+	}
+	if !containsString(tenantStatusTransitions[from], req.Status) {
+		common.Logger.Sugar().Errorf("AdminTenantSetStatus transition ERR: %d %s => %s\n", req.TenantID, from, req.Status)
+		return common.ErrTenantStatus
+	}
+
+	defer evictTenantCache(req.TenantID)
+	rows, err := dao.TenantUpdateStatus(req.TenantID, tenant.Status, req.Status, req.Reason, trialEndTime)
+	if err != nil {
+		return common.ErrService
+	}
+	if rows == 0 {
+		// 期间状态被其他操作改过
+		return common.ErrModify
+	}
+
+	common.Logger.Sugar().Warnf("AdminTenantSetStatus: user %d set tenant %d status %s => %s: %s", sessUser.UID, req.TenantID, from, req.Status, req.Reason)
+	return nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/liuhengloveyou/passport/v4/cache"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+func TestTenantStatus(t *testing.T) {
+	initServiceTest(t)
+	const tid, rootTid = 20002, 20009
+	saved := common.ServConfig.RootTenantID
+	common.ServConfig.RootTenantID = rootTid
+	defer func() { common.ServConfig.RootTenantID = saved }()
+	defer cache.DelTenantCache(tid)
+
+	ctx := context.Background()
+	if _, err := common.DB.Exec(ctx, "INSERT INTO tenants (id, tenant_name) VALUES (?, 'status'), (?, 'root')", tid, rootTid); err != nil {
+		t.Fatal(err)
+	}
+	operator := &protos.User{UID: 1, TenantID: rootTid}
+	future, past := time.Now().Add(time.Hour), time.Now().Add(-time.Hour)
+
+	steps := []struct {
+		name   string
+		status string
+		trial  *time.Time
+		want   error // AdminTenantSetStatus
+		check  error // CheckTenantStatus
+	}{
+		{"suspend", protos.TenantStatusSuspended, nil, nil, common.ErrTenantSuspended},
+		{"suspended_to_trial", protos.TenantStatusTrial, &future, common.ErrTenantStatus, common.ErrTenantSuspended},
+		{"resume", protos.TenantStatusActive, nil, nil, nil},
+		{"active_to_trial", protos.TenantStatusTrial, &future, common.ErrTenantStatus, nil},
+		{"expire", protos.TenantStatusExpired, nil, nil, common.ErrTenantExpired},
+		{"trial_past_end", protos.TenantStatusTrial, &past, common.ErrParam, common.ErrTenantExpired},
+		{"trial_without_end", protos.TenantStatusTrial, nil, common.ErrParam, common.ErrTenantExpired},
+		{"renew_trial", protos.TenantStatusTrial, &future, nil, nil},
+		{"archive", protos.TenantStatusArchived, nil, nil, common.ErrTenantArchived},
+		{"archived_is_final", protos.TenantStatusActive, nil, common.ErrTenantStatus, common.ErrTenantArchived},
+	}
+	for _, tc := range steps {
+		t.Run(tc.name, func(t *testing.T) {
+			err := AdminTenantSetStatus(operator, &protos.TenantStatusReq{TenantID: tid, Status: tc.status, Reason: tc.name, TrialEndTime: tc.trial})
+			if err != tc.want {
+				t.Fatalf("AdminTenantSetStatus = %v, want %v", err, tc.want)
+			}
+			if got := CheckTenantStatus(tid); got != tc.check {
+				t.Fatalf("CheckTenantStatus = %v, want %v", got, tc.check)
+			}
+		})
+	}
+
+	t.Run("trial_ends", func(t *testing.T) {
+		if _, err := common.DB.Exec(ctx, "UPDATE tenants SET status = 'trial', trial_end_time = ? WHERE id = ?", past, tid); err != nil {
+			t.Fatal(err)
+		}
+		cache.DelTenantCache(tid)
+		if got := CheckTenantStatus(tid); got != common.ErrTenantExpired {
+			t.Fatalf("CheckTenantStatus = %v, want ErrTenantExpired", got)
+		}
+	})
+
+	t.Run("root_exempt", func(t *testing.T) {
+		if _, err := common.DB.Exec(ctx, "UPDATE tenants SET status = 'suspended' WHERE id = ?", rootTid); err != nil {
+			t.Fatal(err)
+		}
+		if got := CheckTenantStatus(rootTid); got != nil {
+			t.Fatalf("root tenant CheckTenantStatus = %v", got)
+		}
+		if err := AdminTenantSetStatus(operator, &protos.TenantStatusReq{TenantID: rootTid, Status: protos.TenantStatusActive, Reason: "x"}); err != common.ErrTenantStatus {
+			t.Fatalf("root tenant AdminTenantSetStatus = %v", err)
+		}
+		if err := AdminTenantSetStatus(&protos.User{UID: 1, TenantID: tid}, &protos.TenantStatusReq{TenantID: tid, Status: protos.TenantStatusActive, Reason: "x"}); err != common.ErrNoAuth {
+			t.Fatalf("non-root operator = %v", err)
+		}
+	})
+}
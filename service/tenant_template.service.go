@@ -0,0 +1,417 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/liuhengloveyou/passport/v4/accessctl"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+// TenantTemplateAdd 新增租户模板，名称不能重复。
+func TenantTemplateAdd(req *protos.TenantTemplateReq) (*protos.TenantTemplate, error) {
+	m := &protos.TenantTemplate{Name: strings.TrimSpace(req.Name), Remark: strings.TrimSpace(req.Remark), Content: req.Content}
+	if err := addTenantTemplate(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func addTenantTemplate(m *protos.TenantTemplate) error {
+	if err := validateTemplateContent(m.Content); err != nil {
+		return err
+	}
+	if err := checkTemplateName(m.Name, 0); err != nil {
+		return err
+	}
+
+	id, err := dao.TenantTemplateInsert(m)
+	if err != nil {
+		return common.ErrService
+	}
+	m.ID = id
+	return nil
+}
+
+// TenantTemplateUpdate 修改模板名称、备注与内容；已按模板开通的租户不受影响。
+func TenantTemplateUpdate(req *protos.TenantTemplateReq) error {
+	if req.ID == 0 {
+		return common.ErrParam
+	}
+	m := &protos.TenantTemplate{ID: req.ID, Name: strings.TrimSpace(req.Name), Remark: strings.TrimSpace(req.Remark), Content: req.Content}
+	if err := validateTemplateContent(m.Content); err != nil {
+		return err
+	}
+	if err := checkTemplateName(m.Name, m.ID); err != nil {
+		return err
+	}
+
+	n, err := dao.TenantTemplateUpdate(m)
+	if err != nil {
+		return common.ErrService
+	}
+	if n == 0 {
+		return common.ErrTemplateNotFound
+	}
+	return nil
+}
+
+// TenantTemplateGet 查询模板及内容。
+func TenantTemplateGet(id uint64) (*protos.TenantTemplate, error) {
+	if id == 0 {
+		return nil, common.ErrParam
+	}
+	m, err := dao.TenantTemplateGet(id)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	if m == nil {
+		return nil, common.ErrTemplateNotFound
+	}
+	return m, nil
+}
+
+// TenantTemplateList 分页查询模板，不含内容。
+func TenantTemplateList(page, pageSize uint64) ([]protos.TenantTemplate, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	rr, err := dao.TenantTemplateList(page, pageSize)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	return rr, nil
+}
+
+// TenantTemplateDelete 删除模板。
+func TenantTemplateDelete(id uint64) error {
+	if id == 0 {
+		return common.ErrParam
+	}
+	n, err := dao.TenantTemplateDelete(id)
+	if err != nil {
+		return common.ErrService
+	}
+	if n == 0 {
+		return common.ErrTemplateNotFound
+	}
+	return nil
+}
+
+// AdminTenantClone 把租户的结构快照为模板：配置、组织、部门、角色策略与数据范围，不含账号与账号相关的授权。
+func AdminTenantClone(sessUser *protos.User, req *protos.TenantCloneReq) (*protos.TenantTemplate, error) {
+	if sessUser == nil || req.TenantID == 0 {
+		return nil, common.ErrParam
+	}
+	if common.ServConfig.RootTenantID <= 0 || sessUser.TenantID != common.ServConfig.RootTenantID {
+		common.Logger.Sugar().Error("AdminTenantClone auth ERR: ", sessUser.UID, sessUser.TenantID)
+		return nil, common.ErrNoAuth
+	}
+
+	content, err := snapshotTenant(req.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	m := &protos.TenantTemplate{
+		Name:           strings.TrimSpace(req.Name),
+		Remark:         strings.TrimSpace(req.Remark),
+		SourceTenantID: req.TenantID,
+		Content:        content,
+	}
+	if err = addTenantTemplate(m); err != nil {
+		return nil, err
+	}
+
+	common.Logger.Sugar().Infof("AdminTenantClone: user %d cloned tenant %d into template %d %s", sessUser.UID, req.TenantID, m.ID, m.Name)
+	return m, nil
+}
+
+func checkTemplateName(name string, selfID uint64) error {
+	if name == "" {
+		return common.ErrParam
+	}
+	got, err := dao.TenantTemplateGetByName(name)
+	if err != nil {
+		return common.ErrService
+	}
+	if got != nil && got.ID != selfID {
+		return common.ErrTemplateNameDup
+	}
+	return nil
+}
+
+// snapshotTenant 读取租户当前结构。部门 ID 沿用原租户的 ID，只在模板内有效。
+func snapshotTenant(tenantID uint64) (*protos.TenantTemplateContent, error) {
+	tenant, err := dao.TenantGetByID(tenantID)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	if tenant == nil {
+		return nil, common.ErrTenantNotFound
+	}
+
+	content := &protos.TenantTemplateContent{Configuration: tenant.Configuration}
+	if content.Policies, err = accessctl.GetRolePoliciesInDomain(tenantID, 0); err != nil {
+		common.Logger.Sugar().Errorf("snapshotTenant tenant policy ERR: %v %v\n", tenantID, err)
+		return nil, common.ErrService
+	}
+
+	orgs, err := dao.OrgListByTenant(tenantID)
+	if err != nil {
+		return nil, common.ErrService
+	}
+	orgNames := make(map[uint64]string, len(orgs))
+	for _, org := range orgs {
+		orgNames[org.ID] = org.Name
+	}
+	for _, org := range orgs {
+		one := protos.TemplateOrg{Name: org.Name, Parent: orgNames[org.ParentID]}
+
+		deps, err := dao.DepartmentFind(0, tenantID, org.ID, 0, 0)
+		if err != nil {
+			return nil, common.ErrService
+		}
+		inOrg := make(map[uint64]bool, len(deps))
+		for i := range deps {
+			inOrg[deps[i].Id] = true
+		}
+		for i := range deps {
+			dep := protos.TemplateDepartment{ID: deps[i].Id, Name: deps[i].Name}
+			if inOrg[deps[i].ParentID] {
+				dep.ParentID = deps[i].ParentID
+			}
+			// 部门负责人是账号，不进模板
+			for k, v := range deps[i].Config {
+				if k == DepartmentLeaderUIDsKey {
+					continue
+				}
+				if dep.Config == nil {
+					dep.Config = make(protos.MapStruct)
+				}
+				dep.Config[k] = v
+			}
+			one.Departments = append(one.Departments, dep)
+		}
+
+		if one.Policies, err = accessctl.GetRolePoliciesInDomain(tenantID, org.ID); err != nil {
+			common.Logger.Sugar().Errorf("snapshotTenant org policy ERR: %v %v\n", org.ID, err)
+			return nil, common.ErrService
+		}
+
+		scopes, err := dao.RoleDataScopeList(tenantID, org.ID)
+		if err != nil {
+			return nil, common.ErrService
+		}
+		for _, scope := range scopes {
+			ids := make([]uint64, 0, len(scope.DepIDs))
+			for _, id := range scope.DepIDs {
+				if inOrg[id] {
+					ids = append(ids, id)
+				}
+			}
+			if len(ids) > 0 {
+				scope.DepIDs = ids
+				one.DataScopes = append(one.DataScopes, scope)
+			}
+		}
+
+		content.Orgs = append(content.Orgs, one)
+	}
+
+	return content, nil
+}
+
+// validateTemplateContent 开通租户前校验模板：组织名、组织层级、部门名（租户内唯一）、部门层级与数据范围引用的部门都要有效。
+func validateTemplateContent(c *protos.TenantTemplateContent) error {
+	if c == nil {
+		return common.ErrTemplateInvalid
+	}
+	if !validTemplatePolicies(c.Policies) {
+		return common.ErrTemplateInvalid
+	}
+
+	orgNames := make(map[string]bool, len(c.Orgs))
+	depNames := make(map[string]bool)
+	for i := range c.Orgs {
+		o := &c.Orgs[i]
+		name := strings.TrimSpace(o.Name)
+		if name == "" || orgNames[name] {
+			return common.ErrTemplateInvalid
+		}
+		orgNames[name] = true
+
+		if _, ok := orderTemplateDepartments(o.Departments); !ok {
+			return common.ErrTemplateInvalid
+		}
+		depIDs := make(map[uint64]bool, len(o.Departments))
+		for _, d := range o.Departments {
+			dep := strings.TrimSpace(d.Name)
+			if depNames[dep] {
+				return common.ErrTemplateInvalid
+			}
+			depNames[dep] = true
+			depIDs[d.ID] = true
+		}
+
+		if !validTemplatePolicies(o.Policies) {
+			return common.ErrTemplateInvalid
+		}
+		for _, scope := range o.DataScopes {
+			if scope.Role == "" {
+				return common.ErrTemplateInvalid
+			}
+			for _, id := range scope.DepIDs {
+				if !depIDs[id] {
+					return common.ErrTemplateInvalid
+				}
+			}
+		}
+	}
+	if _, ok := orderTemplateOrgs(c.Orgs); !ok {
+		return common.ErrTemplateInvalid
+	}
+	return nil
+}
+
+func validTemplatePolicies(policies []protos.Policy) bool {
+	for _, p := range policies {
+		if p.Role == "" || p.Obj == "" || p.Act == "" {
+			return false
+		}
+		if p.Effect != "" && p.Effect != protos.PolicyEffectAllow && p.Effect != protos.PolicyEffectDeny {
+			return false
+		}
+	}
+	return true
+}
+
+// orderTemplateDepartments 按层级排序，上级在前。ID 重复、名称为空、上级不存在或成环时返回 false。
+func orderTemplateDepartments(deps []protos.TemplateDepartment) ([]protos.TemplateDepartment, bool) {
+	byID := make(map[uint64]bool, len(deps))
+	for _, d := range deps {
+		if d.ID == 0 || byID[d.ID] || strings.TrimSpace(d.Name) == "" {
+			return nil, false
+		}
+		byID[d.ID] = true
+	}
+
+	out := make([]protos.TemplateDepartment, 0, len(deps))
+	done := make(map[uint64]bool, len(deps))
+	for len(out) < len(deps) {
+		progress := false
+		for _, d := range deps {
+			if done[d.ID] {
+				continue
+			}
+			if d.ParentID != 0 && !byID[d.ParentID] {
+				return nil, false
+			}
+			if d.ParentID == 0 || done[d.ParentID] {
+				out = append(out, d)
+				done[d.ID] = true
+				progress = true
+			}
+		}
+		if !progress {
+			return nil, false
+		}
+	}
+	return out, true
+}
+
+// orderTemplateOrgs 按组织层级排序，上级在前，返回 c.Orgs 的下标。上级组织不在模板中或成环时返回 false。
+func orderTemplateOrgs(orgs []protos.TemplateOrg) ([]int, bool) {
+	byName := make(map[string]bool, len(orgs))
+	for _, o := range orgs {
+		byName[strings.TrimSpace(o.Name)] = true
+	}
+
+	out := make([]int, 0, len(orgs))
+	done := make(map[string]bool, len(orgs))
+	for len(out) < len(orgs) {
+		progress := false
+		for i, o := range orgs {
+			name, parent := strings.TrimSpace(o.Name), strings.TrimSpace(o.Parent)
+			if done[name] {
+				continue
+			}
+			if parent != "" && !byName[parent] {
+				return nil, false
+			}
+			if parent == "" || done[parent] {
+				out = append(out, i)
+				done[name] = true
+				progress = true
+			}
+		}
+		if !progress {
+			return nil, false
+		}
+	}
+	return out, true
+}
+
+// applyTenantTemplate 在新建的租户中按模板建组织、部门、策略与数据范围；uid 为租户管理员，记为部门创建人。
+// 组织要先于租户级策略创建，否则 OrgCreate 会把租户级策略复制进第一个组织；上级组织先于下级组织创建。
+// 策略与手工添加一样经 PolicyAddToRole / PolicyAddToTenant，租户开启严格模式时按权限字典校验。
+func applyTenantTemplate(tenantID, uid uint64, c *protos.TenantTemplateContent) error {
+	order, _ := orderTemplateOrgs(c.Orgs)
+	orgIDs := make(map[string]uint64, len(order))
+	for _, i := range order {
+		o := &c.Orgs[i]
+		orgID, err := OrgCreateChild(tenantID, orgIDs[strings.TrimSpace(o.Parent)], o.Name)
+		if err != nil {
+			return err
+		}
+		orgIDs[strings.TrimSpace(o.Name)] = orgID
+
+		deps, _ := orderTemplateDepartments(o.Departments)
+		depIDs := make(map[uint64]uint64, len(deps))
+		for _, d := range deps {
+			id, err := DepartmentCreate(&protos.Department{
+				UserId:   uid,
+				TenantID: tenantID,
+				OrgID:    orgID,
+				ParentID: depIDs[d.ParentID],
+				Name:     strings.TrimSpace(d.Name),
+			})
+			if err != nil {
+				return err
+			}
+			depIDs[d.ID] = uint64(id)
+			if len(d.Config) > 0 {
+				if _, err = dao.DepartmentUpdateConfig(&protos.Department{Id: uint64(id), Config: d.Config}); err != nil {
+					return common.ErrService
+				}
+			}
+		}
+
+		for _, p := range o.Policies {
+			if err = PolicyAddToRole(tenantID, orgID, p.Role, p.Obj, p.Act, p.Effect); err != nil {
+				common.Logger.Sugar().Errorf("applyTenantTemplate org policy ERR: %v %v %+v %v\n", tenantID, orgID, p, err)
+				return err
+			}
+		}
+		for _, scope := range o.DataScopes {
+			ids := make([]uint64, 0, len(scope.DepIDs))
+			for _, id := range scope.DepIDs {
+				ids = append(ids, depIDs[id])
+			}
+			if err = DataScopeSetCustomDeps(tenantID, orgID, scope.Role, scope.Module, ids); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, p := range c.Policies {
+		if err := PolicyAddToTenant(tenantID, p.Role, p.Obj, p.Act, p.Effect); err != nil {
+			common.Logger.Sugar().Errorf("applyTenantTemplate tenant policy ERR: %v %+v %v\n", tenantID, p, err)
+			return err
+		}
+	}
+	return nil
+}
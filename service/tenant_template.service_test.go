@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/liuhengloveyou/passport/v4/accessctl"
+	"github.com/liuhengloveyou/passport/v4/cache"
+	"github.com/liuhengloveyou/passport/v4/common"
+	"github.com/liuhengloveyou/passport/v4/dao"
+	"github.com/liuhengloveyou/passport/v4/protos"
+)
+
+func TestTenantTemplate(t *testing.T) {
+	initServiceTest(t)
+	const tid, rootTid = 20010, 20009
+	saved := common.ServConfig.RootTenantID
+	common.ServConfig.RootTenantID = rootTid
+	defer func() { common.ServConfig.RootTenantID = saved }()
+	defer cache.DelTenantCache(tid)
+
+	ctx := context.Background()
+	if _, err := common.DB.Exec(ctx, `INSERT INTO tenants (id, tenant_name, configuration) VALUES (?, 'source', '{"roles":[{"value":"root"},{"value":"clerk"}]}')`, tid); err != nil {
+		t.Fatal(err)
+	}
+	orgID, err := OrgCreate(tid, "hq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.DelOrgCache(orgID)
+
+	sales, err := DepartmentCreate(&protos.Department{UserId: 1, TenantID: tid, OrgID: orgID, Name: "sales"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	east, err := DepartmentCreate(&protos.Department{UserId: 1, TenantID: tid, OrgID: orgID, ParentID: uint64(sales), Name: "east"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = dao.DepartmentUpdateConfig(&protos.Department{Id: uint64(east), Config: protos.MapStruct{DepartmentLeaderUIDsKey: []uint64{1}, "code": "E"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range []error{
+		PolicyAddToRole(tid, orgID, "clerk", "/orders", "GET", ""),
+		PolicyAddToRole(tid, orgID, "clerk", "data-scope/orders/custom", "GET", ""),
+		PolicyAddToRole(tid, orgID, accessctl.UserSubject(3), "/secret", "GET", ""),
+		PolicyAddToTenant(tid, "clerk", "/reports", "GET", ""),
+		DataScopeSetCustomDeps(tid, orgID, "clerk", "orders", []uint64{uint64(east)}),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	operator := &protos.User{UID: 1, TenantID: rootTid}
+	if _, err := AdminTenantClone(&protos.User{UID: 1, TenantID: tid}, &protos.TenantCloneReq{TenantID: tid, Name: "retail"}); err != common.ErrNoAuth {
+		t.Fatalf("clone by non-root = %v", err)
+	}
+	tpl, err := AdminTenantClone(operator, &protos.TenantCloneReq{TenantID: tid, Name: "retail"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpl.Content.Orgs) != 1 || len(tpl.Content.Orgs[0].Departments) != 2 || len(tpl.Content.Orgs[0].Policies) != 2 || len(tpl.Content.Policies) != 1 {
+		t.Fatalf("snapshot = %+v", tpl.Content)
+	}
+	for _, d := range tpl.Content.Orgs[0].Departments {
+		if _, ok := d.Config[DepartmentLeaderUIDsKey]; ok {
+			t.Fatalf("leader uids in template: %+v", d)
+		}
+	}
+	if _, err := AdminTenantClone(operator, &protos.TenantCloneReq{TenantID: tid, Name: "retail"}); err != common.ErrTemplateNameDup {
+		t.Fatalf("duplicate name = %v", err)
+	}
+
+	_, newTid, err := AdminTenantNew(operator, &protos.NewTenantReq{TenantName: "from-template", TenantType: "shop", TemplateID: tpl.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.DelTenantCache(newTid)
+
+	orgs, err := OrgListByTenant(newTid)
+	if err != nil || len(orgs) != 1 || orgs[0].Name != "hq" {
+		t.Fatalf("orgs = %+v %v", orgs, err)
+	}
+	newOrg := orgs[0].ID
+	defer cache.DelOrgCache(newOrg)
+
+	deps, err := DepartmentFind(0, newTid, newOrg, 0, 0)
+	if err != nil || len(deps) != 2 {
+		t.Fatalf("departments = %+v %v", deps, err)
+	}
+	byName := map[string]protos.Department{}
+	for _, d := range deps {
+		byName[d.Name] = d
+	}
+	if byName["east"].ParentID != byName["sales"].Id || byName["east"].Config["code"] != "E" {
+		t.Fatalf("department tree = %+v", deps)
+	}
+
+	if ps, _ := accessctl.GetRolePoliciesInDomain(newTid, newOrg); len(ps) != 2 {
+		t.Fatalf("org policies = %+v", ps)
+	}
+	if ps, _ := accessctl.GetRolePoliciesInDomain(newTid, 0); len(ps) != 1 || ps[0].Obj != "/reports" {
+		t.Fatalf("tenant policies = %+v", ps)
+	}
+	if ids, err := DataScopeGetCustomDeps(newTid, newOrg, "clerk", "orders"); err != nil || len(ids) != 1 || ids[0] != byName["east"].Id {
+		t.Fatalf("data scope = %v %v", ids, err)
+	}
+	if roles := TenantGetRole(newTid); len(roles) != 2 {
+		t.Fatalf("configuration roles = %+v", roles)
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		bad := &protos.TenantTemplateContent{Orgs: []protos.TemplateOrg{{
+			Name:        "a",
+			Departments: []protos.TemplateDepartment{{ID: 1, ParentID: 2, Name: "x"}},
+		}}}
+		if _, err := TenantTemplateAdd(&protos.TenantTemplateReq{Name: "bad", Content: bad}); err != common.ErrTemplateInvalid {
+			t.Fatalf("missing parent = %v", err)
+		}
+		if _, _, err := AdminTenantNew(operator, &protos.NewTenantReq{TenantName: "no-template", TenantType: "shop", TemplateID: tpl.ID + 100}); err != common.ErrTemplateNotFound {
+			t.Fatalf("unknown template = %v", err)
+		}
+	})
+
+	// 模板配置开启严格模式时，策略同样要过权限字典
+	t.Run("strict", func(t *testing.T) {
+		strict := &protos.TenantTemplateContent{
+			Configuration: &protos.TenantConfiguration{More: protos.MapStruct{TenantConfStrictPolicy: true}},
+			Orgs: []protos.TemplateOrg{{
+				Name:     "hq",
+				Policies: []protos.Policy{{Role: "clerk", Obj: "/unregistered", Act: "GET"}},
+			}},
+		}
+		strictTpl, err := TenantTemplateAdd(&protos.TenantTemplateReq{Name: "strict", Content: strict})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err = AdminTenantNew(operator, &protos.NewTenantReq{TenantName: "strict-tenant", TenantType: "shop", TemplateID: strictTpl.ID}); err != common.ErrTemplateApply {
+			t.Fatalf("strict template with unregistered obj = %v", err)
+		}
+	})
+}
@@ -63,10 +63,12 @@ func UserLoginByWeixin(req *protos.UserReq) (one *protos.User, e error) {
 		common.Logger.Sugar().Infof("UserLoginByWeixin auto registered uid=%d openid=%s\n", one.UID, req.WxOpenId)
 	}
 
-	disabled, ok := one.Ext["disabled"].(float64)
-	if ok && protos.UserDisableStatus(int8(disabled)) == protos.UserDisabled {
-		common.Logger.Sugar().Errorf("login Disabled ERR: [%v] \n", one.Ext)
-		return nil, common.ErrDisable
+	if err := checkLoginDisabled(one); err != nil {
+		return nil, err
+	}
+	if err := CheckTenantStatus(one.TenantID); err != nil {
+		common.Logger.Sugar().Errorf("login tenant status ERR: %v %v\n", one.TenantID, err)
+		return nil, err
 	}
 
 	now := time.Now()
@@ -147,16 +149,18 @@ func UserLogin(user *protos.UserReq) (one *protos.User, e error) {
 		return nil, common.ErrLogin
 	}
 
-	disabled, ok := one.Ext["disabled"].(float64)
-	if ok && protos.UserDisableStatus(int8(disabled)) == protos.UserDisabled {
-		common.Logger.Sugar().Errorf("login Disabled ERR: [%v] \n", one.Ext)
-		return nil, common.ErrDisable
+	if err := checkLoginDisabled(one); err != nil {
+		return nil, err
 	}
 
 	if len(user.Password) > 0 && (common.EncryPWD(user.Password) != one.Password) {
 		common.Logger.Sugar().Errorf("login pwd ERR: [%v] [%v] [%v]\n", user.Password, common.EncryPWD(user.Password), one.Password)
 		return nil, common.ErrPWD
 	}
+	if err := CheckTenantStatus(one.TenantID); err != nil {
+		common.Logger.Sugar().Errorf("login tenant status ERR: %v %v\n", one.TenantID, err)
+		return nil, err
+	}
 
 	now := time.Now()
 	one.LoginTime = &now
@@ -240,3 +244,22 @@ func loginByNickname(p *protos.UserReq) (one *protos.User, e error) {
 
 	return
 }
+
+// checkLoginDisabled 登录落在默认租户，按账号在默认租户的成员状态判断是否停用；早期数据写在 users.ext 的账号级停用仍然有效。
+func checkLoginDisabled(one *protos.User) error {
+	if UserLegacyDisabled(one.Ext) {
+		common.Logger.Sugar().Errorf("login Disabled ERR: [%v] \n", one.Ext)
+		return common.ErrDisable
+	}
+	if err := CheckTenantMemberEnabled(one.UID, one.TenantID); err != nil {
+		common.Logger.Sugar().Errorf("login tenant member ERR: %v %v %v\n", one.UID, one.TenantID, err)
+		return err
+	}
+	return nil
+}
+
+// UserLegacyDisabled users.ext 中是否有早期数据的账号级停用
+func UserLegacyDisabled(ext protos.MapStruct) bool {
+	disabled, ok := ext[protos.UserDisabledExtKey].(float64)
+	return ok && protos.UserDisableStatus(int8(disabled)) == protos.UserDisabled
+}
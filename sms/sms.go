@@ -37,6 +37,12 @@ type Sms interface {
 	SendWxBindSms(phoneNumber string, aliveSecond int64) (code string, err error)
 }
 
+// InviteSms 可选接口：支持发送租户邀请短信的驱动实现
+type InviteSms interface {
+	// 发送租户邀请，vals 依次为租户名、邀请链接
+	SendInviteSms(phoneNumber string, vals []string) error
+}
+
 var smsFactoryByName = make(map[string]factoryFun)
 
 var (
@@ -162,3 +168,16 @@ func SendWxBindSms(phoneNumber string, aliveSecond int64) (code string, err erro
 
 	return
 }
+
+// SendInviteSms 发送租户邀请短信；邀请不是验证码，不受重发间隔限制
+func SendInviteSms(phoneNumber, tenantName, link string) error {
+	if defaultSms == nil {
+		return ErrSmsNotInit
+	}
+	p, ok := defaultSms.(InviteSms)
+	if !ok {
+		return ErrSmsNotInit
+	}
+
+	return p.SendInviteSms(phoneNumber, []string{tenantName, link})
+}
@@ -23,6 +23,7 @@ type SmsTencentcloud struct {
 	UserLoginTemplateId  string // 用户登录验证码短信模板ID
 	GetBackPwdTemplateId string // 短信找回密码验证码短信模板ID
 	WxBindTemplateId     string // 微信验证绑定手机号验证码短信模板ID
+	InviteTemplateId     string // 租户邀请短信模板ID，两个变量：租户名、邀请链接
 }
 
 func init() {
@@ -47,6 +48,9 @@ func NewSmsTencentcloud(config map[string]interface{}) Sms {
 	if _, ok := config["wx_bind_template_id"].(string); ok {
 		tmp.WxBindTemplateId = config["wx_bind_template_id"].(string)
 	}
+	if _, ok := config["invite_template_id"].(string); ok {
+		tmp.InviteTemplateId = config["invite_template_id"].(string)
+	}
 
 	if len(tmp.SecretID) == 0 ||
 		len(tmp.SecretKey) == 0 {
@@ -100,6 +104,11 @@ func (p *SmsTencentcloud) SendWxBindSms(phoneNumber string, aliveSecond int64) (
 	return
 }
 
+// 发送租户邀请
+func (p *SmsTencentcloud) SendInviteSms(phoneNumber string, vals []string) error {
+	return p.sendSms([]string{phoneNumber}, "", p.InviteTemplateId, vals)
+}
+
 func (p *SmsTencentcloud) sendSms(phoneNumber []string, userSession string, TemplateId string, TemplateVals []string) (err error) {
 	/* 必要步骤：
 	 * 实例化一个认证对象，入参需要传入腾讯云账户密钥对secretId，secretKey。